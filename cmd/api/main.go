@@ -5,6 +5,7 @@ import (
 	"catalog-be/internal/server"
 	"catalog-be/internal/utils"
 	"catalog-be/internal/validation"
+	"context"
 	"fmt"
 	"os"
 	"strconv"
@@ -39,9 +40,13 @@ func main() {
 	internal.InitializeServer(
 		server.Pg,
 		server.Validator,
-		server.S3,
+		server.Storage,
 	).RegisterRoutes(server.App)
 
+	if os.Getenv("SCHEDULER_DISABLED") != "true" {
+		go internal.InitializeScheduler(server.Pg, server.Storage).Start(context.Background())
+	}
+
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
 	err := server.App.Listen(fmt.Sprintf(":%d", port))
 	if err != nil {
@@ -19,6 +19,7 @@ type Circle struct {
 	ID              int            `json:"id"`
 	Name            string         `json:"name"`
 	Slug            string         `json:"slug"`
+	SlugCustom      bool           `json:"slug_custom"` // picked by the owner, kept on rename
 	URL             *string        `json:"url"`
 	PictureURL      *string        `json:"picture_url"`
 	CoverPictureURL *string        `json:"cover_picture_url"`
@@ -29,51 +30,86 @@ type Circle struct {
 	Rating          *string        `json:"rating"` // enum GA, PG, M
 	Verified        bool           `json:"verified"`
 	Published       bool           `json:"published"`
+	Hidden          bool           `json:"hidden"` // set by moderators, left out of listings
 	CreatedAt       *time.Time     `json:"created_at"`
 	UpdatedAt       *time.Time     `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `json:"deleted_at"`
 
 	Day *Day `json:"day"`
 
+	// UnpublishedByModerator keeps the owner from publishing the circle again
+	// until a moderator republishes it.
+	UnpublishedByModerator bool `json:"unpublished_by_moderator"`
+	// HiddenAutomatically is set when the pending reports hid the circle, it
+	// is shown again once they weigh less than the threshold.
+	HiddenAutomatically bool `json:"hidden_automatically"`
+
 	EventID            *int `json:"event_id"`
 	UsedReferralCodeID *int `json:"-"`
+	// PlanID is set by admins, nil falls back to the plan matching the circle rule.
+	PlanID    *int `json:"-"`
+	DeletedBy *int `json:"-"`
 }
 
+// CircleJoinedTables is one circle row with its relations aggregated by
+// postgres into json columns, so a circle is never repeated per fandom or
+// work type.
 type CircleJoinedTables struct {
 	Circle
 
-	FandomID        int            `json:"fandom_id"`
-	FandomName      string         `json:"fandom_name"`
-	FandomVisible   bool           `json:"fandom_visible"`
-	FandomCreatedAt *time.Time     `json:"fandom_created_at"`
-	FandomUpdatedAt *time.Time     `json:"fandom_updated_at"`
-	FandomDeletedAt gorm.DeletedAt `json:"-"`
+	Fandom     []Fandom    `json:"fandom" gorm:"serializer:json"`
+	WorkType   []WorkType  `json:"work_type" gorm:"serializer:json"`
+	Event      *Event      `json:"event" gorm:"serializer:json"`
+	BlockEvent *BlockEvent `json:"block_event" gorm:"serializer:json"`
 
-	WorkTypeID        int            `json:"work_type_id"`
-	WorkTypeName      string         `json:"work_type_name"`
-	WorkTypeCreatedAt *time.Time     `json:"work_type_created_at"`
-	WorkTypeUpdatedAt *time.Time     `json:"work_type_updated_at"`
-	WorkTypeDeletedAt gorm.DeletedAt `json:"-"`
+	PicturePlaceholder      *ImagePlaceholder `json:"picture_placeholder" gorm:"serializer:json"`
+	CoverPicturePlaceholder *ImagePlaceholder `json:"cover_picture_placeholder" gorm:"serializer:json"`
 
 	Bookmarked   bool       `json:"bookmarked"`
 	BookmarkedAt *time.Time `json:"bookmarked_at"`
 
-	EventName        string     `json:"event_name"`
-	EventSlug        string     `json:"event_slug"`
-	EventDescription string     `json:"event_description"`
-	EventStartedAt   *time.Time `json:"event_started_at"`
-	EventEndedAt     *time.Time `json:"event_ended_at"`
+	// only filled when listing bookmarked circles
+	BookmarkCollectionID *int    `json:"bookmark_collection_id"`
+	BookmarkNote         *string `json:"bookmark_note"`
+	BookmarkPriority     int     `json:"bookmark_priority"`
+	BookmarkPosition     int     `json:"bookmark_position"`
+	BookmarkStatus       *string `json:"bookmark_status"`
 
-	BlockEventID      int    `json:"block_event_id"`
-	BlockEventPrefix  string `json:"block_event_prefix"`
-	BlockEventPostfix string `json:"block_event_postfix"`
-	BlockEventName    string `json:"block_event_name"`
+	// only filled when exporting bookmarked circles
+	ProductNames []string `json:"product_names" gorm:"serializer:json"`
 }
 
 func (Circle) TableName() string {
 	return "circle"
 }
 
+type CircleSlugHistory struct {
+	ID        int        `json:"id"`
+	CircleID  int        `json:"circle_id"`
+	Slug      string     `json:"slug"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+func (CircleSlugHistory) TableName() string {
+	return "circle_slug_history"
+}
+
+// CircleDraft holds circle edits that are not live yet. Payload has the same
+// fields as the update circle payload.
+type CircleDraft struct {
+	CircleID     int                    `json:"circle_id" gorm:"primaryKey"`
+	UserID       *int                   `json:"user_id"`
+	Payload      map[string]interface{} `json:"payload" gorm:"serializer:json"`
+	PreviewToken string                 `json:"preview_token"`
+	PublishAt    *time.Time             `json:"publish_at"`
+	CreatedAt    *time.Time             `json:"created_at"`
+	UpdatedAt    *time.Time             `json:"updated_at"`
+}
+
+func (CircleDraft) TableName() string {
+	return "circle_draft"
+}
+
 type CircleFandom struct {
 	CircleID  int        `json:"circle_id"`
 	FandomID  int        `json:"fandom_id"`
@@ -95,58 +131,83 @@ func (CircleWorkType) TableName() string {
 }
 
 type UserBookmark struct {
-	UserID    int        `json:"user_id"`
-	CircleID  int        `json:"circle_id"`
-	CreatedAt *time.Time `json:"created_at"`
+	UserID       int        `json:"user_id"`
+	CircleID     int        `json:"circle_id"`
+	CollectionID *int       `json:"collection_id"`
+	Note         *string    `json:"note"`
+	Priority     int        `json:"priority"`
+	Position     int        `json:"position"`
+	CreatedAt    *time.Time `json:"created_at"`
 }
 
 func (UserBookmark) TableName() string {
 	return "user_bookmark"
 }
 
-type UserUpvote struct {
+// BookmarkCollection is a named list a user groups bookmarks in.
+type BookmarkCollection struct {
+	ID        int        `json:"id"`
 	UserID    int        `json:"user_id"`
-	CircleID  int        `json:"circle_id"`
+	Name      string     `json:"name"`
+	Position  int        `json:"position"`
 	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+
+	// ShareToken is set while the collection is shared through a public link.
+	ShareToken *string    `json:"share_token"`
+	SharedAt   *time.Time `json:"shared_at"`
+
+	BookmarkCount int `json:"bookmark_count" gorm:"->;-:migration"`
 }
 
-func (UserUpvote) TableName() string {
-	return "user_upvote"
+func (BookmarkCollection) TableName() string {
+	return "bookmark_collection"
 }
 
-type CircleRawNew struct {
-	Circle
+type BookmarkStatus string
 
-	FandomID        int            `json:"fandom.id" gorm:"column:fandom.id"`
-	FandomName      string         `json:"fandom.name" gorm:"column:fandom.name"`
-	FandomVisible   bool           `json:"fandom.visible" gorm:"column:fandom.visible"`
-	FandomCreatedAt *time.Time     `json:"fandom.created_at"  gorm:"column:fandom.created_at"`
-	FandomUpdatedAt *time.Time     `json:"fandom.updated_at" gorm:"column:fandom.updated_at"`
-	FandomDeletedAt gorm.DeletedAt `json:"-"`
+const (
+	BookmarkPending BookmarkStatus = "pending"
+	BookmarkVisited BookmarkStatus = "visited"
+	BookmarkSoldOut BookmarkStatus = "sold_out"
+	BookmarkSkipped BookmarkStatus = "skipped"
+)
 
-	WorkTypeID        int            `json:"work_type.id" gorm:"column:work_type.id"`
-	WorkTypeName      string         `json:"work_type.name" gorm:"column:work_type.name"`
-	WorkTypeCreatedAt *time.Time     `json:"work_type.created_at" gorm:"column:work_type.created_at"`
-	WorkTypeUpdatedAt *time.Time     `json:"work_type.updated_at" gorm:"column:work_type.updated_at"`
-	WorkTypeDeletedAt gorm.DeletedAt `json:"-"`
+// UserBookmarkStatus is the check-off state of a bookmarked circle at one event.
+// UpdatedAt is when the user made the change on their device, SyncedAt is when
+// the server stored it. Delta sync compares the transaction that stored it
+// instead, see CircleBookmarkRepo.GetBookmarkStatusesSince.
+type UserBookmarkStatus struct {
+	UserID    int            `json:"-"`
+	CircleID  int            `json:"circle_id"`
+	EventID   int            `json:"event_id"`
+	Status    BookmarkStatus `json:"status"`
+	UpdatedAt time.Time      `json:"updated_at" gorm:"autoUpdateTime:false"`
+	SyncedAt  time.Time      `json:"synced_at"`
+}
 
-	Bookmarked   bool       `json:"bookmarked"`
-	BookmarkedAt *time.Time `json:"bookmarked_at"`
+func (UserBookmarkStatus) TableName() string {
+	return "user_bookmark_status"
+}
 
-	ProductID        int        `json:"product.id" gorm:"column:product.id"`
-	ProductName      string     `json:"product.name" gorm:"column:product.name"`
-	ProductImageURL  string     `json:"product.image_url" gorm:"column:product.image_url"`
-	ProductCreatedAt *time.Time `json:"product.created_at" gorm:"column:product.created_at"`
-	ProductUpdatedAt *time.Time `json:"product.updated_at" gorm:"column:product.updated_at"`
-
-	EventName        string     `json:"event.name" gorm:"column:event.name"`
-	EventSlug        string     `json:"event.slug" gorm:"column:event.slug"`
-	EventDescription string     `json:"event.description" gorm:"column:event.description"`
-	EventStartedAt   *time.Time `json:"event.started_at" gorm:"column:event.started_at"`
-	EventEndedAt     *time.Time `json:"event.ended_at" gorm:"column:event.ended_at"`
-
-	BlockEventID      int    `json:"block_event.id" gorm:"column:block_event.id"`
-	BlockEventPrefix  string `json:"block_event.prefix" gorm:"column:block_event.prefix"`
-	BlockEventPostfix string `json:"block_event.postfix" gorm:"column:block_event.postfix"`
-	BlockEventName    string `json:"block_event.name" gorm:"column:block_event.name"`
+// UserBookmarkDeletion marks a bookmark removed with its statuses, so delta
+// sync can tell the other devices of the user.
+type UserBookmarkDeletion struct {
+	UserID    int       `json:"-"`
+	CircleID  int       `json:"circle_id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func (UserBookmarkDeletion) TableName() string {
+	return "user_bookmark_deletion"
+}
+
+type UserUpvote struct {
+	UserID    int        `json:"user_id"`
+	CircleID  int        `json:"circle_id"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+func (UserUpvote) TableName() string {
+	return "user_upvote"
 }
@@ -0,0 +1,30 @@
+package entity
+
+import "time"
+
+// PlanRule gives a plan to circles an admin did not assign one to.
+type PlanRule string
+
+const (
+	PlanRuleDefault  PlanRule = "default"
+	PlanRuleVerified PlanRule = "verified"
+	PlanRuleReferred PlanRule = "referred"
+)
+
+// Plan holds how much a circle can list and store.
+type Plan struct {
+	ID              int        `json:"id"`
+	Slug            string     `json:"slug"`
+	Name            string     `json:"name"`
+	MaxProducts     int        `json:"max_products"`
+	MaxFandoms      int        `json:"max_fandoms"`
+	MaxWorkTypes    int        `json:"max_work_types"`
+	MaxStorageBytes int        `json:"max_storage_bytes"`
+	Rule            *PlanRule  `json:"rule"`
+	CreatedAt       *time.Time `json:"created_at"`
+	UpdatedAt       *time.Time `json:"updated_at"`
+}
+
+func (Plan) TableName() string {
+	return "plan"
+}
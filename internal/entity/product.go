@@ -6,11 +6,38 @@ import (
 	"gorm.io/gorm"
 )
 
+type ProductStatus string
+
+const (
+	ProductAvailable ProductStatus = "available"
+	ProductPreorder  ProductStatus = "preorder"
+	ProductSoldOut   ProductStatus = "sold_out"
+)
+
 type Product struct {
-	ID        int            `json:"id"`
-	Name      string         `json:"name"`
-	ImageURL  string         `json:"image_url"`
-	CircleID  int            `json:"circle_id"`
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	ImageURL string `json:"image_url"`
+	CircleID int    `json:"circle_id"`
+	// ImagePlaceholder is only read, when the product is loaded with its children.
+	ImagePlaceholder *ImagePlaceholder `json:"image_placeholder" gorm:"->;serializer:json"`
+
+	// Price is in the smallest unit of Currency, nil when the circle did not set one.
+	Price    *int64 `json:"price"`
+	Currency string `json:"currency" gorm:"default:IDR"` // ISO 4217
+	// Stock is nil when the circle does not track it.
+	Stock       *int          `json:"stock"`
+	Status      ProductStatus `json:"status" gorm:"default:available"`
+	Description *string       `json:"description"`
+	// Hidden is set by moderators, left out of the product search.
+	Hidden bool `json:"hidden"`
+	// HiddenAutomatically is set when the pending reports hid the product.
+	HiddenAutomatically bool `json:"hidden_automatically"`
+
+	// Images is the ordered gallery, ImageURL is always its first picture.
+	Images   []ProductImage   `json:"images" gorm:"foreignKey:ProductID"`
+	Variants []ProductVariant `json:"variants" gorm:"foreignKey:ProductID"`
+
 	CreatedAt *time.Time     `json:"created_at"`
 	UpdatedAt *time.Time     `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-"`
@@ -19,3 +46,83 @@ type Product struct {
 func (Product) TableName() string {
 	return "product"
 }
+
+// ProductJoinedTables is a product found across circles with the circle it
+// belongs to, its attended event and block.
+type ProductJoinedTables struct {
+	Product
+
+	CircleName       string      `json:"circle_name"`
+	CircleSlug       string      `json:"circle_slug"`
+	CirclePictureURL *string     `json:"circle_picture_url"`
+	CircleDay        *Day        `json:"circle_day"`
+	Event            *Event      `json:"event" gorm:"serializer:json"`
+	BlockEvent       *BlockEvent `json:"block_event" gorm:"serializer:json"`
+}
+
+type ProductImage struct {
+	ID          int               `json:"id"`
+	ProductID   int               `json:"product_id"`
+	URL         string            `json:"url"`
+	Placeholder *ImagePlaceholder `json:"placeholder" gorm:"->;serializer:json"`
+	Position    int               `json:"position"`
+	CreatedAt   *time.Time        `json:"created_at"`
+}
+
+func (ProductImage) TableName() string {
+	return "product_image"
+}
+
+// ProductVariant is a size or design of a product, a nil Price falls back to
+// the price of the product.
+type ProductVariant struct {
+	ID        int            `json:"id"`
+	ProductID int            `json:"product_id"`
+	Name      string         `json:"name"`
+	Price     *int64         `json:"price"`
+	Stock     *int           `json:"stock"`
+	Position  int            `json:"position"`
+	CreatedAt *time.Time     `json:"created_at"`
+	UpdatedAt *time.Time     `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-"`
+}
+
+func (ProductVariant) TableName() string {
+	return "product_variant"
+}
+
+type ReservationStatus string
+
+const (
+	ReservationPending   ReservationStatus = "pending"
+	ReservationAccepted  ReservationStatus = "accepted"
+	ReservationDeclined  ReservationStatus = "declined"
+	ReservationFulfilled ReservationStatus = "fulfilled"
+	ReservationCancelled ReservationStatus = "cancelled"
+)
+
+// ProductReservation is a request of a visitor to put aside Quantity of a
+// product, or of one of its variants, until it is picked up.
+type ProductReservation struct {
+	ID           int               `json:"id"`
+	ProductID    int               `json:"product_id"`
+	VariantID    *int              `json:"variant_id"`
+	CircleID     int               `json:"circle_id"`
+	UserID       int               `json:"user_id"`
+	Quantity     int               `json:"quantity"`
+	Note         *string           `json:"note"`
+	Status       ReservationStatus `json:"status" gorm:"default:pending"`
+	ResponseNote *string           `json:"response_note"`
+	RespondedAt  *time.Time        `json:"responded_at"`
+	CreatedAt    *time.Time        `json:"created_at"`
+	UpdatedAt    *time.Time        `json:"updated_at"`
+
+	Product *Product        `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Variant *ProductVariant `json:"variant,omitempty" gorm:"foreignKey:VariantID"`
+	Circle  *Circle         `json:"circle,omitempty" gorm:"foreignKey:CircleID"`
+	User    *User           `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+func (ProductReservation) TableName() string {
+	return "product_reservation"
+}
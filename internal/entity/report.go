@@ -2,14 +2,103 @@ package entity
 
 import "time"
 
+type ReportStatus string
+
+const (
+	ReportOpen      ReportStatus = "open"
+	ReportAssigned  ReportStatus = "assigned"
+	ReportResolved  ReportStatus = "resolved"
+	ReportDismissed ReportStatus = "dismissed"
+)
+
+// ReportAction is what a moderator did to the reported circle.
+type ReportAction string
+
+const (
+	ReportActionUnpublish ReportAction = "unpublish"
+	ReportActionRepublish ReportAction = "republish"
+	ReportActionHide      ReportAction = "hide"
+	ReportActionUnhide    ReportAction = "unhide"
+	ReportActionWarn      ReportAction = "warn"
+)
+
+// ReportTarget is what a report is about.
+type ReportTarget string
+
+const (
+	ReportTargetCircle  ReportTarget = "circle"
+	ReportTargetProduct ReportTarget = "product"
+	ReportTargetUser    ReportTarget = "user"
+)
+
 type Report struct {
+	ID         int          `json:"id"`
+	UserID     int          `json:"user_id"`
+	TargetType ReportTarget `json:"target_type"`
+	TargetID   int          `json:"target_id"`
+	// CircleID is the circle the target belongs to, nil for a user without
+	// a circle.
+	CircleID   *int          `json:"circle_id"`
+	Category   *string       `json:"category"`
+	Reason     string        `json:"reason"`
+	Status     ReportStatus  `json:"status" gorm:"default:open"`
+	AssignedTo *int          `json:"assigned_to"`
+	Note       *string       `json:"note"`
+	Action     *ReportAction `json:"action"`
+	ReviewedBy *int          `json:"reviewed_by"`
+	ReviewedAt *time.Time    `json:"reviewed_at"`
+	CreatedAt  *time.Time    `json:"created_at"`
+	UpdatedAt  *time.Time    `json:"updated_at"`
+}
+
+func (Report) TableName() string {
+	return "report"
+}
+
+// ReportCategory is a reason something can be reported for. The weights of
+// the pending reports on a target add up to decide when it gets hidden.
+type ReportCategory struct {
+	Slug      string     `json:"slug" gorm:"primaryKey"`
+	Name      string     `json:"name"`
+	Weight    int        `json:"weight"`
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+func (ReportCategory) TableName() string {
+	return "report_category"
+}
+
+// CircleWarning is a warning a moderator sent to a circle, shown to its
+// members.
+type CircleWarning struct {
 	ID        int        `json:"id"`
-	UserID    int        `json:"user_id"`
 	CircleID  int        `json:"circle_id"`
-	Reason    string     `json:"reason"`
+	ReportID  *int       `json:"report_id"`
+	Message   string     `json:"message"`
+	CreatedBy *int       `json:"created_by"`
 	CreatedAt *time.Time `json:"created_at"`
 }
 
-func (Report) TableName() string {
-	return "report"
+func (CircleWarning) TableName() string {
+	return "circle_warning"
+}
+
+// ReportedTarget is a circle, product or user in the moderation queue with
+// the reports made on it, PendingCount counts the open and assigned ones and
+// PendingWeight adds up their category weights. Slug and Published are only
+// set for circles.
+type ReportedTarget struct {
+	TargetType     ReportTarget `json:"target_type"`
+	TargetID       int          `json:"target_id"`
+	CircleID       *int         `json:"circle_id"`
+	Name           string       `json:"name"`
+	Slug           *string      `json:"slug"`
+	PictureURL     *string      `json:"picture_url"`
+	Published      *bool        `json:"published"`
+	Hidden         bool         `json:"hidden"`
+	ReportCount    int          `json:"report_count"`
+	PendingCount   int          `json:"pending_count"`
+	PendingWeight  int          `json:"pending_weight"`
+	LastReportedAt *time.Time   `json:"last_reported_at"`
 }
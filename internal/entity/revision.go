@@ -0,0 +1,47 @@
+package entity
+
+import "time"
+
+// RevisionEntityType is the kind of data a circle revision describes.
+type RevisionEntityType string
+
+const (
+	RevisionCircle     RevisionEntityType = "circle"
+	RevisionProduct    RevisionEntityType = "product"
+	RevisionEventBlock RevisionEntityType = "event_block"
+)
+
+type RevisionAction string
+
+const (
+	RevisionCreate  RevisionAction = "create"
+	RevisionUpdate  RevisionAction = "update"
+	RevisionDelete  RevisionAction = "delete"
+	RevisionRestore RevisionAction = "restore"
+)
+
+type RevisionChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// CircleRevision is a snapshot of a circle, one of its products or its
+// attended event and block, taken before and after a change.
+type CircleRevision struct {
+	ID             int                    `json:"id"`
+	CircleID       int                    `json:"circle_id"`
+	UserID         *int                   `json:"user_id"`
+	EntityType     RevisionEntityType     `json:"entity_type"`
+	EntityID       int                    `json:"entity_id"`
+	Action         RevisionAction         `json:"action"`
+	Before         map[string]interface{} `json:"before" gorm:"serializer:json"`
+	After          map[string]interface{} `json:"after" gorm:"serializer:json"`
+	Diff           []RevisionChange       `json:"diff" gorm:"serializer:json"`
+	RestoredFromID *int                   `json:"restored_from_id"`
+	CreatedAt      *time.Time             `json:"created_at"`
+}
+
+func (CircleRevision) TableName() string {
+	return "circle_revision"
+}
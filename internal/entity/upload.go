@@ -0,0 +1,136 @@
+package entity
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type UploadStatus string
+
+const (
+	UploadPending   UploadStatus = "pending"
+	UploadConfirmed UploadStatus = "confirmed"
+)
+
+// Upload is an image stored for a circle, sent through the server or put
+// straight into storage with a presigned URL. Ticket, ObjectKey and ExpiresAt
+// are only set for presigned uploads, ObjectKey is where the client puts the
+// original. Path and Variants are set once the variants are stored.
+type Upload struct {
+	ID          int               `json:"id"`
+	Ticket      *string           `json:"ticket"`
+	CircleID    *int              `json:"circle_id"`
+	UserID      *int              `json:"user_id"`
+	Folder      string            `json:"folder"`
+	ObjectKey   *string           `json:"object_key"`
+	Path        *string           `json:"path"`
+	Variants    map[string]string `json:"variants" gorm:"serializer:json"`
+	Size        int               `json:"size"`
+	Hash        *ImageHash        `json:"hash"`
+	Placeholder *ImagePlaceholder `json:"placeholder" gorm:"serializer:json"`
+	Status      UploadStatus      `json:"status"`
+	ExpiresAt   *time.Time        `json:"expires_at"`
+	ConfirmedAt *time.Time        `json:"confirmed_at"`
+	CreatedAt   *time.Time        `json:"created_at"`
+	UpdatedAt   *time.Time        `json:"updated_at"`
+
+	References []UploadReference `json:"references,omitempty" gorm:"foreignKey:UploadID"`
+}
+
+func (Upload) TableName() string {
+	return "upload"
+}
+
+// UploadReferenceType is the kind of data an upload is used by.
+type UploadReferenceType string
+
+const (
+	UploadReferenceCircle         UploadReferenceType = "circle"
+	UploadReferenceCircleDraft    UploadReferenceType = "circle_draft"
+	UploadReferenceProduct        UploadReferenceType = "product"
+	UploadReferenceUser           UploadReferenceType = "user"
+	UploadReferenceCircleRevision UploadReferenceType = "circle_revision"
+)
+
+// UploadReference links an upload to what uses it, saved with the entity.
+// Uploads without any are deleted by the sweeper.
+type UploadReference struct {
+	UploadID   int                 `json:"upload_id" gorm:"primaryKey"`
+	EntityType UploadReferenceType `json:"entity_type" gorm:"primaryKey"`
+	EntityID   int                 `json:"entity_id" gorm:"primaryKey"`
+}
+
+func (UploadReference) TableName() string {
+	return "upload_reference"
+}
+
+// ImagePlaceholder is shown by clients while an image loads, Width and Height
+// are of its full variant and DominantColor is #rrggbb.
+type ImagePlaceholder struct {
+	BlurHash      string `json:"blurhash"`
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+	DominantColor string `json:"dominant_color"`
+}
+
+// ImageHash is the perceptual hash of an upload, shown as 16 hexadecimal
+// digits. It is stored as a signed bigint.
+type ImageHash int64
+
+func (h ImageHash) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%016x", uint64(h))), nil
+}
+
+func (h *ImageHash) UnmarshalText(text []byte) error {
+	hash, err := strconv.ParseUint(string(text), 16, 64)
+	if err != nil {
+		return err
+	}
+
+	*h = ImageHash(hash)
+	return nil
+}
+
+type UploadFlagStatus string
+
+const (
+	UploadFlagPending   UploadFlagStatus = "pending"
+	UploadFlagDismissed UploadFlagStatus = "dismissed"
+	UploadFlagBanned    UploadFlagStatus = "banned"
+)
+
+// UploadFlag asks a moderator to review an upload that looks like an upload
+// of another circle, Distance is how many bits their hashes differ by.
+type UploadFlag struct {
+	ID              int              `json:"id"`
+	UploadID        int              `json:"upload_id"`
+	MatchedUploadID *int             `json:"matched_upload_id"`
+	Distance        int              `json:"distance"`
+	Status          UploadFlagStatus `json:"status"`
+	Note            *string          `json:"note"`
+	ReviewedBy      *int             `json:"reviewed_by"`
+	ReviewedAt      *time.Time       `json:"reviewed_at"`
+	CreatedAt       *time.Time       `json:"created_at"`
+	UpdatedAt       *time.Time       `json:"updated_at"`
+
+	Upload        *Upload `json:"upload,omitempty" gorm:"foreignKey:UploadID"`
+	MatchedUpload *Upload `json:"matched_upload,omitempty" gorm:"foreignKey:MatchedUploadID"`
+}
+
+func (UploadFlag) TableName() string {
+	return "upload_flag"
+}
+
+// BannedImageHash blocks uploads whose hash is close to Hash.
+type BannedImageHash struct {
+	ID        int        `json:"id"`
+	Hash      ImageHash  `json:"hash"`
+	Reason    *string    `json:"reason"`
+	CreatedBy *int       `json:"created_by"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+func (BannedImageHash) TableName() string {
+	return "banned_image_hash"
+}
@@ -16,9 +16,15 @@ type AuthMiddleware struct {
 	userService *user.UserService
 }
 
+// IsAdmin tells whether userID is the admin, the first user. Repos use it to
+// show the admin what moderators hid from everyone else.
+func IsAdmin(userID int) bool {
+	return userID == 1
+}
+
 func (a *AuthMiddleware) AdminOnly(c *fiber.Ctx) error {
 	user := c.Locals("user").(*auth_dto.ATClaims)
-	if user.UserID != 1 {
+	if !IsAdmin(user.UserID) {
 		return c.Status(fiber.StatusUnauthorized).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusUnauthorized, errors.New("UNAUTHORIZED"), nil)))
 	}
 
@@ -0,0 +1,48 @@
+package bookmark_dto
+
+import "time"
+
+type CreateUpdateCollectionPayload struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+}
+
+type SaveBookmarkPayload struct {
+	// CollectionID moves the bookmark to a collection, 0 moves it out of any collection.
+	CollectionID *int    `json:"collection_id" validate:"omitempty,min=0"`
+	Note         *string `json:"note" validate:"omitempty,max=1000"`
+	Priority     *int    `json:"priority" validate:"omitempty,min=0,max=3"`
+}
+
+type ReorderBookmarksPayload struct {
+	// CollectionID is the collection being reordered, 0 or empty for bookmarks outside any collection.
+	CollectionID *int  `json:"collection_id" validate:"omitempty,min=0"`
+	CircleIDs    []int `json:"circle_ids" validate:"required,min=1,dive,min=1"`
+}
+
+type ReorderCollectionsPayload struct {
+	CollectionIDs []int `json:"collection_ids" validate:"required,min=1,dive,min=1"`
+}
+
+type CloneCollectionPayload struct {
+	// Name of the new collection, the shared list name is used when it is empty.
+	Name string `json:"name" validate:"omitempty,max=100"`
+}
+
+type SaveBookmarkStatusPayload struct {
+	CircleID int    `json:"circle_id" validate:"required,min=1"`
+	EventID  int    `json:"event_id" validate:"required,min=1"`
+	Status   string `json:"status" validate:"required,oneof=pending visited sold_out skipped"`
+	// UpdatedAt is when the change was made on the device, it decides which change
+	// wins when several devices were offline. Defaults to now.
+	UpdatedAt *time.Time `json:"updated_at" validate:"omitempty"`
+}
+
+type SaveBookmarkStatusesPayload struct {
+	Statuses []SaveBookmarkStatusPayload `json:"statuses" validate:"required,min=1,max=200,dive"`
+}
+
+type GetBookmarkStatusesFilter struct {
+	// Since is the cursor returned by the previous sync.
+	Since   string `query:"since" validate:"omitempty"`
+	EventID int    `query:"event_id" validate:"omitempty,min=1"`
+}
@@ -3,6 +3,8 @@ package bookmark
 import (
 	"catalog-be/internal/domain"
 	"catalog-be/internal/entity"
+	"database/sql"
+	"errors"
 
 	"gorm.io/gorm"
 )
@@ -11,11 +13,70 @@ type CircleBookmarkRepo struct {
 	db *gorm.DB
 }
 
-// DeleteBookmarkByUserCircleID implements CircleBookmarkRepo.
+// whereCollection scopes user_bookmark rows to one collection, nil or 0 means
+// bookmarks outside any collection.
+func whereCollection(db *gorm.DB, collectionID *int) *gorm.DB {
+	if collectionID == nil || *collectionID == 0 {
+		return db.Where("collection_id IS NULL")
+	}
+
+	return db.Where("collection_id = ?", *collectionID)
+}
+
+// nextBookmarkPosition implements CircleBookmarkRepo.
+func (c *CircleBookmarkRepo) nextBookmarkPosition(db *gorm.DB, userID int, collectionID *int) (int, error) {
+	var position int
+	err := whereCollection(db.Table("user_bookmark").Where("user_id = ?", userID), collectionID).
+		Select("COALESCE(MAX(position), 0) + 1").
+		Scan(&position).Error
+
+	return position, err
+}
+
+// SaveBookmarkDeletions marks the bookmarks of circleID as removed, only the
+// one of userID when it is not 0. It runs in tx before they are deleted so the
+// other devices of their users drop them and their statuses on the next sync.
+func SaveBookmarkDeletions(tx *gorm.DB, circleID int, userID int) *domain.Error {
+	bookmarks := tx.Table("user_bookmark").Select("user_id, circle_id").Where("circle_id = ?", circleID)
+	if userID != 0 {
+		bookmarks = bookmarks.Where("user_id = ?", userID)
+	}
+
+	err := tx.Exec(`
+		INSERT INTO user_bookmark_deletion (user_id, circle_id)
+		?
+		ON CONFLICT (user_id, circle_id) DO UPDATE SET
+			deleted_at = current_timestamp,
+			sync_xid = pg_current_xact_id()
+	`, bookmarks).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// DeleteBookmarkByUserCircleID removes a bookmark and its statuses, leaving a
+// deletion for the delta sync of the other devices.
 func (c *CircleBookmarkRepo) DeleteBookmarkByUserCircleID(circleID int, userID int) *domain.Error {
-	err := c.db.Table("user_bookmark").Where("circle_id = ? AND user_id = ?", circleID, userID).Delete(&entity.UserBookmark{}).Error
+	tx := c.db.Begin()
+	if tx.Error != nil {
+		return domain.NewError(500, tx.Error, nil)
+	}
 
+	deletionErr := SaveBookmarkDeletions(tx, circleID, userID)
+	if deletionErr != nil {
+		tx.Rollback()
+		return deletionErr
+	}
+
+	err := tx.Table("user_bookmark").Where("circle_id = ? AND user_id = ?", circleID, userID).Delete(&entity.UserBookmark{}).Error
 	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	if err := tx.Commit().Error; err != nil {
 		return domain.NewError(500, err, nil)
 	}
 
@@ -23,19 +84,436 @@ func (c *CircleBookmarkRepo) DeleteBookmarkByUserCircleID(circleID int, userID i
 }
 
 // CreateOneBookmark implements CircleBookmarkRepo.
-func (c *CircleBookmarkRepo) CreateOneBookmark(circleID int, userID int) *domain.Error {
-	err := c.db.Table("user_bookmark").Create(&entity.UserBookmark{
-		UserID:   userID,
-		CircleID: circleID,
+func (c *CircleBookmarkRepo) CreateOneBookmark(bookmark *entity.UserBookmark) *domain.Error {
+	position, err := c.nextBookmarkPosition(c.db, bookmark.UserID, bookmark.CollectionID)
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	bookmark.Position = position
+	err = c.db.Table("user_bookmark").Create(bookmark).Error
+
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// GetOneBookmarkByUserCircleID implements CircleBookmarkRepo.
+func (c *CircleBookmarkRepo) GetOneBookmarkByUserCircleID(circleID int, userID int) (*entity.UserBookmark, *domain.Error) {
+	var bookmark entity.UserBookmark
+	err := c.db.Where("circle_id = ? AND user_id = ?", circleID, userID).First(&bookmark).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &bookmark, nil
+}
+
+// UpdateOneBookmark saves the collection, note and priority of a bookmark. A
+// bookmark moved to another collection is put at the end of it.
+func (c *CircleBookmarkRepo) UpdateOneBookmark(bookmark *entity.UserBookmark, moved bool) *domain.Error {
+	if moved {
+		position, err := c.nextBookmarkPosition(c.db, bookmark.UserID, bookmark.CollectionID)
+		if err != nil {
+			return domain.NewError(500, err, nil)
+		}
+		bookmark.Position = position
+	}
+
+	err := c.db.
+		Model(&entity.UserBookmark{}).
+		Where("circle_id = ? AND user_id = ?", bookmark.CircleID, bookmark.UserID).
+		Updates(map[string]interface{}{
+			"collection_id": bookmark.CollectionID,
+			"note":          bookmark.Note,
+			"priority":      bookmark.Priority,
+			"position":      bookmark.Position,
+		}).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// ReorderBookmarks implements CircleBookmarkRepo.
+func (c *CircleBookmarkRepo) ReorderBookmarks(userID int, collectionID *int, circleIDs []int) *domain.Error {
+	tx := c.db.Begin()
+	if tx.Error != nil {
+		return domain.NewError(500, tx.Error, nil)
+	}
+
+	var count int64
+	err := whereCollection(tx.Model(&entity.UserBookmark{}).Where("user_id = ? AND circle_id IN ?", userID, circleIDs), collectionID).
+		Count(&count).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	if int(count) != len(circleIDs) {
+		tx.Rollback()
+		return domain.NewError(400, errors.New("BOOKMARK_NOT_IN_COLLECTION"), nil)
+	}
+
+	for index, circleID := range circleIDs {
+		err := tx.Model(&entity.UserBookmark{}).
+			Where("user_id = ? AND circle_id = ?", userID, circleID).
+			Update("position", index+1).Error
+		if err != nil {
+			tx.Rollback()
+			return domain.NewError(500, err, nil)
+		}
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+// GetAllCollectionsByUserID implements CircleBookmarkRepo.
+func (c *CircleBookmarkRepo) GetAllCollectionsByUserID(userID int) ([]entity.BookmarkCollection, *domain.Error) {
+	collections := []entity.BookmarkCollection{}
+	err := c.db.
+		Table("bookmark_collection bc").
+		Select(`bc.*, (
+			SELECT COUNT(*) FROM user_bookmark ub
+			JOIN circle c ON c.id = ub.circle_id AND c.deleted_at IS NULL
+			WHERE ub.collection_id = bc.id
+		) AS bookmark_count`).
+		Where("bc.user_id = ?", userID).
+		Order("bc.position ASC, bc.id ASC").
+		Find(&collections).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return collections, nil
+}
+
+// GetOneCollectionByID implements CircleBookmarkRepo.
+func (c *CircleBookmarkRepo) GetOneCollectionByID(id int) (*entity.BookmarkCollection, *domain.Error) {
+	var collection entity.BookmarkCollection
+	err := c.db.First(&collection, id).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &collection, nil
+}
+
+// CreateOneCollection implements CircleBookmarkRepo.
+func (c *CircleBookmarkRepo) CreateOneCollection(collection *entity.BookmarkCollection) (*entity.BookmarkCollection, *domain.Error) {
+	var position int
+	err := c.db.Model(&entity.BookmarkCollection{}).
+		Where("user_id = ?", collection.UserID).
+		Select("COALESCE(MAX(position), 0) + 1").
+		Scan(&position).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	collection.Position = position
+	err = c.db.Create(collection).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, domain.NewError(409, errors.New("COLLECTION_ALREADY_EXIST"), nil)
+		}
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return collection, nil
+}
+
+// UpdateOneCollection implements CircleBookmarkRepo.
+func (c *CircleBookmarkRepo) UpdateOneCollection(collection *entity.BookmarkCollection) (*entity.BookmarkCollection, *domain.Error) {
+	err := c.db.Model(collection).Update("name", collection.Name).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, domain.NewError(409, errors.New("COLLECTION_ALREADY_EXIST"), nil)
+		}
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return collection, nil
+}
+
+// DeleteOneCollection removes a collection, its bookmarks are kept outside any collection.
+func (c *CircleBookmarkRepo) DeleteOneCollection(collection *entity.BookmarkCollection) *domain.Error {
+	tx := c.db.Begin()
+	if tx.Error != nil {
+		return domain.NewError(500, tx.Error, nil)
+	}
+
+	position, err := c.nextBookmarkPosition(tx, collection.UserID, nil)
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	// append the bookmarks after the ones already outside any collection
+	err = tx.Model(&entity.UserBookmark{}).
+		Where("collection_id = ?", collection.ID).
+		Updates(map[string]interface{}{
+			"collection_id": nil,
+			"position":      gorm.Expr("position + ?", position),
+		}).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	err = tx.Delete(collection).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+// ReorderCollections implements CircleBookmarkRepo.
+func (c *CircleBookmarkRepo) ReorderCollections(userID int, collectionIDs []int) *domain.Error {
+	tx := c.db.Begin()
+	if tx.Error != nil {
+		return domain.NewError(500, tx.Error, nil)
+	}
+
+	var count int64
+	err := tx.Model(&entity.BookmarkCollection{}).Where("user_id = ? AND id IN ?", userID, collectionIDs).Count(&count).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	if int(count) != len(collectionIDs) {
+		tx.Rollback()
+		return domain.NewError(404, errors.New("COLLECTION_NOT_FOUND"), nil)
+	}
+
+	for index, id := range collectionIDs {
+		err := tx.Model(&entity.BookmarkCollection{}).Where("id = ?", id).Update("position", index+1).Error
+		if err != nil {
+			tx.Rollback()
+			return domain.NewError(500, err, nil)
+		}
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+// GetOneCollectionByShareToken implements CircleBookmarkRepo.
+func (c *CircleBookmarkRepo) GetOneCollectionByShareToken(token string) (*entity.BookmarkCollection, *domain.Error) {
+	var collection entity.BookmarkCollection
+	err := c.db.Where("share_token = ?", token).First(&collection).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &collection, nil
+}
+
+// UpdateCollectionShare saves the share token of a collection, a nil token revokes the link.
+func (c *CircleBookmarkRepo) UpdateCollectionShare(collection *entity.BookmarkCollection) *domain.Error {
+	err := c.db.Model(collection).Updates(map[string]interface{}{
+		"share_token": collection.ShareToken,
+		"shared_at":   collection.SharedAt,
 	}).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// CloneCollection creates target and copies the bookmarks of the source collection
+// into it. Circles the user already bookmarked stay where they are, the number of
+// copied bookmarks is returned.
+func (c *CircleBookmarkRepo) CloneCollection(sourceID int, target *entity.BookmarkCollection) (int, *domain.Error) {
+	tx := c.db.Begin()
+	if tx.Error != nil {
+		return 0, domain.NewError(500, tx.Error, nil)
+	}
 
+	var position int
+	err := tx.Model(&entity.BookmarkCollection{}).
+		Where("user_id = ?", target.UserID).
+		Select("COALESCE(MAX(position), 0) + 1").
+		Scan(&position).Error
 	if err != nil {
+		tx.Rollback()
+		return 0, domain.NewError(500, err, nil)
+	}
+
+	target.Position = position
+	err = tx.Create(target).Error
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return 0, domain.NewError(409, errors.New("COLLECTION_ALREADY_EXIST"), nil)
+		}
+		return 0, domain.NewError(500, err, nil)
+	}
+
+	result := tx.Exec(`
+		INSERT INTO user_bookmark (user_id, circle_id, collection_id, position, created_at)
+		SELECT ?, ub.circle_id, ?, ub.position, CURRENT_TIMESTAMP
+		FROM user_bookmark ub
+		JOIN circle c ON c.id = ub.circle_id AND c.deleted_at IS NULL
+		WHERE ub.collection_id = ?
+		ON CONFLICT (user_id, circle_id) DO NOTHING
+	`, target.UserID, target.ID, sourceID)
+	if result.Error != nil {
+		tx.Rollback()
+		return 0, domain.NewError(500, result.Error, nil)
+	}
+
+	tx.Commit()
+
+	return int(result.RowsAffected), nil
+}
+
+// GetBookmarkedCircleIDs returns which of circleIDs userID has bookmarked.
+func (c *CircleBookmarkRepo) GetBookmarkedCircleIDs(userID int, circleIDs []int) ([]int, *domain.Error) {
+	ids := []int{}
+	err := c.db.Model(&entity.UserBookmark{}).
+		Where("user_id = ? AND circle_id IN ?", userID, circleIDs).
+		Pluck("circle_id", &ids).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return ids, nil
+}
+
+// SaveBookmarkStatuses upserts the statuses of userID, a stored status is only
+// replaced by one changed later on the device.
+func (c *CircleBookmarkRepo) SaveBookmarkStatuses(userID int, statuses []entity.UserBookmarkStatus) *domain.Error {
+	circleIDs := make([]int, 0, len(statuses))
+	seen := make(map[int]bool)
+	for _, status := range statuses {
+		if !seen[status.CircleID] {
+			seen[status.CircleID] = true
+			circleIDs = append(circleIDs, status.CircleID)
+		}
+	}
+
+	tx := c.db.Begin()
+	if tx.Error != nil {
+		return domain.NewError(500, tx.Error, nil)
+	}
+
+	var count int64
+	err := tx.Model(&entity.UserBookmark{}).Where("user_id = ? AND circle_id IN ?", userID, circleIDs).Count(&count).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	if int(count) != len(circleIDs) {
+		tx.Rollback()
+		return domain.NewError(404, errors.New("BOOKMARK_NOT_FOUND"), nil)
+	}
+
+	for _, status := range statuses {
+		err := tx.Exec(`
+			INSERT INTO user_bookmark_status (user_id, circle_id, event_id, status, updated_at, synced_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (user_id, circle_id, event_id) DO UPDATE SET
+				status = excluded.status,
+				updated_at = excluded.updated_at,
+				synced_at = excluded.synced_at,
+				sync_xid = pg_current_xact_id()
+			WHERE user_bookmark_status.updated_at <= excluded.updated_at
+		`, userID, status.CircleID, status.EventID, status.Status, status.UpdatedAt, status.SyncedAt).Error
+		if err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrForeignKeyViolated) {
+				return domain.NewError(404, errors.New("EVENT_NOT_FOUND"), nil)
+			}
+			return domain.NewError(500, err, nil)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
 		return domain.NewError(500, err, nil)
 	}
 
 	return nil
 }
 
+// GetBookmarkStatusesByCircleIDs implements CircleBookmarkRepo.
+func (c *CircleBookmarkRepo) GetBookmarkStatusesByCircleIDs(userID int, circleIDs []int) ([]entity.UserBookmarkStatus, *domain.Error) {
+	statuses := []entity.UserBookmarkStatus{}
+	err := c.db.
+		Where("user_id = ? AND circle_id IN ?", userID, circleIDs).
+		Order("synced_at asc").
+		Find(&statuses).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return statuses, nil
+}
+
+// GetBookmarkStatusesSince returns the statuses of userID written by a
+// transaction at or after the since cursor, with the bookmarks removed since
+// then, every status and no deletion when since is nil. Deletions apply to
+// every event and are older than any status returned for the same circle, a
+// client drops them before applying the statuses. The cursor returned is the
+// oldest transaction still running when they were read, what it commits later
+// is read again by the next sync instead of being skipped. A status or a
+// deletion can be returned twice.
+func (c *CircleBookmarkRepo) GetBookmarkStatusesSince(userID int, since *int64, eventID int) ([]entity.UserBookmarkStatus, []entity.UserBookmarkDeletion, int64, *domain.Error) {
+	// every query sees the same snapshot
+	tx := c.db.Begin(&sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if tx.Error != nil {
+		return nil, nil, 0, domain.NewError(500, tx.Error, nil)
+	}
+	defer tx.Rollback()
+
+	var cursor int64
+	err := tx.Raw("SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint").Scan(&cursor).Error
+	if err != nil {
+		return nil, nil, 0, domain.NewError(500, err, nil)
+	}
+
+	query := tx.Where("user_id = ?", userID)
+
+	if since != nil {
+		query = query.Where("sync_xid >= CAST(CAST(? AS text) AS xid8)", *since)
+	}
+
+	if eventID != 0 {
+		query = query.Where("event_id = ?", eventID)
+	}
+
+	statuses := []entity.UserBookmarkStatus{}
+	err = query.Order("synced_at asc").Find(&statuses).Error
+	if err != nil {
+		return nil, nil, 0, domain.NewError(500, err, nil)
+	}
+
+	deletions := []entity.UserBookmarkDeletion{}
+	if since != nil {
+		err = tx.
+			Where("user_id = ? AND sync_xid >= CAST(CAST(? AS text) AS xid8)", userID, *since).
+			Order("deleted_at asc").
+			Find(&deletions).Error
+		if err != nil {
+			return nil, nil, 0, domain.NewError(500, err, nil)
+		}
+	}
+
+	return statuses, deletions, cursor, nil
+}
+
 func NewCircleBookmarkRepo(db *gorm.DB) *CircleBookmarkRepo {
 	return &CircleBookmarkRepo{db: db}
 }
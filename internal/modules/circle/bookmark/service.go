@@ -2,6 +2,13 @@ package bookmark
 
 import (
 	"catalog-be/internal/domain"
+	"catalog-be/internal/entity"
+	bookmark_dto "catalog-be/internal/modules/circle/bookmark/dto"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type CircleBookmarkService struct {
@@ -15,7 +22,272 @@ func (c *CircleBookmarkService) DeleteBookmarkByUserCircleID(circleID int, userI
 
 // CreateOneBookmark implements CircleBookmarkService.
 func (c *CircleBookmarkService) CreateOneBookmark(circleID int, userID int) *domain.Error {
-	return c.circleRepo.CreateOneBookmark(circleID, userID)
+	return c.circleRepo.CreateOneBookmark(&entity.UserBookmark{
+		CircleID: circleID,
+		UserID:   userID,
+	})
+}
+
+// getOwnCollection returns the collection only when it belongs to userID.
+func (c *CircleBookmarkService) getOwnCollection(userID int, collectionID int) (*entity.BookmarkCollection, *domain.Error) {
+	collection, err := c.circleRepo.GetOneCollectionByID(collectionID)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("COLLECTION_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	if collection.UserID != userID {
+		return nil, domain.NewError(404, errors.New("COLLECTION_NOT_FOUND"), nil)
+	}
+
+	return collection, nil
+}
+
+// UpdateBookmark implements CircleBookmarkService.
+func (c *CircleBookmarkService) UpdateBookmark(circleID int, userID int, body *bookmark_dto.SaveBookmarkPayload) (*entity.UserBookmark, *domain.Error) {
+	bookmark, err := c.circleRepo.GetOneBookmarkByUserCircleID(circleID, userID)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("BOOKMARK_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	moved := false
+	if body.CollectionID != nil {
+		var collectionID *int
+		if *body.CollectionID != 0 {
+			collection, err := c.getOwnCollection(userID, *body.CollectionID)
+			if err != nil {
+				return nil, err
+			}
+			collectionID = &collection.ID
+		}
+
+		moved = (bookmark.CollectionID == nil) != (collectionID == nil) ||
+			(collectionID != nil && *bookmark.CollectionID != *collectionID)
+		bookmark.CollectionID = collectionID
+	}
+
+	if body.Note != nil {
+		if *body.Note == "" {
+			bookmark.Note = nil
+		} else {
+			bookmark.Note = body.Note
+		}
+	}
+
+	if body.Priority != nil {
+		bookmark.Priority = *body.Priority
+	}
+
+	err = c.circleRepo.UpdateOneBookmark(bookmark, moved)
+	if err != nil {
+		return nil, err
+	}
+
+	return bookmark, nil
+}
+
+// ReorderBookmarks implements CircleBookmarkService.
+func (c *CircleBookmarkService) ReorderBookmarks(userID int, body *bookmark_dto.ReorderBookmarksPayload) *domain.Error {
+	if body.CollectionID != nil && *body.CollectionID != 0 {
+		_, err := c.getOwnCollection(userID, *body.CollectionID)
+		if err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[int]bool, len(body.CircleIDs))
+	for _, circleID := range body.CircleIDs {
+		if seen[circleID] {
+			return domain.NewError(400, errors.New("DUPLICATED_CIRCLE_ID"), nil)
+		}
+		seen[circleID] = true
+	}
+
+	return c.circleRepo.ReorderBookmarks(userID, body.CollectionID, body.CircleIDs)
+}
+
+// GetAllCollections implements CircleBookmarkService.
+func (c *CircleBookmarkService) GetAllCollections(userID int) ([]entity.BookmarkCollection, *domain.Error) {
+	return c.circleRepo.GetAllCollectionsByUserID(userID)
+}
+
+// CreateOneCollection implements CircleBookmarkService.
+func (c *CircleBookmarkService) CreateOneCollection(userID int, body *bookmark_dto.CreateUpdateCollectionPayload) (*entity.BookmarkCollection, *domain.Error) {
+	return c.circleRepo.CreateOneCollection(&entity.BookmarkCollection{
+		UserID: userID,
+		Name:   body.Name,
+	})
+}
+
+// UpdateOneCollection implements CircleBookmarkService.
+func (c *CircleBookmarkService) UpdateOneCollection(userID int, collectionID int, body *bookmark_dto.CreateUpdateCollectionPayload) (*entity.BookmarkCollection, *domain.Error) {
+	collection, err := c.getOwnCollection(userID, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	collection.Name = body.Name
+
+	return c.circleRepo.UpdateOneCollection(collection)
+}
+
+// DeleteOneCollection implements CircleBookmarkService.
+func (c *CircleBookmarkService) DeleteOneCollection(userID int, collectionID int) *domain.Error {
+	collection, err := c.getOwnCollection(userID, collectionID)
+	if err != nil {
+		return err
+	}
+
+	return c.circleRepo.DeleteOneCollection(collection)
+}
+
+// ReorderCollections implements CircleBookmarkService.
+func (c *CircleBookmarkService) ReorderCollections(userID int, body *bookmark_dto.ReorderCollectionsPayload) *domain.Error {
+	seen := make(map[int]bool, len(body.CollectionIDs))
+	for _, id := range body.CollectionIDs {
+		if seen[id] {
+			return domain.NewError(400, errors.New("DUPLICATED_COLLECTION_ID"), nil)
+		}
+		seen[id] = true
+	}
+
+	return c.circleRepo.ReorderCollections(userID, body.CollectionIDs)
+}
+
+// ShareCollection implements CircleBookmarkService.
+func (c *CircleBookmarkService) ShareCollection(userID int, collectionID int) (*entity.BookmarkCollection, *domain.Error) {
+	collection, err := c.getOwnCollection(userID, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if collection.ShareToken != nil {
+		return collection, nil
+	}
+
+	token := uuid.NewString()
+	now := time.Now()
+	collection.ShareToken = &token
+	collection.SharedAt = &now
+
+	err = c.circleRepo.UpdateCollectionShare(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// RevokeCollectionShare implements CircleBookmarkService.
+func (c *CircleBookmarkService) RevokeCollectionShare(userID int, collectionID int) *domain.Error {
+	collection, err := c.getOwnCollection(userID, collectionID)
+	if err != nil {
+		return err
+	}
+
+	collection.ShareToken = nil
+	collection.SharedAt = nil
+
+	return c.circleRepo.UpdateCollectionShare(collection)
+}
+
+// GetSharedCollection implements CircleBookmarkService.
+func (c *CircleBookmarkService) GetSharedCollection(token string) (*entity.BookmarkCollection, *domain.Error) {
+	collection, err := c.circleRepo.GetOneCollectionByShareToken(token)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("SHARED_LIST_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// CloneSharedCollection copies a shared list into a new collection of userID.
+func (c *CircleBookmarkService) CloneSharedCollection(userID int, token string, body *bookmark_dto.CloneCollectionPayload) (*entity.BookmarkCollection, *domain.Error) {
+	source, err := c.GetSharedCollection(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if source.UserID == userID {
+		return nil, domain.NewError(400, errors.New("CANNOT_CLONE_OWN_LIST"), nil)
+	}
+
+	name := source.Name
+	if body.Name != "" {
+		name = body.Name
+	}
+
+	target := &entity.BookmarkCollection{
+		UserID: userID,
+		Name:   name,
+	}
+
+	added, err := c.circleRepo.CloneCollection(source.ID, target)
+	if err != nil {
+		return nil, err
+	}
+
+	target.BookmarkCount = added
+
+	return target, nil
+}
+
+// GetBookmarkedCircleIDs implements CircleBookmarkService.
+func (c *CircleBookmarkService) GetBookmarkedCircleIDs(userID int, circleIDs []int) ([]int, *domain.Error) {
+	if len(circleIDs) == 0 {
+		return []int{}, nil
+	}
+
+	return c.circleRepo.GetBookmarkedCircleIDs(userID, circleIDs)
+}
+
+// SaveBookmarkStatuses stores check-off statuses sent by a device and returns
+// what is stored for those circles afterwards, so a device learns when a newer
+// change from another device won.
+func (c *CircleBookmarkService) SaveBookmarkStatuses(userID int, body *bookmark_dto.SaveBookmarkStatusesPayload) ([]entity.UserBookmarkStatus, *domain.Error) {
+	now := time.Now()
+
+	statuses := make([]entity.UserBookmarkStatus, 0, len(body.Statuses))
+	circleIDs := make([]int, 0, len(body.Statuses))
+	for _, payload := range body.Statuses {
+		updatedAt := now
+		// a device clock running ahead must not make its change win forever
+		if payload.UpdatedAt != nil && payload.UpdatedAt.Before(now) {
+			updatedAt = *payload.UpdatedAt
+		}
+
+		statuses = append(statuses, entity.UserBookmarkStatus{
+			UserID:    userID,
+			CircleID:  payload.CircleID,
+			EventID:   payload.EventID,
+			Status:    entity.BookmarkStatus(payload.Status),
+			UpdatedAt: updatedAt,
+			SyncedAt:  now,
+		})
+		circleIDs = append(circleIDs, payload.CircleID)
+	}
+
+	err := c.circleRepo.SaveBookmarkStatuses(userID, statuses)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.circleRepo.GetBookmarkStatusesByCircleIDs(userID, circleIDs)
+}
+
+// SyncBookmarkStatuses returns the statuses stored and the bookmarks removed
+// since the cursor of the previous sync together with the cursor a client
+// should send as since on its next sync.
+func (c *CircleBookmarkService) SyncBookmarkStatuses(userID int, since *int64, eventID int) ([]entity.UserBookmarkStatus, []entity.UserBookmarkDeletion, int64, *domain.Error) {
+	return c.circleRepo.GetBookmarkStatusesSince(userID, since, eventID)
 }
 
 func NewCircleBookmarkService(repo *CircleBookmarkRepo) *CircleBookmarkService {
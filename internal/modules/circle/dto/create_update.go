@@ -2,6 +2,7 @@ package circle_dto
 
 import (
 	"catalog-be/internal/entity"
+	"time"
 )
 
 type ImageURLs struct {
@@ -14,6 +15,7 @@ type ImageURLs struct {
 
 type OnboardNewCirclePayload struct {
 	Name   string `json:"name" validate:"required,min=3,max=255"`
+	Slug   string `json:"slug" validate:"omitempty,min=3,max=255"`
 	Rating string `json:"rating" validate:"required,oneof=GA PG M"`
 	ImageURLs
 	ReferralCode string `json:"referral_code" validate:"omitempty"`
@@ -31,6 +33,7 @@ type CreateWorkTypeCircleRelationPayload struct {
 
 type UpdateCirclePayload struct {
 	Name        *string `json:"name" validate:"omitempty,min=3,max=255"`
+	Slug        *string `json:"slug" validate:"omitempty,min=3,max=255"`
 	Description *string `json:"description" validate:"omitempty"`
 	Rating      *string `json:"rating" validate:"omitempty,oneof=GA PG M"`
 
@@ -59,8 +62,10 @@ type BlockResponse struct {
 
 type CircleOneDetailedResponse struct {
 	entity.Circle
-	Fandom   []entity.Fandom   `json:"fandom"`
-	WorkType []entity.WorkType `json:"work_type"`
+	PicturePlaceholder      *entity.ImagePlaceholder `json:"picture_placeholder"`
+	CoverPicturePlaceholder *entity.ImagePlaceholder `json:"cover_picture_placeholder"`
+	Fandom                  []entity.Fandom          `json:"fandom"`
+	WorkType                []entity.WorkType        `json:"work_type"`
 
 	Bookmarked bool           `json:"bookmarked"`
 	BlockEvent *BlockResponse `json:"block"`
@@ -69,11 +74,65 @@ type CircleOneDetailedResponse struct {
 
 type CirclePaginatedResponse struct {
 	entity.Circle
-	Description *string           `json:"-"`
-	Fandom      []entity.Fandom   `json:"fandom"`
-	WorkType    []entity.WorkType `json:"work_type"`
+	Description             *string                  `json:"-"`
+	PicturePlaceholder      *entity.ImagePlaceholder `json:"picture_placeholder"`
+	CoverPicturePlaceholder *entity.ImagePlaceholder `json:"cover_picture_placeholder"`
+	Fandom                  []entity.Fandom          `json:"fandom"`
+	WorkType                []entity.WorkType        `json:"work_type"`
 
 	Bookmarked bool           `json:"bookmarked"`
 	BlockEvent *BlockResponse `json:"block"`
 	Event      *entity.Event  `json:"event"`
+
+	Bookmark *BookmarkResponse `json:"bookmark,omitempty"`
+}
+
+type BookmarkResponse struct {
+	CollectionID *int       `json:"collection_id"`
+	Note         *string    `json:"note"`
+	Priority     int        `json:"priority"`
+	Position     int        `json:"position"`
+	CreatedAt    *time.Time `json:"created_at"`
+}
+
+// SharedBookmarkListResponse describes a bookmark collection opened through its share link.
+type SharedBookmarkListResponse struct {
+	Name      string     `json:"name"`
+	OwnerName string     `json:"owner_name"`
+	SharedAt  *time.Time `json:"shared_at"`
+}
+
+// RoutePlanStop is one bookmarked circle to visit, Step is its place in the walking order.
+type RoutePlanStop struct {
+	Step       int     `json:"step"`
+	CircleID   int     `json:"circle_id"`
+	Name       string  `json:"name"`
+	Slug       string  `json:"slug"`
+	PictureURL *string `json:"picture_url"`
+	Block      *string `json:"block"`
+	NoBlock    bool    `json:"no_block"`
+	Note       *string `json:"note"`
+	Priority   int     `json:"priority"`
+	// Status is the check-off status at the event, nil until the user sets one.
+	Status *string `json:"status"`
+}
+
+// RoutePlanGroup holds the stops sharing a block prefix, usually one row of a hall.
+type RoutePlanGroup struct {
+	Prefix string          `json:"prefix"`
+	Stops  []RoutePlanStop `json:"stops"`
+}
+
+type RoutePlanResponse struct {
+	Event  *entity.Event    `json:"event"`
+	Day    entity.Day       `json:"day"`
+	Total  int              `json:"total"`
+	Groups []RoutePlanGroup `json:"groups"`
+	// Unplaced are the circles without a block yet, they are not part of the walking order.
+	Unplaced []RoutePlanStop `json:"unplaced"`
+}
+
+type PublishCircleDraftPayload struct {
+	// PublishAt schedules the draft, the draft is published right away when it is empty or in the past.
+	PublishAt *time.Time `json:"publish_at" validate:"omitempty"`
 }
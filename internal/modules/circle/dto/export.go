@@ -0,0 +1,29 @@
+package circle_dto
+
+import (
+	"catalog-be/internal/entity"
+	"time"
+)
+
+type BookmarkExportEvent struct {
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+}
+
+// BookmarkExportRow is one bookmarked circle as written to an export file.
+type BookmarkExportRow struct {
+	Name         string               `json:"name"`
+	Slug         string               `json:"slug"`
+	Block        *string              `json:"block"`
+	Day          *entity.Day          `json:"day"`
+	Event        *BookmarkExportEvent `json:"event"`
+	URL          *string              `json:"url"`
+	FacebookURL  *string              `json:"facebook_url"`
+	InstagramURL *string              `json:"instagram_url"`
+	TwitterURL   *string              `json:"twitter_url"`
+	Products     []string             `json:"products"`
+	Note         *string              `json:"note"`
+	Priority     int                  `json:"priority"`
+}
@@ -11,4 +11,28 @@ type GetPaginatedCirclesFilter struct {
 	Rating      []string    `query:"rating" validate:"omitempty,dive,oneof=GA PG M"`
 	Event       string      `query:"event" validate:"omitempty"`
 	Day         *entity.Day `query:"day" validate:"omitempty,oneof=first second both"`
+
+	// PriceMin and PriceMax keep circles selling a product in the range, in the
+	// smallest unit of Currency. Sold out products are ignored.
+	PriceMin *int64 `query:"price_min" validate:"omitempty,min=0"`
+	PriceMax *int64 `query:"price_max" validate:"omitempty,min=0"`
+	Currency string `query:"currency" validate:"omitempty,iso4217"`
+
+	// CollectionID only applies to bookmarked circles, 0 means bookmarks outside any collection.
+	CollectionID *int `query:"collection_id" validate:"omitempty,min=0"`
+}
+
+type GetPaginatedDeletedCirclesFilter struct {
+	Page  int `query:"page" validate:"required,min=1"`
+	Limit int `query:"limit" validate:"required,min=1,max=20"`
+}
+
+type GetRoutePlanFilter struct {
+	Event string     `query:"event" validate:"required"`
+	Day   entity.Day `query:"day" validate:"required,oneof=first second"`
+
+	// CollectionID limits the plan to one collection, 0 means bookmarks outside any collection.
+	CollectionID *int `query:"collection_id" validate:"omitempty,min=0"`
+	// Format is json by default, text returns a printable checklist.
+	Format string `query:"format" validate:"omitempty,oneof=json text"`
 }
@@ -0,0 +1,233 @@
+// Package export writes bookmarked circles to files users can take outside the app.
+package export
+
+import (
+	"bytes"
+	"catalog-be/internal/entity"
+	circle_dto "catalog-be/internal/modules/circle/dto"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	CSV  Format = "csv"
+	JSON Format = "json"
+	ICS  Format = "ics"
+)
+
+// ContentType returns the mime type of an export format.
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case ICS:
+		return "text/calendar; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+func optional(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
+
+// cell keeps spreadsheet apps from running a user supplied value as a formula.
+func cell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+// Render writes rows in the given format, now is used as the ICS timestamp.
+func Render(format Format, rows []circle_dto.BookmarkExportRow, now time.Time) ([]byte, error) {
+	switch format {
+	case CSV:
+		return renderCSV(rows)
+	case ICS:
+		return renderICS(rows, now), nil
+	default:
+		return json.Marshal(rows)
+	}
+}
+
+func renderCSV(rows []circle_dto.BookmarkExportRow) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	err := writer.Write([]string{
+		"name", "slug", "block", "day", "event", "event_started_at", "event_ended_at",
+		"url", "facebook_url", "instagram_url", "twitter_url", "products", "note", "priority",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		day := ""
+		if row.Day != nil {
+			day = string(*row.Day)
+		}
+
+		var event, startedAt, endedAt string
+		if row.Event != nil {
+			event = row.Event.Name
+			startedAt = row.Event.StartedAt.Format(time.RFC3339)
+			endedAt = row.Event.EndedAt.Format(time.RFC3339)
+		}
+
+		err := writer.Write([]string{
+			cell(row.Name),
+			cell(row.Slug),
+			cell(optional(row.Block)),
+			day,
+			cell(event),
+			startedAt,
+			endedAt,
+			cell(optional(row.URL)),
+			cell(optional(row.FacebookURL)),
+			cell(optional(row.InstagramURL)),
+			cell(optional(row.TwitterURL)),
+			cell(strings.Join(row.Products, "; ")),
+			cell(optional(row.Note)),
+			fmt.Sprint(row.Priority),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// eventDay is one day of an event and the circles bookmarked for it.
+type eventDay struct {
+	event *circle_dto.BookmarkExportEvent
+	day   entity.Day
+	date  time.Time
+	rows  []circle_dto.BookmarkExportRow
+}
+
+// eventDays spreads rows over the days of their event, the first day is the
+// date the event starts and the second day the date after. Circles without an
+// event or a day can not be placed on a calendar and are left out.
+func eventDays(rows []circle_dto.BookmarkExportRow) []*eventDay {
+	days := []*eventDay{}
+	index := make(map[string]*eventDay)
+
+	add := func(row circle_dto.BookmarkExportRow, day entity.Day) {
+		key := row.Event.Slug + "/" + string(day)
+		if index[key] == nil {
+			start := row.Event.StartedAt
+			date := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+			if day == entity.Second {
+				date = date.AddDate(0, 0, 1)
+			}
+
+			index[key] = &eventDay{event: row.Event, day: day, date: date}
+			days = append(days, index[key])
+		}
+		index[key].rows = append(index[key].rows, row)
+	}
+
+	for _, row := range rows {
+		if row.Event == nil || row.Day == nil {
+			continue
+		}
+
+		switch *row.Day {
+		case entity.First, entity.Second:
+			add(row, *row.Day)
+		case entity.Both:
+			add(row, entity.First)
+			add(row, entity.Second)
+		}
+	}
+
+	return days
+}
+
+// escapeICS escapes a TEXT value as described in RFC 5545 section 3.3.11.
+func escapeICS(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(value)
+}
+
+// foldICS splits a content line longer than 75 octets, continuation lines
+// start with a space. Multi byte characters are never split.
+func foldICS(line string) string {
+	var builder strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			builder.WriteString("\r\n ")
+			width = 1
+		}
+		builder.WriteRune(r)
+		width += size
+	}
+
+	return builder.String()
+}
+
+func renderICS(rows []circle_dto.BookmarkExportRow, now time.Time) []byte {
+	var builder strings.Builder
+	line := func(value string) {
+		builder.WriteString(foldICS(value))
+		builder.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Inner Catalog//Bookmarks//EN")
+	line("CALSCALE:GREGORIAN")
+
+	for _, day := range eventDays(rows) {
+		description := make([]string, 0, len(day.rows))
+		for _, row := range day.rows {
+			entry := row.Name
+			if row.Block != nil {
+				entry = *row.Block + " " + entry
+			}
+			if len(row.Products) > 0 {
+				entry += " (" + strings.Join(row.Products, ", ") + ")"
+			}
+			description = append(description, entry)
+		}
+
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:bookmark-%s-%s@innercatalog.com", day.event.Slug, day.day))
+		line("DTSTAMP:" + now.UTC().Format("20060102T150405Z"))
+		line("DTSTART;VALUE=DATE:" + day.date.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + day.date.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escapeICS(fmt.Sprintf("%s day %s - %d bookmarked circles", day.event.Name, day.day, len(day.rows))))
+		line("LOCATION:" + escapeICS(day.event.Name))
+		line("DESCRIPTION:" + escapeICS(strings.Join(description, "\n")))
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+
+	return []byte(builder.String())
+}
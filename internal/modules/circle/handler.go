@@ -4,10 +4,16 @@ import (
 	"catalog-be/internal/domain"
 	auth_dto "catalog-be/internal/modules/auth/dto"
 	"catalog-be/internal/modules/circle/bookmark"
+	bookmark_dto "catalog-be/internal/modules/circle/bookmark/dto"
 	circle_dto "catalog-be/internal/modules/circle/dto"
+	"catalog-be/internal/modules/circle/export"
+	revision_dto "catalog-be/internal/modules/circle/revision/dto"
 	"catalog-be/internal/modules/user"
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -17,6 +23,8 @@ type CircleHandler struct {
 	circleService *CircleService
 	validator     *validator.Validate
 	userService   *user.UserService
+
+	bookmarkService *bookmark.CircleBookmarkService
 }
 
 func (h *CircleHandler) PostPublishOrUnpublishCircle(c *fiber.Ctx) error {
@@ -130,6 +138,21 @@ func (h *CircleHandler) GetOneCricleByCircleSlug(c *fiber.Ctx) error {
 	}
 
 	circle, err := h.circleService.GetOneCircleByCircleSlug(slug, userID)
+	if err != nil && err.Code == fiber.StatusNotFound {
+		canonical, canonicalErr := h.circleService.GetCanonicalCircleSlug(slug)
+		if canonicalErr != nil {
+			return c.Status(canonicalErr.Code).JSON(domain.NewErrorFiber(c, canonicalErr))
+		}
+
+		c.Location(strings.TrimSuffix(c.Path(), slug) + canonical)
+		return c.Status(fiber.StatusMovedPermanently).JSON(fiber.Map{
+			"data": fiber.Map{
+				"slug": canonical,
+			},
+			"code": fiber.StatusMovedPermanently,
+		})
+	}
+
 	if err != nil {
 		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
 	}
@@ -218,6 +241,35 @@ func (h *CircleHandler) GetPaginatedBookmarkedCircles(c *fiber.Ctx) error {
 	})
 }
 
+func (h *CircleHandler) GetExportBookmarkedCircles(c *fiber.Ctx) error {
+	var query circle_dto.GetPaginatedCirclesFilter
+	if err := c.QueryParser(&query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	// an export always holds every bookmark
+	if err := h.validator.StructExcept(query, "Page", "Limit"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	format := export.Format(c.Query("format", string(export.JSON)))
+	if format != export.CSV && format != export.JSON && format != export.ICS {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("INVALID_EXPORT_FORMAT"), nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	content, err := h.circleService.ExportBookmarkedCircles(user.UserID, &query, format)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	c.Set(fiber.HeaderContentType, format.ContentType())
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="bookmarks.%s"`, format))
+
+	return c.Status(fiber.StatusOK).Send(content)
+}
+
 func (h *CircleHandler) PostBookmarkCircleByCircleID(c *fiber.Ctx) error {
 
 	circleID, parseErr := c.ParamsInt("id")
@@ -259,6 +311,380 @@ func (h *CircleHandler) DeleteBookmarkCircleByCircleID(c *fiber.Ctx) error {
 	})
 }
 
+func (h *CircleHandler) PatchBookmarkCircleByCircleID(c *fiber.Ctx) error {
+	circleID, parseErr := c.ParamsInt("id")
+	if parseErr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, parseErr, nil)))
+	}
+
+	var body bookmark_dto.SaveBookmarkPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	saved, err := h.bookmarkService.UpdateBookmark(circleID, user.UserID, &body)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": saved,
+	})
+}
+
+func (h *CircleHandler) PutReorderBookmarks(c *fiber.Ctx) error {
+	var body bookmark_dto.ReorderBookmarksPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	err := h.bookmarkService.ReorderBookmarks(user.UserID, &body)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": "BOOKMARKS_REORDERED",
+	})
+}
+
+func (h *CircleHandler) GetAllBookmarkCollections(c *fiber.Ctx) error {
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	collections, err := h.bookmarkService.GetAllCollections(user.UserID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": collections,
+	})
+}
+
+func (h *CircleHandler) PostCreateBookmarkCollection(c *fiber.Ctx) error {
+	var body bookmark_dto.CreateUpdateCollectionPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	body.Name = strings.TrimSpace(body.Name)
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	collection, err := h.bookmarkService.CreateOneCollection(user.UserID, &body)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"code": fiber.StatusCreated,
+		"data": collection,
+	})
+}
+
+func (h *CircleHandler) PutUpdateBookmarkCollection(c *fiber.Ctx) error {
+	collectionID, parseErr := c.ParamsInt("collectionid")
+	if parseErr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("COLLECTION_ID_SHOULD_BE_NUMBER"), nil)))
+	}
+
+	var body bookmark_dto.CreateUpdateCollectionPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	body.Name = strings.TrimSpace(body.Name)
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	collection, err := h.bookmarkService.UpdateOneCollection(user.UserID, collectionID, &body)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": collection,
+	})
+}
+
+func (h *CircleHandler) DeleteBookmarkCollection(c *fiber.Ctx) error {
+	collectionID, parseErr := c.ParamsInt("collectionid")
+	if parseErr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("COLLECTION_ID_SHOULD_BE_NUMBER"), nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	err := h.bookmarkService.DeleteOneCollection(user.UserID, collectionID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": "COLLECTION_DELETED",
+	})
+}
+
+func (h *CircleHandler) PutBookmarkStatusByCircleID(c *fiber.Ctx) error {
+	circleID, parseErr := c.ParamsInt("id")
+	if parseErr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, parseErr, nil)))
+	}
+
+	var body bookmark_dto.SaveBookmarkStatusPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	body.CircleID = circleID
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	statuses, err := h.bookmarkService.SaveBookmarkStatuses(user.UserID, &bookmark_dto.SaveBookmarkStatusesPayload{
+		Statuses: []bookmark_dto.SaveBookmarkStatusPayload{body},
+	})
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": statuses,
+	})
+}
+
+func (h *CircleHandler) PutBookmarkStatuses(c *fiber.Ctx) error {
+	var body bookmark_dto.SaveBookmarkStatusesPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	statuses, err := h.bookmarkService.SaveBookmarkStatuses(user.UserID, &body)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": statuses,
+	})
+}
+
+func (h *CircleHandler) GetBookmarkStatuses(c *fiber.Ctx) error {
+	var query bookmark_dto.GetBookmarkStatusesFilter
+	if err := c.QueryParser(&query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	var since *int64
+	if query.Since != "" {
+		parsed, parseErr := strconv.ParseInt(query.Since, 10, 64)
+		if parseErr != nil || parsed < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("SINCE_SHOULD_BE_A_CURSOR"), nil)))
+		}
+		since = &parsed
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	statuses, deleted, cursor, err := h.bookmarkService.SyncBookmarkStatuses(user.UserID, since, query.EventID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": fiber.Map{
+			"statuses": statuses,
+			"deleted":  deleted,
+			"cursor":   cursor,
+		},
+	})
+}
+
+func (h *CircleHandler) GetBookmarkRoutePlan(c *fiber.Ctx) error {
+	var query circle_dto.GetRoutePlanFilter
+	if err := c.QueryParser(&query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	plan, err := h.circleService.GetBookmarkRoutePlan(user.UserID, &query)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	if query.Format == "text" {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.Status(fiber.StatusOK).SendString(h.circleService.RenderRoutePlanChecklist(plan))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": plan,
+	})
+}
+
+func (h *CircleHandler) PostShareBookmarkCollection(c *fiber.Ctx) error {
+	collectionID, parseErr := c.ParamsInt("collectionid")
+	if parseErr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("COLLECTION_ID_SHOULD_BE_NUMBER"), nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	collection, err := h.bookmarkService.ShareCollection(user.UserID, collectionID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": collection,
+	})
+}
+
+func (h *CircleHandler) DeleteShareBookmarkCollection(c *fiber.Ctx) error {
+	collectionID, parseErr := c.ParamsInt("collectionid")
+	if parseErr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("COLLECTION_ID_SHOULD_BE_NUMBER"), nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	err := h.bookmarkService.RevokeCollectionShare(user.UserID, collectionID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": "SHARE_LINK_REVOKED",
+	})
+}
+
+func (h *CircleHandler) GetSharedBookmarkList(c *fiber.Ctx) error {
+	var query circle_dto.GetPaginatedCirclesFilter
+	if err := c.QueryParser(&query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user")
+
+	userID := 0
+	if user != nil {
+		userID = user.(*auth_dto.ATClaims).UserID
+	}
+
+	list, circles, err := h.circleService.GetSharedBookmarkList(c.Params("token"), userID, &query)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": fiber.Map{
+			"list":    list,
+			"circles": circles.Data,
+		},
+		"metadata": circles.Metadata,
+	})
+}
+
+func (h *CircleHandler) PostCloneSharedBookmarkList(c *fiber.Ctx) error {
+	var body bookmark_dto.CloneCollectionPayload
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+		}
+	}
+
+	body.Name = strings.TrimSpace(body.Name)
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	collection, err := h.bookmarkService.CloneSharedCollection(user.UserID, c.Params("token"), &body)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"code": fiber.StatusCreated,
+		"data": collection,
+	})
+}
+
+func (h *CircleHandler) PutReorderBookmarkCollections(c *fiber.Ctx) error {
+	var body bookmark_dto.ReorderCollectionsPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	err := h.bookmarkService.ReorderCollections(user.UserID, &body)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": "COLLECTIONS_REORDERED",
+	})
+}
+
 func (h *CircleHandler) PutUpdateAttendingEventByCircleID(c *fiber.Ctx) error {
 	circleID, err := c.ParamsInt("circleid")
 	if err != nil {
@@ -322,6 +748,280 @@ func (h *CircleHandler) DeleteAttendingEventByCircleID(c *fiber.Ctx) error {
 	})
 }
 
+// parseOwnCircleID reads the :circleid param and makes sure it is the circle of the user.
+func (h *CircleHandler) parseOwnCircleID(c *fiber.Ctx, user *auth_dto.ATClaims) (int, *domain.Error) {
+	circleID, parserr := c.ParamsInt("circleid")
+	if parserr != nil {
+		return 0, domain.NewError(fiber.StatusBadRequest, errors.New("CIRCLE_ID_SHOULD_BE_NUMBER"), nil)
+	}
+
+	if user.CircleID == nil || *user.CircleID != circleID {
+		return 0, domain.NewError(fiber.StatusForbidden, errors.New("FORBIDDEN"), nil)
+	}
+
+	return circleID, nil
+}
+
+func (h *CircleHandler) GetPaginatedRevisionsByCircleID(c *fiber.Ctx) error {
+	user := c.Locals("user").(*auth_dto.ATClaims)
+	circleID, ownErr := h.parseOwnCircleID(c, user)
+	if ownErr != nil {
+		return c.Status(ownErr.Code).JSON(domain.NewErrorFiber(c, ownErr))
+	}
+
+	var query revision_dto.GetPaginatedRevisionsFilter
+	if err := c.QueryParser(&query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	revisions, err := h.circleService.GetPaginatedRevisionsByCircleID(circleID, &query)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code":     fiber.StatusOK,
+		"data":     revisions.Data,
+		"metadata": revisions.Metadata,
+	})
+}
+
+func (h *CircleHandler) PostRestoreRevisionByCircleID(c *fiber.Ctx) error {
+	user := c.Locals("user").(*auth_dto.ATClaims)
+	circleID, ownErr := h.parseOwnCircleID(c, user)
+	if ownErr != nil {
+		return c.Status(ownErr.Code).JSON(domain.NewErrorFiber(c, ownErr))
+	}
+
+	revisionID, parserr := c.ParamsInt("revisionid")
+	if parserr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("REVISION_ID_SHOULD_BE_NUMBER"), nil)))
+	}
+
+	err := h.circleService.RestoreRevisionByID(user.UserID, circleID, revisionID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": "REVISION_RESTORED",
+	})
+}
+
+func (h *CircleHandler) GetCircleDraft(c *fiber.Ctx) error {
+	user := c.Locals("user").(*auth_dto.ATClaims)
+	circleID, ownErr := h.parseOwnCircleID(c, user)
+	if ownErr != nil {
+		return c.Status(ownErr.Code).JSON(domain.NewErrorFiber(c, ownErr))
+	}
+
+	draft, err := h.circleService.GetCircleDraft(circleID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": draft,
+	})
+}
+
+func (h *CircleHandler) PutSaveCircleDraft(c *fiber.Ctx) error {
+	user := c.Locals("user").(*auth_dto.ATClaims)
+	circleID, ownErr := h.parseOwnCircleID(c, user)
+	if ownErr != nil {
+		return c.Status(ownErr.Code).JSON(domain.NewErrorFiber(c, ownErr))
+	}
+
+	var body circle_dto.UpdateCirclePayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	draft, err := h.circleService.SaveCircleDraft(user.UserID, circleID, &body)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": draft,
+	})
+}
+
+func (h *CircleHandler) DeleteCircleDraft(c *fiber.Ctx) error {
+	user := c.Locals("user").(*auth_dto.ATClaims)
+	circleID, ownErr := h.parseOwnCircleID(c, user)
+	if ownErr != nil {
+		return c.Status(ownErr.Code).JSON(domain.NewErrorFiber(c, ownErr))
+	}
+
+	err := h.circleService.DeleteCircleDraft(circleID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": "DRAFT_DELETED",
+	})
+}
+
+func (h *CircleHandler) PostPublishCircleDraft(c *fiber.Ctx) error {
+	user := c.Locals("user").(*auth_dto.ATClaims)
+	circleID, ownErr := h.parseOwnCircleID(c, user)
+	if ownErr != nil {
+		return c.Status(ownErr.Code).JSON(domain.NewErrorFiber(c, ownErr))
+	}
+
+	var body circle_dto.PublishCircleDraftPayload
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+		}
+	}
+
+	if body.PublishAt != nil && body.PublishAt.After(time.Now()) {
+		draft, err := h.circleService.ScheduleCircleDraft(user.UserID, circleID, *body.PublishAt)
+		if err != nil {
+			return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+		}
+
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"code": fiber.StatusAccepted,
+			"data": draft,
+		})
+	}
+
+	circle, err := h.circleService.PublishCircleDraftNow(user.UserID, circleID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": circle,
+	})
+}
+
+func (h *CircleHandler) GetPreviewCircleDraft(c *fiber.Ctx) error {
+	token := strings.TrimSpace(c.Params("token"))
+	if token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("TOKEN_IS_EMPTY"), nil)))
+	}
+
+	circle, err := h.circleService.PreviewCircleDraft(token)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set("X-Robots-Tag", "noindex")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": circle,
+	})
+}
+
+func (h *CircleHandler) DeleteOneCircleByCircleID(c *fiber.Ctx) error {
+	user := c.Locals("user").(*auth_dto.ATClaims)
+	circleID, ownErr := h.parseOwnCircleID(c, user)
+	if ownErr != nil {
+		return c.Status(ownErr.Code).JSON(domain.NewErrorFiber(c, ownErr))
+	}
+
+	err := h.circleService.DeleteCircleByID(user.UserID, circleID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": "CIRCLE_DELETED",
+	})
+}
+
+func (h *CircleHandler) PostRestoreOwnCircle(c *fiber.Ctx) error {
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	circle, err := h.circleService.RestoreOwnDeletedCircle(user.UserID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": circle,
+	})
+}
+
+func (h *CircleHandler) GetPaginatedDeletedCircles(c *fiber.Ctx) error {
+	var query circle_dto.GetPaginatedDeletedCirclesFilter
+	if err := c.QueryParser(&query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	circles, err := h.circleService.GetPaginatedDeletedCircles(&query)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code":     fiber.StatusOK,
+		"data":     circles.Data,
+		"metadata": circles.Metadata,
+	})
+}
+
+func (h *CircleHandler) PostRestoreDeletedCircle(c *fiber.Ctx) error {
+	circleID, parserr := c.ParamsInt("circleid")
+	if parserr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("CIRCLE_ID_SHOULD_BE_NUMBER"), nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	circle, err := h.circleService.RestoreDeletedCircleByID(user.UserID, circleID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": circle,
+	})
+}
+
+func (h *CircleHandler) DeletePurgeCircle(c *fiber.Ctx) error {
+	circleID, parserr := c.ParamsInt("circleid")
+	if parserr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("CIRCLE_ID_SHOULD_BE_NUMBER"), nil)))
+	}
+
+	err := h.circleService.PurgeDeletedCircleByID(circleID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": "CIRCLE_PURGED",
+	})
+}
+
 func NewCircleHandler(
 	circleService *CircleService,
 	validator *validator.Validate,
@@ -332,5 +1032,7 @@ func NewCircleHandler(
 		circleService: circleService,
 		validator:     validator,
 		userService:   userService,
+
+		bookmarkService: circleBookmarkService,
 	}
 }
@@ -2,30 +2,48 @@ package circle
 
 import (
 	"catalog-be/internal/domain"
+	"encoding/json"
 	"errors"
 	"os"
 	"strings"
+	"time"
 
 	"catalog-be/internal/entity"
+	"catalog-be/internal/middlewares"
+	"catalog-be/internal/modules/circle/bookmark"
 	circle_dto "catalog-be/internal/modules/circle/dto"
+	"catalog-be/internal/modules/circle/revision"
+	"catalog-be/internal/modules/plan"
+	product_module "catalog-be/internal/modules/product"
+	"catalog-be/internal/modules/upload"
 	"fmt"
 
-	"github.com/WinterYukky/gorm-extra-clause-plugin/exclause"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CircleRepo struct {
 	db *gorm.DB
 }
 
+// moderatedColumns are only changed by moderation, full saves of the owner
+// leave them out so a stale circle does not undo a moderation.
+var moderatedColumns = []string{"published", "hidden", "hidden_automatically", "unpublished_by_moderator"}
+
 // UpdateAttendingEventDayAndCircleBlock implements CircleRepo.
-func (c *CircleRepo) UpdateAttendingEventDayAndCircleBlock(circle *entity.Circle, body *circle_dto.UpdateCircleAttendingEventDayAndBlockPayload) *domain.Error {
+func (c *CircleRepo) UpdateAttendingEventDayAndCircleBlock(userID int, circle *entity.Circle, body *circle_dto.UpdateCircleAttendingEventDayAndBlockPayload) *domain.Error {
 	tx := c.db.Begin()
 	if tx.Error != nil {
 		return domain.NewError(500, tx.Error, nil)
 	}
 
-	err := tx.Save(circle).Error
+	before, snapshotErr := c.eventBlockSnapshot(tx, circle.ID)
+	if snapshotErr != nil {
+		tx.Rollback()
+		return snapshotErr
+	}
+
+	err := tx.Omit(moderatedColumns...).Save(circle).Error
 	if err != nil {
 		tx.Rollback()
 		return domain.NewError(500, err, nil)
@@ -76,18 +94,30 @@ func (c *CircleRepo) UpdateAttendingEventDayAndCircleBlock(circle *entity.Circle
 		}
 	}
 
+	revisionErr := c.recordEventBlockRevision(tx, userID, circle.ID, entity.RevisionUpdate, before, nil)
+	if revisionErr != nil {
+		tx.Rollback()
+		return revisionErr
+	}
+
 	tx.Commit()
 
 	return nil
 }
 
 // DeleteAllBlockEventByCircleIDAndEventID implements CircleRepo.
-func (c *CircleRepo) DeleteAllBlockEventByCircleIDAndEventID(circle *entity.Circle) *domain.Error {
+func (c *CircleRepo) DeleteAllBlockEventByCircleIDAndEventID(userID int, circle *entity.Circle) *domain.Error {
 	tx := c.db.Begin()
 	if tx.Error != nil {
 		return domain.NewError(500, tx.Error, nil)
 	}
 
+	before, snapshotErr := c.eventBlockSnapshot(tx, circle.ID)
+	if snapshotErr != nil {
+		tx.Rollback()
+		return snapshotErr
+	}
+
 	err := tx.Table("block_event").
 		Where("circle_id = ? AND event_id = ?", circle.ID, circle.EventID).
 		Unscoped().
@@ -101,13 +131,19 @@ func (c *CircleRepo) DeleteAllBlockEventByCircleIDAndEventID(circle *entity.Circ
 	circle.EventID = nil
 	circle.Day = nil
 
-	err = tx.Save(circle).Error
+	err = tx.Omit(moderatedColumns...).Save(circle).Error
 
 	if err != nil {
 		tx.Rollback()
 		return domain.NewError(500, err, nil)
 	}
 
+	revisionErr := c.recordEventBlockRevision(tx, userID, circle.ID, entity.RevisionDelete, before, nil)
+	if revisionErr != nil {
+		tx.Rollback()
+		return revisionErr
+	}
+
 	tx.Commit()
 
 	return nil
@@ -123,6 +159,9 @@ func (c *CircleRepo) OnboardNewCircle(circle *entity.Circle, user *entity.User)
 	err := tx.Create(circle).Error
 	if err != nil {
 		tx.Rollback()
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, domain.NewError(409, errors.New("SLUG_ALREADY_TAKEN"), nil)
+		}
 		return nil, domain.NewError(500, err, nil)
 	}
 
@@ -131,6 +170,12 @@ func (c *CircleRepo) OnboardNewCircle(circle *entity.Circle, user *entity.User)
 		return nil, domain.NewError(500, errors.New("CIRCLE_ID_NOT_FOUND"), nil)
 	}
 
+	referenceErr := c.saveCircleReferences(tx, circle)
+	if referenceErr != nil {
+		tx.Rollback()
+		return nil, referenceErr
+	}
+
 	user.CircleID = &circle.ID
 
 	err = tx.Save(user).Error
@@ -172,22 +217,39 @@ func (c *CircleRepo) transformBlockStringIntoBlockEvent(block string) (*entity.B
 	}, nil
 }
 
-// UpsertOneCircle implements CircleRepo.
-func (c *CircleRepo) UpsertOneCircle(circle *entity.Circle) (*entity.Circle, *domain.Error) {
-	err := c.db.Save(circle).Error
-	if err != nil {
-		return nil, domain.NewError(500, err, nil)
+// UpdatePublishedByCircleID publishes or unpublishes a circle for its owner,
+// a circle unpublished by a moderator can not be published again.
+func (c *CircleRepo) UpdatePublishedByCircleID(circleID int, published bool) *domain.Error {
+	query := c.db.Model(&entity.Circle{}).Where("id = ?", circleID)
+	if published {
+		query = query.Where("unpublished_by_moderator IS FALSE")
 	}
-	return circle, nil
+
+	result := query.Update("published", published)
+	if result.Error != nil {
+		return domain.NewError(500, result.Error, nil)
+	}
+
+	if result.RowsAffected == 0 {
+		return domain.NewError(403, errors.New("CIRCLE_UNPUBLISHED_BY_MODERATOR"), nil)
+	}
+
+	return nil
 }
 
 // UpdateOneCircleAndAllRelation implements CircleRepo.
-func (c *CircleRepo) UpdateOneCircleAndAllRelation(userID int, payload *entity.Circle, body *circle_dto.UpdateCirclePayload) ([]entity.CircleJoinedTables, *domain.Error) {
+func (c *CircleRepo) UpdateOneCircleAndAllRelation(userID int, payload *entity.Circle, body *circle_dto.UpdateCirclePayload) (*entity.CircleJoinedTables, *domain.Error) {
 	tx := c.db.Begin()
 	if tx.Error != nil {
 		return nil, domain.NewError(500, tx.Error, nil)
 	}
 
+	before, snapshotErr := c.circleSnapshot(tx, payload.ID)
+	if snapshotErr != nil {
+		tx.Rollback()
+		return nil, snapshotErr
+	}
+
 	if body.FandomIDs != nil {
 		if len(*body.FandomIDs) == 0 {
 			// delete all fandom by circle id
@@ -196,9 +258,6 @@ func (c *CircleRepo) UpdateOneCircleAndAllRelation(userID int, payload *entity.C
 				tx.Rollback()
 				return nil, domain.NewError(500, err, nil)
 			}
-		} else if len(*body.FandomIDs) > 5 {
-			tx.Rollback()
-			return nil, domain.NewError(400, errors.New("FANDOM_LIMIT_EXCEEDED"), nil)
 		} else {
 			// delete all fandom by circle id
 			err := tx.Where("circle_id = ?", payload.ID).Delete(&entity.CircleFandom{}).Error
@@ -233,9 +292,6 @@ func (c *CircleRepo) UpdateOneCircleAndAllRelation(userID int, payload *entity.C
 				tx.Rollback()
 				return nil, domain.NewError(500, err, nil)
 			}
-		} else if len(*body.WorkTypeIDs) > 5 {
-			tx.Rollback()
-			return nil, domain.NewError(400, errors.New("WORK_TYPE_LIMIT_EXCEEDED"), nil)
 		} else {
 
 			// delete all work type by circle id
@@ -262,364 +318,1323 @@ func (c *CircleRepo) UpdateOneCircleAndAllRelation(userID int, payload *entity.C
 		}
 	}
 
-	saveErr := tx.Save(&payload).Error
+	historyErr := c.saveSlugHistory(tx, payload)
+	if historyErr != nil {
+		tx.Rollback()
+		return nil, historyErr
+	}
+
+	saveErr := tx.Omit(moderatedColumns...).Save(&payload).Error
 	if saveErr != nil {
 		tx.Rollback()
+		if errors.Is(saveErr, gorm.ErrDuplicatedKey) {
+			return nil, domain.NewError(409, errors.New("SLUG_ALREADY_TAKEN"), nil)
+		}
 		return nil, domain.NewError(500, saveErr, nil)
 	}
 
+	referenceErr := c.saveCircleReferences(tx, payload)
+	if referenceErr != nil {
+		tx.Rollback()
+		return nil, referenceErr
+	}
+
+	revisionErr := c.recordCircleRevision(tx, userID, payload.ID, entity.RevisionUpdate, before, nil)
+	if revisionErr != nil {
+		tx.Rollback()
+		return nil, revisionErr
+	}
+
 	tx.Commit()
 
-	rows, err := c.GetOneCircleJoinTablesByCircleSlug(payload.Slug, userID)
+	row, err := c.GetOneCircleJoinTablesByCircleSlug(payload.Slug, userID)
 	if err != nil {
-		tx.Rollback()
 		return nil, domain.NewError(err.Code, err.Err, nil)
 	}
 
-	return rows, nil
+	return row, nil
 }
 
-func NewCircleRepo(
-	db *gorm.DB,
-) *CircleRepo {
-	return &CircleRepo{
-		db: db,
+// saveSlugHistory keeps the current slug of the circle as history when the
+// circle is about to be saved with a different slug, so old links still resolve.
+func (c *CircleRepo) saveSlugHistory(tx *gorm.DB, circle *entity.Circle) *domain.Error {
+	var current entity.Circle
+	err := tx.Unscoped().Select("id", "slug").First(&current, circle.ID).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
 	}
-}
 
-// GetOneCircleJoinTablesByCircleSlug implements CircleRepo.
-func (c *CircleRepo) GetOneCircleJoinTablesByCircleSlug(slug string, userID int) ([]entity.CircleJoinedTables, *domain.Error) {
-	var row []entity.CircleJoinedTables
-	err := c.db.Select(`
-			c.*,
-			f.id as fandom_id,
-			f.name as fandom_name,
-			f.visible as fandom_visible,
-			f.created_at as fandom_created_at,
-			f.updated_at as fandom_updated_at,
-			f.deleted_at as fandom_deleted_at,
-
-			wt.id as work_type_id,
-			wt.name as work_type_name,
-			wt.created_at as work_type_created_at,
-			wt.updated_at as work_type_updated_at,
-			wt.deleted_at as work_type_deleted_at,
-
-			e.name as event_name,
-			e.slug as event_slug,
-			e.description as event_description,
-			e.started_at as event_started_at,
-			e.ended_at as event_ended_at,
-
-			be.id as block_event_id,
-			be.prefix as block_event_prefix,
-			be.postfix as block_event_postfix,
-			be.name as block_event_name,
-
-			user_bookmark.created_at as bookmarked_at,
-			CASE WHEN user_bookmark.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS bookmarked
- 		 `).
-		Table("circle c").
-		Joins("LEFT JOIN circle_fandom cf ON c.id = cf.circle_id").
-		Joins("LEFT JOIN fandom f ON cf.fandom_id = f.id").
-		Joins("LEFT JOIN circle_work_type cwt ON c.id = cwt.circle_id").
-		Joins("LEFT JOIN work_type wt ON cwt.work_type_id = wt.id").
-		Joins("LEFT JOIN user_bookmark ON c.id = user_bookmark.circle_id AND user_bookmark.user_id = COALESCE(?, user_bookmark.user_id)", userID).
-		Joins("LEFT JOIN event e ON c.event_id = e.id").
-		Joins("LEFT JOIN block_event be ON c.id = be.circle_id AND be.event_id = c.event_id").
-		Where("c.deleted_at is null AND c.slug = ?", slug).Find(&row).Error
+	if current.Slug == circle.Slug {
+		return nil
+	}
 
+	// the new slug may be one of the circle's own old slugs
+	err = tx.Where("circle_id = ? AND slug = ?", circle.ID, circle.Slug).Delete(&entity.CircleSlugHistory{}).Error
 	if err != nil {
-		return nil, domain.NewError(500, err, nil)
+		return domain.NewError(500, err, nil)
 	}
 
-	return row, nil
+	err = tx.Create(&entity.CircleSlugHistory{
+		CircleID: circle.ID,
+		Slug:     current.Slug,
+	}).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
 }
 
-// findAllBookmarkedCount implements CircleRepo.
-func (c *CircleRepo) GetAllBookmarkedCircleCount(userID int, filter *circle_dto.GetPaginatedCirclesFilter) (int, *domain.Error) {
-	var count int64
+// saveCircleReferences references the uploads of the pictures and description
+// of circle as it is saved in tx.
+func (c *CircleRepo) saveCircleReferences(tx *gorm.DB, circle *entity.Circle) *domain.Error {
+	var texts []string
+	for _, text := range []*string{circle.PictureURL, circle.CoverPictureURL, circle.Description} {
+		if text != nil {
+			texts = append(texts, *text)
+		}
+	}
 
-	dbs := c.db.
-		Select("count(DISTINCT c.id)").
-		Table("circle c").
-		Joins("JOIN user_bookmark ub ON c.id = ub.circle_id AND ub.user_id = COALESCE(?, ub.user_id)", userID).
-		Joins("LEFT JOIN circle_fandom cf ON c.id = cf.circle_id").
-		Joins("LEFT JOIN fandom f ON f.id = cf.fandom_id").
-		Joins("LEFT JOIN circle_work_type cwt ON c.id = cwt.circle_id").
-		Joins("LEFT JOIN work_type wt ON wt.id = cwt.work_type_id").
-		Joins("LEFT JOIN product p ON c.id = p.circle_id").
-		Joins("LEFT JOIN event e ON c.event_id = e.id").
-		Joins("LEFT JOIN block_event be ON c.id = be.circle_id AND be.event_id = c.event_id")
+	return upload.SaveReferences(tx, entity.UploadReferenceCircle, circle.ID, texts...)
+}
 
-	err := dbs.
-		Count(&count).Error
+// circleSnapshot reads the editable state of a circle inside tx.
+func (c *CircleRepo) circleSnapshot(tx *gorm.DB, circleID int) (*revision.CircleSnapshot, *domain.Error) {
+	var circle entity.Circle
+	err := tx.Unscoped().First(&circle, circleID).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
 
+	var fandomIDs []int
+	err = tx.Model(&entity.CircleFandom{}).Where("circle_id = ?", circleID).Order("fandom_id").Pluck("fandom_id", &fandomIDs).Error
 	if err != nil {
-		return 0, domain.NewError(500, err, nil)
+		return nil, domain.NewError(500, err, nil)
 	}
 
-	return int(count), nil
-}
+	var workTypeIDs []int
+	err = tx.Model(&entity.CircleWorkType{}).Where("circle_id = ?", circleID).Order("work_type_id").Pluck("work_type_id", &workTypeIDs).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
 
-// GetPaginatedBookmarkedCirclesByUserID implements CircleRepo.
-func (c *CircleRepo) GetPaginatedBookmarkedCirclesByUserID(userID int, filter *circle_dto.GetPaginatedCirclesFilter) ([]entity.CircleJoinedTables, *domain.Error) {
-
-	cte := c.db.
-		Select(`
-			c.id as id,
-			c.name as name,
-			c.slug as slug,
-			c.picture_url as picture_url,
-			c.url as url,
-			c.facebook_url as facebook_url,
-			c.twitter_url as twitter_url,
-			c.instagram_url as instagram_url,
-			c.verified as verified,
-			c.published as published,
-			c.created_at as created_at,
-			c.updated_at as updated_at,
-			c.deleted_at as deleted_at,
-			c.day as day,
-			c.event_id as event_id,
-			c.cover_picture_url as cover_picture_url,
-			c.rating as rating,
-			ub.created_at as bookmarked_at,
-			true as bookmarked
-		`).
-		Table("circle c").
-		Joins("JOIN user_bookmark ub on c.id = ub.circle_id AND ub.user_id = ?", userID).
-		Where("c.deleted_at is null").
-		Order("ub.created_at desc").
-		Limit(filter.Limit).
-		Offset((filter.Page - 1) * filter.Limit)
+	return revision.NewCircleSnapshot(&circle, fandomIDs, workTypeIDs), nil
+}
 
-	join := c.db.Clauses(exclause.NewWith("cte", cte)).Table("cte as c")
+// eventBlockSnapshot reads the attended event, day and block of a circle inside tx.
+func (c *CircleRepo) eventBlockSnapshot(tx *gorm.DB, circleID int) (*revision.EventBlockSnapshot, *domain.Error) {
+	var circle entity.Circle
+	err := tx.Unscoped().Select("id", "event_id", "day").First(&circle, circleID).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
 
-	join = join.Joins("LEFT JOIN circle_fandom cf ON c.id = cf.circle_id").
-		Joins("LEFT JOIN fandom f ON f.id = cf.fandom_id").
-		Joins("LEFT JOIN circle_work_type cwt ON c.id = cwt.circle_id").
-		Joins("LEFT JOIN work_type wt ON wt.id = cwt.work_type_id").
-		Joins("LEFT JOIN product p ON c.id = p.circle_id").
-		Joins("LEFT JOIN event e ON c.event_id = e.id").
-		Joins("LEFT JOIN block_event be ON c.id = be.circle_id AND be.event_id = c.event_id")
+	snapshot := &revision.EventBlockSnapshot{
+		EventID: circle.EventID,
+		Day:     circle.Day,
+	}
 
-	var circleRaw []entity.CircleJoinedTables
-	err := join.
-		Select(`
-			c.*,
+	if circle.EventID == nil {
+		return snapshot, nil
+	}
 
-			f."name" as fandom_name,
-			f.id as fandom_id,
-			f.visible as fandom_visible,
+	var names []string
+	err = tx.Model(&entity.BlockEvent{}).Where("circle_id = ? AND event_id = ?", circleID, *circle.EventID).Limit(1).Pluck("name", &names).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
 
-			wt."name" as work_type_name,
-			wt.id as work_type_id,
+	if len(names) > 0 {
+		snapshot.Block = &names[0]
+	}
 
+	return snapshot, nil
+}
 
-			be.id as block_event_id,
-			be.prefix as block_event_prefix,
-			be.postfix as block_event_postfix,
-			be.name as block_event_name,
+// recordCircleRevision compares before with the circle as it is now in tx.
+func (c *CircleRepo) recordCircleRevision(tx *gorm.DB, userID int, circleID int, action entity.RevisionAction, before *revision.CircleSnapshot, restoredFromID *int) *domain.Error {
+	after, err := c.circleSnapshot(tx, circleID)
+	if err != nil {
+		return err
+	}
 
-			e.name as event_name,
-			e.slug as event_slug,
-			e.started_at as event_started_at,
-			e.ended_at as event_ended_at
-		`).
-		Order("c.bookmarked_at desc").
-		Find(&circleRaw).Error
+	return revision.Record(tx, userID, &entity.CircleRevision{
+		CircleID:       circleID,
+		EntityType:     entity.RevisionCircle,
+		EntityID:       circleID,
+		Action:         action,
+		RestoredFromID: restoredFromID,
+	}, before, after)
+}
 
+// recordEventBlockRevision compares before with the attended event of the circle as it is now in tx.
+func (c *CircleRepo) recordEventBlockRevision(tx *gorm.DB, userID int, circleID int, action entity.RevisionAction, before *revision.EventBlockSnapshot, restoredFromID *int) *domain.Error {
+	after, err := c.eventBlockSnapshot(tx, circleID)
 	if err != nil {
-		return nil, domain.NewError(500, err, nil)
+		return err
 	}
-	return circleRaw, nil
-}
 
-// FindAll implements CircleRepo.
-func (c *CircleRepo) GetPaginatedCircles(filter *circle_dto.GetPaginatedCirclesFilter, userID int) ([]entity.CircleJoinedTables, *domain.Error) {
-	appStage := os.Getenv("APP_STAGE")
+	return revision.Record(tx, userID, &entity.CircleRevision{
+		CircleID:       circleID,
+		EntityType:     entity.RevisionEventBlock,
+		EntityID:       circleID,
+		Action:         action,
+		RestoredFromID: restoredFromID,
+	}, before, after)
+}
 
-	cte := c.db.
-		Joins("LEFT JOIN circle_fandom cf ON c.id = cf.circle_id").
-		Joins("LEFT JOIN fandom f ON f.id = cf.fandom_id").
-		Joins("LEFT JOIN circle_work_type cwt ON c.id = cwt.circle_id").
-		Joins("LEFT JOIN work_type wt ON wt.id = cwt.work_type_id").
-		Joins("LEFT JOIN block_event be ON c.id = be.circle_id AND be.event_id = c.event_id").
-		Joins("LEFT JOIN event e ON c.event_id = e.id")
+// RestoreRevision implements CircleRepo. limits is the plan of the circle, a
+// restore can not take it over its limits.
+func (c *CircleRepo) RestoreRevision(userID int, target *entity.CircleRevision, limits *entity.Plan) *domain.Error {
+	tx := c.db.Begin()
+	if tx.Error != nil {
+		return domain.NewError(500, tx.Error, nil)
+	}
 
-	cte = cte.Table("circle c").
-		Where("c.deleted_at IS NULL").
-		Where("c.verified IS TRUE")
+	var err *domain.Error
+	switch target.EntityType {
+	case entity.RevisionCircle:
+		err = c.restoreCircle(tx, userID, target, limits)
+	case entity.RevisionProduct:
+		err = c.restoreProduct(tx, userID, target, limits)
+	case entity.RevisionEventBlock:
+		err = c.restoreEventBlock(tx, userID, target)
+	default:
+		err = domain.NewError(400, errors.New("REVISION_NOT_RESTORABLE"), nil)
+	}
 
-	if len(filter.Rating) > 0 {
-		cte = cte.Where("c.rating IN (?)", filter.Rating)
+	if err != nil {
+		tx.Rollback()
+		return err
 	}
-	if filter.Event != "" {
-		cte = cte.Where("e.slug = ?", filter.Event)
+
+	tx.Commit()
+
+	return nil
+}
+
+// restoreCircle puts the circle fields, fandoms and work types back to the
+// state saved in target.
+func (c *CircleRepo) restoreCircle(tx *gorm.DB, userID int, target *entity.CircleRevision, limits *entity.Plan) *domain.Error {
+	if target.After == nil {
+		return domain.NewError(400, errors.New("REVISION_NOT_RESTORABLE"), nil)
 	}
 
-	if len(filter.FandomIDs) > 0 {
-		cte = cte.Where("f.id in (?)", filter.FandomIDs)
+	var snapshot revision.CircleSnapshot
+	if err := revision.Decode(target.After, &snapshot); err != nil {
+		return err
 	}
 
-	if len(filter.WorkTypeIDs) > 0 {
-		cte = cte.Where("wt.id in (?)", filter.WorkTypeIDs)
+	if err := plan.CheckLimit(plan.ResourceFandom, len(snapshot.FandomIDs), limits.MaxFandoms); err != nil {
+		return err
 	}
 
-	if filter.Day != nil {
-		cte = cte.Where("c.day = ?", filter.Day)
+	if err := plan.CheckLimit(plan.ResourceWorkType, len(snapshot.WorkTypeIDs), limits.MaxWorkTypes); err != nil {
+		return err
 	}
 
-	if filter.Search != "" {
-		searchQuery := fmt.Sprintf("%%%s%%", filter.Search)
-		cte = cte.Where("c.name ILIKE ? OR f.name ILIKE ? OR wt.name ILIKE ? OR be.name ILIKE ?",
-			searchQuery,
-			searchQuery,
-			searchQuery,
-			searchQuery)
+	before, snapshotErr := c.circleSnapshot(tx, target.CircleID)
+	if snapshotErr != nil {
+		return snapshotErr
 	}
 
-	if appStage == "production" {
-		cte = cte.Where("c.published IS TRUE")
+	var circle entity.Circle
+	err := tx.First(&circle, target.CircleID).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
 	}
 
-	cte = cte.Select("c.id")
-	cte = cte.
-		Distinct("c.id").
-		Order("c.id desc").
-		Limit(filter.Limit).
-		Offset((filter.Page - 1) * filter.Limit)
+	if snapshot.Slug != circle.Slug {
+		taken, takenErr := c.isSlugTaken(tx, snapshot.Slug, circle.ID)
+		if takenErr != nil {
+			return takenErr
+		}
 
-	var circles []entity.CircleJoinedTables
-	joins := c.db.Clauses(exclause.NewWith("cte", cte)).Table("cte as cte")
-	joins = joins.
-		Joins("INNER JOIN circle c ON cte.id = c.id").
-		Joins("LEFT JOIN circle_fandom cf ON c.id = cf.circle_id").
-		Joins("LEFT JOIN fandom f ON f.id = cf.fandom_id").
-		Joins("LEFT JOIN circle_work_type cwt ON c.id = cwt.circle_id").
-		Joins("LEFT JOIN work_type wt ON wt.id = cwt.work_type_id").
-		Joins("LEFT JOIN product p ON c.id = p.circle_id").
-		Joins("LEFT JOIN user_bookmark ub ON c.id = ub.circle_id AND ub.user_id = COALESCE(?, ub.user_id)", userID).
-		Joins("LEFT JOIN event e ON c.event_id = e.id").
-		Joins("LEFT JOIN block_event be ON c.id = be.circle_id AND be.event_id = c.event_id")
+		if taken {
+			return domain.NewError(409, errors.New("SLUG_ALREADY_TAKEN"), nil)
+		}
+	}
 
-	joins = joins.
-		Select(`
-			c.id as id,
-			c.name as name,
-			c.slug as slug,
-			c.picture_url as picture_url,
-			c.url as url,
-			c.facebook_url as facebook_url,
-			c.twitter_url as twitter_url,
-			c.instagram_url as instagram_url,
-			c.verified as verified,
-			c.published as published,
-			c.created_at as created_at,
-			c.updated_at as updated_at,
-			c.deleted_at as deleted_at,
-			c.day as day,
-			c.event_id as event_id,
-			c.cover_picture_url as cover_picture_url,
-			c.rating as rating,
-
-			f.id as fandom_id,
-			f.name as fandom_name,
-			f.visible as fandom_visible,
-
-			wt.id as work_type_id,
-			wt.name as work_type_name,
-
-			e.name as event_name,
-			e.slug as event_slug,
-			e.started_at as event_started_at,
-			e.ended_at as event_ended_at,
-
-			be.id as block_event_id,
-			be.prefix as block_event_prefix,
-			be.postfix as block_event_postfix,
-			be.name as block_event_name,
+	circle.Name = snapshot.Name
+	circle.Slug = snapshot.Slug
+	circle.URL = snapshot.URL
+	circle.PictureURL = snapshot.PictureURL
+	circle.CoverPictureURL = snapshot.CoverPictureURL
+	circle.FacebookURL = snapshot.FacebookURL
+	circle.InstagramURL = snapshot.InstagramURL
+	circle.TwitterURL = snapshot.TwitterURL
+	circle.Description = snapshot.Description
+	circle.Rating = snapshot.Rating
+
+	err = tx.Where("circle_id = ?", circle.ID).Delete(&entity.CircleFandom{}).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
 
-			ub.created_at as bookmarked_at,
-			CASE WHEN ub.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS bookmarked
-		`).
-		Order("c.id desc")
+	if len(snapshot.FandomIDs) > 0 {
+		var circleFandoms []entity.CircleFandom
+		for _, fandomID := range snapshot.FandomIDs {
+			circleFandoms = append(circleFandoms, entity.CircleFandom{
+				CircleID: circle.ID,
+				FandomID: fandomID,
+			})
+		}
 
-	err := joins.Unscoped().Find(&circles).Error
+		err = tx.Create(&circleFandoms).Error
+		if err != nil {
+			return domain.NewError(500, err, nil)
+		}
+	}
 
+	err = tx.Where("circle_id = ?", circle.ID).Delete(&entity.CircleWorkType{}).Error
 	if err != nil {
-		return nil, domain.NewError(500, err, nil)
+		return domain.NewError(500, err, nil)
 	}
-	return circles, nil
-}
 
-// GetAllCirclesCount implements CircleRepo.
-func (c *CircleRepo) GetAllCirclesCount(filter *circle_dto.GetPaginatedCirclesFilter) (int, *domain.Error) {
-	appStage := os.Getenv("APP_STAGE")
-	var count int64
-	joins := c.db.
-		Select("count(DISTINCT c.id)").
-		Table("circle c").
-		Joins("LEFT JOIN circle_fandom cf ON c.id = cf.circle_id").
-		Joins("LEFT JOIN fandom f ON f.id = cf.fandom_id").
-		Joins("LEFT JOIN circle_work_type cwt ON c.id = cwt.circle_id").
-		Joins("LEFT JOIN work_type wt ON wt.id = cwt.work_type_id").
-		Joins("LEFT JOIN user_bookmark ub ON c.id = ub.circle_id AND ub.user_id = COALESCE(?, ub.user_id)", 0).
-		Joins("LEFT JOIN product p ON c.id = p.circle_id").
-		Joins("LEFT JOIN event e ON c.event_id = e.id").
-		Joins("LEFT JOIN block_event be ON c.id = be.circle_id AND be.event_id = c.event_id")
+	if len(snapshot.WorkTypeIDs) > 0 {
+		var circleWorkTypes []entity.CircleWorkType
+		for _, workTypeID := range snapshot.WorkTypeIDs {
+			circleWorkTypes = append(circleWorkTypes, entity.CircleWorkType{
+				CircleID:   circle.ID,
+				WorkTypeID: workTypeID,
+			})
+		}
 
-	if filter.Search != "" {
-		searchQuery := fmt.Sprintf("%%%s%%", filter.Search)
-		joins = joins.Where("c.name ILIKE ? OR f.name ILIKE ? OR wt.name ILIKE ? OR be.name ILIKE ?",
-			searchQuery,
-			searchQuery,
-			searchQuery,
-			searchQuery)
+		err = tx.Create(&circleWorkTypes).Error
+		if err != nil {
+			return domain.NewError(500, err, nil)
+		}
 	}
 
-	if len(filter.Rating) > 0 {
-		joins = joins.Where("c.rating IN (?)", filter.Rating)
-	}
-	if filter.Event != "" {
-		joins = joins.Where("e.slug = ?", filter.Event)
+	historyErr := c.saveSlugHistory(tx, &circle)
+	if historyErr != nil {
+		return historyErr
 	}
 
-	if len(filter.FandomIDs) > 0 {
-		joins = joins.Where("f.id in (?)", filter.FandomIDs)
+	err = tx.Omit(moderatedColumns...).Save(&circle).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return domain.NewError(409, errors.New("SLUG_ALREADY_TAKEN"), nil)
+		}
+		return domain.NewError(500, err, nil)
 	}
 
-	if len(filter.WorkTypeIDs) > 0 {
-		joins = joins.Where("wt.id in (?)", filter.WorkTypeIDs)
+	if err := c.saveCircleReferences(tx, &circle); err != nil {
+		return err
 	}
 
-	if filter.Day != nil {
-		joins = joins.Where("c.day = ?", filter.Day)
+	return c.recordCircleRevision(tx, userID, circle.ID, entity.RevisionRestore, before, &target.ID)
+}
+
+// restoreProduct brings a product back to the state saved in target, a
+// deleted product is recreated and a product created by target is deleted.
+func (c *CircleRepo) restoreProduct(tx *gorm.DB, userID int, target *entity.CircleRevision, limits *entity.Plan) *domain.Error {
+	var product entity.Product
+	err := tx.Unscoped().Scopes(product_module.WithChildren).Where("id = ? AND circle_id = ?", target.EntityID, target.CircleID).First(&product).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.NewError(404, errors.New("PRODUCT_NOT_FOUND"), nil)
+		}
+		return domain.NewError(500, err, nil)
 	}
 
-	if appStage == "production" {
-		joins = joins.Where("c.published IS TRUE")
+	var before *revision.ProductSnapshot
+	if !product.DeletedAt.Valid {
+		before = revision.NewProductSnapshot(&product)
 	}
 
-	err := joins.
-		Where("c.deleted_at is null and c.verified IS TRUE").
-		Count(&count).Error
+	var after *revision.ProductSnapshot
+	if target.After == nil {
+		if before != nil {
+			err = tx.Delete(&product).Error
+			if err != nil {
+				return domain.NewError(500, err, nil)
+			}
+		}
+	} else {
+		var snapshot revision.ProductSnapshot
+		if err := revision.Decode(target.After, &snapshot); err != nil {
+			return err
+		}
 
-	if err != nil {
-		return 0, domain.NewError(500, err, nil)
+		if before == nil {
+			var count int64
+			err = tx.Model(&entity.Product{}).Where("circle_id = ?", target.CircleID).Count(&count).Error
+			if err != nil {
+				return domain.NewError(500, err, nil)
+			}
+
+			if err := plan.CheckLimit(plan.ResourceProduct, int(count)+1, limits.MaxProducts); err != nil {
+				return err
+			}
+		}
+
+		product.Name = snapshot.Name
+		product.ImageURL = snapshot.ImageURL
+		product.Price = snapshot.Price
+		product.Stock = snapshot.Stock
+		product.Description = snapshot.Description
+		// revisions recorded before products had a currency and status keep the current ones
+		if snapshot.Currency != "" {
+			product.Currency = snapshot.Currency
+		}
+		if snapshot.Status != "" {
+			product.Status = snapshot.Status
+		}
+		product.DeletedAt = gorm.DeletedAt{}
+
+		product.Images = nil
+		if snapshot.Images != nil {
+			product.Images = make([]entity.ProductImage, 0, len(snapshot.Images))
+			for _, url := range snapshot.Images {
+				product.Images = append(product.Images, entity.ProductImage{URL: url})
+			}
+		}
+
+		product.Variants = nil
+		if snapshot.Variants != nil {
+			product.Variants = make([]entity.ProductVariant, 0, len(snapshot.Variants))
+			for _, variant := range snapshot.Variants {
+				product.Variants = append(product.Variants, entity.ProductVariant{
+					ID:    variant.ID,
+					Name:  variant.Name,
+					Price: variant.Price,
+					Stock: variant.Stock,
+				})
+			}
+		}
+
+		err = tx.Unscoped().Omit(clause.Associations).Save(&product).Error
+		if err != nil {
+			return domain.NewError(500, err, nil)
+		}
+
+		if err := product_module.SaveProductChildren(tx, &product); err != nil {
+			return err
+		}
+
+		var restored entity.Product
+		err = tx.Scopes(product_module.WithChildren).First(&restored, product.ID).Error
+		if err != nil {
+			return domain.NewError(500, err, nil)
+		}
+
+		after = revision.NewProductSnapshot(&restored)
 	}
 
-	return int(count), nil
+	return revision.Record(tx, userID, &entity.CircleRevision{
+		CircleID:       target.CircleID,
+		EntityType:     entity.RevisionProduct,
+		EntityID:       product.ID,
+		Action:         entity.RevisionRestore,
+		RestoredFromID: &target.ID,
+	}, before, after)
 }
 
-// GetOneCircleByCircleID implements CircleRepo.
-func (c *CircleRepo) GetOneCircleByCircleID(id int) (*entity.Circle, *domain.Error) {
+// restoreEventBlock puts the attended event, day and block of the circle
+// back to the state saved in target.
+func (c *CircleRepo) restoreEventBlock(tx *gorm.DB, userID int, target *entity.CircleRevision) *domain.Error {
+	if target.After == nil {
+		return domain.NewError(400, errors.New("REVISION_NOT_RESTORABLE"), nil)
+	}
+
+	var snapshot revision.EventBlockSnapshot
+	if err := revision.Decode(target.After, &snapshot); err != nil {
+		return err
+	}
+
+	before, snapshotErr := c.eventBlockSnapshot(tx, target.CircleID)
+	if snapshotErr != nil {
+		return snapshotErr
+	}
+
 	var circle entity.Circle
-	err := c.db.First(&circle, id).Error
+	err := tx.First(&circle, target.CircleID).Error
 	if err != nil {
-		return nil, domain.NewError(500, err, nil)
+		return domain.NewError(500, err, nil)
 	}
 
-	return &circle, nil
+	err = tx.Table("block_event").Where("circle_id = ?", circle.ID).Unscoped().Delete(&entity.BlockEvent{}).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	if snapshot.EventID != nil && snapshot.Block != nil {
+		block, blockErr := c.transformBlockStringIntoBlockEvent(*snapshot.Block)
+		if blockErr != nil {
+			return blockErr
+		}
+
+		var count int64
+		err = tx.Model(&entity.BlockEvent{}).
+			Where("prefix = ? AND postfix = ? AND event_id = ?", block.Prefix, block.Postfix, *snapshot.EventID).
+			Count(&count).Error
+		if err != nil {
+			return domain.NewError(500, err, nil)
+		}
+
+		if count > 0 {
+			return domain.NewError(400, errors.New("BLOCK_ALREADY_EXIST"), nil)
+		}
+
+		block.CircleID = circle.ID
+		block.EventID = *snapshot.EventID
+
+		err = tx.Create(block).Error
+		if err != nil {
+			return domain.NewError(500, err, nil)
+		}
+	}
+
+	circle.EventID = snapshot.EventID
+	circle.Day = snapshot.Day
+
+	err = tx.Omit(moderatedColumns...).Save(&circle).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return c.recordEventBlockRevision(tx, userID, circle.ID, entity.RevisionRestore, before, &target.ID)
+}
+
+// IsSlugTaken implements CircleRepo.
+func (c *CircleRepo) IsSlugTaken(slug string, circleID int) (bool, *domain.Error) {
+	return c.isSlugTaken(c.db, slug, circleID)
+}
+
+func (c *CircleRepo) isSlugTaken(db *gorm.DB, slug string, circleID int) (bool, *domain.Error) {
+	var taken bool
+	err := db.Raw(`
+		SELECT EXISTS(SELECT 1 FROM circle WHERE slug = ? AND id <> ?)
+		OR EXISTS(SELECT 1 FROM circle_slug_history WHERE slug = ? AND circle_id <> ?)
+	`, slug, circleID, slug, circleID).Scan(&taken).Error
+	if err != nil {
+		return false, domain.NewError(500, err, nil)
+	}
+
+	return taken, nil
+}
+
+// GetCanonicalSlugByHistorySlug implements CircleRepo.
+func (c *CircleRepo) GetCanonicalSlugByHistorySlug(slug string) (string, *domain.Error) {
+	var slugs []string
+	err := c.db.
+		Table("circle_slug_history csh").
+		Joins("JOIN circle c ON c.id = csh.circle_id").
+		Where("csh.slug = ? AND c.deleted_at IS NULL", slug).
+		Limit(1).
+		Pluck("c.slug", &slugs).Error
+	if err != nil {
+		return "", domain.NewError(500, err, nil)
+	}
+
+	if len(slugs) == 0 {
+		return "", domain.NewError(500, gorm.ErrRecordNotFound, nil)
+	}
+
+	return slugs[0], nil
+}
+
+func NewCircleRepo(
+	db *gorm.DB,
+) *CircleRepo {
+	return &CircleRepo{
+		db: db,
+	}
+}
+
+// circleListColumns are the circle columns needed by catalog grids. The
+// description is left out because it can hold a large html document.
+const circleListColumns = `
+	c.id,
+	c.name,
+	c.slug,
+	c.picture_url,
+	c.url,
+	c.facebook_url,
+	c.twitter_url,
+	c.instagram_url,
+	c.verified,
+	c.published,
+	c.created_at,
+	c.updated_at,
+	c.deleted_at,
+	c.day,
+	c.event_id,
+	c.cover_picture_url,
+	c.rating`
+
+// circleRelationColumns aggregates the circle relations into json columns so
+// every circle is returned as exactly one row. Timestamps are cast to
+// timestamptz so they are encoded as RFC3339.
+var circleRelationColumns = `
+	COALESCE((
+		SELECT json_agg(json_build_object(
+			'id', f.id,
+			'name', f.name,
+			'visible', f.visible,
+			'created_at', f.created_at AT TIME ZONE 'UTC',
+			'updated_at', f.updated_at AT TIME ZONE 'UTC'
+		) ORDER BY f.id)
+		FROM circle_fandom cf
+		JOIN fandom f ON f.id = cf.fandom_id
+		WHERE cf.circle_id = c.id
+	), '[]') AS fandom,
+
+	COALESCE((
+		SELECT json_agg(json_build_object(
+			'id', wt.id,
+			'name', wt.name,
+			'created_at', wt.created_at AT TIME ZONE 'UTC',
+			'updated_at', wt.updated_at AT TIME ZONE 'UTC'
+		) ORDER BY wt.id)
+		FROM circle_work_type cwt
+		JOIN work_type wt ON wt.id = cwt.work_type_id
+		WHERE cwt.circle_id = c.id
+	), '[]') AS work_type,
+
+	CASE WHEN e.id IS NULL THEN NULL ELSE json_build_object(
+		'id', e.id,
+		'name', e.name,
+		'slug', e.slug,
+		'started_at', e.started_at AT TIME ZONE 'UTC',
+		'ended_at', e.ended_at AT TIME ZONE 'UTC'
+	) END AS event,
+
+	CASE WHEN be.id IS NULL THEN NULL ELSE json_build_object(
+		'id', be.id,
+		'event_id', be.event_id,
+		'circle_id', be.circle_id,
+		'prefix', be.prefix,
+		'postfix', be.postfix,
+		'name', be.name
+	) END AS block_event,
+
+	` + upload.PlaceholderOf("c.picture_url") + ` AS picture_placeholder,
+	` + upload.PlaceholderOf("c.cover_picture_url") + ` AS cover_picture_placeholder`
+
+// joinEventAndBlock joins the attended event and its block, both are at most
+// one row per circle so they never multiply the result.
+func (c *CircleRepo) joinEventAndBlock(db *gorm.DB) *gorm.DB {
+	return db.
+		Joins("LEFT JOIN event e ON c.event_id = e.id").
+		Joins("LEFT JOIN block_event be ON c.id = be.circle_id AND be.event_id = c.event_id")
+}
+
+// filterCircles applies the pagination filter to a query built on `circle c`
+// joined by joinEventAndBlock.
+func (c *CircleRepo) filterCircles(db *gorm.DB, filter *circle_dto.GetPaginatedCirclesFilter) *gorm.DB {
+	if len(filter.Rating) > 0 {
+		db = db.Where("c.rating IN (?)", filter.Rating)
+	}
+
+	if filter.Event != "" {
+		db = db.Where("e.slug = ?", filter.Event)
+	}
+
+	if len(filter.FandomIDs) > 0 {
+		db = db.Where("EXISTS (SELECT 1 FROM circle_fandom cf WHERE cf.circle_id = c.id AND cf.fandom_id IN (?))", filter.FandomIDs)
+	}
+
+	if len(filter.WorkTypeIDs) > 0 {
+		db = db.Where("EXISTS (SELECT 1 FROM circle_work_type cwt WHERE cwt.circle_id = c.id AND cwt.work_type_id IN (?))", filter.WorkTypeIDs)
+	}
+
+	if filter.Day != nil {
+		db = db.Where("c.day = ?", filter.Day)
+	}
+
+	if filter.PriceMin != nil || filter.PriceMax != nil {
+		// a variant with its own price replaces the price of its product
+		products := c.db.
+			Table("product p").
+			Select("1").
+			Joins("LEFT JOIN product_variant pv ON pv.product_id = p.id AND pv.deleted_at IS NULL").
+			Where("p.circle_id = c.id AND p.deleted_at IS NULL AND p.hidden IS FALSE AND COALESCE(pv.price, p.price) IS NOT NULL").
+			Where("p.status <> ?", entity.ProductSoldOut).
+			Where("(pv.stock IS NULL OR pv.stock > 0)")
+
+		if filter.PriceMin != nil {
+			products = products.Where("COALESCE(pv.price, p.price) >= ?", *filter.PriceMin)
+		}
+
+		if filter.PriceMax != nil {
+			products = products.Where("COALESCE(pv.price, p.price) <= ?", *filter.PriceMax)
+		}
+
+		if filter.Currency != "" {
+			products = products.Where("p.currency = ?", strings.ToUpper(filter.Currency))
+		}
+
+		db = db.Where("EXISTS (?)", products)
+	}
+
+	if filter.Search != "" {
+		searchQuery := fmt.Sprintf("%%%s%%", filter.Search)
+		db = db.Where(`(
+			c.name ILIKE ?
+			OR be.name ILIKE ?
+			OR EXISTS (SELECT 1 FROM circle_fandom cf JOIN fandom f ON f.id = cf.fandom_id WHERE cf.circle_id = c.id AND f.name ILIKE ?)
+			OR EXISTS (SELECT 1 FROM circle_work_type cwt JOIN work_type wt ON wt.id = cwt.work_type_id WHERE cwt.circle_id = c.id AND wt.name ILIKE ?)
+		)`,
+			searchQuery,
+			searchQuery,
+			searchQuery,
+			searchQuery)
+	}
+
+	return db
+}
+
+// visibleTo leaves out the circles hidden by moderators, unless viewerID is
+// the admin or a user of the circle.
+func visibleTo(viewerID int) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if middlewares.IsAdmin(viewerID) {
+			return db
+		}
+
+		return db.Where(`(c.hidden IS FALSE OR EXISTS (SELECT 1 FROM "user" u WHERE u.id = ? AND u.circle_id = c.id))`, viewerID)
+	}
+}
+
+// GetOneCircleJoinTablesByCircleSlug returns the circle at slug, a hidden
+// circle is only found by userID when visibleTo allows it.
+func (c *CircleRepo) GetOneCircleJoinTablesByCircleSlug(slug string, userID int) (*entity.CircleJoinedTables, *domain.Error) {
+	var row entity.CircleJoinedTables
+
+	query := c.db.
+		Table("circle c").
+		Select(`c.*,`+circleRelationColumns+`,
+			ub.created_at as bookmarked_at,
+			CASE WHEN ub.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS bookmarked
+		`).
+		Joins("LEFT JOIN user_bookmark ub ON c.id = ub.circle_id AND ub.user_id = ?", userID)
+
+	err := c.joinEventAndBlock(query).
+		Where("c.deleted_at is null AND c.slug = ?", slug).
+		Scopes(visibleTo(userID)).
+		Take(&row).Error
+
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &row, nil
+}
+
+// filterBookmarkCollection narrows bookmarks to filter.CollectionID, 0 means
+// bookmarks outside any collection.
+func filterBookmarkCollection(filter *circle_dto.GetPaginatedCirclesFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.CollectionID == nil {
+			return db
+		}
+
+		if *filter.CollectionID == 0 {
+			return db.Where("ub.collection_id IS NULL")
+		}
+
+		return db.Where("ub.collection_id = ?", *filter.CollectionID)
+	}
+}
+
+// GetAllBookmarkedCirclesByUserID returns every circle userID bookmarked that
+// matches filter, ignoring its page and limit, with the names of their products.
+func (c *CircleRepo) GetAllBookmarkedCirclesByUserID(userID int, filter *circle_dto.GetPaginatedCirclesFilter) ([]entity.CircleJoinedTables, *domain.Error) {
+	query := c.db.
+		Table("circle c").
+		Select(circleListColumns+`,`+circleRelationColumns+`,
+			ub.created_at as bookmarked_at,
+			true as bookmarked,
+			ub.collection_id as bookmark_collection_id,
+			ub.note as bookmark_note,
+			ub.priority as bookmark_priority,
+			ub.position as bookmark_position,
+			COALESCE((
+				SELECT json_agg(p.name ORDER BY p.id)
+				FROM product p
+				WHERE p.circle_id = c.id AND p.deleted_at IS NULL AND p.hidden IS FALSE
+			), '[]') AS product_names
+		`).
+		Joins("JOIN user_bookmark ub ON c.id = ub.circle_id AND ub.user_id = ?", userID)
+
+	query = c.joinEventAndBlock(query).
+		Where("c.deleted_at is null").
+		Scopes(visibleTo(userID), filterBookmarkCollection(filter))
+
+	query = c.filterCircles(query, filter)
+
+	order := "ub.created_at desc"
+	if filter.CollectionID != nil {
+		order = "ub.position asc, ub.created_at desc"
+	}
+
+	var circles []entity.CircleJoinedTables
+	err := query.Order(order).Find(&circles).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+	return circles, nil
+}
+
+// GetBookmarkedCirclesByEventDay returns every circle userID bookmarked that attends
+// the event on day, circles attending both days or with no day are included.
+func (c *CircleRepo) GetBookmarkedCirclesByEventDay(userID int, eventID int, day entity.Day, filter *circle_dto.GetPaginatedCirclesFilter) ([]entity.CircleJoinedTables, *domain.Error) {
+	query := c.db.
+		Table("circle c").
+		Select(circleListColumns+`,`+circleRelationColumns+`,
+			ub.created_at as bookmarked_at,
+			true as bookmarked,
+			ub.collection_id as bookmark_collection_id,
+			ub.note as bookmark_note,
+			ub.priority as bookmark_priority,
+			ub.position as bookmark_position,
+			ubs.status as bookmark_status
+		`).
+		Joins("JOIN user_bookmark ub ON c.id = ub.circle_id AND ub.user_id = ?", userID).
+		Joins("LEFT JOIN user_bookmark_status ubs ON ubs.user_id = ub.user_id AND ubs.circle_id = ub.circle_id AND ubs.event_id = ?", eventID)
+
+	var circles []entity.CircleJoinedTables
+	err := c.joinEventAndBlock(query).
+		Where("c.deleted_at is null").
+		Where("c.event_id = ?", eventID).
+		Where("(c.day IS NULL OR c.day IN ?)", []entity.Day{day, entity.Both}).
+		Scopes(visibleTo(userID), filterBookmarkCollection(filter)).
+		Order("c.id asc").
+		Find(&circles).Error
+
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+	return circles, nil
+}
+
+// GetAllBookmarkedCircleCount counts the circles userID bookmarked that
+// viewerID can see.
+func (c *CircleRepo) GetAllBookmarkedCircleCount(userID int, viewerID int, filter *circle_dto.GetPaginatedCirclesFilter) (int, *domain.Error) {
+	var count int64
+
+	err := c.db.
+		Table("circle c").
+		Joins("JOIN user_bookmark ub ON c.id = ub.circle_id AND ub.user_id = ?", userID).
+		Where("c.deleted_at is null").
+		Scopes(visibleTo(viewerID), filterBookmarkCollection(filter)).
+		Count(&count).Error
+
+	if err != nil {
+		return 0, domain.NewError(500, err, nil)
+	}
+
+	return int(count), nil
+}
+
+// GetPaginatedBookmarkedCirclesByUserID returns a page of the circles userID
+// bookmarked that viewerID can see.
+func (c *CircleRepo) GetPaginatedBookmarkedCirclesByUserID(userID int, viewerID int, filter *circle_dto.GetPaginatedCirclesFilter) ([]entity.CircleJoinedTables, *domain.Error) {
+	query := c.db.
+		Table("circle c").
+		Select(circleListColumns+`,`+circleRelationColumns+`,
+			ub.created_at as bookmarked_at,
+			true as bookmarked,
+			ub.collection_id as bookmark_collection_id,
+			ub.note as bookmark_note,
+			ub.priority as bookmark_priority,
+			ub.position as bookmark_position
+		`).
+		Joins("JOIN user_bookmark ub ON c.id = ub.circle_id AND ub.user_id = ?", userID)
+
+	// positions are only meaningful inside a single collection
+	order := "ub.created_at desc"
+	if filter.CollectionID != nil {
+		order = "ub.position asc, ub.created_at desc"
+	}
+
+	var circles []entity.CircleJoinedTables
+	err := c.joinEventAndBlock(query).
+		Where("c.deleted_at is null").
+		Scopes(visibleTo(viewerID), filterBookmarkCollection(filter)).
+		Order(order).
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&circles).Error
+
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+	return circles, nil
+}
+
+// GetPaginatedCircles implements CircleRepo.
+func (c *CircleRepo) GetPaginatedCircles(filter *circle_dto.GetPaginatedCirclesFilter, userID int) ([]entity.CircleJoinedTables, *domain.Error) {
+	appStage := os.Getenv("APP_STAGE")
+
+	query := c.db.
+		Table("circle c").
+		Select(circleListColumns+`,`+circleRelationColumns+`,
+			ub.created_at as bookmarked_at,
+			CASE WHEN ub.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS bookmarked
+		`).
+		Joins("LEFT JOIN user_bookmark ub ON c.id = ub.circle_id AND ub.user_id = ?", userID)
+
+	query = c.joinEventAndBlock(query).
+		Where("c.deleted_at IS NULL").
+		Where("c.verified IS TRUE").
+		Where("c.hidden IS FALSE")
+
+	query = c.filterCircles(query, filter)
+
+	if appStage == "production" {
+		query = query.Where("c.published IS TRUE")
+	}
+
+	var circles []entity.CircleJoinedTables
+	err := query.
+		Order("c.id desc").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&circles).Error
+
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+	return circles, nil
+}
+
+// GetAllCirclesCount implements CircleRepo.
+func (c *CircleRepo) GetAllCirclesCount(filter *circle_dto.GetPaginatedCirclesFilter) (int, *domain.Error) {
+	appStage := os.Getenv("APP_STAGE")
+
+	query := c.joinEventAndBlock(c.db.Table("circle c")).
+		Where("c.deleted_at is null and c.verified IS TRUE and c.hidden IS FALSE")
+
+	query = c.filterCircles(query, filter)
+
+	if appStage == "production" {
+		query = query.Where("c.published IS TRUE")
+	}
+
+	var count int64
+	err := query.Count(&count).Error
+
+	if err != nil {
+		return 0, domain.NewError(500, err, nil)
+	}
+
+	return int(count), nil
+}
+
+// GetOneCircleByCircleID implements CircleRepo.
+func (c *CircleRepo) GetOneCircleByCircleID(id int) (*entity.Circle, *domain.Error) {
+	var circle entity.Circle
+	err := c.db.First(&circle, id).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &circle, nil
+}
+
+// GetOneDraftByCircleID implements CircleRepo.
+func (c *CircleRepo) GetOneDraftByCircleID(circleID int) (*entity.CircleDraft, *domain.Error) {
+	var draft entity.CircleDraft
+	err := c.db.Where("circle_id = ?", circleID).First(&draft).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &draft, nil
+}
+
+// GetOneDraftByPreviewToken implements CircleRepo.
+func (c *CircleRepo) GetOneDraftByPreviewToken(token string) (*entity.CircleDraft, *domain.Error) {
+	var draft entity.CircleDraft
+	err := c.db.Where("preview_token = ?", token).First(&draft).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &draft, nil
+}
+
+// UpsertOneDraft saves the draft with the references to the uploads of its
+// payload.
+func (c *CircleRepo) UpsertOneDraft(draft *entity.CircleDraft) (*entity.CircleDraft, *domain.Error) {
+	tx := c.db.Begin()
+	if tx.Error != nil {
+		return nil, domain.NewError(500, tx.Error, nil)
+	}
+
+	err := tx.Save(draft).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	payload, err := json.Marshal(draft.Payload)
+	if err != nil {
+		tx.Rollback()
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	referenceErr := upload.SaveReferences(tx, entity.UploadReferenceCircleDraft, draft.CircleID, string(payload))
+	if referenceErr != nil {
+		tx.Rollback()
+		return nil, referenceErr
+	}
+
+	tx.Commit()
+
+	return draft, nil
+}
+
+// deleteDraftReferences forgets the uploads of the draft of a circle once
+// the draft is gone.
+func (c *CircleRepo) deleteDraftReferences(tx *gorm.DB, circleID int) *domain.Error {
+	return upload.SaveReferences(tx, entity.UploadReferenceCircleDraft, circleID)
+}
+
+// DeleteOneDraftByCircleID implements CircleRepo.
+func (c *CircleRepo) DeleteOneDraftByCircleID(circleID int) *domain.Error {
+	tx := c.db.Begin()
+	if tx.Error != nil {
+		return domain.NewError(500, tx.Error, nil)
+	}
+
+	err := tx.Where("circle_id = ?", circleID).Delete(&entity.CircleDraft{}).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	referenceErr := c.deleteDraftReferences(tx, circleID)
+	if referenceErr != nil {
+		tx.Rollback()
+		return referenceErr
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+// GetDueDraftCircleIDs implements CircleRepo.
+func (c *CircleRepo) GetDueDraftCircleIDs(now time.Time) ([]int, *domain.Error) {
+	var circleIDs []int
+	err := c.db.
+		Model(&entity.CircleDraft{}).
+		Where("publish_at IS NOT NULL AND publish_at <= ?", now).
+		Order("publish_at").
+		Pluck("circle_id", &circleIDs).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return circleIDs, nil
+}
+
+// ClaimDueDraftByCircleID removes a due draft and returns it, so only one
+// worker publishes it when the scheduler runs on several instances. nil is
+// returned when the draft is gone or not due anymore.
+func (c *CircleRepo) ClaimDueDraftByCircleID(circleID int, now time.Time) (*entity.CircleDraft, *domain.Error) {
+	tx := c.db.Begin()
+	if tx.Error != nil {
+		return nil, domain.NewError(500, tx.Error, nil)
+	}
+
+	var drafts []entity.CircleDraft
+	err := tx.
+		Clauses(clause.Returning{}).
+		Where("circle_id = ? AND publish_at IS NOT NULL AND publish_at <= ?", circleID, now).
+		Delete(&drafts).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	if len(drafts) == 0 {
+		tx.Rollback()
+		return nil, nil
+	}
+
+	// the published circle and its revision reference the uploads from now on
+	referenceErr := c.deleteDraftReferences(tx, circleID)
+	if referenceErr != nil {
+		tx.Rollback()
+		return nil, referenceErr
+	}
+
+	tx.Commit()
+
+	return &drafts[0], nil
+}
+
+// GetOneEventBySlug implements CircleRepo.
+func (c *CircleRepo) GetOneEventBySlug(slug string) (*entity.Event, *domain.Error) {
+	var event entity.Event
+	err := c.db.Where("slug = ?", slug).First(&event).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &event, nil
+}
+
+// GetFandomsByIDs implements CircleRepo.
+func (c *CircleRepo) GetFandomsByIDs(ids []int) ([]entity.Fandom, *domain.Error) {
+	fandoms := []entity.Fandom{}
+	if len(ids) == 0 {
+		return fandoms, nil
+	}
+
+	err := c.db.Where("id IN ?", ids).Order("id").Find(&fandoms).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return fandoms, nil
+}
+
+// GetWorkTypesByIDs implements CircleRepo.
+func (c *CircleRepo) GetWorkTypesByIDs(ids []int) ([]entity.WorkType, *domain.Error) {
+	workTypes := []entity.WorkType{}
+	if len(ids) == 0 {
+		return workTypes, nil
+	}
+
+	err := c.db.Where("id IN ?", ids).Order("id").Find(&workTypes).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return workTypes, nil
+}
+
+// SoftDeleteCircle implements CircleRepo.
+func (c *CircleRepo) SoftDeleteCircle(userID int, circle *entity.Circle) *domain.Error {
+	tx := c.db.Begin()
+	if tx.Error != nil {
+		return domain.NewError(500, tx.Error, nil)
+	}
+
+	before, snapshotErr := c.eventBlockSnapshot(tx, circle.ID)
+	if snapshotErr != nil {
+		tx.Rollback()
+		return snapshotErr
+	}
+
+	// release the block so another circle can claim it
+	err := tx.Table("block_event").Where("circle_id = ?", circle.ID).Unscoped().Delete(&entity.BlockEvent{}).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	revisionErr := c.recordEventBlockRevision(tx, userID, circle.ID, entity.RevisionDelete, before, nil)
+	if revisionErr != nil {
+		tx.Rollback()
+		return revisionErr
+	}
+
+	err = tx.Model(&entity.User{}).Where("circle_id = ?", circle.ID).Update("circle_id", nil).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	err = tx.Where("circle_id = ?", circle.ID).Delete(&entity.CircleDraft{}).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	referenceErr := c.deleteDraftReferences(tx, circle.ID)
+	if referenceErr != nil {
+		tx.Rollback()
+		return referenceErr
+	}
+
+	err = tx.Model(circle).Update("deleted_by", userID).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	err = tx.Delete(circle).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+// GetPaginatedDeletedCircles implements CircleRepo.
+func (c *CircleRepo) GetPaginatedDeletedCircles(filter *circle_dto.GetPaginatedDeletedCirclesFilter) ([]entity.Circle, *domain.Error) {
+	circles := []entity.Circle{}
+	err := c.db.
+		Unscoped().
+		Where("deleted_at IS NOT NULL").
+		Order("deleted_at DESC").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&circles).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return circles, nil
+}
+
+// GetAllDeletedCirclesCount implements CircleRepo.
+func (c *CircleRepo) GetAllDeletedCirclesCount() (int, *domain.Error) {
+	var count int64
+	err := c.db.
+		Unscoped().
+		Model(&entity.Circle{}).
+		Where("deleted_at IS NOT NULL").
+		Count(&count).Error
+	if err != nil {
+		return 0, domain.NewError(500, err, nil)
+	}
+
+	return int(count), nil
+}
+
+// GetOneDeletedCircleByCircleID implements CircleRepo.
+func (c *CircleRepo) GetOneDeletedCircleByCircleID(id int) (*entity.Circle, *domain.Error) {
+	var circle entity.Circle
+	err := c.db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&circle).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &circle, nil
+}
+
+// GetLastDeletedCircleByUserID implements CircleRepo.
+func (c *CircleRepo) GetLastDeletedCircleByUserID(userID int) (*entity.Circle, *domain.Error) {
+	var circle entity.Circle
+	err := c.db.
+		Unscoped().
+		Where("deleted_by = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC").
+		First(&circle).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &circle, nil
+}
+
+// RestoreDeletedCircle brings a soft deleted circle back and links it to the
+// user who deleted it, unless that user has made another circle since.
+func (c *CircleRepo) RestoreDeletedCircle(circle *entity.Circle) *domain.Error {
+	tx := c.db.Begin()
+	if tx.Error != nil {
+		return domain.NewError(500, tx.Error, nil)
+	}
+
+	err := tx.Unscoped().
+		Model(&entity.Circle{}).
+		Where("id = ?", circle.ID).
+		Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"deleted_by": nil,
+		}).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	if circle.DeletedBy != nil {
+		err = tx.Model(&entity.User{}).
+			Where("id = ? AND circle_id IS NULL", *circle.DeletedBy).
+			Update("circle_id", circle.ID).Error
+		if err != nil {
+			tx.Rollback()
+			return domain.NewError(500, err, nil)
+		}
+	}
+
+	tx.Commit()
+
+	circle.DeletedAt = gorm.DeletedAt{}
+	circle.DeletedBy = nil
+
+	return nil
+}
+
+// PurgeCircle permanently deletes a circle and everything that belongs to it.
+func (c *CircleRepo) PurgeCircle(circleID int) *domain.Error {
+	tx := c.db.Begin()
+	if tx.Error != nil {
+		return domain.NewError(500, tx.Error, nil)
+	}
+
+	referenceErr := upload.DeleteCircleReferences(tx, circleID)
+	if referenceErr != nil {
+		tx.Rollback()
+		return referenceErr
+	}
+
+	deletionErr := bookmark.SaveBookmarkDeletions(tx, circleID, 0)
+	if deletionErr != nil {
+		tx.Rollback()
+		return deletionErr
+	}
+
+	// most of these cascade in postgres already, deleting them here keeps the
+	// purge explicit and covers block_event which is only set to null.
+	tables := []interface{}{
+		&entity.BlockEvent{},
+		&entity.Product{},
+		&entity.UserBookmark{},
+		&entity.UserUpvote{},
+		&entity.Report{},
+		&entity.Referral{},
+		&entity.CircleFandom{},
+		&entity.CircleWorkType{},
+	}
+
+	for _, table := range tables {
+		err := tx.Unscoped().Where("circle_id = ?", circleID).Delete(table).Error
+		if err != nil {
+			tx.Rollback()
+			return domain.NewError(500, err, nil)
+		}
+	}
+
+	err := tx.Model(&entity.User{}).Where("circle_id = ?", circleID).Update("circle_id", nil).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	err = tx.Unscoped().Delete(&entity.Circle{}, circleID).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+// GetDeletedCircleIDsBefore implements CircleRepo.
+func (c *CircleRepo) GetDeletedCircleIDsBefore(before time.Time) ([]int, *domain.Error) {
+	var ids []int
+	err := c.db.
+		Unscoped().
+		Model(&entity.Circle{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return ids, nil
 }
@@ -0,0 +1,9 @@
+package revision_dto
+
+import "catalog-be/internal/entity"
+
+type GetPaginatedRevisionsFilter struct {
+	Page       int                       `query:"page" validate:"required,min=1"`
+	Limit      int                       `query:"limit" validate:"required,min=1,max=20"`
+	EntityType entity.RevisionEntityType `query:"entity_type" validate:"omitempty,oneof=circle product event_block"`
+}
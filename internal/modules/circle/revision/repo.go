@@ -0,0 +1,67 @@
+package revision
+
+import (
+	"catalog-be/internal/domain"
+	"catalog-be/internal/entity"
+	revision_dto "catalog-be/internal/modules/circle/revision/dto"
+
+	"gorm.io/gorm"
+)
+
+type RevisionRepo struct {
+	db *gorm.DB
+}
+
+func (r *RevisionRepo) filterRevisions(circleID int, filter *revision_dto.GetPaginatedRevisionsFilter) *gorm.DB {
+	query := r.db.Model(&entity.CircleRevision{}).Where("circle_id = ?", circleID)
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+
+	return query
+}
+
+// GetPaginatedRevisionsByCircleID implements RevisionRepo.
+func (r *RevisionRepo) GetPaginatedRevisionsByCircleID(circleID int, filter *revision_dto.GetPaginatedRevisionsFilter) ([]entity.CircleRevision, *domain.Error) {
+	var revisions []entity.CircleRevision
+	err := r.filterRevisions(circleID, filter).
+		Order("created_at DESC, id DESC").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&revisions).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return revisions, nil
+}
+
+// CountRevisionsByCircleID implements RevisionRepo.
+func (r *RevisionRepo) CountRevisionsByCircleID(circleID int, filter *revision_dto.GetPaginatedRevisionsFilter) (int, *domain.Error) {
+	var count int64
+	err := r.filterRevisions(circleID, filter).Count(&count).Error
+	if err != nil {
+		return 0, domain.NewError(500, err, nil)
+	}
+
+	return int(count), nil
+}
+
+// GetOneRevisionByID implements RevisionRepo.
+func (r *RevisionRepo) GetOneRevisionByID(id int) (*entity.CircleRevision, *domain.Error) {
+	var revision entity.CircleRevision
+	err := r.db.First(&revision, id).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &revision, nil
+}
+
+func NewRevisionRepo(
+	db *gorm.DB,
+) *RevisionRepo {
+	return &RevisionRepo{
+		db: db,
+	}
+}
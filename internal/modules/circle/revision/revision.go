@@ -0,0 +1,215 @@
+package revision
+
+import (
+	"catalog-be/internal/domain"
+	"catalog-be/internal/entity"
+	"catalog-be/internal/modules/upload"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// CircleSnapshot is the part of a circle an owner can edit and restore.
+type CircleSnapshot struct {
+	Name            string  `json:"name"`
+	Slug            string  `json:"slug"`
+	URL             *string `json:"url"`
+	PictureURL      *string `json:"picture_url"`
+	CoverPictureURL *string `json:"cover_picture_url"`
+	FacebookURL     *string `json:"facebook_url"`
+	InstagramURL    *string `json:"instagram_url"`
+	TwitterURL      *string `json:"twitter_url"`
+	Description     *string `json:"description"`
+	Rating          *string `json:"rating"`
+	FandomIDs       []int   `json:"fandom_ids"`
+	WorkTypeIDs     []int   `json:"work_type_ids"`
+}
+
+type ProductSnapshot struct {
+	Name        string               `json:"name"`
+	ImageURL    string               `json:"image_url"`
+	Price       *int64               `json:"price"`
+	Currency    string               `json:"currency"`
+	Stock       *int                 `json:"stock"`
+	Status      entity.ProductStatus `json:"status"`
+	Description *string              `json:"description"`
+	// Images and Variants are nil in revisions recorded before products had them.
+	Images   []string                 `json:"images"`
+	Variants []ProductVariantSnapshot `json:"variants"`
+}
+
+type ProductVariantSnapshot struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Price *int64 `json:"price"`
+	Stock *int   `json:"stock"`
+}
+
+// EventBlockSnapshot is the event a circle attends, the day and its block name.
+type EventBlockSnapshot struct {
+	EventID *int        `json:"event_id"`
+	Day     *entity.Day `json:"day"`
+	Block   *string     `json:"block"`
+}
+
+func NewCircleSnapshot(circle *entity.Circle, fandomIDs []int, workTypeIDs []int) *CircleSnapshot {
+	if fandomIDs == nil {
+		fandomIDs = []int{}
+	}
+	if workTypeIDs == nil {
+		workTypeIDs = []int{}
+	}
+
+	return &CircleSnapshot{
+		Name:            circle.Name,
+		Slug:            circle.Slug,
+		URL:             circle.URL,
+		PictureURL:      circle.PictureURL,
+		CoverPictureURL: circle.CoverPictureURL,
+		FacebookURL:     circle.FacebookURL,
+		InstagramURL:    circle.InstagramURL,
+		TwitterURL:      circle.TwitterURL,
+		Description:     circle.Description,
+		Rating:          circle.Rating,
+		FandomIDs:       fandomIDs,
+		WorkTypeIDs:     workTypeIDs,
+	}
+}
+
+func NewProductSnapshot(product *entity.Product) *ProductSnapshot {
+	if product == nil {
+		return nil
+	}
+
+	images := make([]string, 0, len(product.Images))
+	for _, image := range product.Images {
+		images = append(images, image.URL)
+	}
+
+	variants := make([]ProductVariantSnapshot, 0, len(product.Variants))
+	for _, variant := range product.Variants {
+		variants = append(variants, ProductVariantSnapshot{
+			ID:    variant.ID,
+			Name:  variant.Name,
+			Price: variant.Price,
+			Stock: variant.Stock,
+		})
+	}
+
+	return &ProductSnapshot{
+		Name:        product.Name,
+		ImageURL:    product.ImageURL,
+		Price:       product.Price,
+		Currency:    product.Currency,
+		Stock:       product.Stock,
+		Status:      product.Status,
+		Description: product.Description,
+		Images:      images,
+		Variants:    variants,
+	}
+}
+
+// Decode reads a snapshot stored in a revision back into out.
+func Decode(snapshot map[string]interface{}, out interface{}) *domain.Error {
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	err = json.Unmarshal(raw, out)
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+func toMap(snapshot interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	err = json.Unmarshal(raw, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func diff(before map[string]interface{}, after map[string]interface{}) []entity.RevisionChange {
+	fields := make(map[string]bool)
+	for field := range before {
+		fields[field] = true
+	}
+	for field := range after {
+		fields[field] = true
+	}
+
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	changes := []entity.RevisionChange{}
+	for _, field := range names {
+		if reflect.DeepEqual(before[field], after[field]) {
+			continue
+		}
+
+		changes = append(changes, entity.RevisionChange{
+			Field: field,
+			Old:   before[field],
+			New:   after[field],
+		})
+	}
+
+	return changes
+}
+
+// Record writes a revision made by userID and the references to the uploads of
+// its snapshots inside tx, so they are committed or rolled back together with
+// the change it describes. Nothing is written when before and after are the
+// same. before or after is nil when the entity is created or deleted.
+func Record(tx *gorm.DB, userID int, revision *entity.CircleRevision, before interface{}, after interface{}) *domain.Error {
+	beforeMap, err := toMap(before)
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	afterMap, err := toMap(after)
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	changes := diff(beforeMap, afterMap)
+	if len(changes) == 0 && (beforeMap == nil) == (afterMap == nil) {
+		return nil
+	}
+
+	if userID != 0 {
+		revision.UserID = &userID
+	}
+	revision.Before = beforeMap
+	revision.After = afterMap
+	revision.Diff = changes
+
+	err = tx.Create(revision).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	// the pictures of a revision stay referenced so restoring it never points
+	// to a swept upload
+	snapshots, err := json.Marshal([]map[string]interface{}{beforeMap, afterMap})
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return upload.SaveReferences(tx, entity.UploadReferenceCircleRevision, revision.ID, string(snapshots))
+}
@@ -0,0 +1,63 @@
+package revision
+
+import (
+	"catalog-be/internal/database/factory"
+	"catalog-be/internal/domain"
+	"catalog-be/internal/dto"
+	"catalog-be/internal/entity"
+	revision_dto "catalog-be/internal/modules/circle/revision/dto"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type RevisionService struct {
+	repo *RevisionRepo
+}
+
+// GetPaginatedRevisionsByCircleID implements RevisionService.
+func (r *RevisionService) GetPaginatedRevisionsByCircleID(circleID int, filter *revision_dto.GetPaginatedRevisionsFilter) (*dto.Pagination[[]entity.CircleRevision], *domain.Error) {
+	revisions, err := r.repo.GetPaginatedRevisionsByCircleID(circleID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := r.repo.CountRevisionsByCircleID(circleID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if revisions == nil {
+		revisions = []entity.CircleRevision{}
+	}
+
+	metadata := factory.GetPaginationMetadata(count, filter.Page, filter.Limit)
+
+	return &dto.Pagination[[]entity.CircleRevision]{
+		Data:     revisions,
+		Metadata: *metadata,
+	}, nil
+}
+
+// GetOneRevisionByCircleID implements RevisionService.
+func (r *RevisionService) GetOneRevisionByCircleID(circleID int, id int) (*entity.CircleRevision, *domain.Error) {
+	revision, err := r.repo.GetOneRevisionByID(id)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("REVISION_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	if revision.CircleID != circleID {
+		return nil, domain.NewError(404, errors.New("REVISION_NOT_FOUND"), nil)
+	}
+
+	return revision, nil
+}
+
+func NewRevisionService(repo *RevisionRepo) *RevisionService {
+	return &RevisionService{
+		repo: repo,
+	}
+}
@@ -9,17 +9,62 @@ import (
 	"catalog-be/internal/modules/circle/circle_fandom"
 	"catalog-be/internal/modules/circle/circle_work_type"
 	circle_dto "catalog-be/internal/modules/circle/dto"
+	"catalog-be/internal/modules/circle/export"
 	"catalog-be/internal/modules/circle/referral"
+	"catalog-be/internal/modules/circle/revision"
+	revision_dto "catalog-be/internal/modules/circle/revision/dto"
+	"catalog-be/internal/modules/plan"
 	refreshtoken "catalog-be/internal/modules/refresh_token"
 	"catalog-be/internal/modules/user"
 	"catalog-be/internal/utils"
 	"catalog-be/internal/validation"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// RESERVED_CIRCLE_SLUGS can not be picked as a circle slug, they collide with
+// routes under /circle or are likely to be used by the web app.
+var RESERVED_CIRCLE_SLUGS = map[string]bool{
+	"admin":      true,
+	"api":        true,
+	"auth":       true,
+	"bookmark":   true,
+	"bookmarked": true,
+	"circle":     true,
+	"create":     true,
+	"dashboard":  true,
+	"deleted":    true,
+	"edit":       true,
+	"event":      true,
+	"login":      true,
+	"logout":     true,
+	"me":         true,
+	"new":        true,
+	"onboard":    true,
+	"product":    true,
+	"profile":    true,
+	"report":     true,
+	"restore":    true,
+	"search":     true,
+	"settings":   true,
+	"upload":     true,
+}
+
+var circleSlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// CIRCLE_RESTORE_WINDOW is how long a deleted circle can be restored before
+// it is purged for good.
+var CIRCLE_RESTORE_WINDOW = 30 * 24 * time.Hour
+
 type CircleService struct {
 	circleRepo            *CircleRepo
 	userService           *user.UserService
@@ -30,6 +75,36 @@ type CircleService struct {
 	bookmark              *bookmark.CircleBookmarkService
 	sanitizer             *validation.Sanitizer
 	referralService       *referral.ReferralService
+	revisionService       *revision.RevisionService
+	planService           *plan.PlanService
+}
+
+// GetPaginatedRevisionsByCircleID implements CircleService.
+func (c *CircleService) GetPaginatedRevisionsByCircleID(circleID int, filter *revision_dto.GetPaginatedRevisionsFilter) (*dto.Pagination[[]entity.CircleRevision], *domain.Error) {
+	return c.revisionService.GetPaginatedRevisionsByCircleID(circleID, filter)
+}
+
+// RestoreRevisionByID implements CircleService.
+func (c *CircleService) RestoreRevisionByID(userID int, circleID int, revisionID int) *domain.Error {
+	target, err := c.revisionService.GetOneRevisionByCircleID(circleID, revisionID)
+	if err != nil {
+		return err
+	}
+
+	limits, err := c.planService.GetPlanByCircleID(circleID)
+	if err != nil {
+		return err
+	}
+
+	err = c.circleRepo.RestoreRevision(userID, target, limits)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
+		}
+		return err
+	}
+
+	return nil
 }
 
 // FindReferralCodeByCircleID implements CircleService.
@@ -47,7 +122,7 @@ func (c *CircleService) DeleteCircleAttendedEventByCircleID(circleID int, userID
 		return nil, err
 	}
 
-	err = c.circleRepo.DeleteAllBlockEventByCircleIDAndEventID(circle)
+	err = c.circleRepo.DeleteAllBlockEventByCircleIDAndEventID(userID, circle)
 	if err != nil {
 		return nil, err
 	}
@@ -56,9 +131,8 @@ func (c *CircleService) DeleteCircleAttendedEventByCircleID(circleID int, userID
 	if updatedErr != nil {
 		return nil, updatedErr
 	}
-	response := c.transformCircleRawToCircleDetailedResponse(updatedCircle)
 
-	return &response[0], nil
+	return c.transformCircleRawToCircleDetailedResponse(updatedCircle), nil
 }
 
 // UpdateCircleAttendingEventByID implements CircleService.
@@ -83,17 +157,16 @@ func (c *CircleService) UpdateCircleAttendingEventByID(circleID int, userID int,
 	} else {
 		circle.Day = body.Day
 	}
-	err = c.circleRepo.UpdateAttendingEventDayAndCircleBlock(circle, body)
+	err = c.circleRepo.UpdateAttendingEventDayAndCircleBlock(userID, circle, body)
 	if err != nil {
 		return nil, err
 	}
-	updated, updatedErr := c.circleRepo.GetOneCircleJoinTablesByCircleSlug(circle.Slug, 0)
+	updated, updatedErr := c.circleRepo.GetOneCircleJoinTablesByCircleSlug(circle.Slug, userID)
 	if updatedErr != nil {
 		return nil, updatedErr
 	}
-	response := c.transformCircleRawToCircleDetailedResponse(updated)
 
-	return &response[0], nil
+	return c.transformCircleRawToCircleDetailedResponse(updated), nil
 
 }
 
@@ -107,6 +180,8 @@ func NewCircleService(
 	bookmark *bookmark.CircleBookmarkService,
 	sanitizer *validation.Sanitizer,
 	referralService *referral.ReferralService,
+	revisionService *revision.RevisionService,
+	planService *plan.PlanService,
 ) *CircleService {
 	return &CircleService{
 		circleRepo:            circleRepo,
@@ -118,147 +193,120 @@ func NewCircleService(
 		bookmark:              bookmark,
 		sanitizer:             sanitizer,
 		referralService:       referralService,
+		revisionService:       revisionService,
+		planService:           planService,
 	}
 }
 
-// transformCircleRawToCircleOneForPaginationResponse implements CircleService.
-func (c *CircleService) transformCircleRawToPaginatedResponse(rows []entity.CircleJoinedTables) []circle_dto.CirclePaginatedResponse {
-	if len(rows) == 0 {
-		return []circle_dto.CirclePaginatedResponse{}
+// transformBlockEventToBlockResponse implements CircleService.
+func (c *CircleService) transformBlockEventToBlockResponse(block *entity.BlockEvent) *circle_dto.BlockResponse {
+	if block == nil {
+		return nil
 	}
 
-	var response []circle_dto.CirclePaginatedResponse
+	return &circle_dto.BlockResponse{
+		ID:   block.ID,
+		Name: block.Name,
+	}
+}
+
+// transformCircleRawToPaginatedResponse implements CircleService.
+func (c *CircleService) transformCircleRawToPaginatedResponse(rows []entity.CircleJoinedTables) []circle_dto.CirclePaginatedResponse {
+	response := make([]circle_dto.CirclePaginatedResponse, 0, len(rows))
 
 	for _, row := range rows {
-		// check if row is inside response
-		var found bool
-		for i, res := range response {
-			if res.ID == row.ID {
-				found = true
-
-				fandomExist := false
-
-				for _, fandom := range response[i].Fandom {
-					if fandom.ID == row.FandomID {
-						fandomExist = true
-						break
-					}
-				}
-
-				if !fandomExist && row.FandomID != 0 {
-					response[i].Fandom = append(response[i].Fandom, entity.Fandom{
-						ID:   row.FandomID,
-						Name: row.FandomName,
-					})
-				}
-
-				workTypeExist := false
-
-				for _, workType := range response[i].WorkType {
-					if workType.ID == row.WorkTypeID {
-						workTypeExist = true
-						break
-					}
-				}
-
-				if !workTypeExist && row.WorkTypeID != 0 {
-					response[i].WorkType = append(response[i].WorkType, entity.WorkType{
-						ID:   row.WorkTypeID,
-						Name: row.WorkTypeName,
-					})
-				}
+		latestRow := circle_dto.CirclePaginatedResponse{
+			Circle:                  row.Circle,
+			PicturePlaceholder:      row.PicturePlaceholder,
+			CoverPicturePlaceholder: row.CoverPicturePlaceholder,
+			Fandom:                  row.Fandom,
+			WorkType:                row.WorkType,
+			Bookmarked:              row.Bookmarked,
+			BlockEvent:              c.transformBlockEventToBlockResponse(row.BlockEvent),
+			Event:                   row.Event,
+		}
 
-			}
+		if latestRow.Fandom == nil {
+			latestRow.Fandom = []entity.Fandom{}
+		}
 
+		if latestRow.WorkType == nil {
+			latestRow.WorkType = []entity.WorkType{}
 		}
 
-		if !found {
-			latestRow := circle_dto.CirclePaginatedResponse{
-				Circle: entity.Circle{
-					ID:              row.ID,
-					Name:            row.Name,
-					Slug:            row.Slug,
-					PictureURL:      row.PictureURL,
-					FacebookURL:     row.FacebookURL,
-					InstagramURL:    row.InstagramURL,
-					TwitterURL:      row.TwitterURL,
-					Description:     nil,
-					Verified:        row.Verified,
-					Published:       row.Published,
-					CreatedAt:       row.CreatedAt,
-					UpdatedAt:       row.UpdatedAt,
-					DeletedAt:       row.DeletedAt,
-					Day:             row.Day,
-					URL:             row.URL,
-					EventID:         row.EventID,
-					CoverPictureURL: row.CoverPictureURL,
-					Rating:          row.Rating,
-				},
-				Fandom:     []entity.Fandom{},
-				WorkType:   []entity.WorkType{},
-				Bookmarked: row.Bookmarked,
-			}
+		response = append(response, latestRow)
+	}
 
-			if row.FandomID != 0 {
-				latestRow.Fandom = append(latestRow.Fandom, entity.Fandom{
-					ID:   row.FandomID,
-					Name: row.FandomName,
-				})
-			} else {
-				latestRow.Fandom = []entity.Fandom{}
-			}
+	return response
+}
 
-			if row.WorkTypeID != 0 {
-				latestRow.WorkType = append(latestRow.WorkType, entity.WorkType{
-					ID:   row.WorkTypeID,
-					Name: row.WorkTypeName,
-				})
-			} else {
-				latestRow.WorkType = []entity.WorkType{}
-			}
+// validateCustomSlug implements CircleService.
+func (c *CircleService) validateCustomSlug(slug string, circleID int) *domain.Error {
+	if len(slug) < 3 || len(slug) > 255 || !circleSlugPattern.MatchString(slug) {
+		return domain.NewError(400, errors.New("SLUG_INVALID_FORMAT"), nil)
+	}
 
-			if row.BlockEventID != 0 {
-				latestRow.BlockEvent = &circle_dto.BlockResponse{
-					ID:   row.BlockEventID,
-					Name: row.BlockEventName,
-				}
-			}
+	if RESERVED_CIRCLE_SLUGS[slug] {
+		return domain.NewError(400, errors.New("SLUG_RESERVED"), nil)
+	}
 
-			if row.EventID != nil {
-				latestRow.Event = &entity.Event{
-					ID:        *row.EventID,
-					Name:      row.EventName,
-					Slug:      row.EventSlug,
-					StartedAt: *row.EventStartedAt,
-					EndedAt:   *row.EventEndedAt,
-				}
-			}
+	taken, err := c.circleRepo.IsSlugTaken(slug, circleID)
+	if err != nil {
+		return err
+	}
 
-			response = append(response, latestRow)
-		}
+	if taken {
+		return domain.NewError(409, errors.New("SLUG_ALREADY_TAKEN"), nil)
 	}
 
-	return response
+	return nil
 }
 
-// UpdateCircleByID implements CircleService.
-func (c *CircleService) UpdateCircleByID(userID int, circleID int, body *circle_dto.UpdateCirclePayload) (*circle_dto.CircleOneDetailedResponse, *domain.Error) {
-	if body.Name != nil && *body.Name == "" {
-		return nil, domain.NewError(400, errors.New("CIRCLE_NAME_CANNOT_BE_EMPTY"), nil)
+// generateUniqueSlug implements CircleService.
+func (c *CircleService) generateUniqueSlug(name string, circleID int) (string, *domain.Error) {
+	base, slugErr := c.utils.Slugify(name)
+	if slugErr != nil {
+		return "", domain.NewError(500, slugErr, nil)
 	}
 
-	circle, err := c.GetOneCircleByCircleID(circleID)
+	if base == "" {
+		base = "circle"
+	}
+
+	for i := 0; i < 5; i++ {
+		slug := strings.ToLower(base + "-" + c.utils.GenerateRandomCode(2))
+
+		taken, err := c.circleRepo.IsSlugTaken(slug, circleID)
+		if err != nil {
+			return "", err
+		}
+
+		if !taken {
+			return slug, nil
+		}
+	}
+
+	return "", domain.NewError(409, errors.New("SLUG_ALREADY_TAKEN"), nil)
+}
+
+// GetCanonicalCircleSlug implements CircleService.
+func (c *CircleService) GetCanonicalCircleSlug(slug string) (string, *domain.Error) {
+	canonical, err := c.circleRepo.GetCanonicalSlugByHistorySlug(slug)
 	if err != nil {
-		return nil, err
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return "", domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
+		}
+		return "", err
 	}
 
+	return canonical, nil
+}
+
+// applyUpdatePayload copies the circle fields of body into circle, the slug,
+// fandoms and work types are handled by the caller.
+func (c *CircleService) applyUpdatePayload(circle *entity.Circle, body *circle_dto.UpdateCirclePayload) {
 	if body.Name != nil && *body.Name != circle.Name {
-		slug, slugErr := c.utils.Slugify(*body.Name)
-		if slugErr != nil {
-			return nil, domain.NewError(500, slugErr, nil)
-		}
 		circle.Name = *body.Name
-		circle.Slug = strings.ToLower(slug + "-" + c.utils.GenerateRandomCode(2))
 	}
 
 	if body.URL != nil && body.URL != circle.URL {
@@ -297,19 +345,70 @@ func (c *CircleService) UpdateCircleByID(userID int, circleID int, body *circle_
 			circle.CoverPictureURL = body.CoverPictureURL
 		}
 	}
+}
+
+// enforceRelationLimits checks the fandoms and work types sent in body against
+// the plan of the circle.
+func (c *CircleService) enforceRelationLimits(circleID int, body *circle_dto.UpdateCirclePayload) *domain.Error {
+	if body.FandomIDs != nil {
+		if err := c.planService.Enforce(circleID, plan.ResourceFandom, len(*body.FandomIDs)); err != nil {
+			return err
+		}
+	}
+
+	if body.WorkTypeIDs != nil {
+		if err := c.planService.Enforce(circleID, plan.ResourceWorkType, len(*body.WorkTypeIDs)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateCircleByID implements CircleService.
+func (c *CircleService) UpdateCircleByID(userID int, circleID int, body *circle_dto.UpdateCirclePayload) (*circle_dto.CircleOneDetailedResponse, *domain.Error) {
+	if body.Name != nil && *body.Name == "" {
+		return nil, domain.NewError(400, errors.New("CIRCLE_NAME_CANNOT_BE_EMPTY"), nil)
+	}
 
-	rows, err := c.circleRepo.UpdateOneCircleAndAllRelation(userID, circle, body)
+	circle, err := c.GetOneCircleByCircleID(circleID)
 	if err != nil {
 		return nil, err
 	}
 
-	response := c.transformCircleRawToCircleDetailedResponse(rows)
+	if err := c.enforceRelationLimits(circleID, body); err != nil {
+		return nil, err
+	}
 
-	if len(response) == 0 {
-		return nil, domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
+	if body.Slug != nil && *body.Slug != "" {
+		slug := strings.ToLower(strings.TrimSpace(*body.Slug))
+		if slug != circle.Slug {
+			if slugErr := c.validateCustomSlug(slug, circle.ID); slugErr != nil {
+				return nil, slugErr
+			}
+			circle.Slug = slug
+		}
+		circle.SlugCustom = true
+	} else if body.Name != nil && *body.Name != circle.Name && !circle.SlugCustom {
+		// a slug the owner picked is kept on rename
+		slug, slugErr := c.generateUniqueSlug(*body.Name, circle.ID)
+		if slugErr != nil {
+			return nil, slugErr
+		}
+		circle.Slug = slug
 	}
 
-	return &response[0], nil
+	c.applyUpdatePayload(circle, body)
+
+	row, err := c.circleRepo.UpdateOneCircleAndAllRelation(userID, circle, body)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	return c.transformCircleRawToCircleDetailedResponse(row), nil
 }
 
 // GetOneCircleByCircleID implements CircleService.
@@ -333,159 +432,342 @@ func (c *CircleService) SaveBookmarkCircle(circleID int, userID int) *domain.Err
 }
 
 // transformCircleRawToCircleDetailedResponse implements CircleService.
-func (c *CircleService) transformCircleRawToCircleDetailedResponse(rows []entity.CircleJoinedTables) []circle_dto.CircleOneDetailedResponse {
-	if len(rows) == 0 {
-		return []circle_dto.CircleOneDetailedResponse{}
+func (c *CircleService) transformCircleRawToCircleDetailedResponse(row *entity.CircleJoinedTables) *circle_dto.CircleOneDetailedResponse {
+	response := &circle_dto.CircleOneDetailedResponse{
+		Circle:                  row.Circle,
+		PicturePlaceholder:      row.PicturePlaceholder,
+		CoverPicturePlaceholder: row.CoverPicturePlaceholder,
+		Fandom:                  row.Fandom,
+		WorkType:                row.WorkType,
+		Bookmarked:              row.Bookmarked,
+		BlockEvent:              c.transformBlockEventToBlockResponse(row.BlockEvent),
+		Event:                   row.Event,
 	}
 
-	var response []circle_dto.CircleOneDetailedResponse
+	if response.Fandom == nil {
+		response.Fandom = []entity.Fandom{}
+	}
 
-	for _, row := range rows {
-		// check if row is inside response
-		var found bool
-		for i, res := range response {
-			if res.ID == row.ID {
-				found = true
-
-				fandomExist := false
-
-				for _, fandom := range response[i].Fandom {
-					if fandom.ID == row.FandomID {
-						fandomExist = true
-						break
-					}
-				}
-
-				if !fandomExist && row.FandomID != 0 {
-					response[i].Fandom = append(response[i].Fandom, entity.Fandom{
-						ID:        row.FandomID,
-						Name:      row.FandomName,
-						CreatedAt: row.FandomCreatedAt,
-						UpdatedAt: row.FandomUpdatedAt,
-					})
-				}
-
-				workTypeExist := false
-
-				for _, workType := range response[i].WorkType {
-					if workType.ID == row.WorkTypeID {
-						workTypeExist = true
-						break
-					}
-				}
-
-				if !workTypeExist && row.WorkTypeID != 0 {
-					response[i].WorkType = append(response[i].WorkType, entity.WorkType{
-						ID:        row.WorkTypeID,
-						Name:      row.WorkTypeName,
-						CreatedAt: row.WorkTypeCreatedAt,
-						UpdatedAt: row.WorkTypeUpdatedAt,
-					})
-				}
+	if response.WorkType == nil {
+		response.WorkType = []entity.WorkType{}
+	}
 
-			}
+	return response
+}
 
+// GetPaginatedBookmarkedCircle implements CircleService.
+func (c *CircleService) GetPaginatedBookmarkedCircle(userID int, filter *circle_dto.GetPaginatedCirclesFilter) (*dto.Pagination[[]circle_dto.CirclePaginatedResponse], *domain.Error) {
+	rows, err := c.circleRepo.GetPaginatedBookmarkedCirclesByUserID(userID, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	response := c.transformCircleRawToPaginatedResponse(rows)
+	for i, row := range rows {
+		response[i].Bookmark = &circle_dto.BookmarkResponse{
+			CollectionID: row.BookmarkCollectionID,
+			Note:         row.BookmarkNote,
+			Priority:     row.BookmarkPriority,
+			Position:     row.BookmarkPosition,
+			CreatedAt:    row.BookmarkedAt,
 		}
+	}
 
-		if !found {
-			latestRow := circle_dto.CircleOneDetailedResponse{
-				Circle: entity.Circle{
-					ID:              row.ID,
-					Name:            row.Name,
-					Slug:            row.Slug,
-					PictureURL:      row.PictureURL,
-					FacebookURL:     row.FacebookURL,
-					InstagramURL:    row.InstagramURL,
-					TwitterURL:      row.TwitterURL,
-					Description:     row.Description,
-					Verified:        row.Verified,
-					Published:       row.Published,
-					CreatedAt:       row.CreatedAt,
-					UpdatedAt:       row.UpdatedAt,
-					DeletedAt:       row.DeletedAt,
-					Day:             row.Day,
-					URL:             row.URL,
-					EventID:         row.EventID,
-					CoverPictureURL: row.CoverPictureURL,
-					Rating:          row.Rating,
-				},
-				Fandom:     []entity.Fandom{},
-				WorkType:   []entity.WorkType{},
-				Bookmarked: row.Bookmarked,
-			}
+	count, err := c.circleRepo.GetAllBookmarkedCircleCount(userID, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	metadata := factory.GetPaginationMetadata(count, filter.Page, filter.Limit)
 
-			if row.FandomID != 0 {
-				latestRow.Fandom = append(latestRow.Fandom, entity.Fandom{
-					ID:        row.FandomID,
-					Name:      row.FandomName,
-					CreatedAt: row.FandomCreatedAt,
-					UpdatedAt: row.FandomUpdatedAt,
-				})
-			} else {
-				latestRow.Fandom = []entity.Fandom{}
-			}
+	return &dto.Pagination[[]circle_dto.CirclePaginatedResponse]{
+		Data:     response,
+		Metadata: *metadata,
+	}, nil
+}
 
-			if row.WorkTypeID != 0 {
-				latestRow.WorkType = append(latestRow.WorkType, entity.WorkType{
-					ID:        row.WorkTypeID,
-					Name:      row.WorkTypeName,
-					CreatedAt: row.WorkTypeCreatedAt,
-					UpdatedAt: row.WorkTypeUpdatedAt,
-				})
-			} else {
-				latestRow.WorkType = []entity.WorkType{}
-			}
+// GetSharedBookmarkList returns a shared bookmark collection and its circles,
+// bookmarked is set for viewerID instead of the owner of the list.
+func (c *CircleService) GetSharedBookmarkList(token string, viewerID int, filter *circle_dto.GetPaginatedCirclesFilter) (*circle_dto.SharedBookmarkListResponse, *dto.Pagination[[]circle_dto.CirclePaginatedResponse], *domain.Error) {
+	collection, err := c.bookmark.GetSharedCollection(token)
+	if err != nil {
+		return nil, nil, err
+	}
 
-			if row.EventID != nil {
-				emptyString := ""
-				latestRow.Event = &entity.Event{
-					ID:          *row.EventID,
-					Name:        row.EventName,
-					Slug:        row.EventSlug,
-					Description: emptyString,
-					StartedAt:   *row.EventStartedAt,
-					EndedAt:     *row.EventEndedAt,
-				}
-			}
+	owner, err := c.userService.FindOneByID(collection.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
 
-			if row.BlockEventID != 0 {
-				latestRow.BlockEvent = &circle_dto.BlockResponse{
-					ID:   row.BlockEventID,
-					Name: row.BlockEventName,
-				}
+	// the notes, priorities and positions of the owner are left out
+	filter.CollectionID = &collection.ID
+	rows, err := c.circleRepo.GetPaginatedBookmarkedCirclesByUserID(collection.UserID, viewerID, filter)
+	if err != nil {
+		return nil, nil, err
+	}
 
-			}
+	count, err := c.circleRepo.GetAllBookmarkedCircleCount(collection.UserID, viewerID, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	circles := &dto.Pagination[[]circle_dto.CirclePaginatedResponse]{
+		Data:     c.transformCircleRawToPaginatedResponse(rows),
+		Metadata: *factory.GetPaginationMetadata(count, filter.Page, filter.Limit),
+	}
 
-			response = append(response, latestRow)
+	bookmarked := make(map[int]bool)
+	if viewerID != 0 {
+		circleIDs := make([]int, 0, len(circles.Data))
+		for _, circle := range circles.Data {
+			circleIDs = append(circleIDs, circle.ID)
+		}
+
+		ids, err := c.bookmark.GetBookmarkedCircleIDs(viewerID, circleIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, id := range ids {
+			bookmarked[id] = true
 		}
 	}
 
-	return response
+	for i := range circles.Data {
+		circles.Data[i].Bookmarked = bookmarked[circles.Data[i].ID]
+	}
+
+	return &circle_dto.SharedBookmarkListResponse{
+		Name:      collection.Name,
+		OwnerName: owner.Name,
+		SharedAt:  collection.SharedAt,
+	}, circles, nil
 }
 
-// GetPaginatedBookmarkedCircle implements CircleService.
-func (c *CircleService) GetPaginatedBookmarkedCircle(userID int, filter *circle_dto.GetPaginatedCirclesFilter) (*dto.Pagination[[]circle_dto.CirclePaginatedResponse], *domain.Error) {
-	rows, err := c.circleRepo.GetPaginatedBookmarkedCirclesByUserID(userID, filter)
+// ExportBookmarkedCircles renders every circle userID bookmarked that matches
+// filter, page and limit are ignored.
+func (c *CircleService) ExportBookmarkedCircles(userID int, filter *circle_dto.GetPaginatedCirclesFilter, format export.Format) ([]byte, *domain.Error) {
+	rows, err := c.circleRepo.GetAllBookmarkedCirclesByUserID(userID, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	response := c.transformCircleRawToPaginatedResponse(rows)
+	exportRows := make([]circle_dto.BookmarkExportRow, 0, len(rows))
+	for _, row := range rows {
+		exportRow := circle_dto.BookmarkExportRow{
+			Name:         row.Name,
+			Slug:         row.Slug,
+			Day:          row.Day,
+			URL:          row.URL,
+			FacebookURL:  row.FacebookURL,
+			InstagramURL: row.InstagramURL,
+			TwitterURL:   row.TwitterURL,
+			Products:     row.ProductNames,
+			Note:         row.BookmarkNote,
+			Priority:     row.BookmarkPriority,
+		}
+
+		if exportRow.Products == nil {
+			exportRow.Products = []string{}
+		}
 
-	count, err := c.circleRepo.GetAllBookmarkedCircleCount(userID, filter)
+		if row.BlockEvent != nil {
+			exportRow.Block = &row.BlockEvent.Name
+		}
+
+		if row.Event != nil {
+			exportRow.Event = &circle_dto.BookmarkExportEvent{
+				Name:      row.Event.Name,
+				Slug:      row.Event.Slug,
+				StartedAt: row.Event.StartedAt,
+				EndedAt:   row.Event.EndedAt,
+			}
+		}
+
+		exportRows = append(exportRows, exportRow)
+	}
+
+	content, renderErr := export.Render(format, exportRows, time.Now())
+	if renderErr != nil {
+		return nil, domain.NewError(500, renderErr, nil)
+	}
+
+	return content, nil
+}
+
+// splitBlockPostfix splits a block postfix such as "12a" into its leading number
+// and the rest, number is -1 when the postfix does not start with a digit.
+func splitBlockPostfix(postfix string) (int, string) {
+	end := 0
+	for end < len(postfix) && postfix[end] >= '0' && postfix[end] <= '9' {
+		end++
+	}
+
+	if end == 0 {
+		return -1, postfix
+	}
+
+	number, err := strconv.Atoi(postfix[:end])
 	if err != nil {
+		return -1, postfix
+	}
+
+	return number, postfix[end:]
+}
+
+// lessBlockPostfix orders postfixes the way tables are numbered, so "2" comes
+// before "10" and "12a" before "12b".
+func lessBlockPostfix(a string, b string) bool {
+	numberA, restA := splitBlockPostfix(a)
+	numberB, restB := splitBlockPostfix(b)
+
+	if numberA != numberB {
+		return numberA < numberB
+	}
+
+	return restA < restB
+}
+
+// GetBookmarkRoutePlan groups the circles userID bookmarked for an event day by
+// block prefix, in the order they are walked past.
+func (c *CircleService) GetBookmarkRoutePlan(userID int, filter *circle_dto.GetRoutePlanFilter) (*circle_dto.RoutePlanResponse, *domain.Error) {
+	event, err := c.circleRepo.GetOneEventBySlug(filter.Event)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("EVENT_NOT_FOUND"), nil)
+		}
 		return nil, err
 	}
-	metadata := factory.GetPaginationMetadata(count, filter.Page, filter.Limit)
 
-	return &dto.Pagination[[]circle_dto.CirclePaginatedResponse]{
-		Data:     response,
-		Metadata: *metadata,
+	rows, err := c.circleRepo.GetBookmarkedCirclesByEventDay(userID, event.ID, filter.Day, &circle_dto.GetPaginatedCirclesFilter{
+		CollectionID: filter.CollectionID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	placed := make([]entity.CircleJoinedTables, 0, len(rows))
+	unplaced := []circle_dto.RoutePlanStop{}
+	for _, row := range rows {
+		if row.BlockEvent == nil {
+			unplaced = append(unplaced, circle_dto.RoutePlanStop{
+				CircleID:   row.ID,
+				Name:       row.Name,
+				Slug:       row.Slug,
+				PictureURL: row.PictureURL,
+				NoBlock:    true,
+				Note:       row.BookmarkNote,
+				Priority:   row.BookmarkPriority,
+				Status:     row.BookmarkStatus,
+			})
+			continue
+		}
+
+		placed = append(placed, row)
+	}
+
+	sort.SliceStable(placed, func(i, j int) bool {
+		a, b := placed[i].BlockEvent, placed[j].BlockEvent
+		if a.Prefix != b.Prefix {
+			return a.Prefix < b.Prefix
+		}
+
+		return lessBlockPostfix(a.Postfix, b.Postfix)
+	})
+
+	groups := []circle_dto.RoutePlanGroup{}
+	for index, row := range placed {
+		block := row.BlockEvent.Name
+		stop := circle_dto.RoutePlanStop{
+			Step:       index + 1,
+			CircleID:   row.ID,
+			Name:       row.Name,
+			Slug:       row.Slug,
+			PictureURL: row.PictureURL,
+			Block:      &block,
+			Note:       row.BookmarkNote,
+			Priority:   row.BookmarkPriority,
+			Status:     row.BookmarkStatus,
+		}
+
+		if len(groups) == 0 || groups[len(groups)-1].Prefix != row.BlockEvent.Prefix {
+			groups = append(groups, circle_dto.RoutePlanGroup{
+				Prefix: row.BlockEvent.Prefix,
+				Stops:  []circle_dto.RoutePlanStop{},
+			})
+		}
+
+		last := &groups[len(groups)-1]
+		last.Stops = append(last.Stops, stop)
+	}
+
+	return &circle_dto.RoutePlanResponse{
+		Event:    event,
+		Day:      filter.Day,
+		Total:    len(rows),
+		Groups:   groups,
+		Unplaced: unplaced,
 	}, nil
 }
 
+// RenderRoutePlanChecklist writes a route plan as a plain text checklist meant to be printed.
+func (c *CircleService) RenderRoutePlanChecklist(plan *circle_dto.RoutePlanResponse) string {
+	var builder strings.Builder
+
+	writeStop := func(stop circle_dto.RoutePlanStop) {
+		block := "-"
+		if stop.Block != nil {
+			block = *stop.Block
+		}
+
+		mark := " "
+		if stop.Status != nil {
+			switch entity.BookmarkStatus(*stop.Status) {
+			case entity.BookmarkVisited:
+				mark = "x"
+			case entity.BookmarkSoldOut:
+				mark = "!"
+			case entity.BookmarkSkipped:
+				mark = "-"
+			}
+		}
+
+		builder.WriteString(fmt.Sprintf("[%s] %-14s %s", mark, block, stop.Name))
+		if stop.Priority > 0 {
+			builder.WriteString(" " + strings.Repeat("*", stop.Priority))
+		}
+		builder.WriteString("\n")
+
+		if stop.Note != nil && *stop.Note != "" {
+			builder.WriteString(fmt.Sprintf("    %-14s %s\n", "", *stop.Note))
+		}
+	}
+
+	builder.WriteString(fmt.Sprintf("%s - day %s\n", plan.Event.Name, plan.Day))
+	builder.WriteString(fmt.Sprintf("%d circles\n", plan.Total))
+
+	for _, group := range plan.Groups {
+		builder.WriteString(fmt.Sprintf("\n== %s ==\n", group.Prefix))
+		for _, stop := range group.Stops {
+			writeStop(stop)
+		}
+	}
+
+	if len(plan.Unplaced) > 0 {
+		builder.WriteString("\n== NO BLOCK YET ==\n")
+		for _, stop := range plan.Unplaced {
+			writeStop(stop)
+		}
+	}
+
+	return builder.String()
+}
+
 // GetPaginatedCircles implements CircleService.
 func (c *CircleService) GetPaginatedCircles(filter *circle_dto.GetPaginatedCirclesFilter, userID int) (*dto.Pagination[[]circle_dto.CirclePaginatedResponse], *domain.Error) {
+	if filter.PriceMin != nil && filter.PriceMax != nil && *filter.PriceMin > *filter.PriceMax {
+		return nil, domain.NewError(400, errors.New("INVALID_PRICE_RANGE"), nil)
+	}
+
 	rows, err := c.circleRepo.GetPaginatedCircles(filter, userID)
 	if err != nil {
 		return nil, err
@@ -511,18 +793,15 @@ func (c *CircleService) GetOneCircleByCircleSlug(slug string, userID int) (*circ
 		return nil, domain.NewError(400, errors.New("SLUG_IS_EMPTY"), nil)
 	}
 
-	rows, err := c.circleRepo.GetOneCircleJoinTablesByCircleSlug(slug, userID)
+	row, err := c.circleRepo.GetOneCircleJoinTablesByCircleSlug(slug, userID)
 	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
+		}
 		return nil, err
 	}
 
-	response := c.transformCircleRawToCircleDetailedResponse(rows)
-
-	if len(response) == 0 {
-		return nil, domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
-	}
-
-	return &response[0], nil
+	return c.transformCircleRawToCircleDetailedResponse(row), nil
 }
 
 // PublishCircleByID implements CircleService.
@@ -538,7 +817,7 @@ func (c *CircleService) PublishCircleByID(circleID int) (*string, *domain.Error)
 
 	circle.Published = !circle.Published
 
-	_, err = c.circleRepo.UpsertOneCircle(circle)
+	err = c.circleRepo.UpdatePublishedByCircleID(circle.ID, circle.Published)
 
 	if err != nil {
 		return nil, err
@@ -566,11 +845,6 @@ func (c *CircleService) OnboardNewCircle(body *circle_dto.OnboardNewCirclePayloa
 		referralID = ref.ID
 	}
 
-	slug, slugErr := c.utils.Slugify(body.Name)
-	if slugErr != nil {
-		return nil, domain.NewError(500, slugErr, nil)
-	}
-
 	user, userErr := c.userService.FindOneByID(userID)
 	if userErr != nil {
 		if errors.Is(userErr.Err, gorm.ErrRecordNotFound) {
@@ -578,10 +852,26 @@ func (c *CircleService) OnboardNewCircle(body *circle_dto.OnboardNewCirclePayloa
 		}
 		return nil, userErr
 	}
-	slug = slug + "-" + c.utils.GenerateRandomCode(2)
+
+	var slug string
+	slugCustom := body.Slug != ""
+	if slugCustom {
+		slug = strings.ToLower(strings.TrimSpace(body.Slug))
+		if slugErr := c.validateCustomSlug(slug, 0); slugErr != nil {
+			return nil, slugErr
+		}
+	} else {
+		generated, slugErr := c.generateUniqueSlug(body.Name, 0)
+		if slugErr != nil {
+			return nil, slugErr
+		}
+		slug = generated
+	}
+
 	payload := entity.Circle{
 		Name:         body.Name,
-		Slug:         strings.ToLower(slug),
+		Slug:         slug,
+		SlugCustom:   slugCustom,
 		PictureURL:   &body.PictureURL,
 		FacebookURL:  &body.FacebookURL,
 		InstagramURL: &body.InstagramURL,
@@ -610,3 +900,421 @@ func (c *CircleService) OnboardNewCircle(body *circle_dto.OnboardNewCirclePayloa
 	}, nil
 
 }
+
+// decodeDraftPayload implements CircleService.
+func (c *CircleService) decodeDraftPayload(draft *entity.CircleDraft) (*circle_dto.UpdateCirclePayload, *domain.Error) {
+	raw, err := json.Marshal(draft.Payload)
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	var body circle_dto.UpdateCirclePayload
+	err = json.Unmarshal(raw, &body)
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &body, nil
+}
+
+// GetCircleDraft implements CircleService.
+func (c *CircleService) GetCircleDraft(circleID int) (*entity.CircleDraft, *domain.Error) {
+	draft, err := c.circleRepo.GetOneDraftByCircleID(circleID)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("DRAFT_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	return draft, nil
+}
+
+// SaveCircleDraft merges body into the draft of the circle, fields left out
+// of body keep their drafted value.
+func (c *CircleService) SaveCircleDraft(userID int, circleID int, body *circle_dto.UpdateCirclePayload) (*entity.CircleDraft, *domain.Error) {
+	if body.Name != nil && *body.Name == "" {
+		return nil, domain.NewError(400, errors.New("CIRCLE_NAME_CANNOT_BE_EMPTY"), nil)
+	}
+
+	if err := c.enforceRelationLimits(circleID, body); err != nil {
+		return nil, err
+	}
+
+	if body.Slug != nil && *body.Slug != "" {
+		slug := strings.ToLower(strings.TrimSpace(*body.Slug))
+		if slugErr := c.validateCustomSlug(slug, circleID); slugErr != nil {
+			return nil, slugErr
+		}
+		body.Slug = &slug
+	}
+
+	_, err := c.GetOneCircleByCircleID(circleID)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	draft, err := c.circleRepo.GetOneDraftByCircleID(circleID)
+	if err != nil && !errors.Is(err.Err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if draft == nil {
+		draft = &entity.CircleDraft{
+			CircleID:     circleID,
+			Payload:      map[string]interface{}{},
+			PreviewToken: uuid.NewString(),
+		}
+	}
+
+	raw, marshalErr := json.Marshal(body)
+	if marshalErr != nil {
+		return nil, domain.NewError(500, marshalErr, nil)
+	}
+
+	var fields map[string]interface{}
+	if unmarshalErr := json.Unmarshal(raw, &fields); unmarshalErr != nil {
+		return nil, domain.NewError(500, unmarshalErr, nil)
+	}
+
+	if draft.Payload == nil {
+		draft.Payload = map[string]interface{}{}
+	}
+
+	for field, value := range fields {
+		if value != nil {
+			draft.Payload[field] = value
+		}
+	}
+
+	draft.UserID = &userID
+
+	return c.circleRepo.UpsertOneDraft(draft)
+}
+
+// DeleteCircleDraft implements CircleService.
+func (c *CircleService) DeleteCircleDraft(circleID int) *domain.Error {
+	return c.circleRepo.DeleteOneDraftByCircleID(circleID)
+}
+
+// PreviewCircleDraft returns the circle as it will look once the draft
+// behind token is published, nothing is saved.
+func (c *CircleService) PreviewCircleDraft(token string) (*circle_dto.CircleOneDetailedResponse, *domain.Error) {
+	draft, err := c.circleRepo.GetOneDraftByPreviewToken(token)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("DRAFT_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	body, err := c.decodeDraftPayload(draft)
+	if err != nil {
+		return nil, err
+	}
+
+	circle, err := c.GetOneCircleByCircleID(draft.CircleID)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	row, err := c.circleRepo.GetOneCircleJoinTablesByCircleSlug(circle.Slug, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if body.Name != nil && *body.Name == "" {
+		body.Name = nil
+	}
+
+	c.applyUpdatePayload(&row.Circle, body)
+	row.Circle.Published = true
+
+	if body.Slug != nil && *body.Slug != "" {
+		row.Circle.Slug = *body.Slug
+	}
+
+	if body.FandomIDs != nil {
+		row.Fandom, err = c.circleRepo.GetFandomsByIDs(*body.FandomIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if body.WorkTypeIDs != nil {
+		row.WorkType, err = c.circleRepo.GetWorkTypesByIDs(*body.WorkTypeIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c.transformCircleRawToCircleDetailedResponse(row), nil
+}
+
+// publishDraft applies the draft edits and makes the circle visible.
+func (c *CircleService) publishDraft(userID int, draft *entity.CircleDraft) (*circle_dto.CircleOneDetailedResponse, *domain.Error) {
+	body, err := c.decodeDraftPayload(draft)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.UpdateCircleByID(userID, draft.CircleID, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if !response.Published {
+		// the edits stay applied when a moderator unpublished the circle, it
+		// only stays unpublished
+		err = c.circleRepo.UpdatePublishedByCircleID(draft.CircleID, true)
+		if err != nil && err.Code != 403 {
+			return nil, err
+		}
+
+		response.Published = err == nil
+	}
+
+	return response, nil
+}
+
+// PublishCircleDraftNow implements CircleService.
+func (c *CircleService) PublishCircleDraftNow(userID int, circleID int) (*circle_dto.CircleOneDetailedResponse, *domain.Error) {
+	draft, err := c.circleRepo.GetOneDraftByCircleID(circleID)
+	if err != nil && !errors.Is(err.Err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if draft == nil {
+		// nothing drafted, publishing only makes the circle visible
+		draft = &entity.CircleDraft{
+			CircleID: circleID,
+			Payload:  map[string]interface{}{},
+		}
+	}
+
+	response, err := c.publishDraft(userID, draft)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.circleRepo.DeleteOneDraftByCircleID(circleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ScheduleCircleDraft implements CircleService.
+func (c *CircleService) ScheduleCircleDraft(userID int, circleID int, publishAt time.Time) (*entity.CircleDraft, *domain.Error) {
+	draft, err := c.circleRepo.GetOneDraftByCircleID(circleID)
+	if err != nil && !errors.Is(err.Err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if draft == nil {
+		draft = &entity.CircleDraft{
+			CircleID:     circleID,
+			Payload:      map[string]interface{}{},
+			PreviewToken: uuid.NewString(),
+		}
+	}
+
+	publishAt = publishAt.UTC()
+	draft.PublishAt = &publishAt
+	draft.UserID = &userID
+
+	return c.circleRepo.UpsertOneDraft(draft)
+}
+
+// PublishDueCircleDrafts publishes every draft scheduled at or before now and
+// returns how many were published. A draft that fails to publish is kept
+// without its schedule so the owner can fix it.
+func (c *CircleService) PublishDueCircleDrafts(now time.Time) (int, *domain.Error) {
+	circleIDs, err := c.circleRepo.GetDueDraftCircleIDs(now)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	var lastErr *domain.Error
+	for _, circleID := range circleIDs {
+		draft, err := c.circleRepo.ClaimDueDraftByCircleID(circleID, now)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if draft == nil {
+			continue
+		}
+
+		userID := 0
+		if draft.UserID != nil {
+			userID = *draft.UserID
+		}
+
+		_, err = c.publishDraft(userID, draft)
+		if err != nil {
+			lastErr = err
+
+			if errors.Is(err.Err, gorm.ErrRecordNotFound) || err.Code == 404 {
+				continue
+			}
+
+			draft.PublishAt = nil
+			if _, saveErr := c.circleRepo.UpsertOneDraft(draft); saveErr != nil {
+				lastErr = saveErr
+			}
+			continue
+		}
+
+		published++
+	}
+
+	return published, lastErr
+}
+
+// DeleteCircleByID implements CircleService.
+func (c *CircleService) DeleteCircleByID(userID int, circleID int) *domain.Error {
+	circle, err := c.GetOneCircleByCircleID(circleID)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
+		}
+		return err
+	}
+
+	return c.circleRepo.SoftDeleteCircle(userID, circle)
+}
+
+// isRestorable implements CircleService.
+func (c *CircleService) isRestorable(circle *entity.Circle) *domain.Error {
+	if circle.DeletedAt.Valid && time.Since(circle.DeletedAt.Time) > CIRCLE_RESTORE_WINDOW {
+		return domain.NewError(410, errors.New("RESTORE_WINDOW_EXPIRED"), nil)
+	}
+
+	taken, err := c.circleRepo.IsSlugTaken(circle.Slug, circle.ID)
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return domain.NewError(409, errors.New("SLUG_ALREADY_TAKEN"), nil)
+	}
+
+	return nil
+}
+
+// RestoreOwnDeletedCircle restores the circle the user deleted last.
+func (c *CircleService) RestoreOwnDeletedCircle(userID int) (*circle_dto.CircleOneDetailedResponse, *domain.Error) {
+	user, err := c.userService.FindOneByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.CircleID != nil {
+		return nil, domain.NewError(409, errors.New("USER_ALREADY_HAVE_CIRCLE"), nil)
+	}
+
+	circle, err := c.circleRepo.GetLastDeletedCircleByUserID(userID)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	if err := c.isRestorable(circle); err != nil {
+		return nil, err
+	}
+
+	err = c.circleRepo.RestoreDeletedCircle(circle)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetOneCircleByCircleSlug(circle.Slug, userID)
+}
+
+// GetPaginatedDeletedCircles implements CircleService.
+func (c *CircleService) GetPaginatedDeletedCircles(filter *circle_dto.GetPaginatedDeletedCirclesFilter) (*dto.Pagination[[]entity.Circle], *domain.Error) {
+	circles, err := c.circleRepo.GetPaginatedDeletedCircles(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := c.circleRepo.GetAllDeletedCirclesCount()
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := factory.GetPaginationMetadata(count, filter.Page, filter.Limit)
+
+	return &dto.Pagination[[]entity.Circle]{
+		Data:     circles,
+		Metadata: *metadata,
+	}, nil
+}
+
+// RestoreDeletedCircleByID implements CircleService.
+func (c *CircleService) RestoreDeletedCircleByID(userID int, circleID int) (*circle_dto.CircleOneDetailedResponse, *domain.Error) {
+	circle, err := c.circleRepo.GetOneDeletedCircleByCircleID(circleID)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	if err := c.isRestorable(circle); err != nil {
+		return nil, err
+	}
+
+	err = c.circleRepo.RestoreDeletedCircle(circle)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetOneCircleByCircleSlug(circle.Slug, userID)
+}
+
+// PurgeDeletedCircleByID implements CircleService.
+func (c *CircleService) PurgeDeletedCircleByID(circleID int) *domain.Error {
+	_, err := c.circleRepo.GetOneDeletedCircleByCircleID(circleID)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
+		}
+		return err
+	}
+
+	return c.circleRepo.PurgeCircle(circleID)
+}
+
+// PurgeExpiredDeletedCircles purges every circle deleted longer than the
+// restore window before now and returns how many were purged.
+func (c *CircleService) PurgeExpiredDeletedCircles(now time.Time) (int, *domain.Error) {
+	ids, err := c.circleRepo.GetDeletedCircleIDsBefore(now.Add(-CIRCLE_RESTORE_WINDOW))
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	var lastErr *domain.Error
+	for _, id := range ids {
+		if err := c.circleRepo.PurgeCircle(id); err != nil {
+			lastErr = err
+			continue
+		}
+		purged++
+	}
+
+	return purged, lastErr
+}
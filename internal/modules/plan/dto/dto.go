@@ -0,0 +1,31 @@
+package plan_dto
+
+import "catalog-be/internal/entity"
+
+type CreateUpdatePlanPayload struct {
+	Slug         string `json:"slug" validate:"required,min=1,max=50"`
+	Name         string `json:"name" validate:"required,min=1,max=100"`
+	MaxProducts  int    `json:"max_products" validate:"min=0"`
+	MaxFandoms   int    `json:"max_fandoms" validate:"min=0"`
+	MaxWorkTypes int    `json:"max_work_types" validate:"min=0"`
+	// MaxStorageBytes is the total size of the uploads a circle can keep.
+	MaxStorageBytes int `json:"max_storage_bytes" validate:"min=0"`
+	// Rule is empty for a plan only given by admins.
+	Rule string `json:"rule" validate:"omitempty,oneof=default verified referred"`
+}
+
+type AssignPlanPayload struct {
+	// PlanID is nil to fall back to the plan matching the circle rule.
+	PlanID *int `json:"plan_id" validate:"omitempty,min=1"`
+}
+
+type ResourceUsage struct {
+	Resource string `json:"resource"`
+	Used     int    `json:"used"`
+	Limit    int    `json:"limit"`
+}
+
+type PlanUsageResponse struct {
+	Plan  entity.Plan     `json:"plan"`
+	Usage []ResourceUsage `json:"usage"`
+}
@@ -0,0 +1,132 @@
+package plan
+
+import (
+	"catalog-be/internal/domain"
+	auth_dto "catalog-be/internal/modules/auth/dto"
+	plan_dto "catalog-be/internal/modules/plan/dto"
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type PlanHandler struct {
+	planService *PlanService
+	validator   *validator.Validate
+}
+
+func (h *PlanHandler) GetAllPlans(c *fiber.Ctx) error {
+	plans, err := h.planService.GetAllPlans()
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": plans,
+	})
+}
+
+func (h *PlanHandler) PostCreatePlan(c *fiber.Ctx) error {
+	var body plan_dto.CreateUpdatePlanPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	plan, err := h.planService.CreatePlan(&body)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"code": fiber.StatusCreated,
+		"data": plan,
+	})
+}
+
+func (h *PlanHandler) PutUpdatePlan(c *fiber.Ctx) error {
+	id, parseErr := c.ParamsInt("id")
+	if parseErr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("PLAN_ID_SHOULD_BE_NUMBER"), nil)))
+	}
+
+	var body plan_dto.CreateUpdatePlanPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	plan, err := h.planService.UpdatePlan(id, &body)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": plan,
+	})
+}
+
+// GetCirclePlanUsage lets a circle see its plan, its limits and how much of
+// them it uses.
+func (h *PlanHandler) GetCirclePlanUsage(c *fiber.Ctx) error {
+	circleID, parseErr := c.ParamsInt("id")
+	if parseErr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("CIRCLE_ID_SHOULD_BE_NUMBER"), nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+	if *user.CircleID != circleID {
+		return c.Status(fiber.StatusForbidden).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusForbidden, errors.New("FORBIDDEN"), nil)))
+	}
+
+	usage, err := h.planService.GetUsageByCircleID(circleID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": usage,
+	})
+}
+
+func (h *PlanHandler) PutAssignCirclePlan(c *fiber.Ctx) error {
+	circleID, parseErr := c.ParamsInt("id")
+	if parseErr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("CIRCLE_ID_SHOULD_BE_NUMBER"), nil)))
+	}
+
+	var body plan_dto.AssignPlanPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	usage, err := h.planService.AssignPlanToCircle(circleID, body.PlanID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": usage,
+	})
+}
+
+func NewPlanHandler(planService *PlanService, validator *validator.Validate) *PlanHandler {
+	return &PlanHandler{
+		planService: planService,
+		validator:   validator,
+	}
+}
@@ -0,0 +1,157 @@
+package plan
+
+import (
+	"catalog-be/internal/domain"
+	"catalog-be/internal/entity"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type PlanRepo struct {
+	db *gorm.DB
+}
+
+// STORAGE_USED_QUERY sums the confirmed uploads of @circle counted in its
+// storage quota. Uploads only its revisions still use are not counted, so
+// replacing a picture frees the room the old one took.
+const STORAGE_USED_QUERY = `
+	SELECT coalesce(sum(u.size), 0)
+	FROM upload u
+	WHERE u.circle_id = @circle
+		AND u.status = 'confirmed'
+		AND (
+			NOT EXISTS (SELECT 1 FROM upload_reference r WHERE r.upload_id = u.id AND r.entity_type = 'circle_revision')
+			OR EXISTS (SELECT 1 FROM upload_reference r WHERE r.upload_id = u.id AND r.entity_type <> 'circle_revision')
+		)
+`
+
+// GetAllPlans implements PlanRepo.
+func (p *PlanRepo) GetAllPlans() ([]entity.Plan, *domain.Error) {
+	plans := []entity.Plan{}
+	err := p.db.Order("id asc").Find(&plans).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return plans, nil
+}
+
+// GetOnePlanByID implements PlanRepo.
+func (p *PlanRepo) GetOnePlanByID(id int) (*entity.Plan, *domain.Error) {
+	var plan entity.Plan
+	err := p.db.First(&plan, id).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &plan, nil
+}
+
+// CreateOnePlan implements PlanRepo.
+func (p *PlanRepo) CreateOnePlan(plan *entity.Plan) *domain.Error {
+	err := p.db.Create(plan).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return domain.NewError(409, errors.New("PLAN_ALREADY_EXIST"), nil)
+		}
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// UpdateOnePlan implements PlanRepo.
+func (p *PlanRepo) UpdateOnePlan(plan *entity.Plan) *domain.Error {
+	err := p.db.
+		Model(plan).
+		Select("slug", "name", "max_products", "max_fandoms", "max_work_types", "max_storage_bytes", "rule").
+		Updates(plan).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return domain.NewError(409, errors.New("PLAN_ALREADY_EXIST"), nil)
+		}
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// GetPlanByCircleID returns the plan assigned to the circle, or else the plan
+// of the first rule it matches: referred, verified, then default.
+func (p *PlanRepo) GetPlanByCircleID(circleID int) (*entity.Plan, *domain.Error) {
+	var plan entity.Plan
+	err := p.db.
+		Table("circle c").
+		Select("p.*").
+		Joins(`JOIN plan p ON p.id = c.plan_id OR (c.plan_id IS NULL AND (
+			p.rule = ?
+			OR (p.rule = ? AND c.verified IS TRUE)
+			OR (p.rule = ? AND c.used_referral_code_id IS NOT NULL)
+		))`, entity.PlanRuleDefault, entity.PlanRuleVerified, entity.PlanRuleReferred).
+		Where("c.id = ?", circleID).
+		Order(gorm.Expr(`CASE
+			WHEN p.id = c.plan_id THEN 0
+			WHEN p.rule = ? THEN 1
+			WHEN p.rule = ? THEN 2
+			ELSE 3
+		END`, entity.PlanRuleReferred, entity.PlanRuleVerified)).
+		Take(&plan).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &plan, nil
+}
+
+// CountUsageByCircleID implements PlanRepo.
+func (p *PlanRepo) CountUsageByCircleID(circleID int) (map[Resource]int, *domain.Error) {
+	var usage struct {
+		Products  int
+		Fandoms   int
+		WorkTypes int
+		Storage   int
+	}
+
+	err := p.db.Raw(`
+		SELECT
+			(SELECT count(*) FROM product WHERE circle_id = @circle AND deleted_at IS NULL) AS products,
+			(SELECT count(*) FROM circle_fandom WHERE circle_id = @circle) AS fandoms,
+			(SELECT count(*) FROM circle_work_type WHERE circle_id = @circle) AS work_types,
+			(`+STORAGE_USED_QUERY+`) AS storage
+	`, map[string]interface{}{"circle": circleID}).Scan(&usage).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return map[Resource]int{
+		ResourceProduct:  usage.Products,
+		ResourceFandom:   usage.Fandoms,
+		ResourceWorkType: usage.WorkTypes,
+		ResourceStorage:  usage.Storage,
+	}, nil
+}
+
+// AssignPlanToCircle implements PlanRepo.
+func (p *PlanRepo) AssignPlanToCircle(circleID int, planID *int) *domain.Error {
+	result := p.db.
+		Model(&entity.Circle{}).
+		Where("id = ?", circleID).
+		Update("plan_id", planID)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrForeignKeyViolated) {
+			return domain.NewError(404, errors.New("PLAN_NOT_FOUND"), nil)
+		}
+		return domain.NewError(500, result.Error, nil)
+	}
+
+	if result.RowsAffected == 0 {
+		return domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
+	}
+
+	return nil
+}
+
+func NewPlanRepo(db *gorm.DB) *PlanRepo {
+	return &PlanRepo{db: db}
+}
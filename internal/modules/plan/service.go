@@ -0,0 +1,191 @@
+package plan
+
+import (
+	"catalog-be/internal/domain"
+	"catalog-be/internal/entity"
+	plan_dto "catalog-be/internal/modules/plan/dto"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// DEFAULT_PLAN applies when no plan is assigned to a circle and no plan rule
+// matches it.
+var DEFAULT_PLAN = entity.Plan{
+	Slug:         "free",
+	Name:         "Free",
+	MaxProducts:  5,
+	MaxFandoms:   5,
+	MaxWorkTypes: 5,
+
+	MaxStorageBytes: 50 * 1024 * 1024,
+}
+
+// Resource is something a plan limits.
+type Resource string
+
+const (
+	ResourceProduct  Resource = "product"
+	ResourceFandom   Resource = "fandom"
+	ResourceWorkType Resource = "work_type"
+	// ResourceStorage is counted in bytes.
+	ResourceStorage Resource = "storage"
+)
+
+// resources is the order usage is reported in.
+var resources = []Resource{ResourceProduct, ResourceFandom, ResourceWorkType, ResourceStorage}
+
+// limitErrors are the errors returned when a circle goes over a limit.
+var limitErrors = map[Resource]string{
+	ResourceProduct:  "MAX_PRODUCT_EXCEEDED",
+	ResourceFandom:   "FANDOM_LIMIT_EXCEEDED",
+	ResourceWorkType: "WORK_TYPE_LIMIT_EXCEEDED",
+	ResourceStorage:  "STORAGE_QUOTA_EXCEEDED",
+}
+
+// Limit is how much of r the plan allows.
+func (r Resource) Limit(plan *entity.Plan) int {
+	switch r {
+	case ResourceProduct:
+		return plan.MaxProducts
+	case ResourceFandom:
+		return plan.MaxFandoms
+	case ResourceWorkType:
+		return plan.MaxWorkTypes
+	case ResourceStorage:
+		return plan.MaxStorageBytes
+	}
+	return 0
+}
+
+// CheckLimit rejects count of resource when it goes over limit. It is used
+// directly where the count is only known inside a transaction.
+func CheckLimit(resource Resource, count int, limit int) *domain.Error {
+	if count > limit {
+		return domain.NewError(400, errors.New(limitErrors[resource]), nil)
+	}
+	return nil
+}
+
+type PlanService struct {
+	repo *PlanRepo
+}
+
+// GetPlanByCircleID implements PlanService.
+func (p *PlanService) GetPlanByCircleID(circleID int) (*entity.Plan, *domain.Error) {
+	plan, err := p.repo.GetPlanByCircleID(circleID)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			fallback := DEFAULT_PLAN
+			return &fallback, nil
+		}
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// Enforce rejects a change that would give the circle count of resource.
+func (p *PlanService) Enforce(circleID int, resource Resource, count int) *domain.Error {
+	plan, err := p.GetPlanByCircleID(circleID)
+	if err != nil {
+		return err
+	}
+
+	return CheckLimit(resource, count, resource.Limit(plan))
+}
+
+// GetUsageByCircleID reports the plan of the circle with how much of each
+// limit it uses.
+func (p *PlanService) GetUsageByCircleID(circleID int) (*plan_dto.PlanUsageResponse, *domain.Error) {
+	plan, err := p.GetPlanByCircleID(circleID)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := p.repo.CountUsageByCircleID(circleID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make([]plan_dto.ResourceUsage, 0, len(resources))
+	for _, resource := range resources {
+		usage = append(usage, plan_dto.ResourceUsage{
+			Resource: string(resource),
+			Used:     used[resource],
+			Limit:    resource.Limit(plan),
+		})
+	}
+
+	return &plan_dto.PlanUsageResponse{
+		Plan:  *plan,
+		Usage: usage,
+	}, nil
+}
+
+// GetAllPlans implements PlanService.
+func (p *PlanService) GetAllPlans() ([]entity.Plan, *domain.Error) {
+	return p.repo.GetAllPlans()
+}
+
+func planFromPayload(plan *entity.Plan, payload *plan_dto.CreateUpdatePlanPayload) {
+	plan.Slug = payload.Slug
+	plan.Name = payload.Name
+	plan.MaxProducts = payload.MaxProducts
+	plan.MaxFandoms = payload.MaxFandoms
+	plan.MaxWorkTypes = payload.MaxWorkTypes
+	plan.MaxStorageBytes = payload.MaxStorageBytes
+	plan.Rule = nil
+	if payload.Rule != "" {
+		rule := entity.PlanRule(payload.Rule)
+		plan.Rule = &rule
+	}
+}
+
+// CreatePlan implements PlanService.
+func (p *PlanService) CreatePlan(payload *plan_dto.CreateUpdatePlanPayload) (*entity.Plan, *domain.Error) {
+	var plan entity.Plan
+	planFromPayload(&plan, payload)
+
+	err := p.repo.CreateOnePlan(&plan)
+	if err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+// UpdatePlan implements PlanService.
+func (p *PlanService) UpdatePlan(id int, payload *plan_dto.CreateUpdatePlanPayload) (*entity.Plan, *domain.Error) {
+	plan, err := p.repo.GetOnePlanByID(id)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("PLAN_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	planFromPayload(plan, payload)
+
+	err = p.repo.UpdateOnePlan(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// AssignPlanToCircle gives planID to the circle, a nil planID goes back to
+// the plan matching the circle rule.
+func (p *PlanService) AssignPlanToCircle(circleID int, planID *int) (*plan_dto.PlanUsageResponse, *domain.Error) {
+	err := p.repo.AssignPlanToCircle(circleID, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetUsageByCircleID(circleID)
+}
+
+func NewPlanService(repo *PlanRepo) *PlanService {
+	return &PlanService{repo: repo}
+}
@@ -1,6 +1,81 @@
 package product_dto
 
+import "catalog-be/internal/entity"
+
+type ProductImageBody struct {
+	URL string `json:"url" validate:"required,url,max=255"`
+}
+
+type ProductVariantBody struct {
+	// ID is empty for a new variant.
+	ID    int    `json:"id" validate:"omitempty,min=1"`
+	Name  string `json:"name" validate:"required,min=1,max=100"`
+	Price *int64 `json:"price" validate:"omitempty,min=0"`
+	Stock *int   `json:"stock" validate:"omitempty,min=0"`
+}
+
 type CreateUpdateProductBody struct {
-	Name     string `json:"name" validate:"required,min=3,max=255"`
-	ImageURL string `json:"image_url" validate:"required,url"`
+	Name string `json:"name" validate:"required,min=3,max=255"`
+	// ImageURL is a shortcut for a gallery of one picture.
+	ImageURL string `json:"image_url" validate:"required_without=Images,omitempty,url"`
+
+	// Price is in the smallest unit of Currency.
+	Price       *int64  `json:"price" validate:"omitempty,min=0"`
+	Currency    string  `json:"currency" validate:"omitempty,iso4217"`
+	Stock       *int    `json:"stock" validate:"omitempty,min=0"`
+	Status      string  `json:"status" validate:"omitempty,oneof=available preorder sold_out"`
+	Description *string `json:"description" validate:"omitempty,max=2000"`
+
+	// Images replaces the gallery in this order.
+	Images []ProductImageBody `json:"images" validate:"omitempty,max=10,dive"`
+	// Variants replaces the variants when sent, they are kept when left out.
+	Variants []ProductVariantBody `json:"variants" validate:"omitempty,max=20,dive"`
+}
+
+type UpsertProductBody struct {
+	// ID is empty for a new product.
+	ID int `json:"id" validate:"omitempty,min=1"`
+	CreateUpdateProductBody
+}
+
+type BatchUpsertProductBody struct {
+	// Products is every product of the circle, the ones left out are deleted.
+	Products []UpsertProductBody `json:"products" validate:"dive"`
+}
+
+type GetPaginatedProductsFilter struct {
+	Search      string `query:"search" validate:"omitempty"`
+	FandomIDs   []int  `query:"fandom_id" validate:"omitempty,dive"`
+	WorkTypeIDs []int  `query:"work_type_id" validate:"omitempty,dive"`
+	Event       string `query:"event" validate:"omitempty"`
+	Status      string `query:"status" validate:"omitempty,oneof=available preorder sold_out"`
+	Page        int    `query:"page" validate:"required,min=1"`
+	Limit       int    `query:"limit" validate:"required,min=1,max=20"`
+
+	// PriceMin and PriceMax keep products with a price or a variant price in
+	// the range, in the smallest unit of Currency. Sold out ones are ignored.
+	PriceMin *int64 `query:"price_min" validate:"omitempty,min=0"`
+	PriceMax *int64 `query:"price_max" validate:"omitempty,min=0"`
+	Currency string `query:"currency" validate:"omitempty,iso4217"`
+}
+
+type ProductBlockResponse struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// ProductCircleResponse is the circle selling a product found by a search.
+type ProductCircleResponse struct {
+	ID         int                   `json:"id"`
+	Name       string                `json:"name"`
+	Slug       string                `json:"slug"`
+	PictureURL *string               `json:"picture_url"`
+	Day        *entity.Day           `json:"day"`
+	Event      *entity.Event         `json:"event"`
+	Block      *ProductBlockResponse `json:"block"`
+}
+
+type ProductSearchResponse struct {
+	entity.Product
+	Circle ProductCircleResponse `json:"circle"`
 }
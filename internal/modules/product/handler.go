@@ -5,6 +5,8 @@ import (
 	"catalog-be/internal/entity"
 	auth_dto "catalog-be/internal/modules/auth/dto"
 	product_dto "catalog-be/internal/modules/product/dto"
+	"catalog-be/internal/modules/product/reservation"
+	reservation_dto "catalog-be/internal/modules/product/reservation/dto"
 	"errors"
 
 	"github.com/go-playground/validator/v10"
@@ -12,8 +14,62 @@ import (
 )
 
 type ProductHandler struct {
-	productService *ProductService
-	validator      *validator.Validate
+	productService     *ProductService
+	reservationService *reservation.ProductReservationService
+	validator          *validator.Validate
+}
+
+func productFromBody(id int, body *product_dto.CreateUpdateProductBody) entity.Product {
+	product := entity.Product{
+		ID:          id,
+		Name:        body.Name,
+		ImageURL:    body.ImageURL,
+		Price:       body.Price,
+		Currency:    body.Currency,
+		Stock:       body.Stock,
+		Status:      entity.ProductStatus(body.Status),
+		Description: body.Description,
+	}
+
+	for _, image := range body.Images {
+		product.Images = append(product.Images, entity.ProductImage{URL: image.URL})
+	}
+
+	if body.Variants != nil {
+		product.Variants = make([]entity.ProductVariant, 0, len(body.Variants))
+		for _, variant := range body.Variants {
+			product.Variants = append(product.Variants, entity.ProductVariant{
+				ID:    variant.ID,
+				Name:  variant.Name,
+				Price: variant.Price,
+				Stock: variant.Stock,
+			})
+		}
+	}
+
+	return product
+}
+
+func (p *ProductHandler) GetPaginatedProducts(c *fiber.Ctx) error {
+	var query product_dto.GetPaginatedProductsFilter
+	if err := c.QueryParser(&query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := p.validator.Struct(query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	products, err := p.productService.GetPaginatedProducts(&query)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code":     fiber.StatusOK,
+		"data":     products.Data,
+		"metadata": products.Metadata,
+	})
 }
 
 func (p *ProductHandler) GetAllProductByCircleID(c *fiber.Ctx) error {
@@ -24,7 +80,13 @@ func (p *ProductHandler) GetAllProductByCircleID(c *fiber.Ctx) error {
 			JSON(domain.NewErrorFiber(c, domain.NewError(400, err, nil)))
 	}
 
-	products, productErr := p.productService.GetAllProductsByCircleID(id)
+	userID := 0
+	user := c.Locals("user")
+	if user != nil {
+		userID = user.(*auth_dto.ATClaims).UserID
+	}
+
+	products, productErr := p.productService.GetAllProductsByCircleID(id, userID)
 	if productErr != nil {
 		return c.
 			Status(fiber.StatusInternalServerError).
@@ -64,23 +126,7 @@ func (p *ProductHandler) CreateOneProductByCircleID(c *fiber.Ctx) error {
 			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
 	}
 
-	count, countErr := p.productService.CountProductsByCircleID(id)
-	if countErr != nil {
-		return c.
-			Status(fiber.StatusInternalServerError).
-			JSON(domain.NewErrorFiber(c, countErr))
-	}
-
-	if count >= 5 {
-		return c.
-			Status(fiber.StatusBadRequest).
-			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("MAX_PRODUCT_EXCEEDED"), nil)))
-	}
-
-	product, productErr := p.productService.CreateOneProductByCircleID(id, entity.Product{
-		Name:     body.Name,
-		ImageURL: body.ImageURL,
-	})
+	product, productErr := p.productService.CreateOneProductByCircleID(user.UserID, id, productFromBody(0, &body))
 	if productErr != nil {
 		return c.
 			Status(productErr.Code).
@@ -128,11 +174,7 @@ func (p *ProductHandler) UpdateOneProductByCircleID(c *fiber.Ctx) error {
 			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
 	}
 
-	product, productErr := p.productService.UpdateOneProductByCircleAndProductID(id, entity.Product{
-		ID:       productID,
-		Name:     body.Name,
-		ImageURL: body.ImageURL,
-	})
+	product, productErr := p.productService.UpdateOneProductByCircleAndProductID(user.UserID, id, productFromBody(productID, &body))
 	if productErr != nil {
 		return c.
 			Status(productErr.Code).
@@ -145,6 +187,52 @@ func (p *ProductHandler) UpdateOneProductByCircleID(c *fiber.Ctx) error {
 	})
 }
 
+func (p *ProductHandler) PutUpsertProductsByCircleID(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+	if *user.CircleID != id {
+		return c.
+			Status(fiber.StatusForbidden).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusForbidden, errors.New("FORBIDDEN"), nil)))
+	}
+
+	var body product_dto.BatchUpsertProductBody
+	if err := c.BodyParser(&body); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := p.validator.Struct(&body); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	inputs := make([]entity.Product, 0, len(body.Products))
+	for i := range body.Products {
+		inputs = append(inputs, productFromBody(body.Products[i].ID, &body.Products[i].CreateUpdateProductBody))
+	}
+
+	products, productErr := p.productService.BatchUpsertProductsByCircleID(user.UserID, id, inputs)
+	if productErr != nil {
+		return c.
+			Status(productErr.Code).
+			JSON(domain.NewErrorFiber(c, productErr))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": products,
+	})
+}
+
 func (p *ProductHandler) DeleteOneProductByCircleIDAndProductID(c *fiber.Ctx) error {
 	circleID, err := c.ParamsInt("id")
 	if err != nil {
@@ -167,7 +255,7 @@ func (p *ProductHandler) DeleteOneProductByCircleIDAndProductID(c *fiber.Ctx) er
 			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusForbidden, errors.New("FORBIDDEN"), nil)))
 	}
 
-	deleteErr := p.productService.DeleteOneProductByID(circleID, productID)
+	deleteErr := p.productService.DeleteOneProductByID(user.UserID, circleID, productID)
 
 	if deleteErr != nil {
 		return c.
@@ -182,9 +270,196 @@ func (p *ProductHandler) DeleteOneProductByCircleIDAndProductID(c *fiber.Ctx) er
 
 }
 
-func NewProductHandler(productService *ProductService, validator *validator.Validate) *ProductHandler {
+func (p *ProductHandler) PostCreateReservation(c *fiber.Ctx) error {
+	circleID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	productID, err := c.ParamsInt("productid")
+	if err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	var body reservation_dto.CreateReservationPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := p.validator.Struct(&body); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	created, createErr := p.reservationService.CreateReservation(user.UserID, circleID, productID, &body)
+	if createErr != nil {
+		return c.
+			Status(createErr.Code).
+			JSON(domain.NewErrorFiber(c, createErr))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"code": fiber.StatusCreated,
+		"data": created,
+	})
+}
+
+func (p *ProductHandler) GetCircleReservations(c *fiber.Ctx) error {
+	circleID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+	if *user.CircleID != circleID {
+		return c.
+			Status(fiber.StatusForbidden).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusForbidden, errors.New("FORBIDDEN"), nil)))
+	}
+
+	var query reservation_dto.GetPaginatedReservationsFilter
+	if err := c.QueryParser(&query); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := p.validator.Struct(query); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	reservations, reservationErr := p.reservationService.GetPaginatedCircleReservations(circleID, &query)
+	if reservationErr != nil {
+		return c.
+			Status(reservationErr.Code).
+			JSON(domain.NewErrorFiber(c, reservationErr))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code":     fiber.StatusOK,
+		"data":     reservations.Data,
+		"metadata": reservations.Metadata,
+	})
+}
+
+func (p *ProductHandler) PutUpdateReservationStatus(c *fiber.Ctx) error {
+	circleID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	reservationID, err := c.ParamsInt("reservationid")
+	if err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+	if *user.CircleID != circleID {
+		return c.
+			Status(fiber.StatusForbidden).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusForbidden, errors.New("FORBIDDEN"), nil)))
+	}
+
+	var body reservation_dto.UpdateReservationStatusPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := p.validator.Struct(&body); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	updated, updateErr := p.reservationService.UpdateReservationStatus(circleID, reservationID, &body)
+	if updateErr != nil {
+		return c.
+			Status(updateErr.Code).
+			JSON(domain.NewErrorFiber(c, updateErr))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": updated,
+	})
+}
+
+func (p *ProductHandler) GetUserReservations(c *fiber.Ctx) error {
+	var query reservation_dto.GetPaginatedReservationsFilter
+	if err := c.QueryParser(&query); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := p.validator.Struct(query); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	reservations, reservationErr := p.reservationService.GetPaginatedUserReservations(user.UserID, &query)
+	if reservationErr != nil {
+		return c.
+			Status(reservationErr.Code).
+			JSON(domain.NewErrorFiber(c, reservationErr))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code":     fiber.StatusOK,
+		"data":     reservations.Data,
+		"metadata": reservations.Metadata,
+	})
+}
+
+func (p *ProductHandler) DeleteCancelReservation(c *fiber.Ctx) error {
+	reservationID, err := c.ParamsInt("reservationid")
+	if err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	cancelled, cancelErr := p.reservationService.CancelReservation(user.UserID, reservationID)
+	if cancelErr != nil {
+		return c.
+			Status(cancelErr.Code).
+			JSON(domain.NewErrorFiber(c, cancelErr))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": cancelled,
+	})
+}
+
+func NewProductHandler(productService *ProductService, reservationService *reservation.ProductReservationService, validator *validator.Validate) *ProductHandler {
 	return &ProductHandler{
-		productService: productService,
-		validator:      validator,
+		productService:     productService,
+		reservationService: reservationService,
+		validator:          validator,
 	}
 }
@@ -3,18 +3,181 @@ package product
 import (
 	"catalog-be/internal/domain"
 	"catalog-be/internal/entity"
+	"catalog-be/internal/middlewares"
+	"catalog-be/internal/modules/circle/revision"
+	product_dto "catalog-be/internal/modules/product/dto"
+	"catalog-be/internal/modules/upload"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// productEditableColumns are the columns a circle sets on its products.
+var productEditableColumns = []string{"name", "image_url", "price", "currency", "stock", "status", "description"}
+
 type ProductRepo struct {
 	db *gorm.DB
 }
 
+// WithChildren preloads the gallery and the variants of products in their
+// order, with the placeholders of their pictures.
+func WithChildren(db *gorm.DB) *gorm.DB {
+	return db.
+		Select("product.*, "+upload.PlaceholderOf("product.image_url")+" AS image_placeholder").
+		Preload("Images", func(db *gorm.DB) *gorm.DB {
+			return db.
+				Select("product_image.*, " + upload.PlaceholderOf("product_image.url") + " AS placeholder").
+				Order("position asc, id asc")
+		}).
+		Preload("Variants", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position asc, id asc")
+		})
+}
+
+// SaveProductChildren replaces the gallery of product when Images is not nil
+// and syncs its variants when Variants is not nil. Without Images the gallery
+// is kept and ImageURL put back to its first picture, a product with no
+// gallery yet gets one from ImageURL. Variants are matched by id, the ones
+// left out are soft deleted so past reservations keep pointing to them.
+// The uploads the saved product uses are referenced again afterwards.
+func SaveProductChildren(tx *gorm.DB, product *entity.Product) *domain.Error {
+	if product.Images == nil {
+		var gallery []entity.ProductImage
+		err := tx.Where("product_id = ?", product.ID).Order("position asc, id asc").Find(&gallery).Error
+		if err != nil {
+			return domain.NewError(500, err, nil)
+		}
+
+		if len(gallery) == 0 && product.ImageURL != "" {
+			product.Images = []entity.ProductImage{{URL: product.ImageURL}}
+		} else if len(gallery) > 0 && gallery[0].URL != product.ImageURL {
+			product.ImageURL = gallery[0].URL
+			err = tx.Model(&entity.Product{}).Where("id = ?", product.ID).Update("image_url", product.ImageURL).Error
+			if err != nil {
+				return domain.NewError(500, err, nil)
+			}
+		}
+	}
+
+	if product.Images != nil {
+		err := tx.Where("product_id = ?", product.ID).Delete(&entity.ProductImage{}).Error
+		if err != nil {
+			return domain.NewError(500, err, nil)
+		}
+
+		for i := range product.Images {
+			product.Images[i].ID = 0
+			product.Images[i].ProductID = product.ID
+			product.Images[i].Position = i
+		}
+
+		if len(product.Images) > 0 {
+			err = tx.Create(&product.Images).Error
+			if err != nil {
+				return domain.NewError(500, err, nil)
+			}
+
+			product.ImageURL = product.Images[0].URL
+			err = tx.Model(&entity.Product{}).Where("id = ?", product.ID).Update("image_url", product.ImageURL).Error
+			if err != nil {
+				return domain.NewError(500, err, nil)
+			}
+		}
+	}
+
+	if product.Variants != nil {
+		var existing []entity.ProductVariant
+		err := tx.Unscoped().Where("product_id = ?", product.ID).Find(&existing).Error
+		if err != nil {
+			return domain.NewError(500, err, nil)
+		}
+
+		existingByID := make(map[int]bool)
+		for _, variant := range existing {
+			existingByID[variant.ID] = true
+		}
+
+		kept := make(map[int]bool)
+		for i := range product.Variants {
+			variant := &product.Variants[i]
+			variant.ProductID = product.ID
+			variant.Position = i
+			variant.DeletedAt = gorm.DeletedAt{}
+
+			if variant.ID == 0 {
+				err := tx.Create(variant).Error
+				if err != nil {
+					return domain.NewError(500, err, nil)
+				}
+				kept[variant.ID] = true
+				continue
+			}
+
+			if !existingByID[variant.ID] {
+				return domain.NewError(404, errors.New("VARIANT_NOT_FOUND"), nil)
+			}
+
+			err := tx.Unscoped().
+				Model(&entity.ProductVariant{}).
+				Where("id = ?", variant.ID).
+				Select("name", "price", "stock", "position", "deleted_at").
+				Updates(variant).Error
+			if err != nil {
+				return domain.NewError(500, err, nil)
+			}
+			kept[variant.ID] = true
+		}
+
+		var removed []int
+		for _, variant := range existing {
+			if !kept[variant.ID] && !variant.DeletedAt.Valid {
+				removed = append(removed, variant.ID)
+			}
+		}
+
+		if len(removed) > 0 {
+			err := tx.Where("id IN ?", removed).Delete(&entity.ProductVariant{}).Error
+			if err != nil {
+				return domain.NewError(500, err, nil)
+			}
+		}
+	}
+
+	return saveProductReferences(tx, product.ID)
+}
+
+// saveProductReferences references the uploads of the picture, gallery and
+// description of the product as they are saved in tx.
+func saveProductReferences(tx *gorm.DB, productID int) *domain.Error {
+	var saved entity.Product
+	err := tx.
+		Unscoped().
+		Preload("Images").
+		Select("id", "image_url", "description").
+		First(&saved, productID).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	texts := []string{saved.ImageURL}
+	if saved.Description != nil {
+		texts = append(texts, *saved.Description)
+	}
+	for _, image := range saved.Images {
+		texts = append(texts, image.URL)
+	}
+
+	return upload.SaveReferences(tx, entity.UploadReferenceProduct, productID, texts...)
+}
+
 // GetOneProductByProductID implements ProductRepo.
 func (p *ProductRepo) GetOneProductByProductID(id int) (*entity.Product, *domain.Error) {
 	var product entity.Product
-	err := p.db.First(&product, id).Error
+	err := p.db.Scopes(WithChildren).First(&product, id).Error
 	if err != nil {
 		return nil, domain.NewError(500, err, nil)
 	}
@@ -33,14 +196,51 @@ func (p *ProductRepo) CountProductsByCircleID(circleID int) (int, *domain.Error)
 	return int(count), nil
 }
 
+// recordProductRevision implements ProductRepo.
+func (p *ProductRepo) recordProductRevision(tx *gorm.DB, userID int, circleID int, productID int, action entity.RevisionAction, before *entity.Product, after *entity.Product) *domain.Error {
+	return revision.Record(tx, userID, &entity.CircleRevision{
+		CircleID:   circleID,
+		EntityType: entity.RevisionProduct,
+		EntityID:   productID,
+		Action:     action,
+	}, revision.NewProductSnapshot(before), revision.NewProductSnapshot(after))
+}
+
 // CreateOneOneByCircleID implements ProductRepo.
-func (p *ProductRepo) CreateOneOneByCircleID(circleID int, product entity.Product) (*entity.Product, *domain.Error) {
-	err := p.db.Where("circle_id = ?", circleID).Save(&product).Error
+func (p *ProductRepo) CreateOneOneByCircleID(userID int, circleID int, product entity.Product) (*entity.Product, *domain.Error) {
+	tx := p.db.Begin()
+	if tx.Error != nil {
+		return nil, domain.NewError(500, tx.Error, nil)
+	}
+
+	err := tx.Where("circle_id = ?", circleID).Omit(clause.Associations).Save(&product).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	childrenErr := SaveProductChildren(tx, &product)
+	if childrenErr != nil {
+		tx.Rollback()
+		return nil, childrenErr
+	}
+
+	var after entity.Product
+	err = tx.Scopes(WithChildren).First(&after, product.ID).Error
 	if err != nil {
+		tx.Rollback()
 		return nil, domain.NewError(500, err, nil)
 	}
 
-	return &product, nil
+	revisionErr := p.recordProductRevision(tx, userID, circleID, product.ID, entity.RevisionCreate, nil, &after)
+	if revisionErr != nil {
+		tx.Rollback()
+		return nil, revisionErr
+	}
+
+	tx.Commit()
+
+	return &after, nil
 }
 
 // DeleteAllByCircleID implements ProductRepo.
@@ -52,39 +252,58 @@ func (p *ProductRepo) DeleteAllByCircleID(circleID int) *domain.Error {
 	return nil
 }
 
-// BatchUpsertByCircleID implements ProductRepo.
-func (p *ProductRepo) BatchUpsertByCircleID(circleID int, inputs []entity.Product) ([]entity.Product, *domain.Error) {
+// BatchUpsertByCircleID saves the whole product tree of a circle in one
+// transaction. Products with an id are updated, products without one are
+// created and products of the circle left out of inputs are deleted.
+func (p *ProductRepo) BatchUpsertByCircleID(userID int, circleID int, inputs []entity.Product) ([]entity.Product, *domain.Error) {
 	tx := p.db.Begin()
 	if tx.Error != nil {
 		return nil, domain.NewError(500, tx.Error, nil)
 	}
 
+	var previousProducts []entity.Product
+	err := tx.Scopes(WithChildren).Where("circle_id = ?", circleID).Find(&previousProducts).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	previousProductsByID := make(map[int]*entity.Product)
+	for i := range previousProducts {
+		previousProductsByID[previousProducts[i].ID] = &previousProducts[i]
+	}
+
 	createdOrUpdatedProductsIDs := make(map[int]bool)
 
 	for _, input := range inputs {
+		input.CircleID = circleID
+
 		if input.ID == 0 {
-			err := tx.Create(&input).Error
+			err := tx.Omit(clause.Associations).Create(&input).Error
 			if err != nil {
 				tx.Rollback()
 				return nil, domain.NewError(500, err, nil)
 			}
-
-			createdOrUpdatedProductsIDs[input.ID] = true
 		} else {
-			err := tx.Model(&entity.Product{}).Where("id = ? AND circle_id", input.ID, circleID).Updates(input).Error
+			if previousProductsByID[input.ID] == nil {
+				tx.Rollback()
+				return nil, domain.NewError(404, errors.New("PRODUCT_NOT_FOUND"), nil)
+			}
+
+			err := tx.Model(&entity.Product{}).Where("id = ? AND circle_id = ?", input.ID, circleID).Select(productEditableColumns).Updates(&input).Error
 			if err != nil {
 				tx.Rollback()
 				return nil, domain.NewError(500, err, nil)
 			}
-			createdOrUpdatedProductsIDs[input.ID] = true
 		}
-	}
 
-	var previousProducts []entity.Product
-	err := tx.Where("circle_id = ?", circleID).Find(&previousProducts).Error
-	if err != nil {
-		tx.Rollback()
-		return nil, domain.NewError(500, err, nil)
+		childrenErr := SaveProductChildren(tx, &input)
+		if childrenErr != nil {
+			tx.Rollback()
+			return nil, childrenErr
+		}
+
+		createdOrUpdatedProductsIDs[input.ID] = true
 	}
 
 	var idsToDelete []int
@@ -105,13 +324,35 @@ func (p *ProductRepo) BatchUpsertByCircleID(circleID int, inputs []entity.Produc
 
 	var updatedProducts []entity.Product
 
-	err = tx.Where("circle_id = ?", circleID).Find(&updatedProducts).Error
+	err = tx.Scopes(WithChildren).Where("circle_id = ?", circleID).Order("id asc").Find(&updatedProducts).Error
 
 	if err != nil {
 		tx.Rollback()
 		return nil, domain.NewError(500, err, nil)
 	}
 
+	for i := range updatedProducts {
+		before := previousProductsByID[updatedProducts[i].ID]
+		action := entity.RevisionUpdate
+		if before == nil {
+			action = entity.RevisionCreate
+		}
+
+		revisionErr := p.recordProductRevision(tx, userID, circleID, updatedProducts[i].ID, action, before, &updatedProducts[i])
+		if revisionErr != nil {
+			tx.Rollback()
+			return nil, revisionErr
+		}
+	}
+
+	for _, id := range idsToDelete {
+		revisionErr := p.recordProductRevision(tx, userID, circleID, id, entity.RevisionDelete, previousProductsByID[id], nil)
+		if revisionErr != nil {
+			tx.Rollback()
+			return nil, revisionErr
+		}
+	}
+
 	tx.Commit()
 
 	return updatedProducts, nil
@@ -128,19 +369,51 @@ func (p *ProductRepo) CreateOneProduct(product entity.Product) (*entity.Product,
 }
 
 // DeleteOneProductByProductID implements ProductRepo.
-func (p *ProductRepo) DeleteOneProductByProductID(circleID int, id int) *domain.Error {
-	err := p.db.Delete(&entity.Product{}, id).Error
+func (p *ProductRepo) DeleteOneProductByProductID(userID int, circleID int, id int) *domain.Error {
+	tx := p.db.Begin()
+	if tx.Error != nil {
+		return domain.NewError(500, tx.Error, nil)
+	}
+
+	var product entity.Product
+	err := tx.Scopes(WithChildren).Where("id = ? AND circle_id = ?", id, circleID).First(&product).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	err = tx.Omit(clause.Associations).Delete(&product).Error
 	if err != nil {
+		tx.Rollback()
 		return domain.NewError(500, err, nil)
 	}
 
+	revisionErr := p.recordProductRevision(tx, userID, circleID, product.ID, entity.RevisionDelete, &product, nil)
+	if revisionErr != nil {
+		tx.Rollback()
+		return revisionErr
+	}
+
+	tx.Commit()
+
 	return nil
 }
 
-// GetAllProductByCircleID implements ProductRepo.
-func (p *ProductRepo) GetAllProductByCircleID(circleID int) ([]entity.Product, *domain.Error) {
+// GetAllProductByCircleID returns the products of a circle. The ones hidden by
+// moderators, or all of them when the circle is hidden, are only returned
+// when viewerID is the admin or a user of the circle.
+func (p *ProductRepo) GetAllProductByCircleID(circleID int, viewerID int) ([]entity.Product, *domain.Error) {
+	query := p.db.
+		Scopes(WithChildren).
+		Joins("JOIN circle c ON c.id = product.circle_id").
+		Where("product.circle_id = ?", circleID)
+
+	if !middlewares.IsAdmin(viewerID) {
+		query = query.Where(`((product.hidden IS FALSE AND c.hidden IS FALSE) OR EXISTS (SELECT 1 FROM "user" u WHERE u.id = ? AND u.circle_id = c.id))`, viewerID)
+	}
+
 	var products []entity.Product
-	err := p.db.Where("circle_id = ?", circleID).Find(&products).Error
+	err := query.Find(&products).Error
 	if err != nil {
 		return nil, domain.NewError(500, err, nil)
 	}
@@ -149,13 +422,193 @@ func (p *ProductRepo) GetAllProductByCircleID(circleID int) ([]entity.Product, *
 }
 
 // UpdateOneByProductID implements ProductRepo.
-func (p ProductRepo) UpdateOneByProductID(id int, product entity.Product) (*entity.Product, *domain.Error) {
-	err := p.db.Model(&entity.Product{}).Where("id = ?", id).Updates(&product).Error
+func (p ProductRepo) UpdateOneByProductID(userID int, id int, product entity.Product) (*entity.Product, *domain.Error) {
+	tx := p.db.Begin()
+	if tx.Error != nil {
+		return nil, domain.NewError(500, tx.Error, nil)
+	}
+
+	var before entity.Product
+	err := tx.Scopes(WithChildren).First(&before, id).Error
 	if err != nil {
+		tx.Rollback()
 		return nil, domain.NewError(500, err, nil)
 	}
 
-	return &product, nil
+	// every editable column is written so a price, stock or description can be cleared
+	err = tx.Model(&entity.Product{}).Where("id = ?", id).Select(productEditableColumns).Updates(&product).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	product.ID = id
+	childrenErr := SaveProductChildren(tx, &product)
+	if childrenErr != nil {
+		tx.Rollback()
+		return nil, childrenErr
+	}
+
+	var after entity.Product
+	err = tx.Scopes(WithChildren).First(&after, id).Error
+	if err != nil {
+		tx.Rollback()
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	revisionErr := p.recordProductRevision(tx, userID, after.CircleID, id, entity.RevisionUpdate, &before, &after)
+	if revisionErr != nil {
+		tx.Rollback()
+		return nil, revisionErr
+	}
+
+	tx.Commit()
+
+	return &after, nil
+}
+
+// searchProducts builds the product search on `product p` joined with the
+// circle selling it, its attended event and block. Only products and verified
+// circles not hidden by moderators are found, published ones in production.
+func (p *ProductRepo) searchProducts(filter *product_dto.GetPaginatedProductsFilter) *gorm.DB {
+	query := p.db.
+		Table("product p").
+		Joins("JOIN circle c ON c.id = p.circle_id").
+		Joins("LEFT JOIN event e ON c.event_id = e.id").
+		Joins("LEFT JOIN block_event be ON c.id = be.circle_id AND be.event_id = c.event_id").
+		Where("p.deleted_at IS NULL").
+		Where("p.hidden IS FALSE").
+		Where("c.deleted_at IS NULL").
+		Where("c.verified IS TRUE").
+		Where("c.hidden IS FALSE")
+
+	if os.Getenv("APP_STAGE") == "production" {
+		query = query.Where("c.published IS TRUE")
+	}
+
+	if filter.Search != "" {
+		searchQuery := fmt.Sprintf("%%%s%%", filter.Search)
+		query = query.Where("(p.name ILIKE ? OR p.description ILIKE ?)", searchQuery, searchQuery)
+	}
+
+	if filter.Event != "" {
+		query = query.Where("e.slug = ?", filter.Event)
+	}
+
+	if len(filter.FandomIDs) > 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM circle_fandom cf WHERE cf.circle_id = c.id AND cf.fandom_id IN (?))", filter.FandomIDs)
+	}
+
+	if len(filter.WorkTypeIDs) > 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM circle_work_type cwt WHERE cwt.circle_id = c.id AND cwt.work_type_id IN (?))", filter.WorkTypeIDs)
+	}
+
+	if filter.Status != "" {
+		query = query.Where("p.status = ?", filter.Status)
+	}
+
+	if filter.Currency != "" {
+		query = query.Where("p.currency = ?", strings.ToUpper(filter.Currency))
+	}
+
+	if filter.PriceMin != nil || filter.PriceMax != nil {
+		// a variant with its own price replaces the price of its product
+		prices := p.db.
+			Table("product pp").
+			Select("1").
+			Joins("LEFT JOIN product_variant pv ON pv.product_id = pp.id AND pv.deleted_at IS NULL").
+			Where("pp.id = p.id AND COALESCE(pv.price, pp.price) IS NOT NULL").
+			Where("(pv.stock IS NULL OR pv.stock > 0)")
+
+		if filter.PriceMin != nil {
+			prices = prices.Where("COALESCE(pv.price, pp.price) >= ?", *filter.PriceMin)
+		}
+
+		if filter.PriceMax != nil {
+			prices = prices.Where("COALESCE(pv.price, pp.price) <= ?", *filter.PriceMax)
+		}
+
+		query = query.
+			Where("p.status <> ?", entity.ProductSoldOut).
+			Where("EXISTS (?)", prices)
+	}
+
+	return query
+}
+
+// GetPaginatedProducts implements ProductRepo.
+func (p *ProductRepo) GetPaginatedProducts(filter *product_dto.GetPaginatedProductsFilter) ([]entity.ProductJoinedTables, *domain.Error) {
+	var products []entity.ProductJoinedTables
+	err := p.searchProducts(filter).
+		Select(`
+			p.*,
+			c.name AS circle_name,
+			c.slug AS circle_slug,
+			c.picture_url AS circle_picture_url,
+			c.day AS circle_day,
+			CASE WHEN e.id IS NULL THEN NULL ELSE json_build_object(
+				'id', e.id,
+				'name', e.name,
+				'slug', e.slug,
+				'started_at', e.started_at AT TIME ZONE 'UTC',
+				'ended_at', e.ended_at AT TIME ZONE 'UTC'
+			) END AS event,
+			CASE WHEN be.id IS NULL THEN NULL ELSE json_build_object(
+				'id', be.id,
+				'event_id', be.event_id,
+				'circle_id', be.circle_id,
+				'prefix', be.prefix,
+				'postfix', be.postfix,
+				'name', be.name
+			) END AS block_event`).
+		Order("p.id desc").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&products).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	if len(products) == 0 {
+		return products, nil
+	}
+
+	ids := make([]int, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+
+	var children []entity.Product
+	err = p.db.Scopes(WithChildren).Where("id IN ?", ids).Find(&children).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	byID := make(map[int]*entity.Product, len(children))
+	for i := range children {
+		byID[children[i].ID] = &children[i]
+	}
+
+	for i := range products {
+		if child, ok := byID[products[i].ID]; ok {
+			products[i].ImagePlaceholder = child.ImagePlaceholder
+			products[i].Images = child.Images
+			products[i].Variants = child.Variants
+		}
+	}
+
+	return products, nil
+}
+
+// GetAllProductsCount implements ProductRepo.
+func (p *ProductRepo) GetAllProductsCount(filter *product_dto.GetPaginatedProductsFilter) (int, *domain.Error) {
+	var count int64
+	err := p.searchProducts(filter).Count(&count).Error
+	if err != nil {
+		return 0, domain.NewError(500, err, nil)
+	}
+
+	return int(count), nil
 }
 
 func NewProductRepo(
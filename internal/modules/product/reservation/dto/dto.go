@@ -0,0 +1,20 @@
+package reservation_dto
+
+type CreateReservationPayload struct {
+	// VariantID is required when the product has variants.
+	VariantID *int    `json:"variant_id" validate:"omitempty,min=1"`
+	Quantity  int     `json:"quantity" validate:"required,min=1,max=100"`
+	Note      *string `json:"note" validate:"omitempty,max=500"`
+}
+
+type UpdateReservationStatusPayload struct {
+	Status string `json:"status" validate:"required,oneof=accepted declined fulfilled"`
+	// Note is shown to the visitor, e.g. where to pick the order up.
+	Note *string `json:"note" validate:"omitempty,max=500"`
+}
+
+type GetPaginatedReservationsFilter struct {
+	Status string `query:"status" validate:"omitempty,oneof=pending accepted declined fulfilled cancelled"`
+	Page   int    `query:"page" validate:"required,min=1"`
+	Limit  int    `query:"limit" validate:"required,min=1,max=20"`
+}
@@ -0,0 +1,244 @@
+package reservation
+
+import (
+	"catalog-be/internal/domain"
+	"catalog-be/internal/entity"
+	reservation_dto "catalog-be/internal/modules/product/reservation/dto"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProductReservationRepo struct {
+	db *gorm.DB
+}
+
+// withDetails preloads what both sides need to read a reservation, products
+// and variants deleted after the request are kept in the history.
+func withDetails(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Product", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
+		Preload("Variant", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
+		Preload("Circle", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, name, slug, picture_url")
+		}).
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, name, email, profile_picture_url")
+		})
+}
+
+func filterReservations(filter *reservation_dto.GetPaginatedReservationsFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.Status != "" {
+			db = db.Where("status = ?", filter.Status)
+		}
+		return db
+	}
+}
+
+// reservable leaves out the products hidden by moderators and the products of
+// hidden circles, they can not be reserved nor sold.
+func reservable(db *gorm.DB) *gorm.DB {
+	return db.Where(`product.hidden IS FALSE AND EXISTS (SELECT 1 FROM circle c WHERE c.id = product.circle_id AND c.hidden IS FALSE)`)
+}
+
+// GetOneProductByID returns the product when it can be reserved.
+func (r *ProductReservationRepo) GetOneProductByID(id int) (*entity.Product, *domain.Error) {
+	var product entity.Product
+	err := r.db.Scopes(reservable).Preload("Variants").First(&product, id).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &product, nil
+}
+
+// CreateOneReservation implements ProductReservationRepo.
+func (r *ProductReservationRepo) CreateOneReservation(reservation *entity.ProductReservation) *domain.Error {
+	err := r.db.Omit(clause.Associations).Create(reservation).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// GetOneReservationByID implements ProductReservationRepo.
+func (r *ProductReservationRepo) GetOneReservationByID(id int) (*entity.ProductReservation, *domain.Error) {
+	var reservation entity.ProductReservation
+	err := r.db.Scopes(withDetails).First(&reservation, id).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &reservation, nil
+}
+
+// GetPaginatedReservationsByCircleID implements ProductReservationRepo.
+func (r *ProductReservationRepo) GetPaginatedReservationsByCircleID(circleID int, filter *reservation_dto.GetPaginatedReservationsFilter) ([]entity.ProductReservation, *domain.Error) {
+	reservations := []entity.ProductReservation{}
+	err := r.db.
+		Scopes(withDetails, filterReservations(filter)).
+		Where("circle_id = ?", circleID).
+		Order("created_at desc, id desc").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&reservations).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return reservations, nil
+}
+
+// CountReservationsByCircleID implements ProductReservationRepo.
+func (r *ProductReservationRepo) CountReservationsByCircleID(circleID int, filter *reservation_dto.GetPaginatedReservationsFilter) (int, *domain.Error) {
+	var count int64
+	err := r.db.
+		Model(&entity.ProductReservation{}).
+		Scopes(filterReservations(filter)).
+		Where("circle_id = ?", circleID).
+		Count(&count).Error
+	if err != nil {
+		return 0, domain.NewError(500, err, nil)
+	}
+
+	return int(count), nil
+}
+
+// GetPaginatedReservationsByUserID implements ProductReservationRepo.
+func (r *ProductReservationRepo) GetPaginatedReservationsByUserID(userID int, filter *reservation_dto.GetPaginatedReservationsFilter) ([]entity.ProductReservation, *domain.Error) {
+	reservations := []entity.ProductReservation{}
+	err := r.db.
+		Scopes(withDetails, filterReservations(filter)).
+		Where("user_id = ?", userID).
+		Order("created_at desc, id desc").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&reservations).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return reservations, nil
+}
+
+// CountReservationsByUserID implements ProductReservationRepo.
+func (r *ProductReservationRepo) CountReservationsByUserID(userID int, filter *reservation_dto.GetPaginatedReservationsFilter) (int, *domain.Error) {
+	var count int64
+	err := r.db.
+		Model(&entity.ProductReservation{}).
+		Scopes(filterReservations(filter)).
+		Where("user_id = ?", userID).
+		Count(&count).Error
+	if err != nil {
+		return 0, domain.NewError(500, err, nil)
+	}
+
+	return int(count), nil
+}
+
+// takeStock removes the reserved quantity from the variant when it tracks its
+// stock, from the product otherwise. Rows are locked so two circle members
+// accepting at the same time can not sell the same item twice, a product
+// hidden since it was reserved is not sold.
+func (r *ProductReservationRepo) takeStock(tx *gorm.DB, reservation *entity.ProductReservation) *domain.Error {
+	var product entity.Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(reservable).First(&product, reservation.ProductID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.NewError(404, errors.New("PRODUCT_NOT_FOUND"), nil)
+		}
+		return domain.NewError(500, err, nil)
+	}
+
+	if reservation.VariantID != nil {
+		var variant entity.ProductVariant
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, *reservation.VariantID).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return domain.NewError(404, errors.New("VARIANT_NOT_FOUND"), nil)
+			}
+			return domain.NewError(500, err, nil)
+		}
+
+		if variant.Stock != nil {
+			if *variant.Stock < reservation.Quantity {
+				return domain.NewError(409, errors.New("INSUFFICIENT_STOCK"), nil)
+			}
+
+			err = tx.Model(&variant).Update("stock", *variant.Stock-reservation.Quantity).Error
+			if err != nil {
+				return domain.NewError(500, err, nil)
+			}
+			return nil
+		}
+	}
+
+	if product.Stock == nil {
+		return nil
+	}
+
+	if *product.Stock < reservation.Quantity {
+		return domain.NewError(409, errors.New("INSUFFICIENT_STOCK"), nil)
+	}
+
+	stock := *product.Stock - reservation.Quantity
+	updates := map[string]interface{}{"stock": stock}
+	if stock == 0 && product.Status == entity.ProductAvailable {
+		updates["status"] = entity.ProductSoldOut
+	}
+
+	err = tx.Model(&product).Updates(updates).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// UpdateReservationStatus saves the new status of reservation only when it is
+// still from, and takes its stock when it is accepted.
+func (r *ProductReservationRepo) UpdateReservationStatus(reservation *entity.ProductReservation, from entity.ReservationStatus) *domain.Error {
+	tx := r.db.Begin()
+
+	result := tx.
+		Model(&entity.ProductReservation{}).
+		Where("id = ? AND status = ?", reservation.ID, from).
+		Updates(map[string]interface{}{
+			"status":        reservation.Status,
+			"response_note": reservation.ResponseNote,
+			"responded_at":  reservation.RespondedAt,
+			"updated_at":    gorm.Expr("current_timestamp"),
+		})
+	if result.Error != nil {
+		tx.Rollback()
+		return domain.NewError(500, result.Error, nil)
+	}
+
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return domain.NewError(409, errors.New("RESERVATION_STATUS_CHANGED"), nil)
+	}
+
+	if reservation.Status == entity.ReservationAccepted {
+		stockErr := r.takeStock(tx, reservation)
+		if stockErr != nil {
+			tx.Rollback()
+			return stockErr
+		}
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+func NewProductReservationRepo(db *gorm.DB) *ProductReservationRepo {
+	return &ProductReservationRepo{db: db}
+}
@@ -0,0 +1,198 @@
+package reservation
+
+import (
+	"catalog-be/internal/database/factory"
+	"catalog-be/internal/domain"
+	"catalog-be/internal/dto"
+	"catalog-be/internal/entity"
+	reservation_dto "catalog-be/internal/modules/product/reservation/dto"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// reservationTransitions are the statuses a reservation can move to from its
+// current one. Declined, fulfilled and cancelled reservations are final.
+var reservationTransitions = map[entity.ReservationStatus][]entity.ReservationStatus{
+	entity.ReservationPending:  {entity.ReservationAccepted, entity.ReservationDeclined, entity.ReservationCancelled},
+	entity.ReservationAccepted: {entity.ReservationFulfilled},
+}
+
+func canMoveTo(from entity.ReservationStatus, to entity.ReservationStatus) bool {
+	for _, status := range reservationTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+type ProductReservationService struct {
+	repo *ProductReservationRepo
+}
+
+// CreateReservation implements ProductReservationService.
+func (r *ProductReservationService) CreateReservation(userID int, circleID int, productID int, payload *reservation_dto.CreateReservationPayload) (*entity.ProductReservation, *domain.Error) {
+	product, err := r.repo.GetOneProductByID(productID)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("PRODUCT_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	if product.CircleID != circleID {
+		return nil, domain.NewError(404, errors.New("PRODUCT_NOT_FOUND"), nil)
+	}
+
+	if product.Status == entity.ProductSoldOut {
+		return nil, domain.NewError(400, errors.New("PRODUCT_SOLD_OUT"), nil)
+	}
+
+	stock := product.Stock
+	if payload.VariantID != nil {
+		var variant *entity.ProductVariant
+		for i := range product.Variants {
+			if product.Variants[i].ID == *payload.VariantID {
+				variant = &product.Variants[i]
+				break
+			}
+		}
+
+		if variant == nil {
+			return nil, domain.NewError(404, errors.New("VARIANT_NOT_FOUND"), nil)
+		}
+
+		if variant.Stock != nil {
+			stock = variant.Stock
+		}
+	} else if len(product.Variants) > 0 {
+		return nil, domain.NewError(400, errors.New("VARIANT_REQUIRED"), nil)
+	}
+
+	if stock != nil && *stock < payload.Quantity {
+		return nil, domain.NewError(400, errors.New("INSUFFICIENT_STOCK"), nil)
+	}
+
+	reservation := entity.ProductReservation{
+		ProductID: product.ID,
+		VariantID: payload.VariantID,
+		CircleID:  circleID,
+		UserID:    userID,
+		Quantity:  payload.Quantity,
+		Note:      payload.Note,
+		Status:    entity.ReservationPending,
+	}
+
+	err = r.repo.CreateOneReservation(&reservation)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.repo.GetOneReservationByID(reservation.ID)
+}
+
+// GetPaginatedCircleReservations implements ProductReservationService.
+func (r *ProductReservationService) GetPaginatedCircleReservations(circleID int, filter *reservation_dto.GetPaginatedReservationsFilter) (*dto.Pagination[[]entity.ProductReservation], *domain.Error) {
+	reservations, err := r.repo.GetPaginatedReservationsByCircleID(circleID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := r.repo.CountReservationsByCircleID(circleID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.Pagination[[]entity.ProductReservation]{
+		Data:     reservations,
+		Metadata: *factory.GetPaginationMetadata(count, filter.Page, filter.Limit),
+	}, nil
+}
+
+// GetPaginatedUserReservations implements ProductReservationService.
+func (r *ProductReservationService) GetPaginatedUserReservations(userID int, filter *reservation_dto.GetPaginatedReservationsFilter) (*dto.Pagination[[]entity.ProductReservation], *domain.Error) {
+	reservations, err := r.repo.GetPaginatedReservationsByUserID(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := r.repo.CountReservationsByUserID(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.Pagination[[]entity.ProductReservation]{
+		Data:     reservations,
+		Metadata: *factory.GetPaginationMetadata(count, filter.Page, filter.Limit),
+	}, nil
+}
+
+// getReservation returns the reservation only when belongs(reservation) holds.
+func (r *ProductReservationService) getReservation(id int, belongs func(*entity.ProductReservation) bool) (*entity.ProductReservation, *domain.Error) {
+	reservation, err := r.repo.GetOneReservationByID(id)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("RESERVATION_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	if !belongs(reservation) {
+		return nil, domain.NewError(404, errors.New("RESERVATION_NOT_FOUND"), nil)
+	}
+
+	return reservation, nil
+}
+
+func (r *ProductReservationService) moveTo(reservation *entity.ProductReservation, status entity.ReservationStatus) (*entity.ProductReservation, *domain.Error) {
+	from := reservation.Status
+	if !canMoveTo(from, status) {
+		return nil, domain.NewError(409, errors.New("INVALID_RESERVATION_STATUS"), nil)
+	}
+
+	reservation.Status = status
+	err := r.repo.UpdateReservationStatus(reservation, from)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.repo.GetOneReservationByID(reservation.ID)
+}
+
+// UpdateReservationStatus lets a circle accept, decline or fulfil a
+// reservation made on one of its products.
+func (r *ProductReservationService) UpdateReservationStatus(circleID int, id int, payload *reservation_dto.UpdateReservationStatusPayload) (*entity.ProductReservation, *domain.Error) {
+	reservation, err := r.getReservation(id, func(reservation *entity.ProductReservation) bool {
+		return reservation.CircleID == circleID
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	reservation.RespondedAt = &now
+	if payload.Note != nil {
+		reservation.ResponseNote = payload.Note
+	}
+
+	return r.moveTo(reservation, entity.ReservationStatus(payload.Status))
+}
+
+// CancelReservation lets a visitor take back a reservation the circle did not
+// answer yet.
+func (r *ProductReservationService) CancelReservation(userID int, id int) (*entity.ProductReservation, *domain.Error) {
+	reservation, err := r.getReservation(id, func(reservation *entity.ProductReservation) bool {
+		return reservation.UserID == userID
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.moveTo(reservation, entity.ReservationCancelled)
+}
+
+func NewProductReservationService(repo *ProductReservationRepo) *ProductReservationService {
+	return &ProductReservationService{repo: repo}
+}
@@ -1,24 +1,102 @@
 package product
 
 import (
+	"catalog-be/internal/database/factory"
 	"catalog-be/internal/domain"
+	"catalog-be/internal/dto"
 	"catalog-be/internal/entity"
+	"catalog-be/internal/modules/plan"
+	product_dto "catalog-be/internal/modules/product/dto"
 	"errors"
+	"strings"
 
 	"gorm.io/gorm"
 )
 
+// DEFAULT_PRODUCT_CURRENCY is used when a product is saved without a currency.
+var DEFAULT_PRODUCT_CURRENCY = "IDR"
+
 type ProductService struct {
-	repo *ProductRepo
+	repo        *ProductRepo
+	planService *plan.PlanService
+}
+
+// normalizeProduct fills the default currency and status, a product that runs
+// out of stock while available is marked as sold out.
+func normalizeProduct(product *entity.Product) {
+	product.Currency = strings.ToUpper(product.Currency)
+	if product.Currency == "" {
+		product.Currency = DEFAULT_PRODUCT_CURRENCY
+	}
+
+	if product.Status == "" {
+		product.Status = entity.ProductAvailable
+	}
+
+	if product.Stock != nil && *product.Stock == 0 && product.Status == entity.ProductAvailable {
+		product.Status = entity.ProductSoldOut
+	}
+
+	if product.Description != nil && strings.TrimSpace(*product.Description) == "" {
+		product.Description = nil
+	}
+
+	// an update without images keeps the gallery it has
+	if product.ID == 0 && len(product.Images) == 0 && product.ImageURL != "" {
+		product.Images = []entity.ProductImage{{URL: product.ImageURL}}
+	}
+}
+
+// validateProduct checks what the validator can not, a product needs at least
+// one picture and its variants need distinct names.
+func validateProduct(product *entity.Product) *domain.Error {
+	if len(product.Images) == 0 && product.ImageURL == "" {
+		return domain.NewError(400, errors.New("PRODUCT_IMAGE_REQUIRED"), nil)
+	}
+
+	names := make(map[string]bool)
+	for _, variant := range product.Variants {
+		name := strings.ToLower(strings.TrimSpace(variant.Name))
+		if names[name] {
+			return domain.NewError(400, errors.New("DUPLICATED_VARIANT_NAME"), nil)
+		}
+		names[name] = true
+	}
+
+	return nil
+}
+
+// BatchUpsertProductsByCircleID implements ProductService.
+func (p *ProductService) BatchUpsertProductsByCircleID(userID int, circleID int, inputs []entity.Product) ([]entity.Product, *domain.Error) {
+	if err := p.planService.Enforce(circleID, plan.ResourceProduct, len(inputs)); err != nil {
+		return nil, err
+	}
+
+	for i := range inputs {
+		normalizeProduct(&inputs[i])
+		if err := validateProduct(&inputs[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.repo.BatchUpsertByCircleID(userID, circleID, inputs)
 }
 
 // DeleteOneProductByID implements ProductService.
-func (p *ProductService) DeleteOneProductByID(circleID int, id int) *domain.Error {
-	return p.repo.DeleteOneProductByProductID(circleID, id)
+func (p *ProductService) DeleteOneProductByID(userID int, circleID int, id int) *domain.Error {
+	err := p.repo.DeleteOneProductByProductID(userID, circleID, id)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return domain.NewError(404, errors.New("PRODUCT_NOT_FOUND"), nil)
+		}
+		return err
+	}
+
+	return nil
 }
 
 // UpdateOneProductByCircleAndProductID implements ProductService.
-func (p *ProductService) UpdateOneProductByCircleAndProductID(circleID int, input entity.Product) (*entity.Product, *domain.Error) {
+func (p *ProductService) UpdateOneProductByCircleAndProductID(userID int, circleID int, input entity.Product) (*entity.Product, *domain.Error) {
 	check, err := p.repo.GetOneProductByProductID(input.ID)
 	if err != nil {
 		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
@@ -32,7 +110,12 @@ func (p *ProductService) UpdateOneProductByCircleAndProductID(circleID int, inpu
 		return nil, domain.NewError(403, errors.New("FORBIDDEN"), nil)
 	}
 
-	return p.repo.UpdateOneByProductID(input.ID, input)
+	normalizeProduct(&input)
+	if err := validateProduct(&input); err != nil {
+		return nil, err
+	}
+
+	return p.repo.UpdateOneByProductID(userID, input.ID, input)
 }
 
 // CountProductsByCircleID implements ProductService.
@@ -41,24 +124,91 @@ func (p *ProductService) CountProductsByCircleID(circleID int) (int, *domain.Err
 }
 
 // CreateOneProductByCircleID implements ProductService.
-func (p *ProductService) CreateOneProductByCircleID(circleID int, input entity.Product) (*entity.Product, *domain.Error) {
+func (p *ProductService) CreateOneProductByCircleID(userID int, circleID int, input entity.Product) (*entity.Product, *domain.Error) {
+	count, err := p.repo.CountProductsByCircleID(circleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.planService.Enforce(circleID, plan.ResourceProduct, count+1); err != nil {
+		return nil, err
+	}
+
 	product := entity.Product{
-		ID:       input.ID,
-		CircleID: circleID,
-		Name:     input.Name,
-		ImageURL: input.ImageURL,
+		ID:          input.ID,
+		CircleID:    circleID,
+		Name:        input.Name,
+		ImageURL:    input.ImageURL,
+		Price:       input.Price,
+		Currency:    input.Currency,
+		Stock:       input.Stock,
+		Status:      input.Status,
+		Description: input.Description,
+		Images:      input.Images,
+		Variants:    input.Variants,
+	}
+	normalizeProduct(&product)
+	if err := validateProduct(&product); err != nil {
+		return nil, err
 	}
 
-	return p.repo.CreateOneOneByCircleID(circleID, product)
+	return p.repo.CreateOneOneByCircleID(userID, circleID, product)
+}
+
+// GetAllProductsByCircleID returns the products of a circle viewerID can see.
+func (p *ProductService) GetAllProductsByCircleID(circleID int, viewerID int) ([]entity.Product, *domain.Error) {
+	return p.repo.GetAllProductByCircleID(circleID, viewerID)
 }
 
-// GetAllProductsByCircleID implements ProductService.
-func (p *ProductService) GetAllProductsByCircleID(circleID int) ([]entity.Product, *domain.Error) {
-	return p.repo.GetAllProductByCircleID(circleID)
+// GetPaginatedProducts implements ProductService.
+func (p *ProductService) GetPaginatedProducts(filter *product_dto.GetPaginatedProductsFilter) (*dto.Pagination[[]product_dto.ProductSearchResponse], *domain.Error) {
+	if filter.PriceMin != nil && filter.PriceMax != nil && *filter.PriceMin > *filter.PriceMax {
+		return nil, domain.NewError(400, errors.New("INVALID_PRICE_RANGE"), nil)
+	}
+
+	rows, err := p.repo.GetPaginatedProducts(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := p.repo.GetAllProductsCount(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]product_dto.ProductSearchResponse, 0, len(rows))
+	for _, row := range rows {
+		circle := product_dto.ProductCircleResponse{
+			ID:         row.CircleID,
+			Name:       row.CircleName,
+			Slug:       row.CircleSlug,
+			PictureURL: row.CirclePictureURL,
+			Day:        row.CircleDay,
+			Event:      row.Event,
+		}
+
+		if row.BlockEvent != nil {
+			circle.Block = &product_dto.ProductBlockResponse{
+				ID:   row.BlockEvent.ID,
+				Name: row.BlockEvent.Name,
+			}
+		}
+
+		response = append(response, product_dto.ProductSearchResponse{
+			Product: row.Product,
+			Circle:  circle,
+		})
+	}
+
+	return &dto.Pagination[[]product_dto.ProductSearchResponse]{
+		Data:     response,
+		Metadata: *factory.GetPaginationMetadata(count, filter.Page, filter.Limit),
+	}, nil
 }
 
-func NewProductService(repo *ProductRepo) *ProductService {
+func NewProductService(repo *ProductRepo, planService *plan.PlanService) *ProductService {
 	return &ProductService{
-		repo: repo,
+		repo:        repo,
+		planService: planService,
 	}
 }
@@ -1,5 +1,42 @@
 package report_dto
 
 type CreateReportPayload struct {
-	Reason string `json:"reason" validate:"required,min=3,max=255"`
+	// Category is the slug of a report category.
+	Category string `json:"category" validate:"required,max=50"`
+	// Reason gives details, it is optional with a category.
+	Reason string `json:"reason" validate:"omitempty,min=3,max=255"`
+}
+
+type UpdateReportCategoryPayload struct {
+	Name   string `json:"name" validate:"required,min=1,max=100"`
+	Weight int    `json:"weight" validate:"min=0"`
+}
+
+// GetPaginatedReportedTargetsFilter narrows the reports counted for every
+// target, targets without any matching report are left out.
+type GetPaginatedReportedTargetsFilter struct {
+	TargetType string `query:"target_type" validate:"omitempty,oneof=circle product user"`
+	Status     string `query:"status" validate:"omitempty,oneof=open assigned resolved dismissed"`
+	AssignedTo int    `query:"assigned_to" validate:"omitempty,min=1"`
+	Search     string `query:"search" validate:"omitempty,max=255"`
+	Page       int    `query:"page" validate:"required,min=1"`
+	Limit      int    `query:"limit" validate:"required,min=1,max=20"`
+}
+
+type AssignReportPayload struct {
+	// AssigneeID is the moderator taking the report, nil assigns it to
+	// whoever sends the request.
+	AssigneeID *int `json:"assignee_id" validate:"omitempty,min=1"`
+}
+
+type ReviewReportPayload struct {
+	Status string  `json:"status" validate:"required,oneof=resolved dismissed"`
+	Note   *string `json:"note" validate:"omitempty,max=500"`
+}
+
+type ReportActionPayload struct {
+	Action string `json:"action" validate:"required,oneof=unpublish republish hide unhide warn"`
+	// Message is sent to the circle of the target, required to warn it.
+	Message string  `json:"message" validate:"required_if=Action warn,omitempty,max=1000"`
+	Note    *string `json:"note" validate:"omitempty,max=500"`
 }
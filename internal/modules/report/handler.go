@@ -5,6 +5,7 @@ import (
 	"catalog-be/internal/entity"
 	auth_dto "catalog-be/internal/modules/auth/dto"
 	report_dto "catalog-be/internal/modules/report/dto"
+	"errors"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -26,7 +27,20 @@ func NewReportHandler(
 }
 
 func (rh *ReportHandler) PostCreateOneReportCircle(c *fiber.Ctx) error {
-	circleID, err := c.ParamsInt("id")
+	return rh.createReport(c, entity.ReportTargetCircle)
+}
+
+func (rh *ReportHandler) PostCreateOneReportProduct(c *fiber.Ctx) error {
+	return rh.createReport(c, entity.ReportTargetProduct)
+}
+
+func (rh *ReportHandler) PostCreateOneReportUser(c *fiber.Ctx) error {
+	return rh.createReport(c, entity.ReportTargetUser)
+}
+
+// createReport reports the target with the id of the route.
+func (rh *ReportHandler) createReport(c *fiber.Ctx, targetType entity.ReportTarget) error {
+	targetID, err := c.ParamsInt("id")
 	if err != nil {
 		return c.
 			Status(fiber.StatusBadRequest).
@@ -48,10 +62,12 @@ func (rh *ReportHandler) PostCreateOneReportCircle(c *fiber.Ctx) error {
 
 	user := c.Locals("user").(*auth_dto.ATClaims)
 
-	serviceErr := rh.reportService.CreateReportCircle(&entity.Report{
-		UserID:   user.UserID,
-		CircleID: circleID,
-		Reason:   body.Reason,
+	serviceErr := rh.reportService.CreateReport(&entity.Report{
+		UserID:     user.UserID,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Category:   &body.Category,
+		Reason:     body.Reason,
 	})
 	if serviceErr != nil {
 		return c.
@@ -64,3 +80,282 @@ func (rh *ReportHandler) PostCreateOneReportCircle(c *fiber.Ctx) error {
 		"data": true,
 	})
 }
+
+// GetAllReportCategories lists the categories a circle can be reported for.
+func (rh *ReportHandler) GetAllReportCategories(c *fiber.Ctx) error {
+	categories, err := rh.reportService.GetAllCategories()
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": categories,
+	})
+}
+
+// PutUpdateReportCategory changes the name and weight of a category.
+func (rh *ReportHandler) PutUpdateReportCategory(c *fiber.Ctx) error {
+	var body report_dto.UpdateReportCategoryPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := rh.validator.Struct(&body); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	category, err := rh.reportService.UpdateCategory(c.Params("slug"), &body)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": category,
+	})
+}
+
+// GetPaginatedReportedTargets lists the circles, products and users with
+// reports for moderators.
+func (rh *ReportHandler) GetPaginatedReportedTargets(c *fiber.Ctx) error {
+	var query report_dto.GetPaginatedReportedTargetsFilter
+	if err := c.QueryParser(&query); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := rh.validator.Struct(query); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	targets, serviceErr := rh.reportService.GetPaginatedReportedTargets(&query)
+	if serviceErr != nil {
+		return c.
+			Status(serviceErr.Code).
+			JSON(domain.NewErrorFiber(c, serviceErr))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code":     fiber.StatusOK,
+		"data":     targets.Data,
+		"metadata": targets.Metadata,
+	})
+}
+
+func (rh *ReportHandler) GetAllReportsByCircleID(c *fiber.Ctx) error {
+	circleID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	reports, serviceErr := rh.reportService.FindAllReportByCircleID(circleID)
+	if serviceErr != nil {
+		return c.
+			Status(serviceErr.Code).
+			JSON(domain.NewErrorFiber(c, serviceErr))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": reports,
+	})
+}
+
+func (rh *ReportHandler) GetAllReportsByProductID(c *fiber.Ctx) error {
+	return rh.getReportsByTarget(c, entity.ReportTargetProduct)
+}
+
+func (rh *ReportHandler) GetAllReportsByUserID(c *fiber.Ctx) error {
+	return rh.getReportsByTarget(c, entity.ReportTargetUser)
+}
+
+// getReportsByTarget lists the reports on the target with the id of the route.
+func (rh *ReportHandler) getReportsByTarget(c *fiber.Ctx, targetType entity.ReportTarget) error {
+	targetID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	reports, serviceErr := rh.reportService.FindAllReportByTarget(targetType, targetID)
+	if serviceErr != nil {
+		return c.
+			Status(serviceErr.Code).
+			JSON(domain.NewErrorFiber(c, serviceErr))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": reports,
+	})
+}
+
+func (rh *ReportHandler) GetOneReportByID(c *fiber.Ctx) error {
+	reportID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	report, serviceErr := rh.reportService.FindReportByID(reportID)
+	if serviceErr != nil {
+		return c.
+			Status(serviceErr.Code).
+			JSON(domain.NewErrorFiber(c, serviceErr))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": report,
+	})
+}
+
+func (rh *ReportHandler) PutAssignReport(c *fiber.Ctx) error {
+	reportID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	var body report_dto.AssignReportPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := rh.validator.Struct(&body); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	report, serviceErr := rh.reportService.AssignReport(user.UserID, reportID, &body)
+	if serviceErr != nil {
+		return c.
+			Status(serviceErr.Code).
+			JSON(domain.NewErrorFiber(c, serviceErr))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": report,
+	})
+}
+
+// PutReviewReport resolves or dismisses a report with a note.
+func (rh *ReportHandler) PutReviewReport(c *fiber.Ctx) error {
+	reportID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	var body report_dto.ReviewReportPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := rh.validator.Struct(&body); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	report, serviceErr := rh.reportService.ReviewReport(user.UserID, reportID, &body)
+	if serviceErr != nil {
+		return c.
+			Status(serviceErr.Code).
+			JSON(domain.NewErrorFiber(c, serviceErr))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": report,
+	})
+}
+
+func (rh *ReportHandler) PostReportAction(c *fiber.Ctx) error {
+	reportID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	var body report_dto.ReportActionPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := rh.validator.Struct(&body); err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	report, serviceErr := rh.reportService.TakeAction(user.UserID, reportID, &body)
+	if serviceErr != nil {
+		return c.
+			Status(serviceErr.Code).
+			JSON(domain.NewErrorFiber(c, serviceErr))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": report,
+	})
+}
+
+// GetCircleWarnings shows a circle the warnings moderators sent it.
+func (rh *ReportHandler) GetCircleWarnings(c *fiber.Ctx) error {
+	circleID, err := c.ParamsInt("circleid")
+	if err != nil {
+		return c.
+			Status(fiber.StatusBadRequest).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	user := c.Locals("user").(*auth_dto.ATClaims)
+	if *user.CircleID != circleID {
+		return c.
+			Status(fiber.StatusForbidden).
+			JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusForbidden, errors.New("FORBIDDEN"), nil)))
+	}
+
+	warnings, serviceErr := rh.reportService.GetCircleWarnings(circleID)
+	if serviceErr != nil {
+		return c.
+			Status(serviceErr.Code).
+			JSON(domain.NewErrorFiber(c, serviceErr))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": warnings,
+	})
+}
@@ -3,10 +3,16 @@ package report
 import (
 	"catalog-be/internal/domain"
 	"catalog-be/internal/entity"
+	report_dto "catalog-be/internal/modules/report/dto"
+	"errors"
+	"fmt"
 
 	"gorm.io/gorm"
 )
 
+// pendingStatuses are the statuses of reports a moderator still has to close.
+var pendingStatuses = []entity.ReportStatus{entity.ReportOpen, entity.ReportAssigned}
+
 type ReportRepo struct {
 	db *gorm.DB
 }
@@ -16,15 +22,138 @@ func NewReportRepo(db *gorm.DB) *ReportRepo {
 	return &ReportRepo{db}
 }
 
-// Create Report for Circle
-func (r *ReportRepo) CreateReportCircle(report *entity.Report) *domain.Error {
+// Create Report on a circle, product or user
+func (r *ReportRepo) CreateReport(report *entity.Report) *domain.Error {
 	err := r.db.Table("report").Create(report).Error
 	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return domain.NewError(409, errors.New("REPORT_ALREADY_OPEN"), nil)
+		}
+		if errors.Is(err, gorm.ErrForeignKeyViolated) {
+			return domain.NewError(404, errors.New("REPORT_CATEGORY_NOT_FOUND"), nil)
+		}
 		return domain.NewError(500, err, nil)
 	}
 	return nil
 }
 
+// GetTargetCircleID returns the circle a product or user belongs to, nil for
+// a user without a circle. It fails with gorm.ErrRecordNotFound when the
+// target does not exist.
+func (r *ReportRepo) GetTargetCircleID(targetType entity.ReportTarget, targetID int) (*int, *domain.Error) {
+	var target struct {
+		CircleID *int
+	}
+
+	var model interface{} = &entity.User{}
+	if targetType == entity.ReportTargetProduct {
+		model = &entity.Product{}
+	}
+
+	result := r.db.Model(model).Select("circle_id").Where("id = ?", targetID).Limit(1).Scan(&target)
+	if result.Error != nil {
+		return nil, domain.NewError(500, result.Error, nil)
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, domain.NewError(500, gorm.ErrRecordNotFound, nil)
+	}
+
+	return target.CircleID, nil
+}
+
+// GetPendingWeightByTarget adds up the category weights of the pending
+// reports on the target, reports without a category weigh nothing.
+func (r *ReportRepo) GetPendingWeightByTarget(targetType entity.ReportTarget, targetID int) (int, *domain.Error) {
+	var weight int
+	err := r.db.
+		Table("report r").
+		Joins("JOIN report_category rc ON rc.slug = r.category").
+		Where("r.target_type = ? AND r.target_id = ? AND r.status IN ?", targetType, targetID, pendingStatuses).
+		Select("coalesce(sum(rc.weight), 0)").
+		Scan(&weight).Error
+	if err != nil {
+		return 0, domain.NewError(500, err, nil)
+	}
+
+	return weight, nil
+}
+
+// HideCircle hides the circle as automatically hidden, it returns false when
+// it already was hidden.
+func (r *ReportRepo) HideCircle(circleID int) (bool, *domain.Error) {
+	result := r.db.
+		Model(&entity.Circle{}).
+		Where("id = ? AND hidden IS FALSE", circleID).
+		Updates(map[string]interface{}{"hidden": true, "hidden_automatically": true})
+	if result.Error != nil {
+		return false, domain.NewError(500, result.Error, nil)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// HideProduct hides the product as automatically hidden, it returns false
+// when it already was hidden.
+func (r *ReportRepo) HideProduct(productID int) (bool, *domain.Error) {
+	result := r.db.
+		Model(&entity.Product{}).
+		Where("id = ? AND hidden IS FALSE", productID).
+		Updates(map[string]interface{}{"hidden": true, "hidden_automatically": true})
+	if result.Error != nil {
+		return false, domain.NewError(500, result.Error, nil)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// UnhideAutomaticallyHidden shows the circle or product again when its
+// reports hid it, one hidden by a moderator stays hidden.
+func (r *ReportRepo) UnhideAutomaticallyHidden(targetType entity.ReportTarget, targetID int) (bool, *domain.Error) {
+	var model interface{} = &entity.Circle{}
+	if targetType == entity.ReportTargetProduct {
+		model = &entity.Product{}
+	}
+
+	result := r.db.
+		Model(model).
+		Where("id = ? AND hidden_automatically IS TRUE", targetID).
+		Updates(map[string]interface{}{"hidden": false, "hidden_automatically": false})
+	if result.Error != nil {
+		return false, domain.NewError(500, result.Error, nil)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// GetAllCategories implements ReportRepo.
+func (r *ReportRepo) GetAllCategories() ([]entity.ReportCategory, *domain.Error) {
+	categories := []entity.ReportCategory{}
+	err := r.db.Order("weight desc, slug asc").Find(&categories).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return categories, nil
+}
+
+// UpdateOneCategory implements ReportRepo.
+func (r *ReportRepo) UpdateOneCategory(category *entity.ReportCategory) *domain.Error {
+	result := r.db.
+		Model(category).
+		Select("name", "weight", "updated_at").
+		Updates(category)
+	if result.Error != nil {
+		return domain.NewError(500, result.Error, nil)
+	}
+
+	if result.RowsAffected == 0 {
+		return domain.NewError(404, errors.New("REPORT_CATEGORY_NOT_FOUND"), nil)
+	}
+
+	return nil
+}
+
 // Find certain Report by ID
 func (r *ReportRepo) FindByID(id int) (*entity.Report, *domain.Error) {
 	report := new(entity.Report)
@@ -35,12 +164,209 @@ func (r *ReportRepo) FindByID(id int) (*entity.Report, *domain.Error) {
 	return report, nil
 }
 
-// Find All Report by Circle ID
+// Find All Report by Circle ID, the reports on its products and members
+// included
 func (r *ReportRepo) FindAllByCircleID(circleID int) ([]entity.Report, *domain.Error) {
 	var reports []entity.Report
-	err := r.db.Table("report").Where("circle_id = ?", circleID).Find(&reports).Error
+	err := r.db.Table("report").Where("circle_id = ?", circleID).Order("created_at desc, id desc").Find(&reports).Error
 	if err != nil {
 		return nil, domain.NewError(500, err, nil)
 	}
 	return reports, nil
 }
+
+// Find All Report on a target
+func (r *ReportRepo) FindAllByTarget(targetType entity.ReportTarget, targetID int) ([]entity.Report, *domain.Error) {
+	var reports []entity.Report
+	err := r.db.Table("report").Where("target_type = ? AND target_id = ?", targetType, targetID).Order("created_at desc, id desc").Find(&reports).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+	return reports, nil
+}
+
+// reportedTargets groups the reports matching filter by target, joined with
+// the circle, product or user they are about.
+func (r *ReportRepo) reportedTargets(filter *report_dto.GetPaginatedReportedTargetsFilter) *gorm.DB {
+	query := r.db.
+		Table("report r").
+		Joins("LEFT JOIN circle c ON r.target_type = ? AND c.id = r.target_id", entity.ReportTargetCircle).
+		Joins("LEFT JOIN product p ON r.target_type = ? AND p.id = r.target_id", entity.ReportTargetProduct).
+		Joins(`LEFT JOIN "user" u ON r.target_type = ? AND u.id = r.target_id`, entity.ReportTargetUser).
+		Joins("LEFT JOIN report_category rc ON rc.slug = r.category").
+		Where("COALESCE(c.id, p.id, u.id) IS NOT NULL").
+		Where("COALESCE(c.deleted_at, p.deleted_at, u.deleted_at) IS NULL")
+
+	if filter.TargetType != "" {
+		query = query.Where("r.target_type = ?", filter.TargetType)
+	}
+
+	if filter.Status != "" {
+		query = query.Where("r.status = ?", filter.Status)
+	}
+
+	if filter.AssignedTo != 0 {
+		query = query.Where("r.assigned_to = ?", filter.AssignedTo)
+	}
+
+	if filter.Search != "" {
+		query = query.Where("COALESCE(c.name, p.name, u.name) ILIKE ?", fmt.Sprintf("%%%s%%", filter.Search))
+	}
+
+	return query
+}
+
+// GetPaginatedReportedTargets lists the targets with the heaviest pending
+// reports first, then the most pending reports, then the most recently
+// reported.
+func (r *ReportRepo) GetPaginatedReportedTargets(filter *report_dto.GetPaginatedReportedTargetsFilter) ([]entity.ReportedTarget, *domain.Error) {
+	targets := []entity.ReportedTarget{}
+	err := r.reportedTargets(filter).
+		Select(`
+			r.target_type,
+			r.target_id,
+			COALESCE(c.id, p.circle_id, u.circle_id) AS circle_id,
+			COALESCE(c.name, p.name, u.name) AS name,
+			c.slug,
+			COALESCE(c.picture_url, p.image_url, u.profile_picture_url) AS picture_url,
+			c.published,
+			COALESCE(c.hidden, p.hidden, false) AS hidden,
+			count(*) AS report_count,
+			count(*) FILTER (WHERE r.status IN ?) AS pending_count,
+			coalesce(sum(rc.weight) FILTER (WHERE r.status IN ?), 0) AS pending_weight,
+			max(r.created_at) AS last_reported_at`, pendingStatuses, pendingStatuses).
+		Group("r.target_type, r.target_id, c.id, p.id, u.id").
+		Order("pending_weight desc, pending_count desc, last_reported_at desc, r.target_type asc, r.target_id desc").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Scan(&targets).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return targets, nil
+}
+
+// CountReportedTargets implements ReportRepo.
+func (r *ReportRepo) CountReportedTargets(filter *report_dto.GetPaginatedReportedTargetsFilter) (int, *domain.Error) {
+	var count int64
+	err := r.reportedTargets(filter).
+		Select("count(DISTINCT (r.target_type, r.target_id))").
+		Scan(&count).Error
+	if err != nil {
+		return 0, domain.NewError(500, err, nil)
+	}
+
+	return int(count), nil
+}
+
+// UpdatePendingReport saves the assignment or the review of report only when
+// it is still pending, so two moderators can not close the same report.
+func (r *ReportRepo) UpdatePendingReport(report *entity.Report) *domain.Error {
+	result := r.db.
+		Model(report).
+		Where("status IN ?", pendingStatuses).
+		Select("status", "assigned_to", "note", "reviewed_by", "reviewed_at", "updated_at").
+		Updates(&entity.Report{
+			Status:     report.Status,
+			AssignedTo: report.AssignedTo,
+			Note:       report.Note,
+			ReviewedBy: report.ReviewedBy,
+			ReviewedAt: report.ReviewedAt,
+		})
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrForeignKeyViolated) {
+			return domain.NewError(404, errors.New("ASSIGNEE_NOT_FOUND"), nil)
+		}
+		return domain.NewError(500, result.Error, nil)
+	}
+
+	if result.RowsAffected == 0 {
+		return domain.NewError(409, errors.New("REPORT_ALREADY_CLOSED"), nil)
+	}
+
+	return nil
+}
+
+// ApplyAction takes the action of review on its target and resolves every
+// pending report of the target with it. warning is only sent for warn.
+func (r *ReportRepo) ApplyAction(review *entity.Report, warning *entity.CircleWarning) *domain.Error {
+	tx := r.db.Begin()
+
+	resolved := &entity.Report{
+		Status:     entity.ReportResolved,
+		Note:       review.Note,
+		Action:     review.Action,
+		ReviewedBy: review.ReviewedBy,
+		ReviewedAt: review.ReviewedAt,
+	}
+	columns := []string{"status", "note", "action", "reviewed_by", "reviewed_at", "updated_at"}
+
+	// review is closed first, a report already closed by another moderator
+	// does not take its action again
+	result := tx.
+		Model(&entity.Report{}).
+		Where("id = ? AND status IN ?", review.ID, pendingStatuses).
+		Select(columns).
+		Updates(resolved)
+	if result.Error != nil {
+		tx.Rollback()
+		return domain.NewError(500, result.Error, nil)
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return domain.NewError(409, errors.New("REPORT_ALREADY_CLOSED"), nil)
+	}
+
+	var model interface{} = &entity.Circle{}
+	if review.TargetType == entity.ReportTargetProduct {
+		model = &entity.Product{}
+	}
+
+	var err error
+	target := tx.Model(model).Where("id = ?", review.TargetID)
+	switch *review.Action {
+	case entity.ReportActionUnpublish:
+		err = target.Updates(map[string]interface{}{"published": false, "unpublished_by_moderator": true}).Error
+	case entity.ReportActionRepublish:
+		err = target.Updates(map[string]interface{}{"published": true, "unpublished_by_moderator": false}).Error
+	case entity.ReportActionHide:
+		err = target.Updates(map[string]interface{}{"hidden": true, "hidden_automatically": false}).Error
+	case entity.ReportActionUnhide:
+		err = target.Updates(map[string]interface{}{"hidden": false, "hidden_automatically": false}).Error
+	case entity.ReportActionWarn:
+		err = tx.Create(warning).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	err = tx.
+		Model(&entity.Report{}).
+		Where("target_type = ? AND target_id = ? AND status IN ?", review.TargetType, review.TargetID, pendingStatuses).
+		Select(columns).
+		Updates(resolved).Error
+	if err != nil {
+		tx.Rollback()
+		return domain.NewError(500, err, nil)
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+// GetAllWarningsByCircleID implements ReportRepo.
+func (r *ReportRepo) GetAllWarningsByCircleID(circleID int) ([]entity.CircleWarning, *domain.Error) {
+	warnings := []entity.CircleWarning{}
+	err := r.db.
+		Where("circle_id = ?", circleID).
+		Order("created_at desc, id desc").
+		Find(&warnings).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return warnings, nil
+}
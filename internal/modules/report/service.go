@@ -1,44 +1,300 @@
 package report
 
 import (
+	"catalog-be/internal/database/factory"
 	"catalog-be/internal/domain"
+	"catalog-be/internal/dto"
 	"catalog-be/internal/entity"
 	"catalog-be/internal/modules/circle"
+	report_dto "catalog-be/internal/modules/report/dto"
+	"catalog-be/internal/utils"
 	"errors"
+	"slices"
+	"strconv"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// DEFAULT_HIDE_THRESHOLD is the pending report weight hiding a circle when
+// REPORT_HIDE_THRESHOLD is not set.
+const DEFAULT_HIDE_THRESHOLD = 10
+
 type ReportService struct {
 	repo       *ReportRepo
 	circleRepo *circle.CircleRepo
+	// hideThreshold is the weight the pending reports of a circle have to
+	// reach to hide it until a moderator reviews them, 0 never hides.
+	hideThreshold int
 }
 
 // Initialize Circle Report Service
-func NewReportService(repo *ReportRepo, circleRepo *circle.CircleRepo) *ReportService {
+func NewReportService(repo *ReportRepo, circleRepo *circle.CircleRepo, utils utils.Utils) *ReportService {
+	hideThreshold, err := strconv.Atoi(utils.GetEnv("REPORT_HIDE_THRESHOLD", strconv.Itoa(DEFAULT_HIDE_THRESHOLD)))
+	if err != nil || hideThreshold < 0 {
+		hideThreshold = DEFAULT_HIDE_THRESHOLD
+	}
+
 	return &ReportService{
 		repo,
 		circleRepo,
+		hideThreshold,
 	}
 }
 
-// CreateReport implements CircleReportService
-func (r *ReportService) CreateReportCircle(report *entity.Report) *domain.Error {
-	if _, err := r.circleRepo.GetOneCircleByCircleID(report.CircleID); err != nil {
+// targetActions are the actions a moderator can take on each report target,
+// users can only be warned through their circle.
+var targetActions = map[entity.ReportTarget][]entity.ReportAction{
+	entity.ReportTargetCircle:  {entity.ReportActionUnpublish, entity.ReportActionRepublish, entity.ReportActionHide, entity.ReportActionUnhide, entity.ReportActionWarn},
+	entity.ReportTargetProduct: {entity.ReportActionHide, entity.ReportActionUnhide, entity.ReportActionWarn},
+	entity.ReportTargetUser:    {entity.ReportActionWarn},
+}
+
+// CreateReport implements ReportService, it fills the circle the target
+// belongs to.
+func (r *ReportService) CreateReport(report *entity.Report) *domain.Error {
+	circleID, err := r.findTargetCircleID(report.TargetType, report.TargetID)
+	if err != nil {
+		return err
+	}
+
+	report.CircleID = circleID
+	if err := r.repo.CreateReport(report); err != nil {
+		return err
+	}
+
+	return r.hideIfOverThreshold(report.TargetType, report.TargetID)
+}
+
+func (r *ReportService) findTargetCircleID(targetType entity.ReportTarget, targetID int) (*int, *domain.Error) {
+	if targetType == entity.ReportTargetCircle {
+		circle, err := r.circleRepo.GetOneCircleByCircleID(targetID)
+		if err != nil {
+			if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+				return nil, domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
+			}
+			return nil, err
+		}
+		return &circle.ID, nil
+	}
+
+	circleID, err := r.repo.GetTargetCircleID(targetType, targetID)
+	if err != nil {
 		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
-			return domain.NewError(404, errors.New("CIRCLE_NOT_FOUND"), nil)
+			if targetType == entity.ReportTargetProduct {
+				return nil, domain.NewError(404, errors.New("PRODUCT_NOT_FOUND"), nil)
+			}
+			return nil, domain.NewError(404, errors.New("USER_NOT_FOUND"), nil)
 		}
+		return nil, err
+	}
+
+	return circleID, nil
+}
+
+// hideIfOverThreshold hides the circle or product once its pending reports
+// weigh hideThreshold, they stay open for a moderator to confirm or unhide
+// it. Users are never hidden.
+func (r *ReportService) hideIfOverThreshold(targetType entity.ReportTarget, targetID int) *domain.Error {
+	if r.hideThreshold == 0 || targetType == entity.ReportTargetUser {
+		return nil
+	}
+
+	weight, err := r.repo.GetPendingWeightByTarget(targetType, targetID)
+	if err != nil {
 		return err
 	}
-	return r.repo.CreateReportCircle(report)
+
+	if weight < r.hideThreshold {
+		return nil
+	}
+
+	if targetType == entity.ReportTargetProduct {
+		_, err = r.repo.HideProduct(targetID)
+		return err
+	}
+
+	_, err = r.repo.HideCircle(targetID)
+	return err
+}
+
+// unhideIfUnderThreshold shows the circle or product hidden by its pending
+// reports again once a review leaves them weighing less than hideThreshold.
+func (r *ReportService) unhideIfUnderThreshold(targetType entity.ReportTarget, targetID int) *domain.Error {
+	if targetType == entity.ReportTargetUser {
+		return nil
+	}
+
+	weight, err := r.repo.GetPendingWeightByTarget(targetType, targetID)
+	if err != nil {
+		return err
+	}
+
+	if r.hideThreshold != 0 && weight >= r.hideThreshold {
+		return nil
+	}
+
+	_, err = r.repo.UnhideAutomaticallyHidden(targetType, targetID)
+	return err
+}
+
+// GetAllCategories implements ReportService.
+func (r *ReportService) GetAllCategories() ([]entity.ReportCategory, *domain.Error) {
+	return r.repo.GetAllCategories()
+}
+
+// UpdateCategory implements ReportService.
+func (r *ReportService) UpdateCategory(slug string, payload *report_dto.UpdateReportCategoryPayload) (*entity.ReportCategory, *domain.Error) {
+	category := &entity.ReportCategory{
+		Slug:   slug,
+		Name:   payload.Name,
+		Weight: payload.Weight,
+	}
+	if err := r.repo.UpdateOneCategory(category); err != nil {
+		return nil, err
+	}
+
+	return category, nil
 }
 
 // FindCircleReportByID implements CircleReportService
 func (r *ReportService) FindReportByID(id int) (*entity.Report, *domain.Error) {
-	return r.repo.FindByID(id)
+	report, err := r.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("REPORT_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+	return report, nil
 }
 
 // FindAllCircleReportByCircleID implements CircleReportService
 func (r *ReportService) FindAllReportByCircleID(circleID int) ([]entity.Report, *domain.Error) {
 	return r.repo.FindAllByCircleID(circleID)
 }
+
+// FindAllReportByTarget implements ReportService.
+func (r *ReportService) FindAllReportByTarget(targetType entity.ReportTarget, targetID int) ([]entity.Report, *domain.Error) {
+	return r.repo.FindAllByTarget(targetType, targetID)
+}
+
+// GetPaginatedReportedTargets is the moderation queue, reports grouped by
+// the circle, product or user they are about.
+func (r *ReportService) GetPaginatedReportedTargets(filter *report_dto.GetPaginatedReportedTargetsFilter) (*dto.Pagination[[]entity.ReportedTarget], *domain.Error) {
+	targets, err := r.repo.GetPaginatedReportedTargets(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := r.repo.CountReportedTargets(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.Pagination[[]entity.ReportedTarget]{
+		Data:     targets,
+		Metadata: *factory.GetPaginationMetadata(count, filter.Page, filter.Limit),
+	}, nil
+}
+
+// AssignReport gives a pending report to a moderator, the one sending the
+// request when payload has no assignee.
+func (r *ReportService) AssignReport(userID int, id int, payload *report_dto.AssignReportPayload) (*entity.Report, *domain.Error) {
+	report, err := r.FindReportByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	assignee := userID
+	if payload.AssigneeID != nil {
+		assignee = *payload.AssigneeID
+	}
+
+	report.Status = entity.ReportAssigned
+	report.AssignedTo = &assignee
+
+	err = r.repo.UpdatePendingReport(report)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindReportByID(id)
+}
+
+// ReviewReport closes a pending report as resolved or dismissed, the target
+// hidden by its reports is shown again when the rest weigh less than the
+// threshold.
+func (r *ReportService) ReviewReport(userID int, id int, payload *report_dto.ReviewReportPayload) (*entity.Report, *domain.Error) {
+	report, err := r.FindReportByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report.Status = entity.ReportStatus(payload.Status)
+	report.Note = payload.Note
+	report.ReviewedBy = &userID
+	report.ReviewedAt = &now
+
+	err = r.repo.UpdatePendingReport(report)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.unhideIfUnderThreshold(report.TargetType, report.TargetID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindReportByID(id)
+}
+
+// TakeAction unpublishes, republishes, hides, unhides or warns the target a report is
+// about, a warning goes to the circle of the target. Every pending report of
+// the target is resolved with it.
+func (r *ReportService) TakeAction(userID int, id int, payload *report_dto.ReportActionPayload) (*entity.Report, *domain.Error) {
+	report, err := r.FindReportByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	action := entity.ReportAction(payload.Action)
+	if !slices.Contains(targetActions[report.TargetType], action) ||
+		(action == entity.ReportActionWarn && report.CircleID == nil) {
+		return nil, domain.NewError(400, errors.New("ACTION_NOT_SUPPORTED"), nil)
+	}
+
+	now := time.Now()
+	report.Action = &action
+	report.Note = payload.Note
+	report.ReviewedBy = &userID
+	report.ReviewedAt = &now
+
+	var warning *entity.CircleWarning
+	if action == entity.ReportActionWarn {
+		warning = &entity.CircleWarning{
+			CircleID:  *report.CircleID,
+			ReportID:  &report.ID,
+			Message:   payload.Message,
+			CreatedBy: &userID,
+		}
+	}
+
+	err = r.repo.ApplyAction(report, warning)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.unhideIfUnderThreshold(report.TargetType, report.TargetID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindReportByID(id)
+}
+
+// GetCircleWarnings implements ReportService.
+func (r *ReportService) GetCircleWarnings(circleID int) ([]entity.CircleWarning, *domain.Error) {
+	return r.repo.GetAllWarningsByCircleID(circleID)
+}
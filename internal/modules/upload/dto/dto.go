@@ -0,0 +1,22 @@
+package upload_dto
+
+import "time"
+
+type PresignUploadPayload struct {
+	Type        string `json:"type" validate:"required,oneof=covers products profiles descriptions"`
+	ContentType string `json:"content_type" validate:"required,oneof=image/png image/jpeg image/gif image/webp"`
+}
+
+type ConfirmUploadPayload struct {
+	Ticket string `json:"ticket" validate:"required,uuid"`
+}
+
+// PresignUploadResponse tells the client where to PUT the file, with the
+// headers to send, and the ticket to confirm the upload with afterwards.
+type PresignUploadResponse struct {
+	Ticket    string            `json:"ticket"`
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
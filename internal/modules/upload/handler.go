@@ -2,6 +2,10 @@ package upload
 
 import (
 	"catalog-be/internal/domain"
+	auth_dto "catalog-be/internal/modules/auth/dto"
+	upload_dto "catalog-be/internal/modules/upload/dto"
+	"catalog-be/internal/modules/upload/screening"
+	screening_dto "catalog-be/internal/modules/upload/screening/dto"
 	"errors"
 	"os"
 
@@ -10,11 +14,13 @@ import (
 )
 
 type UploadHandler struct {
-	validator     *validator.Validate
-	uploadService *UploadService
+	validator        *validator.Validate
+	uploadService    *UploadService
+	screeningService *screening.ScreeningService
 }
 
 func (h *UploadHandler) PostUploadImage(c *fiber.Ctx) error {
+	user := c.Locals("user").(*auth_dto.ATClaims)
 	appStage := os.Getenv("APP_STAGE")
 
 	if appStage == "" {
@@ -44,29 +50,219 @@ func (h *UploadHandler) PostUploadImage(c *fiber.Ctx) error {
 	if file == nil {
 		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(
 			c,
-			domain.NewError(fiber.StatusBadRequest, errors.New("FILE_IS_REQUIRED"), nil),
+			domain.NewError(fiber.StatusBadRequest, ErrFileIsRequired, nil),
 		))
 	}
 
-	path, uploadErr := h.uploadService.UploadImage(folder, file)
+	uploaded, uploadErr := h.uploadService.UploadImage(user.UserID, *user.CircleID, folder, file)
 	if uploadErr != nil {
 		return c.Status(uploadErr.Code).JSON(domain.NewErrorFiber(c, uploadErr))
 	}
 
+	return c.Status(fiber.StatusCreated).JSON(uploadedResponse(uploaded))
+}
+
+// uploadedResponse keeps data as the CDN url of the upload like it has always
+// been, its variants and placeholder are sent next to it.
+func uploadedResponse(uploaded *UploadedImage) fiber.Map {
 	cdn := os.Getenv("CDN_URL")
 
+	variants := make(map[string]string, len(uploaded.Variants))
+	for name, path := range uploaded.Variants {
+		variants[name] = cdn + path
+	}
+
+	return fiber.Map{
+		"code":        fiber.StatusCreated,
+		"data":        cdn + uploaded.Path,
+		"variants":    variants,
+		"placeholder": uploaded.Placeholder,
+	}
+}
+
+// PostPresignUpload lets a circle put a large image straight into storage
+// instead of sending it through the server.
+func (h *UploadHandler) PostPresignUpload(c *fiber.Ctx) error {
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	var body upload_dto.PresignUploadPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	presigned, err := h.uploadService.PresignUpload(user.UserID, *user.CircleID, &body)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"code": fiber.StatusCreated,
+		"data": presigned,
+	})
+}
+
+func (h *UploadHandler) PostConfirmUpload(c *fiber.Ctx) error {
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	var body upload_dto.ConfirmUploadPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	uploaded, err := h.uploadService.ConfirmUpload(*user.CircleID, body.Ticket)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(uploadedResponse(uploaded))
+}
+
+func (h *UploadHandler) PutLocalObject(c *fiber.Ctx) error {
+	err := h.uploadService.PutLocalObject(
+		c.Params("*"),
+		c.Query("expires"),
+		c.Query("signature"),
+		c.Get(fiber.HeaderContentType),
+		c.Body(),
+	)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// GetPaginatedUploadFlags lists the uploads flagged as near-duplicates of
+// another circle's uploads.
+func (h *UploadHandler) GetPaginatedUploadFlags(c *fiber.Ctx) error {
+	var query screening_dto.GetPaginatedFlagsFilter
+	if err := c.QueryParser(&query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	flags, err := h.screeningService.GetPaginatedFlags(&query)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code":     fiber.StatusOK,
+		"data":     flags.Data,
+		"metadata": flags.Metadata,
+	})
+}
+
+func (h *UploadHandler) PutReviewUploadFlag(c *fiber.Ctx) error {
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	flagID, parseErr := c.ParamsInt("id")
+	if parseErr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("FLAG_ID_SHOULD_BE_NUMBER"), nil)))
+	}
+
+	var body screening_dto.ReviewFlagPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	flag, err := h.screeningService.ReviewFlag(user.UserID, flagID, &body)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": flag,
+	})
+}
+
+func (h *UploadHandler) GetPaginatedBannedHashes(c *fiber.Ctx) error {
+	var query screening_dto.GetPaginatedBannedHashesFilter
+	if err := c.QueryParser(&query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	hashes, err := h.screeningService.GetPaginatedBannedHashes(&query)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code":     fiber.StatusOK,
+		"data":     hashes.Data,
+		"metadata": hashes.Metadata,
+	})
+}
+
+// PostCreateBannedHash blocks uploads of an image, and of images close to it.
+func (h *UploadHandler) PostCreateBannedHash(c *fiber.Ctx) error {
+	user := c.Locals("user").(*auth_dto.ATClaims)
+
+	var body screening_dto.CreateBannedHashPayload
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	if err := h.validator.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, err, nil)))
+	}
+
+	banned, err := h.screeningService.CreateBannedHash(user.UserID, &body)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"code": fiber.StatusCreated,
-		"data": cdn + path,
+		"data": banned,
+	})
+}
+
+func (h *UploadHandler) DeleteBannedHash(c *fiber.Ctx) error {
+	hashID, parseErr := c.ParamsInt("id")
+	if parseErr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(domain.NewErrorFiber(c, domain.NewError(fiber.StatusBadRequest, errors.New("BANNED_HASH_ID_SHOULD_BE_NUMBER"), nil)))
+	}
+
+	err := h.screeningService.DeleteBannedHash(hashID)
+	if err != nil {
+		return c.Status(err.Code).JSON(domain.NewErrorFiber(c, err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code": fiber.StatusOK,
+		"data": "BANNED_HASH_DELETED",
 	})
 }
 
 func NewUploadHandler(
 	validator *validator.Validate,
 	uploadService *UploadService,
+	screeningService *screening.ScreeningService,
 ) *UploadHandler {
 	return &UploadHandler{
 		validator,
 		uploadService,
+		screeningService,
 	}
 }
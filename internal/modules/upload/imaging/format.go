@@ -0,0 +1,43 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+)
+
+// Format is an image format told apart by its magic bytes.
+type Format string
+
+const (
+	FormatUnknown Format = ""
+	FormatJPEG    Format = "jpeg"
+	FormatPNG     Format = "png"
+	FormatGIF     Format = "gif"
+	FormatWebP    Format = "webp"
+)
+
+// Sniff detects the format of data from its first bytes, whatever the file
+// is called or claims to be.
+func Sniff(data []byte) Format {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return FormatJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return FormatGIF
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWebP
+	}
+	return FormatUnknown
+}
+
+// DecodeConfig reads the format and the dimensions from the header of data
+// without decoding its pixels.
+func DecodeConfig(data []byte) (image.Config, Format, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return image.Config{}, FormatUnknown, err
+	}
+	return config, Format(format), nil
+}
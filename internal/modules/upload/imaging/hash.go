@@ -0,0 +1,37 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math/bits"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Hash is a 64 bit difference hash of a picture. Copies of a picture that
+// were re-encoded, resized or slightly edited are only a few bits apart.
+type Hash uint64
+
+// DHash compares the brightness of neighbouring pixels of img shrunk to 9x8,
+// every bit is set when a pixel is darker than the one on its right.
+func DHash(img image.Image) Hash {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	xdraw.CatmullRom.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var hash Hash
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y < small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// Distance is how many bits h and other differ by, 0 to 64.
+func (h Hash) Distance(other Hash) int {
+	return bits.OnesCount64(uint64(h ^ other))
+}
@@ -0,0 +1,221 @@
+// Package imaging decodes uploaded pictures and re-encodes them as resized
+// WebP variants. Re-encoding from pixels drops every metadata block of the
+// original file, EXIF and GPS included.
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/chai2010/webp"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Preset is a variant fitted inside Width x Height, pictures are never
+// upscaled.
+type Preset struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// Variant is one encoded preset of a picture.
+type Variant struct {
+	Name   string
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// Result is what Process makes of a picture, the size of Placeholder is the
+// size of the largest variant.
+type Result struct {
+	Variants    []Variant
+	Hash        Hash
+	Placeholder Placeholder
+}
+
+// Process decodes data, hashes it, sums it up as a placeholder and encodes
+// one WebP variant per preset.
+// Only the first frame of an animated GIF is kept.
+func Process(data []byte, presets []Preset, quality float32) (*Result, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := jpegOrientation(data)
+
+	// shrink once to the largest preset before rotating, so the pixel by pixel
+	// rotation never runs on a full size phone photo
+	maxWidth, maxHeight := 0, 0
+	for _, preset := range presets {
+		maxWidth = max(maxWidth, preset.Width)
+		maxHeight = max(maxHeight, preset.Height)
+	}
+	if orientation >= 5 {
+		maxWidth, maxHeight = maxHeight, maxWidth
+	}
+	img = orient(fit(img, maxWidth, maxHeight), orientation)
+
+	result := &Result{
+		Variants:    make([]Variant, 0, len(presets)),
+		Hash:        DHash(img),
+		Placeholder: NewPlaceholder(img),
+	}
+	for _, preset := range presets {
+		resized := fit(img, preset.Width, preset.Height)
+
+		var buf bytes.Buffer
+		err = webp.Encode(&buf, resized, &webp.Options{Quality: quality})
+		if err != nil {
+			return nil, err
+		}
+
+		result.Variants = append(result.Variants, Variant{
+			Name:   preset.Name,
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+			Data:   buf.Bytes(),
+		})
+	}
+
+	return result, nil
+}
+
+// fit scales img down to fit inside width x height keeping its ratio.
+func fit(img image.Image, width int, height int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= width && h <= height {
+		return img
+	}
+
+	scale := min(float64(width)/float64(w), float64(height)/float64(h))
+	dw := max(1, int(float64(w)*scale+0.5))
+	dh := max(1, int(float64(h)*scale+0.5))
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// orient turns img upright according to an EXIF orientation, 1 to 8.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+
+	return dst
+}
+
+// jpegOrientation reads the EXIF orientation of a JPEG, 1 when data is not a
+// JPEG or has none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			return 1
+		}
+
+		marker := data[offset+1]
+		// start of scan, the metadata segments are all before it
+		if marker == 0xDA {
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if length < 2 || offset+2+length > len(data) {
+			return 1
+		}
+
+		segment := data[offset+4 : offset+2+length]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+
+		offset += 2 + length
+	}
+
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return 1
+}
@@ -0,0 +1,186 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Placeholder is shown by clients while a picture loads: a BlurHash of it,
+// its size to reserve room for it and its dominant color as #rrggbb.
+type Placeholder struct {
+	BlurHash      string
+	Width         int
+	Height        int
+	DominantColor string
+}
+
+// PLACEHOLDER_SIDE is the longest side the picture is shrunk to before it is
+// summed up, a placeholder has no detail to keep.
+const PLACEHOLDER_SIDE = 32
+
+const base83 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// NewPlaceholder sums img up, transparent pixels are seen over white.
+func NewPlaceholder(img image.Image) Placeholder {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	scale := min(1, float64(PLACEHOLDER_SIDE)/float64(max(w, h)))
+	small := image.NewRGBA(image.Rect(0, 0, max(1, int(float64(w)*scale+0.5)), max(1, int(float64(h)*scale+0.5))))
+	draw.Draw(small, small.Bounds(), image.White, image.Point{}, draw.Src)
+	xdraw.CatmullRom.Scale(small, small.Bounds(), img, bounds, draw.Over, nil)
+
+	// landscape pictures get more horizontal components, portraits vertical
+	componentsX, componentsY := 4, 3
+	if h > w {
+		componentsX, componentsY = 3, 4
+	}
+
+	return Placeholder{
+		BlurHash:      blurHash(small, componentsX, componentsY),
+		Width:         w,
+		Height:        h,
+		DominantColor: dominantColor(small),
+	}
+}
+
+// blurHash encodes img as described by https://github.com/woltapp/blurhash.
+func blurHash(img *image.RGBA, componentsX int, componentsY int) string {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+
+	factors := make([][3]float64, 0, componentsX*componentsY)
+	for j := 0; j < componentsY; j++ {
+		for i := 0; i < componentsX; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+
+			var factor [3]float64
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					basis := normalisation *
+						math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(h))
+
+					pixel := img.RGBAAt(x, y)
+					factor[0] += basis * srgbToLinear(pixel.R)
+					factor[1] += basis * srgbToLinear(pixel.G)
+					factor[2] += basis * srgbToLinear(pixel.B)
+				}
+			}
+
+			for c := range factor {
+				factor[c] /= float64(w * h)
+			}
+			factors = append(factors, factor)
+		}
+	}
+
+	var hash strings.Builder
+	encodeBase83(&hash, (componentsX-1)+(componentsY-1)*9, 1)
+
+	dc, ac := factors[0], factors[1:]
+
+	maximum := 1.0
+	if len(ac) > 0 {
+		actual := 0.0
+		for _, factor := range ac {
+			actual = max(actual, math.Abs(factor[0]), math.Abs(factor[1]), math.Abs(factor[2]))
+		}
+
+		quantised := clamp(int(math.Floor(actual*166-0.5)), 0, 82)
+		maximum = float64(quantised+1) / 166
+		encodeBase83(&hash, quantised, 1)
+	} else {
+		encodeBase83(&hash, 0, 1)
+	}
+
+	encodeBase83(&hash, linearToSrgb(dc[0])<<16|linearToSrgb(dc[1])<<8|linearToSrgb(dc[2]), 4)
+
+	for _, factor := range ac {
+		value := 0
+		for _, component := range factor {
+			value = value*19 + clamp(int(math.Floor(signPow(component/maximum, 0.5)*9+9.5)), 0, 18)
+		}
+		encodeBase83(&hash, value, 2)
+	}
+
+	return hash.String()
+}
+
+// dominantColor is the average of the most common colors of img, colors are
+// grouped by their 4 highest bits.
+func dominantColor(img *image.RGBA) string {
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	buckets := make(map[uint16]*bucket)
+
+	var dominant *bucket
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			pixel := img.RGBAAt(x, y)
+			key := uint16(pixel.R>>4)<<8 | uint16(pixel.G>>4)<<4 | uint16(pixel.B>>4)
+
+			b := buckets[key]
+			if b == nil {
+				b = &bucket{}
+				buckets[key] = b
+			}
+			b.count++
+			b.r += int(pixel.R)
+			b.g += int(pixel.G)
+			b.b += int(pixel.B)
+
+			if dominant == nil || b.count > dominant.count {
+				dominant = b
+			}
+		}
+	}
+
+	average := color.RGBA{
+		R: uint8(dominant.r / dominant.count),
+		G: uint8(dominant.g / dominant.count),
+		B: uint8(dominant.b / dominant.count),
+	}
+	return fmt.Sprintf("#%02x%02x%02x", average.R, average.G, average.B)
+}
+
+func encodeBase83(hash *strings.Builder, value int, length int) {
+	for i := length; i >= 1; i-- {
+		digit := (value / int(math.Pow(83, float64(i-1)))) % 83
+		hash.WriteByte(base83[digit])
+	}
+}
+
+func srgbToLinear(value uint8) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSrgb(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value float64, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
+
+func clamp(value int, low int, high int) int {
+	return max(low, min(high, value))
+}
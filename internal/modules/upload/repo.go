@@ -0,0 +1,192 @@
+package upload
+
+import (
+	"catalog-be/internal/domain"
+	"catalog-be/internal/entity"
+	"catalog-be/internal/modules/plan"
+	"errors"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UploadRepo struct {
+	db *gorm.DB
+}
+
+// PlaceholderOf selects the placeholder of the upload an image url points
+// to, matched on the last three segments of the url which are the path of
+// the upload whatever CDN is in front of it.
+func PlaceholderOf(urlColumn string) string {
+	return `(
+		SELECT u.placeholder FROM upload u
+		WHERE u.path = substring(` + urlColumn + ` from '(/[^/]+/[^/]+/[^/]+)$') AND u.status = 'confirmed'
+		LIMIT 1
+	)`
+}
+
+// CreateOneUpload implements UploadRepo.
+func (r *UploadRepo) CreateOneUpload(upload *entity.Upload) *domain.Error {
+	err := r.db.Create(upload).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// GetOneUploadByTicket implements UploadRepo.
+func (r *UploadRepo) GetOneUploadByTicket(ticket string) (*entity.Upload, *domain.Error) {
+	var upload entity.Upload
+	err := r.db.Where("ticket = ?", ticket).First(&upload).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &upload, nil
+}
+
+// ConfirmUpload saves the variants of upload only when it is still pending,
+// so the same ticket can not be confirmed twice.
+func (r *UploadRepo) ConfirmUpload(upload *entity.Upload) *domain.Error {
+	result := r.db.
+		Model(upload).
+		Where("status = ?", entity.UploadPending).
+		Select("status", "path", "variants", "size", "hash", "placeholder", "confirmed_at", "updated_at").
+		Updates(&entity.Upload{
+			Status:      entity.UploadConfirmed,
+			Path:        upload.Path,
+			Variants:    upload.Variants,
+			Size:        upload.Size,
+			Hash:        upload.Hash,
+			Placeholder: upload.Placeholder,
+			ConfirmedAt: upload.ConfirmedAt,
+		})
+	if result.Error != nil {
+		return domain.NewError(500, result.Error, nil)
+	}
+
+	if result.RowsAffected == 0 {
+		return domain.NewError(409, errors.New("UPLOAD_ALREADY_CONFIRMED"), nil)
+	}
+
+	upload.Status = entity.UploadConfirmed
+
+	return nil
+}
+
+// SumSizeByCircleID is the storage a circle uses out of its quota.
+func (r *UploadRepo) SumSizeByCircleID(circleID int) (int, *domain.Error) {
+	var size int
+	err := r.db.
+		Raw(plan.STORAGE_USED_QUERY, map[string]interface{}{"circle": circleID}).
+		Scan(&size).Error
+	if err != nil {
+		return 0, domain.NewError(500, err, nil)
+	}
+
+	return size, nil
+}
+
+// uploadPathPattern matches the path of an upload inside an url, a
+// description or a JSON snapshot, capturing it without its variant suffix and
+// extension since every variant belongs to the same upload.
+var uploadPathPattern = regexp.MustCompile(`(/[^/\s"'()<>]+/[^/\s"'()<>]+/[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})(?:_[0-9a-z]+)?\.webp`)
+
+// referencedPaths returns the upload paths found in texts, once each.
+func referencedPaths(texts ...string) []string {
+	paths := []string{}
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, match := range uploadPathPattern.FindAllStringSubmatch(text, -1) {
+			path := match[1] + ".webp"
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// SaveReferences links the entity to the uploads whose path appears in texts,
+// replacing the references it had. It runs in the transaction saving the
+// entity so an upload in use is never seen without a reference by the sweeper.
+func SaveReferences(tx *gorm.DB, entityType entity.UploadReferenceType, entityID int, texts ...string) *domain.Error {
+	err := tx.
+		Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Delete(&entity.UploadReference{}).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	paths := referencedPaths(texts...)
+	if len(paths) == 0 {
+		return nil
+	}
+
+	err = tx.Exec(`
+		INSERT INTO upload_reference (upload_id, entity_type, entity_id)
+		SELECT id, ?, ? FROM upload WHERE path IN ?
+	`, entityType, entityID, paths).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// DeleteCircleReferences forgets the references of a circle, of its draft,
+// products and revisions, when it is purged.
+func DeleteCircleReferences(tx *gorm.DB, circleID int) *domain.Error {
+	err := tx.Exec(`
+		DELETE FROM upload_reference r
+		WHERE (r.entity_type IN ('circle', 'circle_draft') AND r.entity_id = ?)
+		OR (r.entity_type = 'product' AND r.entity_id IN (SELECT id FROM product WHERE circle_id = ?))
+		OR (r.entity_type = 'circle_revision' AND r.entity_id IN (SELECT id FROM circle_revision WHERE circle_id = ?))
+	`, circleID, circleID, circleID).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// GetOrphanedUploads returns confirmed uploads made before confirmedBefore
+// without any reference and presigned uploads never confirmed before they
+// expired.
+func (r *UploadRepo) GetOrphanedUploads(confirmedBefore time.Time, now time.Time, limit int) ([]entity.Upload, *domain.Error) {
+	var uploads []entity.Upload
+	err := r.db.
+		Where(
+			"(status = ? AND created_at < ? AND NOT EXISTS (SELECT 1 FROM upload_reference r WHERE r.upload_id = upload.id))",
+			entity.UploadConfirmed,
+			confirmedBefore,
+		).
+		Or("(status = ? AND expires_at < ?)", entity.UploadPending, now).
+		Order("id").
+		Limit(limit).
+		Find(&uploads).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return uploads, nil
+}
+
+// DeleteOneUploadByID implements UploadRepo.
+func (r *UploadRepo) DeleteOneUploadByID(id int) *domain.Error {
+	err := r.db.Delete(&entity.Upload{}, id).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+func NewUploadRepo(db *gorm.DB) *UploadRepo {
+	return &UploadRepo{db: db}
+}
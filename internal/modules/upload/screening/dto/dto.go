@@ -0,0 +1,26 @@
+package screening_dto
+
+type GetPaginatedFlagsFilter struct {
+	Status string `query:"status" validate:"omitempty,oneof=pending dismissed banned"`
+	Page   int    `query:"page" validate:"required,min=1"`
+	Limit  int    `query:"limit" validate:"required,min=1,max=20"`
+}
+
+type ReviewFlagPayload struct {
+	// Status banned also bans the hash of the flagged upload.
+	Status string  `json:"status" validate:"required,oneof=dismissed banned"`
+	Note   *string `json:"note" validate:"omitempty,max=500"`
+}
+
+type GetPaginatedBannedHashesFilter struct {
+	Page  int `query:"page" validate:"required,min=1"`
+	Limit int `query:"limit" validate:"required,min=1,max=20"`
+}
+
+// CreateBannedHashPayload bans the hash of an upload, or a hash given as 16
+// hexadecimal digits.
+type CreateBannedHashPayload struct {
+	UploadID *int    `json:"upload_id" validate:"required_without=Hash,omitempty,min=1"`
+	Hash     *string `json:"hash" validate:"required_without=UploadID,omitempty,hexadecimal,len=16"`
+	Reason   *string `json:"reason" validate:"omitempty,max=500"`
+}
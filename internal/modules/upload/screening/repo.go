@@ -0,0 +1,233 @@
+package screening
+
+import (
+	"catalog-be/internal/domain"
+	"catalog-be/internal/entity"
+	screening_dto "catalog-be/internal/modules/upload/screening/dto"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ScreeningRepo struct {
+	db *gorm.DB
+}
+
+func filterFlags(filter *screening_dto.GetPaginatedFlagsFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.Status != "" {
+			db = db.Where("status = ?", filter.Status)
+		}
+		return db
+	}
+}
+
+// IsHashBanned tells whether a banned hash differs from hash by at most
+// distance bits.
+func (r *ScreeningRepo) IsHashBanned(hash entity.ImageHash, distance int) (bool, *domain.Error) {
+	var banned bool
+	err := r.db.
+		Raw(
+			"SELECT EXISTS (SELECT 1 FROM banned_image_hash WHERE bit_count((hash # ?)::bit(64)) <= ?)",
+			int64(hash),
+			distance,
+		).
+		Scan(&banned).Error
+	if err != nil {
+		return false, domain.NewError(500, err, nil)
+	}
+
+	return banned, nil
+}
+
+// FlagNearDuplicate flags upload when a confirmed upload of another circle in
+// the same folder differs from it by at most distance bits, against the
+// closest one. The folder keeps the scan to the uploads it could be copying,
+// uploads flagged themselves are copies and are not compared against.
+func (r *ScreeningRepo) FlagNearDuplicate(upload *entity.Upload, distance int) *domain.Error {
+	err := r.db.Exec(`
+		INSERT INTO upload_flag (upload_id, matched_upload_id, distance)
+		SELECT CAST(@upload AS integer), u.id, bit_count((u.hash # @hash)::bit(64))
+		FROM upload u
+		WHERE u.hash IS NOT NULL
+			AND u.status = 'confirmed'
+			AND u.folder = @folder
+			AND u.circle_id <> @circle
+			AND NOT EXISTS (SELECT 1 FROM upload_flag f WHERE f.upload_id = u.id)
+			AND bit_count((u.hash # @hash)::bit(64)) <= @distance
+		ORDER BY 3, u.id
+		LIMIT 1
+	`,
+		map[string]interface{}{
+			"upload":   upload.ID,
+			"hash":     int64(*upload.Hash),
+			"folder":   upload.Folder,
+			"circle":   *upload.CircleID,
+			"distance": distance,
+		},
+	).Error
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// GetPaginatedFlags implements ScreeningRepo.
+func (r *ScreeningRepo) GetPaginatedFlags(filter *screening_dto.GetPaginatedFlagsFilter) ([]entity.UploadFlag, *domain.Error) {
+	flags := []entity.UploadFlag{}
+	err := r.db.
+		Scopes(filterFlags(filter)).
+		Preload("Upload").
+		Preload("MatchedUpload").
+		Order("created_at desc, id desc").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&flags).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return flags, nil
+}
+
+// CountFlags implements ScreeningRepo.
+func (r *ScreeningRepo) CountFlags(filter *screening_dto.GetPaginatedFlagsFilter) (int, *domain.Error) {
+	var count int64
+	err := r.db.
+		Model(&entity.UploadFlag{}).
+		Scopes(filterFlags(filter)).
+		Count(&count).Error
+	if err != nil {
+		return 0, domain.NewError(500, err, nil)
+	}
+
+	return int(count), nil
+}
+
+// GetOneFlagByID implements ScreeningRepo.
+func (r *ScreeningRepo) GetOneFlagByID(id int) (*entity.UploadFlag, *domain.Error) {
+	var flag entity.UploadFlag
+	err := r.db.
+		Preload("Upload").
+		Preload("MatchedUpload").
+		First(&flag, id).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &flag, nil
+}
+
+// ReviewFlag saves the review of flag only when it is still pending, and bans
+// the hash of its upload when it is reviewed as banned.
+func (r *ScreeningRepo) ReviewFlag(flag *entity.UploadFlag) *domain.Error {
+	tx := r.db.Begin()
+
+	result := tx.
+		Model(flag).
+		Where("status = ?", entity.UploadFlagPending).
+		Select("status", "note", "reviewed_by", "reviewed_at", "updated_at").
+		Updates(&entity.UploadFlag{
+			Status:     flag.Status,
+			Note:       flag.Note,
+			ReviewedBy: flag.ReviewedBy,
+			ReviewedAt: flag.ReviewedAt,
+		})
+	if result.Error != nil {
+		tx.Rollback()
+		return domain.NewError(500, result.Error, nil)
+	}
+
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return domain.NewError(409, errors.New("FLAG_ALREADY_REVIEWED"), nil)
+	}
+
+	if flag.Status == entity.UploadFlagBanned && flag.Upload != nil && flag.Upload.Hash != nil {
+		err := tx.
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&entity.BannedImageHash{
+				Hash:      *flag.Upload.Hash,
+				Reason:    flag.Note,
+				CreatedBy: flag.ReviewedBy,
+			}).Error
+		if err != nil {
+			tx.Rollback()
+			return domain.NewError(500, err, nil)
+		}
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+// GetOneUploadByID implements ScreeningRepo.
+func (r *ScreeningRepo) GetOneUploadByID(id int) (*entity.Upload, *domain.Error) {
+	var upload entity.Upload
+	err := r.db.First(&upload, id).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return &upload, nil
+}
+
+// GetPaginatedBannedHashes implements ScreeningRepo.
+func (r *ScreeningRepo) GetPaginatedBannedHashes(filter *screening_dto.GetPaginatedBannedHashesFilter) ([]entity.BannedImageHash, *domain.Error) {
+	hashes := []entity.BannedImageHash{}
+	err := r.db.
+		Order("created_at desc, id desc").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&hashes).Error
+	if err != nil {
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return hashes, nil
+}
+
+// CountBannedHashes implements ScreeningRepo.
+func (r *ScreeningRepo) CountBannedHashes() (int, *domain.Error) {
+	var count int64
+	err := r.db.Model(&entity.BannedImageHash{}).Count(&count).Error
+	if err != nil {
+		return 0, domain.NewError(500, err, nil)
+	}
+
+	return int(count), nil
+}
+
+// CreateOneBannedHash implements ScreeningRepo.
+func (r *ScreeningRepo) CreateOneBannedHash(hash *entity.BannedImageHash) *domain.Error {
+	err := r.db.Create(hash).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return domain.NewError(409, errors.New("HASH_ALREADY_BANNED"), nil)
+		}
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// DeleteOneBannedHashByID implements ScreeningRepo.
+func (r *ScreeningRepo) DeleteOneBannedHashByID(id int) *domain.Error {
+	result := r.db.Delete(&entity.BannedImageHash{}, id)
+	if result.Error != nil {
+		return domain.NewError(500, result.Error, nil)
+	}
+
+	if result.RowsAffected == 0 {
+		return domain.NewError(404, errors.New("BANNED_HASH_NOT_FOUND"), nil)
+	}
+
+	return nil
+}
+
+func NewScreeningRepo(db *gorm.DB) *ScreeningRepo {
+	return &ScreeningRepo{db: db}
+}
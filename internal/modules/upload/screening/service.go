@@ -0,0 +1,159 @@
+package screening
+
+import (
+	"catalog-be/internal/database/factory"
+	"catalog-be/internal/domain"
+	"catalog-be/internal/dto"
+	"catalog-be/internal/entity"
+	screening_dto "catalog-be/internal/modules/upload/screening/dto"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NEAR_DUPLICATE_DISTANCE is how many bits two hashes can differ by and still
+// be the same picture, re-encoded or resized copies stay within a few bits.
+var NEAR_DUPLICATE_DISTANCE = 8
+
+var ErrImageBanned = errors.New("IMAGE_BANNED")
+
+type ScreeningService struct {
+	repo *ScreeningRepo
+}
+
+// CheckHash rejects an image close to a banned hash.
+func (s *ScreeningService) CheckHash(hash entity.ImageHash) *domain.Error {
+	banned, err := s.repo.IsHashBanned(hash, NEAR_DUPLICATE_DISTANCE)
+	if err != nil {
+		return err
+	}
+
+	if banned {
+		return domain.NewError(400, ErrImageBanned, nil)
+	}
+
+	return nil
+}
+
+// FlagNearDuplicate flags a registered upload for review when it looks like
+// an upload of another circle in the same folder.
+func (s *ScreeningService) FlagNearDuplicate(upload *entity.Upload) *domain.Error {
+	if upload.Hash == nil || upload.CircleID == nil {
+		return nil
+	}
+
+	return s.repo.FlagNearDuplicate(upload, NEAR_DUPLICATE_DISTANCE)
+}
+
+// GetPaginatedFlags implements ScreeningService.
+func (s *ScreeningService) GetPaginatedFlags(filter *screening_dto.GetPaginatedFlagsFilter) (*dto.Pagination[[]entity.UploadFlag], *domain.Error) {
+	flags, err := s.repo.GetPaginatedFlags(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.repo.CountFlags(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.Pagination[[]entity.UploadFlag]{
+		Data:     flags,
+		Metadata: *factory.GetPaginationMetadata(count, filter.Page, filter.Limit),
+	}, nil
+}
+
+// ReviewFlag lets an admin dismiss a flag, or ban the hash of the flagged
+// upload so it can not be uploaded again.
+func (s *ScreeningService) ReviewFlag(userID int, id int, payload *screening_dto.ReviewFlagPayload) (*entity.UploadFlag, *domain.Error) {
+	flag, err := s.repo.GetOneFlagByID(id)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("FLAG_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	if flag.Status != entity.UploadFlagPending {
+		return nil, domain.NewError(409, errors.New("FLAG_ALREADY_REVIEWED"), nil)
+	}
+
+	now := time.Now()
+	flag.Status = entity.UploadFlagStatus(payload.Status)
+	flag.Note = payload.Note
+	flag.ReviewedBy = &userID
+	flag.ReviewedAt = &now
+
+	err = s.repo.ReviewFlag(flag)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetOneFlagByID(id)
+}
+
+// GetPaginatedBannedHashes implements ScreeningService.
+func (s *ScreeningService) GetPaginatedBannedHashes(filter *screening_dto.GetPaginatedBannedHashesFilter) (*dto.Pagination[[]entity.BannedImageHash], *domain.Error) {
+	hashes, err := s.repo.GetPaginatedBannedHashes(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.repo.CountBannedHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.Pagination[[]entity.BannedImageHash]{
+		Data:     hashes,
+		Metadata: *factory.GetPaginationMetadata(count, filter.Page, filter.Limit),
+	}, nil
+}
+
+// CreateBannedHash bans the hash of an upload or a hash given by an admin.
+func (s *ScreeningService) CreateBannedHash(userID int, payload *screening_dto.CreateBannedHashPayload) (*entity.BannedImageHash, *domain.Error) {
+	var hash entity.ImageHash
+
+	if payload.UploadID != nil {
+		upload, err := s.repo.GetOneUploadByID(*payload.UploadID)
+		if err != nil {
+			if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+				return nil, domain.NewError(404, errors.New("UPLOAD_NOT_FOUND"), nil)
+			}
+			return nil, err
+		}
+
+		if upload.Hash == nil {
+			return nil, domain.NewError(400, errors.New("UPLOAD_HAS_NO_HASH"), nil)
+		}
+		hash = *upload.Hash
+	} else {
+		parseErr := hash.UnmarshalText([]byte(*payload.Hash))
+		if parseErr != nil {
+			return nil, domain.NewError(400, parseErr, nil)
+		}
+	}
+
+	banned := entity.BannedImageHash{
+		Hash:      hash,
+		Reason:    payload.Reason,
+		CreatedBy: &userID,
+	}
+
+	err := s.repo.CreateOneBannedHash(&banned)
+	if err != nil {
+		return nil, err
+	}
+
+	return &banned, nil
+}
+
+// DeleteBannedHash implements ScreeningService.
+func (s *ScreeningService) DeleteBannedHash(id int) *domain.Error {
+	return s.repo.DeleteOneBannedHashByID(id)
+}
+
+func NewScreeningService(repo *ScreeningRepo) *ScreeningService {
+	return &ScreeningService{repo: repo}
+}
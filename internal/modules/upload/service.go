@@ -1,33 +1,55 @@
 package upload
 
 import (
+	"bytes"
 	"catalog-be/internal/domain"
+	"catalog-be/internal/entity"
+	"catalog-be/internal/modules/plan"
+	upload_dto "catalog-be/internal/modules/upload/dto"
+	"catalog-be/internal/modules/upload/imaging"
+	"catalog-be/internal/modules/upload/screening"
+	"catalog-be/internal/storage"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-var ACCEPTED_IMAGE_TYPES = map[string]bool{
-	".png":       true,
-	".jpg":       true,
-	".jpeg":      true,
-	".gif":       true,
-	".heic":      true,
-	".webp":      true,
-	"image/png":  true,
-	"image/jpeg": true,
-	"image/jpg":  true,
-	"image/heic": true,
-	"image/gif":  true,
-	"image/webp": true,
+// ACCEPTED_IMAGE_EXTENSIONS are the extensions the image pipeline can decode
+// with the format the file content has to be. HEIC is left out because it
+// has no decoder in Go.
+var ACCEPTED_IMAGE_EXTENSIONS = map[string]imaging.Format{
+	".png":  imaging.FormatPNG,
+	".jpg":  imaging.FormatJPEG,
+	".jpeg": imaging.FormatJPEG,
+	".gif":  imaging.FormatGIF,
+	".webp": imaging.FormatWebP,
 }
 
+// MAX_IMAGE_SIDE and MAX_IMAGE_PIXELS reject decompression bombs before their
+// pixels are decoded, a small file can declare a huge picture.
+var (
+	MAX_IMAGE_SIDE   = 12000
+	MAX_IMAGE_PIXELS = 25_000_000
+)
+
+var (
+	ErrFileIsRequired          = errors.New("FILE_IS_REQUIRED")
+	ErrFileSizeTooLarge        = errors.New("FILE_SIZE_TOO_LARGE")
+	ErrFileTypeInvalid         = errors.New("FILE_TYPE_INVALID")
+	ErrFileExtensionMismatch   = errors.New("FILE_EXTENSION_MISMATCH")
+	ErrImageDimensionsTooLarge = errors.New("IMAGE_DIMENSIONS_TOO_LARGE")
+	ErrImageCorrupted          = errors.New("IMAGE_CORRUPTED")
+)
+
 var MAX_FILE_SIZE_BASED_ON_NAME = map[string]int64{
 	"products":     5 * 1024 * 1024,
 	"profiles":     5 * 1024 * 1024,
@@ -35,98 +57,488 @@ var MAX_FILE_SIZE_BASED_ON_NAME = map[string]int64{
 	"descriptions": 2.5 * 1024 * 1024,
 }
 
+// IMAGE_PRESETS_BASED_ON_NAME are the variants generated for every folder,
+// full is always the last and biggest one.
+var IMAGE_PRESETS_BASED_ON_NAME = map[string][]imaging.Preset{
+	"products": {
+		{Name: "thumb", Width: 240, Height: 240},
+		{Name: "card", Width: 640, Height: 640},
+		{Name: "full", Width: 1600, Height: 1600},
+	},
+	"profiles": {
+		{Name: "thumb", Width: 96, Height: 96},
+		{Name: "card", Width: 256, Height: 256},
+		{Name: "full", Width: 512, Height: 512},
+	},
+	"covers": {
+		{Name: "thumb", Width: 480, Height: 160},
+		{Name: "card", Width: 1200, Height: 400},
+		{Name: "full", Width: 1920, Height: 640},
+	},
+	"descriptions": {
+		{Name: "thumb", Width: 320, Height: 320},
+		{Name: "card", Width: 800, Height: 800},
+		{Name: "full", Width: 1600, Height: 1600},
+	},
+}
+
+// FULL_VARIANT is stored without a suffix, so the main url of an upload keeps
+// the /[appStage]/[foldername]/uuid.[fileExt] format.
+const FULL_VARIANT = "full"
+
+var WEBP_QUALITY float32 = 80
+
+// PRESIGNED_UPLOAD_EXPIRES is how long a client has to put a presigned upload,
+// UPLOAD_TICKET_EXPIRES how long it has to confirm it.
+var (
+	PRESIGNED_UPLOAD_EXPIRES = 15 * time.Minute
+	UPLOAD_TICKET_EXPIRES    = 1 * time.Hour
+)
+
+// UPLOAD_ORPHAN_GRACE leaves time to save what an upload is for before the
+// sweeper deletes it, UPLOAD_SWEEP_BATCH is how many are deleted per sweep.
+var (
+	UPLOAD_ORPHAN_GRACE = 24 * time.Hour
+	UPLOAD_SWEEP_BATCH  = 100
+)
+
+// CONTENT_TYPE_EXTENSIONS names presigned originals, the extension has to
+// match their content when they are confirmed.
+var CONTENT_TYPE_EXTENSIONS = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// UploadedImage is the path of the full variant and the paths of every
+// variant by preset name, Size is the bytes of all of them, Hash the
+// perceptual hash of the image and Placeholder what to show while it loads.
+type UploadedImage struct {
+	Path        string                  `json:"path"`
+	Variants    map[string]string       `json:"variants"`
+	Size        int                     `json:"size"`
+	Hash        entity.ImageHash        `json:"hash"`
+	Placeholder entity.ImagePlaceholder `json:"placeholder"`
+}
+
 type UploadService struct {
-	s3 *s3.Client
+	storage          storage.Storage
+	uploadRepo       *UploadRepo
+	planService      *plan.PlanService
+	screeningService *screening.ScreeningService
 }
 
 // randomizedFilename implements UploadService.
-func (u *UploadService) randomizedFilename(currentName string) (string, *domain.Error) {
+func (u *UploadService) randomizedFilename() (string, *domain.Error) {
 	uuid, err := uuid.NewV7()
 
 	if err != nil {
 		return "", domain.NewError(500, err, nil)
 	}
-	ext := filepath.Ext(currentName)
-	uuidString := uuid.String()
-	newName := fmt.Sprintf("%s%s", uuidString, ext)
-	return newName, nil
+	return uuid.String(), nil
+}
+
+// variantFilename is the name of a WebP variant of the upload called name.
+func variantFilename(name string, variant string) string {
+	if variant == FULL_VARIANT {
+		return fmt.Sprintf("%s.webp", name)
+	}
+	return fmt.Sprintf("%s_%s.webp", name, variant)
 }
 
-// validateImage implements UploadService.
-func (u *UploadService) validateImage(folderName string, file *multipart.FileHeader) *domain.Error {
-	var MAX_FILE_SIZE = int64(5 * 1024 * 1024)
+func maxFileSize(folderName string) int64 {
 	if MAX_FILE_SIZE_BASED_ON_NAME[folderName] != 0 {
-		MAX_FILE_SIZE = MAX_FILE_SIZE_BASED_ON_NAME[folderName]
+		return MAX_FILE_SIZE_BASED_ON_NAME[folderName]
 	}
+	return 5 * 1024 * 1024
+}
 
-	if file.Size > MAX_FILE_SIZE {
-		return domain.NewError(400, errors.New("FILE_SIZE_TOO_LARGE"), nil)
+// validateImage checks the file from its content, the Content-Type sent by
+// the client is not trusted. Its pixels are decoded later by the pipeline.
+func (u *UploadService) validateImage(folderName string, filename string, data []byte) *domain.Error {
+	MAX_FILE_SIZE := maxFileSize(folderName)
+
+	if int64(len(data)) > MAX_FILE_SIZE {
+		return domain.NewError(400, ErrFileSizeTooLarge, nil)
+	}
+
+	format := imaging.Sniff(data)
+	if format == imaging.FormatUnknown {
+		return domain.NewError(400, ErrFileTypeInvalid, nil)
 	}
 
-	if file.Header.Get("Content-Type") == "" {
-		return domain.NewError(400, errors.New("FILE_TYPE_INVALID"), nil)
+	if ACCEPTED_IMAGE_EXTENSIONS[strings.ToLower(filepath.Ext(filename))] != format {
+		return domain.NewError(400, ErrFileExtensionMismatch, nil)
 	}
 
-	mimeType := file.Header.Get("Content-Type")
+	config, decoded, err := imaging.DecodeConfig(data)
+	if err != nil || decoded != format {
+		return domain.NewError(400, ErrImageCorrupted, nil)
+	}
 
-	if !ACCEPTED_IMAGE_TYPES[mimeType] {
-		return domain.NewError(400, errors.New("FILE_TYPE_INVALID"), nil)
+	if config.Width > MAX_IMAGE_SIDE || config.Height > MAX_IMAGE_SIDE || config.Width*config.Height > MAX_IMAGE_PIXELS {
+		return domain.NewError(400, ErrImageDimensionsTooLarge, nil)
 	}
 
 	return nil
 }
 
-// Upload implements UploadService.
-func (u *UploadService) UploadImage(folderName string, file *multipart.FileHeader) (string, *domain.Error) {
-	bucket := os.Getenv("BUCKET_NAME")
+// UploadImage validates a file sent through the server, stores its variants
+// and registers them to the circle.
+func (u *UploadService) UploadImage(userID int, circleID int, folderName string, file *multipart.FileHeader) (*UploadedImage, *domain.Error) {
+	MAX_FILE_SIZE := maxFileSize(folderName)
+
+	// the declared size is checked first so an oversized file is never read
+	if file.Size > MAX_FILE_SIZE {
+		return nil, domain.NewError(400, ErrFileSizeTooLarge, nil)
+	}
+
+	f, openErr := file.Open()
+	if openErr != nil {
+		return nil, domain.NewError(500, openErr, nil)
+	}
+	defer f.Close()
+
+	data, readErr := io.ReadAll(io.LimitReader(f, MAX_FILE_SIZE+1))
+	if readErr != nil {
+		return nil, domain.NewError(500, readErr, nil)
+	}
+
+	err := u.validateImage(folderName, file.Filename, data)
+	if err != nil {
+		return nil, err
+	}
+
+	uploaded, err := u.storeImage(circleID, folderName, data)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	upload := entity.Upload{
+		CircleID:    &circleID,
+		UserID:      &userID,
+		Folder:      folderName,
+		Path:        &uploaded.Path,
+		Variants:    uploaded.Variants,
+		Size:        uploaded.Size,
+		Hash:        &uploaded.Hash,
+		Placeholder: &uploaded.Placeholder,
+		Status:      entity.UploadConfirmed,
+		ConfirmedAt: &now,
+	}
+	err = u.uploadRepo.CreateOneUpload(&upload)
+	if err != nil {
+		u.deleteObjects(&entity.Upload{Variants: uploaded.Variants})
+		return nil, err
+	}
+
+	// screening is best-effort, the upload is stored and confirmed already
+	if err := u.screeningService.FlagNearDuplicate(&upload); err != nil {
+		fmt.Printf("UPLOAD_FLAG_NEAR_DUPLICATE_FAILED: %s\n", err.Err.Error())
+	}
+
+	return uploaded, nil
+}
+
+// storeImage re-encodes validated image data as a WebP variant for every
+// preset of the folder and stores them all, unless the image is banned or
+// the circle has no room left for them in its plan.
+func (u *UploadService) storeImage(circleID int, folderName string, data []byte) (*UploadedImage, *domain.Error) {
 	appStage := os.Getenv("APP_STAGE")
-	err := u.validateImage(folderName, file)
+
+	name, err := u.randomizedFilename()
 	if err != nil {
-		return "", err
+		return nil, domain.NewError(500, errors.New("FILE_NAME_FAILED_TO_GENERATE"), nil)
+	}
+
+	result, processErr := imaging.Process(data, IMAGE_PRESETS_BASED_ON_NAME[folderName], WEBP_QUALITY)
+	if processErr != nil {
+		return nil, domain.NewError(400, ErrImageCorrupted, nil)
 	}
 
-	name, err := u.randomizedFilename(file.Filename)
+	hash := entity.ImageHash(result.Hash)
+	err = u.screeningService.CheckHash(hash)
 	if err != nil {
-		return "", domain.NewError(500, errors.New("FILE_NAME_FAILED_TO_GENERATE"), nil)
+		return nil, err
 	}
 
-	f, openErr := file.Open()
-	if openErr != nil {
-		return "", domain.NewError(500, openErr, nil)
+	size := 0
+	for _, variant := range result.Variants {
+		size += len(variant.Data)
 	}
-	defer f.Close()
 
-	contentType := file.Header.Get("Content-Type")
+	used, err := u.uploadRepo.SumSizeByCircleID(circleID)
+	if err != nil {
+		return nil, err
+	}
 
-	objectKey := appStage + "/" + folderName + "/" + name
+	err = u.planService.Enforce(circleID, plan.ResourceStorage, used+size)
+	if err != nil {
+		return nil, err
+	}
 
-	_, uploadErr := u.s3.PutObject(
-		context.TODO(),
-		&s3.PutObjectInput{
-			Bucket:      &bucket,
-			Key:         &objectKey,
-			Body:        f,
-			ContentType: &contentType,
+	opts := storage.PutOptions{
+		ContentType:  "image/webp",
+		CacheControl: "public, max-age=31536000, immutable",
+	}
+	uploaded := UploadedImage{
+		Variants: make(map[string]string, len(result.Variants)),
+		Size:     size,
+		Hash:     hash,
+		Placeholder: entity.ImagePlaceholder{
+			BlurHash:      result.Placeholder.BlurHash,
+			Width:         result.Placeholder.Width,
+			Height:        result.Placeholder.Height,
+			DominantColor: result.Placeholder.DominantColor,
 		},
+	}
+
+	for _, variant := range result.Variants {
+		filename := variantFilename(name, variant.Name)
+		objectKey := appStage + "/" + folderName + "/" + filename
+
+		uploadErr := u.storage.Put(context.TODO(), objectKey, bytes.NewReader(variant.Data), opts)
+		if uploadErr != nil {
+			u.deleteObjects(&entity.Upload{Variants: uploaded.Variants})
+			return nil, uploadErr
+		}
+
+		uploaded.Variants[variant.Name] = fmt.Sprintf("/%s/%s/%s", appStage, folderName, filename)
+	}
+
+	// Final path format
+	// format: /[appStage]/[foldername]/uuid.webp, other variants uuid_[variant].webp
+	// example: /local/products/uuid.webp
+	// example: /development/profiles/uuid_thumb.webp
+	uploaded.Path = uploaded.Variants[FULL_VARIANT]
+
+	return &uploaded, nil
+}
+
+// PresignUpload gives a circle a URL to put an original straight into
+// storage, it is only used once the ticket is confirmed.
+func (u *UploadService) PresignUpload(userID int, circleID int, payload *upload_dto.PresignUploadPayload) (*upload_dto.PresignUploadResponse, *domain.Error) {
+	appStage := os.Getenv("APP_STAGE")
+
+	// the size is only known once confirmed, a full quota is rejected early
+	used, err := u.uploadRepo.SumSizeByCircleID(circleID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.planService.Enforce(circleID, plan.ResourceStorage, used+1)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket, err := u.randomizedFilename()
+	if err != nil {
+		return nil, domain.NewError(500, errors.New("FILE_NAME_FAILED_TO_GENERATE"), nil)
+	}
+
+	// format: [appStage]/pending/[foldername]/ticket.[fileExt]
+	objectKey := fmt.Sprintf("%s/pending/%s/%s%s", appStage, payload.Type, ticket, CONTENT_TYPE_EXTENSIONS[payload.ContentType])
+
+	url, err := u.storage.Presign(
+		context.TODO(),
+		objectKey,
+		storage.PutOptions{ContentType: payload.ContentType},
+		PRESIGNED_UPLOAD_EXPIRES,
 	)
+	if err != nil {
+		return nil, err
+	}
 
-	if uploadErr != nil {
-		return "", domain.NewError(500, uploadErr, nil)
+	now := time.Now()
+	expiresAt := now.Add(UPLOAD_TICKET_EXPIRES)
+	err = u.uploadRepo.CreateOneUpload(&entity.Upload{
+		Ticket:    &ticket,
+		CircleID:  &circleID,
+		UserID:    &userID,
+		Folder:    payload.Type,
+		ObjectKey: &objectKey,
+		Status:    entity.UploadPending,
+		ExpiresAt: &expiresAt,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	path := fmt.Sprintf("/%s/%s/%s", appStage, folderName, name)
+	return &upload_dto.PresignUploadResponse{
+		Ticket: ticket,
+		URL:    url,
+		Method: "PUT",
+		Headers: map[string]string{
+			"Content-Type": payload.ContentType,
+		},
+		ExpiresAt: now.Add(PRESIGNED_UPLOAD_EXPIRES),
+	}, nil
+}
 
-	// Final path format
-	// format: /[appStage]/[foldername]/uuid.[fileExt]
-	// example: /local/products/uuid.jpg
-	// example: /development/profiles/uuid.jpg
-	return path, nil
+// ConfirmUpload checks the original put with a presigned URL like a file sent
+// to UploadImage, stores its variants and registers them to the circle. The
+// original is deleted either way.
+func (u *UploadService) ConfirmUpload(circleID int, ticket string) (*UploadedImage, *domain.Error) {
+	upload, err := u.uploadRepo.GetOneUploadByTicket(ticket)
+	if err != nil {
+		if errors.Is(err.Err, gorm.ErrRecordNotFound) {
+			return nil, domain.NewError(404, errors.New("UPLOAD_NOT_FOUND"), nil)
+		}
+		return nil, err
+	}
+
+	if upload.CircleID == nil || *upload.CircleID != circleID {
+		return nil, domain.NewError(404, errors.New("UPLOAD_NOT_FOUND"), nil)
+	}
+
+	if upload.Status == entity.UploadConfirmed {
+		return nil, domain.NewError(409, errors.New("UPLOAD_ALREADY_CONFIRMED"), nil)
+	}
+
+	if time.Now().After(*upload.ExpiresAt) {
+		return nil, domain.NewError(400, errors.New("UPLOAD_TICKET_EXPIRED"), nil)
+	}
+
+	objectKey := *upload.ObjectKey
+
+	exists, err := u.storage.Exists(context.TODO(), objectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, domain.NewError(400, errors.New("UPLOAD_OBJECT_NOT_FOUND"), nil)
+	}
+
+	data, err := u.readObject(objectKey, maxFileSize(upload.Folder))
+	if err != nil {
+		return nil, err
+	}
+
+	// the original is never served, a failed delete only leaves it behind
+	defer u.storage.Delete(context.TODO(), objectKey)
+
+	err = u.validateImage(upload.Folder, objectKey, data)
+	if err != nil {
+		return nil, err
+	}
+
+	uploaded, err := u.storeImage(circleID, upload.Folder, data)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	upload.Path = &uploaded.Path
+	upload.Variants = uploaded.Variants
+	upload.Size = uploaded.Size
+	upload.Hash = &uploaded.Hash
+	upload.Placeholder = &uploaded.Placeholder
+	upload.ConfirmedAt = &now
+
+	// a confirm of the same ticket racing this one may have won, without the
+	// upload row the sweeper would never find these variants
+	err = u.uploadRepo.ConfirmUpload(upload)
+	if err != nil {
+		u.deleteObjects(&entity.Upload{Variants: uploaded.Variants})
+		return nil, err
+	}
+
+	// screening is best-effort, the upload is stored and confirmed already
+	if err := u.screeningService.FlagNearDuplicate(upload); err != nil {
+		fmt.Printf("UPLOAD_FLAG_NEAR_DUPLICATE_FAILED: %s\n", err.Err.Error())
+	}
+
+	return uploaded, nil
+}
+
+// SweepOrphanedUploads deletes the objects of uploads nothing references
+// anymore after UPLOAD_ORPHAN_GRACE, and of presigned uploads never
+// confirmed, then forgets them. It returns how many were deleted.
+func (u *UploadService) SweepOrphanedUploads(now time.Time) (int, *domain.Error) {
+	uploads, err := u.uploadRepo.GetOrphanedUploads(now.Add(-UPLOAD_ORPHAN_GRACE), now, UPLOAD_SWEEP_BATCH)
+	if err != nil {
+		return 0, err
+	}
+
+	swept := 0
+	var lastErr *domain.Error
+	for _, upload := range uploads {
+		if err := u.deleteObjects(&upload); err != nil {
+			lastErr = err
+			continue
+		}
+
+		if err := u.uploadRepo.DeleteOneUploadByID(upload.ID); err != nil {
+			lastErr = err
+			continue
+		}
+		swept++
+	}
+
+	return swept, lastErr
+}
+
+// deleteObjects deletes every variant of upload and its presigned original.
+func (u *UploadService) deleteObjects(upload *entity.Upload) *domain.Error {
+	keys := make([]string, 0, len(upload.Variants)+1)
+	for _, path := range upload.Variants {
+		keys = append(keys, strings.TrimPrefix(path, "/"))
+	}
+	if upload.ObjectKey != nil {
+		keys = append(keys, *upload.ObjectKey)
+	}
+
+	for _, key := range keys {
+		err := u.storage.Delete(context.TODO(), key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readObject reads at most limit+1 bytes of the object, enough for
+// validateImage to tell it is too large.
+func (u *UploadService) readObject(key string, limit int64) ([]byte, *domain.Error) {
+	object, err := u.storage.Get(context.TODO(), key)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	data, readErr := io.ReadAll(io.LimitReader(object, limit+1))
+	if readErr != nil {
+		return nil, domain.NewError(500, readErr, nil)
+	}
+
+	return data, nil
+}
+
+// PutLocalObject stores an object sent to a URL presigned by the local
+// storage driver, other drivers are uploaded to directly.
+func (u *UploadService) PutLocalObject(key string, expires string, signature string, contentType string, body []byte) *domain.Error {
+	local, ok := u.storage.(*storage.LocalStorage)
+	if !ok {
+		return domain.NewError(404, storage.ErrObjectNotFound, nil)
+	}
+
+	return local.PutSigned(context.TODO(), key, expires, signature, contentType, bytes.NewReader(body))
 }
 
 func NewUploadService(
-	s3 *s3.Client,
+	storage storage.Storage,
+	uploadRepo *UploadRepo,
+	planService *plan.PlanService,
+	screeningService *screening.ScreeningService,
 ) *UploadService {
 	return &UploadService{
-		s3,
+		storage,
+		uploadRepo,
+		planService,
+		screeningService,
 	}
 }
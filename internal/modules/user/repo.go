@@ -3,6 +3,7 @@ package user
 import (
 	"catalog-be/internal/domain"
 	"catalog-be/internal/entity"
+	"catalog-be/internal/modules/upload"
 
 	"gorm.io/gorm"
 )
@@ -38,12 +39,30 @@ func (u *UserRepo) FindOneByID(id int) (*entity.User, *domain.Error) {
 	return &user, nil
 }
 
-// UpdateOneByID implements UserRepo.
+// UpdateOneByID updates the user and references the upload of a new profile
+// picture.
 func (u *UserRepo) UpdateOneByID(id int, user entity.User) (*entity.User, *domain.Error) {
+	tx := u.db.Begin()
+	if tx.Error != nil {
+		return nil, domain.NewError(500, tx.Error, nil)
+	}
+
 	var updated entity.User
-	if err := u.db.Model(&entity.User{}).Where("id = ?", id).Updates(&user).Scan(&updated).Error; err != nil {
+	if err := tx.Model(&entity.User{}).Where("id = ?", id).Updates(&user).Scan(&updated).Error; err != nil {
+		tx.Rollback()
 		return nil, domain.NewError(500, err, nil)
 	}
+
+	// an empty picture is left as it is by Updates
+	if user.ProfilePictureURL != "" {
+		if err := upload.SaveReferences(tx, entity.UploadReferenceUser, id, user.ProfilePictureURL); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	tx.Commit()
+
 	return &updated, nil
 }
 
@@ -7,10 +7,12 @@ import (
 	"catalog-be/internal/modules/circle/referral"
 	"catalog-be/internal/modules/event"
 	"catalog-be/internal/modules/fandom"
+	"catalog-be/internal/modules/plan"
 	"catalog-be/internal/modules/product"
 	"catalog-be/internal/modules/report"
 	"catalog-be/internal/modules/upload"
 	"catalog-be/internal/modules/work_type"
+	"catalog-be/internal/storage"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -26,6 +28,8 @@ type HTTP struct {
 	product        *product.ProductHandler
 	referral       *referral.ReferralHandler
 	report         *report.ReportHandler
+	plan           *plan.PlanHandler
+	storage        storage.Storage
 }
 
 func (h *HTTP) RegisterRoutes(app *fiber.App) {
@@ -64,23 +68,70 @@ func (h *HTTP) RegisterRoutes(app *fiber.App) {
 	circle.Post("/onboard", h.authMiddleware.Init, h.circle.PostOnboardNewCircle)
 	circle.Patch("/:circleid", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.circle.PatchUpdateOneCircleByCircleID)
 	circle.Post("/:circleid/publish", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.circle.PostPublishOrUnpublishCircle)
+	circle.Get("/:circleid/revision", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.circle.GetPaginatedRevisionsByCircleID)
+	circle.Post("/:circleid/revision/:revisionid/restore", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.circle.PostRestoreRevisionByCircleID)
+	circle.Get("/:circleid/draft", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.circle.GetCircleDraft)
+	circle.Put("/:circleid/draft", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.circle.PutSaveCircleDraft)
+	circle.Delete("/:circleid/draft", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.circle.DeleteCircleDraft)
+	circle.Post("/:circleid/draft/publish", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.circle.PostPublishCircleDraft)
+	circle.Get("/preview/:token", h.circle.GetPreviewCircleDraft)
+
+	circle.Delete("/:circleid", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.circle.DeleteOneCircleByCircleID)
+	circle.Post("/restore", h.authMiddleware.Init, h.circle.PostRestoreOwnCircle)
+
+	// For admin only account
+	circle.Get("/deleted", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.circle.GetPaginatedDeletedCircles)
+	circle.Post("/:circleid/restore", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.circle.PostRestoreDeletedCircle)
+	circle.Delete("/:circleid/purge", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.circle.DeletePurgeCircle)
 
 	circle.Get("/", h.authMiddleware.IfAuthed, h.circle.GetPaginatedCircles)
 	circle.Get("/bookmarked", h.authMiddleware.Init, h.circle.GetPaginatedBookmarkedCircles)
+	circle.Get("/bookmarked/export", h.authMiddleware.Init, h.circle.GetExportBookmarkedCircles)
 	circle.Get("/:slug", h.authMiddleware.IfAuthed, h.circle.GetOneCricleByCircleSlug)
 
 	circle.Get("/:circleid/referral", h.circle.GetCircleReferralByCirclceID)
 
 	circle.Post("/:id/bookmark", h.authMiddleware.Init, h.circle.PostBookmarkCircleByCircleID)
 	circle.Delete("/:id/bookmark", h.authMiddleware.Init, h.circle.DeleteBookmarkCircleByCircleID)
+	circle.Patch("/:id/bookmark", h.authMiddleware.Init, h.circle.PatchBookmarkCircleByCircleID)
+	circle.Put("/:id/bookmark/status", h.authMiddleware.Init, h.circle.PutBookmarkStatusByCircleID)
 
-	circle.Get("/:id/product", h.product.GetAllProductByCircleID)
+	circle.Get("/:id/product", h.authMiddleware.IfAuthed, h.product.GetAllProductByCircleID)
 	circle.Post("/:id/product", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.product.CreateOneProductByCircleID)
+	circle.Put("/:id/product", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.product.PutUpsertProductsByCircleID)
 	circle.Put("/:id/product/:productid", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.product.UpdateOneProductByCircleID)
 	circle.Delete("/:id/product/:productid", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.product.DeleteOneProductByCircleIDAndProductID)
+	circle.Get("/:id/plan", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.plan.GetCirclePlanUsage)
+	circle.Put("/:id/plan", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.plan.PutAssignCirclePlan)
+	circle.Post("/:id/product/:productid/reservation", h.authMiddleware.Init, h.product.PostCreateReservation)
+	circle.Get("/:id/reservation", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.product.GetCircleReservations)
+	circle.Put("/:id/reservation/:reservationid", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.product.PutUpdateReservationStatus)
 
 	circle.Put("/:circleid/event", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.circle.PutUpdateAttendingEventByCircleID)
 	circle.Delete("/:circleid/event", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.circle.DeleteAttendingEventByCircleID)
+	circle.Get("/:circleid/warning", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.report.GetCircleWarnings)
+
+	bookmark := v1.Group("/bookmark")
+	bookmark.Put("/order", h.authMiddleware.Init, h.circle.PutReorderBookmarks)
+	bookmark.Get("/route", h.authMiddleware.Init, h.circle.GetBookmarkRoutePlan)
+	bookmark.Get("/status", h.authMiddleware.Init, h.circle.GetBookmarkStatuses)
+	bookmark.Put("/status", h.authMiddleware.Init, h.circle.PutBookmarkStatuses)
+	bookmark.Get("/collection", h.authMiddleware.Init, h.circle.GetAllBookmarkCollections)
+	bookmark.Post("/collection", h.authMiddleware.Init, h.circle.PostCreateBookmarkCollection)
+	bookmark.Put("/collection/order", h.authMiddleware.Init, h.circle.PutReorderBookmarkCollections)
+	bookmark.Put("/collection/:collectionid", h.authMiddleware.Init, h.circle.PutUpdateBookmarkCollection)
+	bookmark.Delete("/collection/:collectionid", h.authMiddleware.Init, h.circle.DeleteBookmarkCollection)
+	bookmark.Post("/collection/:collectionid/share", h.authMiddleware.Init, h.circle.PostShareBookmarkCollection)
+	bookmark.Delete("/collection/:collectionid/share", h.authMiddleware.Init, h.circle.DeleteShareBookmarkCollection)
+	bookmark.Get("/shared/:token", h.authMiddleware.IfAuthed, h.circle.GetSharedBookmarkList)
+	bookmark.Post("/shared/:token/clone", h.authMiddleware.Init, h.circle.PostCloneSharedBookmarkList)
+
+	product := v1.Group("/product")
+	product.Get("/", h.product.GetPaginatedProducts)
+
+	reservation := v1.Group("/reservation")
+	reservation.Get("/", h.authMiddleware.Init, h.product.GetUserReservations)
+	reservation.Delete("/:reservationid", h.authMiddleware.Init, h.product.DeleteCancelReservation)
 
 	event := v1.Group("/event")
 	event.Post("/", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.event.CreateOneEvent)
@@ -88,12 +139,47 @@ func (h *HTTP) RegisterRoutes(app *fiber.App) {
 
 	upload := v1.Group("/upload")
 	upload.Post("/image", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.upload.PostUploadImage)
+	upload.Post("/presign", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.upload.PostPresignUpload)
+	upload.Post("/confirm", h.authMiddleware.Init, h.authMiddleware.CircleOnly, h.upload.PostConfirmUpload)
+
+	// For admin only account
+	upload.Get("/flag", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.upload.GetPaginatedUploadFlags)
+	upload.Put("/flag/:id", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.upload.PutReviewUploadFlag)
+	upload.Get("/banned-hash", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.upload.GetPaginatedBannedHashes)
+	upload.Post("/banned-hash", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.upload.PostCreateBannedHash)
+	upload.Delete("/banned-hash/:id", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.upload.DeleteBannedHash)
+
+	// Objects of the local storage driver are served by this server, in
+	// place of the CDN in front of the bucket.
+	if local, ok := h.storage.(*storage.LocalStorage); ok {
+		app.Static(storage.LOCAL_ROUTE, local.Dir, fiber.Static{MaxAge: 31536000})
+		app.Put(storage.LOCAL_ROUTE+"/*", h.upload.PutLocalObject)
+	}
 
 	referral := v1.Group("/referral")
 	referral.Post("/", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.referral.CreateOneReferral)
 
+	plan := v1.Group("/plan")
+	plan.Get("/", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.plan.GetAllPlans)
+	plan.Post("/", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.plan.PostCreatePlan)
+	plan.Put("/:id", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.plan.PutUpdatePlan)
+
 	report := v1.Group("/report")
 	report.Post("/:id/circle", h.authMiddleware.Init, h.report.PostCreateOneReportCircle)
+	report.Post("/:id/product", h.authMiddleware.Init, h.report.PostCreateOneReportProduct)
+	report.Post("/:id/user", h.authMiddleware.Init, h.report.PostCreateOneReportUser)
+	report.Get("/category", h.report.GetAllReportCategories)
+
+	// For admin only account
+	report.Put("/category/:slug", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.report.PutUpdateReportCategory)
+	report.Get("/", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.report.GetPaginatedReportedTargets)
+	report.Get("/circle/:id", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.report.GetAllReportsByCircleID)
+	report.Get("/product/:id", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.report.GetAllReportsByProductID)
+	report.Get("/user/:id", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.report.GetAllReportsByUserID)
+	report.Get("/:id", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.report.GetOneReportByID)
+	report.Put("/:id", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.report.PutReviewReport)
+	report.Put("/:id/assign", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.report.PutAssignReport)
+	report.Post("/:id/action", h.authMiddleware.Init, h.authMiddleware.AdminOnly, h.report.PostReportAction)
 }
 
 func NewHTTP(
@@ -107,6 +193,8 @@ func NewHTTP(
 	product *product.ProductHandler,
 	referral *referral.ReferralHandler,
 	report *report.ReportHandler,
+	plan *plan.PlanHandler,
+	storage storage.Storage,
 ) *HTTP {
 	return &HTTP{
 		auth,
@@ -119,5 +207,7 @@ func NewHTTP(
 		product,
 		referral,
 		report,
+		plan,
+		storage,
 	}
 }
@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"catalog-be/internal/modules/circle"
+	"catalog-be/internal/modules/upload"
+	"catalog-be/internal/utils"
+	"context"
+	"fmt"
+	"time"
+)
+
+// Scheduler runs the background jobs of the api on a fixed interval.
+type Scheduler struct {
+	circleService *circle.CircleService
+	uploadService *upload.UploadService
+	interval      time.Duration
+	// sweepInterval is longer than interval, finding orphaned uploads scans
+	// every picture and description.
+	sweepInterval time.Duration
+	lastSweep     time.Time
+}
+
+// Start blocks and runs the jobs every interval until ctx is done.
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.run()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.run()
+		}
+	}
+}
+
+func (s *Scheduler) run() {
+	now := time.Now().UTC()
+
+	published, err := s.circleService.PublishDueCircleDrafts(now)
+	if err != nil {
+		fmt.Printf("SCHEDULER_PUBLISH_DRAFT_FAILED: %s\n", err.Err.Error())
+	}
+
+	if published > 0 {
+		fmt.Printf("SCHEDULER_PUBLISHED_DRAFTS: %d\n", published)
+	}
+
+	purged, err := s.circleService.PurgeExpiredDeletedCircles(now)
+	if err != nil {
+		fmt.Printf("SCHEDULER_PURGE_CIRCLE_FAILED: %s\n", err.Err.Error())
+	}
+
+	if purged > 0 {
+		fmt.Printf("SCHEDULER_PURGED_CIRCLES: %d\n", purged)
+	}
+
+	if now.Sub(s.lastSweep) >= s.sweepInterval {
+		s.lastSweep = now
+
+		swept, err := s.uploadService.SweepOrphanedUploads(now)
+		if err != nil {
+			fmt.Printf("SCHEDULER_SWEEP_UPLOAD_FAILED: %s\n", err.Err.Error())
+		}
+
+		if swept > 0 {
+			fmt.Printf("SCHEDULER_SWEPT_UPLOADS: %d\n", swept)
+		}
+	}
+}
+
+func NewScheduler(circleService *circle.CircleService, uploadService *upload.UploadService, utils utils.Utils) *Scheduler {
+	interval, err := time.ParseDuration(utils.GetEnv("SCHEDULER_INTERVAL", "1m"))
+	if err != nil || interval <= 0 {
+		interval = time.Minute
+	}
+
+	sweepInterval, err := time.ParseDuration(utils.GetEnv("UPLOAD_SWEEP_INTERVAL", "1h"))
+	if err != nil || sweepInterval <= 0 {
+		sweepInterval = time.Hour
+	}
+
+	return &Scheduler{
+		circleService: circleService,
+		uploadService: uploadService,
+		interval:      interval,
+		sweepInterval: sweepInterval,
+	}
+}
@@ -2,12 +2,12 @@ package server
 
 import (
 	"catalog-be/internal/database"
+	"catalog-be/internal/storage"
 	"catalog-be/internal/utils"
 	"fmt"
 	"os"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -17,17 +17,12 @@ type FiberServer struct {
 	App       *fiber.App
 	Pg        *gorm.DB
 	Validator *validator.Validate
-	S3        *s3.Client
+	Storage   storage.Storage
 }
 
 func New() *FiberServer {
 	dsn := SetDsn()
 
-	var accountId = os.Getenv("ACCOUNT_ID")
-	s3Endpoint := aws.Endpoint{
-		URL: fmt.Sprintf("https://%s.r2.cloudflarestorage.com", accountId),
-	}
-
 	server := &FiberServer{
 		App: fiber.New(fiber.Config{
 			ServerHeader: "catalog-be",
@@ -35,12 +30,38 @@ func New() *FiberServer {
 		}),
 		Pg:        database.New(dsn, true),
 		Validator: validator.New(),
-		S3:        database.NewS3(s3Endpoint, "auto"),
+		Storage:   NewStorage(),
 	}
 
 	return server
 }
 
+// NewStorage picks the storage driver from STORAGE_DRIVER, the S3 compatible
+// bucket by default or the local disk for development.
+func NewStorage() storage.Storage {
+	utilsUtils := utils.NewUtils()
+
+	if utilsUtils.GetEnv("STORAGE_DRIVER", "s3") == "local" {
+		return storage.NewLocalStorage(
+			utilsUtils.GetEnv("STORAGE_LOCAL_DIR", "./storage"),
+			os.Getenv("CDN_URL"),
+			utilsUtils.GetEnv("STORAGE_LOCAL_SECRET", os.Getenv("JWT_SECRET")),
+		)
+	}
+
+	s3Endpoint := aws.Endpoint{
+		URL: utilsUtils.GetEnv(
+			"STORAGE_ENDPOINT",
+			fmt.Sprintf("https://%s.r2.cloudflarestorage.com", os.Getenv("ACCOUNT_ID")),
+		),
+	}
+
+	return storage.NewS3Storage(
+		database.NewS3(s3Endpoint, utilsUtils.GetEnv("STORAGE_REGION", "auto")),
+		os.Getenv("BUCKET_NAME"),
+	)
+}
+
 func SetDsn() string {
 	utilsUtils := utils.NewUtils()
 	result := fmt.Sprintf(
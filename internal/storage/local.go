@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"catalog-be/internal/domain"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LOCAL_ROUTE is where the router serves the objects of a LocalStorage and
+// accepts the uploads it presigned.
+const LOCAL_ROUTE = "/storage"
+
+var (
+	ErrObjectKeyInvalid    = errors.New("OBJECT_KEY_INVALID")
+	ErrPresignedURLExpired = errors.New("PRESIGNED_URL_EXPIRED")
+	ErrPresignedURLInvalid = errors.New("PRESIGNED_URL_INVALID")
+)
+
+// LocalStorage keeps objects as files under Dir, for local development and
+// tests without any bucket. Presigned URLs point back to this server and are
+// signed with secret instead of cloud credentials.
+type LocalStorage struct {
+	Dir     string
+	baseURL string
+	secret  string
+}
+
+// path resolves key inside Dir, keys escaping it are rejected.
+func (s *LocalStorage) path(key string) (string, *domain.Error) {
+	path := filepath.Join(s.Dir, filepath.FromSlash(key))
+	relative, err := filepath.Rel(s.Dir, path)
+	if err != nil || key == "" || relative == "." || strings.HasPrefix(relative, "..") {
+		return "", domain.NewError(400, ErrObjectKeyInvalid, nil)
+	}
+
+	return path, nil
+}
+
+// Put implements Storage. The options are not kept, the static route serves
+// files with the content type of their extension.
+func (s *LocalStorage) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) *domain.Error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	mkdirErr := os.MkdirAll(filepath.Dir(path), 0755)
+	if mkdirErr != nil {
+		return domain.NewError(500, mkdirErr, nil)
+	}
+
+	file, createErr := os.Create(path)
+	if createErr != nil {
+		return domain.NewError(500, createErr, nil)
+	}
+	defer file.Close()
+
+	_, copyErr := io.Copy(file, body)
+	if copyErr != nil {
+		return domain.NewError(500, copyErr, nil)
+	}
+
+	return nil
+}
+
+// Get implements Storage.
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, *domain.Error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, openErr := os.Open(path)
+	if openErr != nil {
+		if errors.Is(openErr, os.ErrNotExist) {
+			return nil, domain.NewError(404, ErrObjectNotFound, nil)
+		}
+		return nil, domain.NewError(500, openErr, nil)
+	}
+
+	return file, nil
+}
+
+// Delete implements Storage.
+func (s *LocalStorage) Delete(ctx context.Context, key string) *domain.Error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	removeErr := os.Remove(path)
+	if removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+		return domain.NewError(500, removeErr, nil)
+	}
+
+	return nil
+}
+
+// Presign implements Storage. The URL is the static route of the object with
+// its expiry and signature, it is accepted by PutSigned.
+func (s *LocalStorage) Presign(ctx context.Context, key string, opts PutOptions, expires time.Duration) (string, *domain.Error) {
+	_, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	query := url.Values{
+		"expires":   {expiresAt},
+		"signature": {s.sign(key, expiresAt, opts.ContentType)},
+	}
+
+	return fmt.Sprintf("%s/%s?%s", strings.TrimSuffix(s.baseURL, "/"), key, query.Encode()), nil
+}
+
+// Exists implements Storage.
+func (s *LocalStorage) Exists(ctx context.Context, key string) (bool, *domain.Error) {
+	path, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+
+	_, statErr := os.Stat(path)
+	if statErr != nil {
+		if errors.Is(statErr, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, domain.NewError(500, statErr, nil)
+	}
+
+	return true, nil
+}
+
+// PutSigned stores body under key when expires and signature are the ones
+// of a URL returned by Presign for the same key and content type.
+func (s *LocalStorage) PutSigned(ctx context.Context, key string, expires string, signature string, contentType string, body io.Reader) *domain.Error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return domain.NewError(403, ErrPresignedURLInvalid, nil)
+	}
+
+	// the content type is signed, a different one fails like a forged URL
+	if !hmac.Equal([]byte(signature), []byte(s.sign(key, expires, contentType))) {
+		return domain.NewError(403, ErrPresignedURLInvalid, nil)
+	}
+
+	if time.Now().Unix() > expiresAt {
+		return domain.NewError(403, ErrPresignedURLExpired, nil)
+	}
+
+	return s.Put(ctx, key, body, PutOptions{ContentType: contentType})
+}
+
+func (s *LocalStorage) sign(key string, expires string, contentType string) string {
+	mac := hmac.New(sha256.New, []byte(s.secret))
+	mac.Write([]byte(key + "\n" + expires + "\n" + contentType))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewLocalStorage keeps objects under dir, baseURL is where the router serves
+// LOCAL_ROUTE, e.g. http://localhost:8080/storage.
+func NewLocalStorage(
+	dir string,
+	baseURL string,
+	secret string,
+) *LocalStorage {
+	return &LocalStorage{
+		filepath.Clean(dir),
+		baseURL,
+		secret,
+	}
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"catalog-be/internal/domain"
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Storage stores objects in a bucket of any S3 compatible service, e.g.
+// Cloudflare R2 or localstack.
+type S3Storage struct {
+	client *s3.Client
+	bucket string
+}
+
+// Put implements Storage.
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) *domain.Error {
+	_, err := s.client.PutObject(ctx, s.putObjectInput(key, body, opts))
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// Get implements Storage.
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, *domain.Error) {
+	object, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, domain.NewError(404, ErrObjectNotFound, nil)
+		}
+		return nil, domain.NewError(500, err, nil)
+	}
+
+	return object.Body, nil
+}
+
+// Delete implements Storage.
+func (s *S3Storage) Delete(ctx context.Context, key string) *domain.Error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return domain.NewError(500, err, nil)
+	}
+
+	return nil
+}
+
+// Presign implements Storage.
+func (s *S3Storage) Presign(ctx context.Context, key string, opts PutOptions, expires time.Duration) (string, *domain.Error) {
+	request, err := s3.NewPresignClient(s.client).PresignPutObject(
+		ctx,
+		s.putObjectInput(key, nil, opts),
+		s3.WithPresignExpires(expires),
+	)
+	if err != nil {
+		return "", domain.NewError(500, err, nil)
+	}
+
+	return request.URL, nil
+}
+
+// Exists implements Storage.
+func (s *S3Storage) Exists(ctx context.Context, key string) (bool, *domain.Error) {
+	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, domain.NewError(500, err, nil)
+	}
+
+	return true, nil
+}
+
+func (s *S3Storage) putObjectInput(key string, body io.Reader, opts PutOptions) *s3.PutObjectInput {
+	input := &s3.PutObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+		Body:   body,
+	}
+	if opts.ContentType != "" {
+		input.ContentType = &opts.ContentType
+	}
+	if opts.CacheControl != "" {
+		input.CacheControl = &opts.CacheControl
+	}
+
+	return input
+}
+
+func NewS3Storage(
+	client *s3.Client,
+	bucket string,
+) *S3Storage {
+	return &S3Storage{
+		client,
+		bucket,
+	}
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"catalog-be/internal/domain"
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrObjectNotFound = errors.New("OBJECT_NOT_FOUND")
+
+// PutOptions are the headers an object is served with.
+type PutOptions struct {
+	ContentType  string
+	CacheControl string
+}
+
+// Storage keeps uploaded objects by key, a key is a slash separated path
+// without a leading slash, e.g. local/products/uuid.webp.
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, opts PutOptions) *domain.Error
+	Get(ctx context.Context, key string) (io.ReadCloser, *domain.Error)
+	Delete(ctx context.Context, key string) *domain.Error
+	// Presign returns a URL a client can PUT the object to until expires has
+	// passed, the request has to send opts.ContentType as its Content-Type.
+	Presign(ctx context.Context, key string, opts PutOptions, expires time.Duration) (string, *domain.Error)
+	Exists(ctx context.Context, key string) (bool, *domain.Error)
+}
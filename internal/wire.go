@@ -12,24 +12,30 @@ import (
 	"catalog-be/internal/modules/circle/circle_fandom"
 	"catalog-be/internal/modules/circle/circle_work_type"
 	"catalog-be/internal/modules/circle/referral"
+	"catalog-be/internal/modules/circle/revision"
 	"catalog-be/internal/modules/event"
 	"catalog-be/internal/modules/fandom"
+	"catalog-be/internal/modules/plan"
 	"catalog-be/internal/modules/product"
+	"catalog-be/internal/modules/product/reservation"
 	refreshtoken "catalog-be/internal/modules/refresh_token"
+	"catalog-be/internal/modules/report"
 	"catalog-be/internal/modules/upload"
+	"catalog-be/internal/modules/upload/screening"
 	"catalog-be/internal/modules/user"
 	"catalog-be/internal/modules/work_type"
 	"catalog-be/internal/router"
+	"catalog-be/internal/scheduler"
+	"catalog-be/internal/storage"
 	"catalog-be/internal/utils"
 	"catalog-be/internal/validation"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/wire"
 	"gorm.io/gorm"
 )
 
-func InitializeServer(db *gorm.DB, validate *validator.Validate, s3 *s3.Client) *router.HTTP {
+func InitializeServer(db *gorm.DB, validate *validator.Validate, storage storage.Storage) *router.HTTP {
 	wire.Build(
 		internal_config.NewConfig,
 
@@ -65,10 +71,20 @@ func InitializeServer(db *gorm.DB, validate *validator.Validate, s3 *s3.Client)
 		referral.NewReferralRepo,
 		referral.NewReferralService,
 
+		revision.NewRevisionRepo,
+		revision.NewRevisionService,
+
+		plan.NewPlanRepo,
+		plan.NewPlanService,
+		plan.NewPlanHandler,
+
 		product.NewProductRepo,
 		product.NewProductService,
 		product.NewProductHandler,
 
+		reservation.NewProductReservationRepo,
+		reservation.NewProductReservationService,
+
 		circle.NewCircleHandler,
 		circle.NewCircleRepo,
 		circle.NewCircleService,
@@ -77,8 +93,16 @@ func InitializeServer(db *gorm.DB, validate *validator.Validate, s3 *s3.Client)
 		event.NewEventRepo,
 		event.NewEventService,
 
+		report.NewReportHandler,
+		report.NewReportRepo,
+		report.NewReportService,
+
 		upload.NewUploadHandler,
 		upload.NewUploadService,
+		upload.NewUploadRepo,
+
+		screening.NewScreeningRepo,
+		screening.NewScreeningService,
 
 		validation.NewSanitizer,
 		middlewares.NewAuthMiddleware,
@@ -87,3 +111,47 @@ func InitializeServer(db *gorm.DB, validate *validator.Validate, s3 *s3.Client)
 	)
 	return nil
 }
+
+func InitializeScheduler(db *gorm.DB, storage storage.Storage) *scheduler.Scheduler {
+	wire.Build(
+		utils.NewUtils,
+
+		refreshtoken.NewRefreshTokenRepo,
+		refreshtoken.NewRefreshTokenService,
+
+		user.NewUserRepo,
+		user.NewUserService,
+
+		bookmark.NewCircleBookmarkRepo,
+		bookmark.NewCircleBookmarkService,
+
+		circle_work_type.NewCircleWorkTypeRepo,
+		circle_work_type.NewCircleWorkTypeService,
+
+		circle_fandom.NewCircleFandomRepo,
+		circle_fandom.NewCircleFandomService,
+
+		referral.NewReferralRepo,
+		referral.NewReferralService,
+
+		revision.NewRevisionRepo,
+		revision.NewRevisionService,
+
+		plan.NewPlanRepo,
+		plan.NewPlanService,
+
+		circle.NewCircleRepo,
+		circle.NewCircleService,
+
+		upload.NewUploadRepo,
+		upload.NewUploadService,
+
+		screening.NewScreeningRepo,
+		screening.NewScreeningService,
+
+		validation.NewSanitizer,
+
+		scheduler.NewScheduler,
+	)
+	return nil
+}
@@ -16,25 +16,30 @@ import (
 	"catalog-be/internal/modules/report"
 	"catalog-be/internal/modules/circle/circle_work_type"
 	"catalog-be/internal/modules/circle/referral"
+	"catalog-be/internal/modules/circle/revision"
 	"catalog-be/internal/modules/event"
 	"catalog-be/internal/modules/fandom"
+	"catalog-be/internal/modules/plan"
 	"catalog-be/internal/modules/product"
+	"catalog-be/internal/modules/product/reservation"
 	"catalog-be/internal/modules/refresh_token"
 	"catalog-be/internal/modules/upload"
+	"catalog-be/internal/modules/upload/screening"
 	"catalog-be/internal/modules/user"
 	"catalog-be/internal/modules/work_type"
 	"catalog-be/internal/router"
+	"catalog-be/internal/scheduler"
+	"catalog-be/internal/storage"
 	"catalog-be/internal/utils"
 	"catalog-be/internal/validation"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
 )
 
 // Injectors from wire.go:
 
-func InitializeServer(db *gorm.DB, validate *validator.Validate, s3_2 *s3.Client) *router.HTTP {
+func InitializeServer(db *gorm.DB, validate *validator.Validate, storage2 storage.Storage) *router.HTTP {
 	userRepo := user.NewUserRepo(db)
 	userService := user.NewUserService(userRepo)
 	config := internal_config.NewConfig()
@@ -51,7 +56,11 @@ func InitializeServer(db *gorm.DB, validate *validator.Validate, s3_2 *s3.Client
 	sanitizer := validation.NewSanitizer()
 	referralRepo := referral.NewReferralRepo(db)
 	referralService := referral.NewReferralService(referralRepo)
-	circleService := circle.NewCircleService(circleRepo, userService, utilsUtils, refreshTokenService, circleWorkTypeService, circleFandomService, circleBookmarkService, sanitizer, referralService)
+	revisionRepo := revision.NewRevisionRepo(db)
+	revisionService := revision.NewRevisionService(revisionRepo)
+	planRepo := plan.NewPlanRepo(db)
+	planService := plan.NewPlanService(planRepo)
+	circleService := circle.NewCircleService(circleRepo, userService, utilsUtils, refreshTokenService, circleWorkTypeService, circleFandomService, circleBookmarkService, sanitizer, referralService, revisionService, planService)
 	authService := auth.NewAuthService(userService, config, refreshTokenService, utilsUtils, circleService)
 	authHandler := auth.NewAuthHandler(authService, validate)
 	authMiddleware := middlewares.NewAuthMiddleware(userService)
@@ -65,15 +74,21 @@ func InitializeServer(db *gorm.DB, validate *validator.Validate, s3_2 *s3.Client
 	eventRepo := event.NewEventRepo(db)
 	eventService := event.NewEventService(eventRepo, utilsUtils)
 	eventHandler := event.NewEventHandler(eventService, validate)
-	uploadService := upload.NewUploadService(s3_2)
-	uploadHandler := upload.NewUploadHandler(validate, uploadService)
+	uploadRepo := upload.NewUploadRepo(db)
+	screeningRepo := screening.NewScreeningRepo(db)
+	screeningService := screening.NewScreeningService(screeningRepo)
+	uploadService := upload.NewUploadService(storage2, uploadRepo, planService, screeningService)
+	uploadHandler := upload.NewUploadHandler(validate, uploadService, screeningService)
 	productRepo := product.NewProductRepo(db)
-	productService := product.NewProductService(productRepo)
-	productHandler := product.NewProductHandler(productService, validate)
+	productService := product.NewProductService(productRepo, planService)
+	productReservationRepo := reservation.NewProductReservationRepo(db)
+	productReservationService := reservation.NewProductReservationService(productReservationRepo)
+	productHandler := product.NewProductHandler(productService, productReservationService, validate)
 	referralHandler := referral.NewReferralHandler(referralService, validate)
+	planHandler := plan.NewPlanHandler(planService, validate)
 	
 	reportRepo := report.NewReportRepo(db)
-	reportService := report.NewReportService(reportRepo, circleRepo)
+	reportService := report.NewReportService(reportRepo, circleRepo, utilsUtils)
 	reportHandler := report.NewReportHandler(reportService, validate)
 	
 	http := router.NewHTTP(
@@ -87,6 +102,37 @@ func InitializeServer(db *gorm.DB, validate *validator.Validate, s3_2 *s3.Client
 		productHandler, 
 		referralHandler, 
 		reportHandler,
+		planHandler,
+		storage2,
 	)
 	return http
 }
+
+func InitializeScheduler(db *gorm.DB, storage2 storage.Storage) *scheduler.Scheduler {
+	circleRepo := circle.NewCircleRepo(db)
+	userRepo := user.NewUserRepo(db)
+	userService := user.NewUserService(userRepo)
+	utilsUtils := utils.NewUtils()
+	refreshTokenRepo := refreshtoken.NewRefreshTokenRepo(db)
+	refreshTokenService := refreshtoken.NewRefreshTokenService(refreshTokenRepo, utilsUtils)
+	circleWorkTypeRepo := circle_work_type.NewCircleWorkTypeRepo(db)
+	circleWorkTypeService := circle_work_type.NewCircleWorkTypeService(circleWorkTypeRepo)
+	circleFandomRepo := circle_fandom.NewCircleFandomRepo(db)
+	circleFandomService := circle_fandom.NewCircleFandomService(circleFandomRepo)
+	circleBookmarkRepo := bookmark.NewCircleBookmarkRepo(db)
+	circleBookmarkService := bookmark.NewCircleBookmarkService(circleBookmarkRepo)
+	sanitizer := validation.NewSanitizer()
+	referralRepo := referral.NewReferralRepo(db)
+	referralService := referral.NewReferralService(referralRepo)
+	revisionRepo := revision.NewRevisionRepo(db)
+	revisionService := revision.NewRevisionService(revisionRepo)
+	planRepo := plan.NewPlanRepo(db)
+	planService := plan.NewPlanService(planRepo)
+	circleService := circle.NewCircleService(circleRepo, userService, utilsUtils, refreshTokenService, circleWorkTypeService, circleFandomService, circleBookmarkService, sanitizer, referralService, revisionService, planService)
+	uploadRepo := upload.NewUploadRepo(db)
+	screeningRepo := screening.NewScreeningRepo(db)
+	screeningService := screening.NewScreeningService(screeningRepo)
+	uploadService := upload.NewUploadService(storage2, uploadRepo, planService, screeningService)
+	schedulerScheduler := scheduler.NewScheduler(circleService, uploadService, utilsUtils)
+	return schedulerScheduler
+}
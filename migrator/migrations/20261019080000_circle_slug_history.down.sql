@@ -0,0 +1,3 @@
+drop index if exists "idx_circle_slug_history_circle_id";
+
+drop table if exists "circle_slug_history";
@@ -0,0 +1,10 @@
+create table
+    "circle_slug_history" (
+        "id" serial primary key,
+        "circle_id" integer not null,
+        "slug" varchar(255) not null unique,
+        "created_at" timestamp not null default current_timestamp,
+        foreign key ("circle_id") references "circle" ("id") on delete cascade
+    );
+
+create index "idx_circle_slug_history_circle_id" on "circle_slug_history" ("circle_id");
@@ -0,0 +1,3 @@
+drop index if exists "idx_circle_revision_circle_id_created_at";
+
+drop table if exists "circle_revision";
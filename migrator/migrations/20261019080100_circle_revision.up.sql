@@ -0,0 +1,19 @@
+create table
+    "circle_revision" (
+        "id" serial primary key,
+        "circle_id" integer not null,
+        "user_id" integer,
+        "entity_type" varchar(50) not null,
+        "entity_id" integer not null,
+        "action" varchar(50) not null,
+        "before" jsonb,
+        "after" jsonb,
+        "diff" jsonb not null default '[]',
+        "restored_from_id" integer,
+        "created_at" timestamp not null default current_timestamp,
+        foreign key ("circle_id") references "circle" ("id") on delete cascade,
+        foreign key ("user_id") references "user" ("id") on delete set null,
+        foreign key ("restored_from_id") references "circle_revision" ("id") on delete set null
+    );
+
+create index "idx_circle_revision_circle_id_created_at" on "circle_revision" ("circle_id", "created_at" desc);
@@ -0,0 +1,3 @@
+drop index if exists "idx_circle_draft_publish_at";
+
+drop table if exists "circle_draft";
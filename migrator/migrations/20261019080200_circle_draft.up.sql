@@ -0,0 +1,16 @@
+create table
+    "circle_draft" (
+        "circle_id" integer primary key,
+        "user_id" integer,
+        "payload" jsonb not null default '{}',
+        "preview_token" varchar(64) not null unique,
+        "publish_at" timestamp,
+        "created_at" timestamp not null default current_timestamp,
+        "updated_at" timestamp not null default current_timestamp,
+        foreign key ("circle_id") references "circle" ("id") on delete cascade,
+        foreign key ("user_id") references "user" ("id") on delete set null
+    );
+
+create index "idx_circle_draft_publish_at" on "circle_draft" ("publish_at")
+where
+    "publish_at" is not null;
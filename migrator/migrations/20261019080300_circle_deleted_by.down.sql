@@ -0,0 +1,4 @@
+drop index if exists "idx_circle_deleted_at";
+
+alter table "circle"
+drop column if exists "deleted_by";
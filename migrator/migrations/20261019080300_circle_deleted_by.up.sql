@@ -0,0 +1,8 @@
+alter table "circle"
+add column "deleted_by" integer;
+
+alter table "circle" add foreign key ("deleted_by") references "user" (id) on delete set null;
+
+create index "idx_circle_deleted_at" on "circle" ("deleted_at")
+where
+    "deleted_at" is not null;
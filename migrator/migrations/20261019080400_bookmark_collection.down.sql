@@ -0,0 +1,11 @@
+drop index if exists "idx_user_bookmark_user_id_collection_id";
+
+alter table "user_bookmark"
+drop column if exists "collection_id",
+drop column if exists "note",
+drop column if exists "priority",
+drop column if exists "position";
+
+drop index if exists "idx_bookmark_collection_user_id";
+
+drop table if exists "bookmark_collection";
@@ -0,0 +1,45 @@
+create table
+    "bookmark_collection" (
+        "id" serial primary key,
+        "user_id" integer not null,
+        "name" varchar(100) not null,
+        "position" integer not null default 0,
+        "created_at" timestamp not null default current_timestamp,
+        "updated_at" timestamp not null default current_timestamp,
+        unique ("user_id", "name"),
+        foreign key ("user_id") references "user" ("id") on delete cascade
+    );
+
+create index "idx_bookmark_collection_user_id" on "bookmark_collection" ("user_id");
+
+alter table "user_bookmark"
+add column "collection_id" integer,
+add column "note" text,
+add column "priority" smallint not null default 0,
+add column "position" integer not null default 0;
+
+alter table "user_bookmark" add foreign key ("collection_id") references "bookmark_collection" ("id") on delete set null;
+
+create index "idx_user_bookmark_user_id_collection_id" on "user_bookmark" ("user_id", "collection_id");
+
+-- keep the current newest first order
+update "user_bookmark" ub
+set
+    "position" = ordered.position
+from
+    (
+        select
+            "user_id",
+            "circle_id",
+            row_number() over (
+                partition by
+                    "user_id"
+                order by
+                    "created_at" desc
+            ) as position
+        from
+            "user_bookmark"
+    ) ordered
+where
+    ub.user_id = ordered.user_id
+    and ub.circle_id = ordered.circle_id;
@@ -0,0 +1,3 @@
+alter table "bookmark_collection"
+drop column if exists "share_token",
+drop column if exists "shared_at";
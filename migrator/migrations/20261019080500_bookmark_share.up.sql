@@ -0,0 +1,3 @@
+alter table "bookmark_collection"
+add column "share_token" varchar(64) unique,
+add column "shared_at" timestamp;
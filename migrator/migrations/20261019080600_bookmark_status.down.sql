@@ -0,0 +1,3 @@
+drop index if exists "idx_user_bookmark_status_user_id_synced_at";
+
+drop table if exists "user_bookmark_status";
@@ -0,0 +1,15 @@
+create table
+    "user_bookmark_status" (
+        "user_id" integer not null,
+        "circle_id" integer not null,
+        "event_id" integer not null,
+        "status" varchar(20) not null default 'pending',
+        "updated_at" timestamp not null default current_timestamp,
+        "synced_at" timestamp not null default current_timestamp,
+        primary key ("user_id", "circle_id", "event_id"),
+        check ("status" in ('pending', 'visited', 'sold_out', 'skipped')),
+        foreign key ("user_id", "circle_id") references "user_bookmark" ("user_id", "circle_id") on delete cascade,
+        foreign key ("event_id") references "event" ("id") on delete cascade
+    );
+
+create index "idx_user_bookmark_status_user_id_synced_at" on "user_bookmark_status" ("user_id", "synced_at");
@@ -0,0 +1,11 @@
+drop index if exists "idx_product_circle_id_price";
+
+alter table "product"
+drop constraint if exists "product_status_check",
+drop constraint if exists "product_stock_check",
+drop constraint if exists "product_price_check",
+drop column if exists "description",
+drop column if exists "status",
+drop column if exists "stock",
+drop column if exists "currency",
+drop column if exists "price";
@@ -0,0 +1,13 @@
+alter table "product"
+add column "price" bigint,
+add column "currency" varchar(3) not null default 'IDR',
+add column "stock" integer,
+add column "status" varchar(20) not null default 'available',
+add column "description" text,
+add constraint "product_price_check" check ("price" >= 0),
+add constraint "product_stock_check" check ("stock" >= 0),
+add constraint "product_status_check" check ("status" in ('available', 'preorder', 'sold_out'));
+
+create index "idx_product_circle_id_price" on "product" ("circle_id", "price")
+where
+    "deleted_at" is null;
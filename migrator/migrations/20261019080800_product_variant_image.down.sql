@@ -0,0 +1,7 @@
+drop index if exists "idx_product_variant_product_id";
+
+drop table if exists "product_variant";
+
+drop index if exists "idx_product_image_product_id";
+
+drop table if exists "product_image";
@@ -0,0 +1,37 @@
+create table
+    "product_image" (
+        "id" serial primary key,
+        "product_id" integer not null,
+        "url" varchar(255) not null,
+        "position" integer not null default 0,
+        "created_at" timestamp not null default current_timestamp,
+        foreign key ("product_id") references "product" ("id") on delete cascade
+    );
+
+create index "idx_product_image_product_id" on "product_image" ("product_id");
+
+create table
+    "product_variant" (
+        "id" serial primary key,
+        "product_id" integer not null,
+        "name" varchar(100) not null,
+        "price" bigint check ("price" >= 0),
+        "stock" integer check ("stock" >= 0),
+        "position" integer not null default 0,
+        "created_at" timestamp not null default current_timestamp,
+        "updated_at" timestamp not null default current_timestamp,
+        "deleted_at" timestamp,
+        foreign key ("product_id") references "product" ("id") on delete cascade
+    );
+
+create index "idx_product_variant_product_id" on "product_variant" ("product_id");
+
+-- the current picture becomes the first picture of the gallery
+insert into
+    "product_image" ("product_id", "url", "position")
+select
+    "id",
+    "image_url",
+    0
+from
+    "product";
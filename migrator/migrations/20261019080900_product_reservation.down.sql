@@ -0,0 +1,5 @@
+drop index if exists "idx_product_reservation_user_id";
+
+drop index if exists "idx_product_reservation_circle_id_status";
+
+drop table if exists "product_reservation";
@@ -0,0 +1,31 @@
+create table
+    "product_reservation" (
+        "id" serial primary key,
+        "product_id" integer not null,
+        "variant_id" integer,
+        "circle_id" integer not null,
+        "user_id" integer not null,
+        "quantity" integer not null check ("quantity" > 0),
+        "note" text,
+        "status" varchar(20) not null default 'pending' check (
+            "status" in (
+                'pending',
+                'accepted',
+                'declined',
+                'fulfilled',
+                'cancelled'
+            )
+        ),
+        "response_note" text,
+        "responded_at" timestamp,
+        "created_at" timestamp not null default current_timestamp,
+        "updated_at" timestamp not null default current_timestamp,
+        foreign key ("product_id") references "product" ("id") on delete cascade,
+        foreign key ("variant_id") references "product_variant" ("id") on delete cascade,
+        foreign key ("circle_id") references "circle" ("id") on delete cascade,
+        foreign key ("user_id") references "user" ("id") on delete cascade
+    );
+
+create index "idx_product_reservation_circle_id_status" on "product_reservation" ("circle_id", "status");
+
+create index "idx_product_reservation_user_id" on "product_reservation" ("user_id");
@@ -0,0 +1,4 @@
+alter table "circle"
+drop column if exists "plan_id";
+
+drop table if exists "plan";
@@ -0,0 +1,29 @@
+create table
+    "plan" (
+        "id" serial primary key,
+        "slug" varchar(50) not null unique,
+        "name" varchar(100) not null,
+        "max_products" integer not null check ("max_products" >= 0),
+        "max_fandoms" integer not null check ("max_fandoms" >= 0),
+        "max_work_types" integer not null check ("max_work_types" >= 0),
+        -- circles without an assigned plan get the plan matching their rule
+        "rule" varchar(20) unique check ("rule" in ('default', 'verified', 'referred')),
+        "created_at" timestamp not null default current_timestamp,
+        "updated_at" timestamp not null default current_timestamp
+    );
+
+-- the limits that used to be hard-coded
+insert into
+    "plan" (
+        "slug",
+        "name",
+        "max_products",
+        "max_fandoms",
+        "max_work_types",
+        "rule"
+    )
+values
+    ('free', 'Free', 5, 5, 5, 'default');
+
+alter table "circle"
+add column "plan_id" integer references "plan" ("id") on delete set null;
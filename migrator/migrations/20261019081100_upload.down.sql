@@ -0,0 +1,3 @@
+drop index if exists "idx_upload_circle_id_status";
+
+drop table if exists "upload";
@@ -0,0 +1,19 @@
+create table
+    "upload" (
+        "id" serial primary key,
+        "ticket" varchar(36) not null unique,
+        "circle_id" integer not null,
+        "user_id" integer not null,
+        "folder" varchar(20) not null,
+        "object_key" text not null,
+        "path" text,
+        "status" varchar(20) not null default 'pending' check ("status" in ('pending', 'confirmed')),
+        "expires_at" timestamp not null,
+        "confirmed_at" timestamp,
+        "created_at" timestamp not null default current_timestamp,
+        "updated_at" timestamp not null default current_timestamp,
+        foreign key ("circle_id") references "circle" ("id") on delete cascade,
+        foreign key ("user_id") references "user" ("id") on delete cascade
+    );
+
+create index "idx_upload_circle_id_status" on "upload" ("circle_id", "status");
@@ -0,0 +1,32 @@
+alter table "plan"
+drop column if exists "max_storage_bytes";
+
+drop table if exists "upload_reference";
+
+drop index if exists "idx_upload_status_created_at";
+
+delete from "upload"
+where
+    "ticket" is null
+    or "circle_id" is null
+    or "user_id" is null;
+
+alter table "upload"
+drop constraint "upload_circle_id_fkey",
+drop constraint "upload_user_id_fkey",
+add constraint "upload_circle_id_fkey" foreign key ("circle_id") references "circle" ("id") on delete cascade,
+add constraint "upload_user_id_fkey" foreign key ("user_id") references "user" ("id") on delete cascade;
+
+update "upload"
+set
+    "object_key" = coalesce("object_key", ''),
+    "expires_at" = coalesce("expires_at", "created_at");
+
+alter table "upload"
+drop column if exists "size",
+drop column if exists "variants",
+alter column "ticket" set not null,
+alter column "circle_id" set not null,
+alter column "user_id" set not null,
+alter column "object_key" set not null,
+alter column "expires_at" set not null;
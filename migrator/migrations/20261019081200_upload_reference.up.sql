@@ -0,0 +1,29 @@
+alter table "upload"
+alter column "ticket" drop not null,
+alter column "circle_id" drop not null,
+alter column "user_id" drop not null,
+alter column "object_key" drop not null,
+alter column "expires_at" drop not null,
+add column "variants" jsonb,
+add column "size" bigint not null default 0;
+
+-- uploads outlive their owner so the sweeper can still delete their objects
+alter table "upload"
+drop constraint "upload_circle_id_fkey",
+drop constraint "upload_user_id_fkey",
+add constraint "upload_circle_id_fkey" foreign key ("circle_id") references "circle" ("id") on delete set null,
+add constraint "upload_user_id_fkey" foreign key ("user_id") references "user" ("id") on delete set null;
+
+create index "idx_upload_status_created_at" on "upload" ("status", "created_at");
+
+create table
+    "upload_reference" (
+        "upload_id" integer not null,
+        "entity_type" varchar(20) not null check ("entity_type" in ('circle', 'circle_draft', 'product', 'user')),
+        "entity_id" integer not null,
+        primary key ("upload_id", "entity_type", "entity_id"),
+        foreign key ("upload_id") references "upload" ("id") on delete cascade
+    );
+
+alter table "plan"
+add column "max_storage_bytes" bigint not null default 52428800;
@@ -0,0 +1,8 @@
+drop table if exists "banned_image_hash";
+
+drop index if exists "idx_upload_flag_status_created_at";
+
+drop table if exists "upload_flag";
+
+alter table "upload"
+drop column if exists "hash";
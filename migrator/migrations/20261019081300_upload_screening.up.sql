@@ -0,0 +1,31 @@
+alter table "upload"
+add column "hash" bigint;
+
+create table
+    "upload_flag" (
+        "id" serial primary key,
+        "upload_id" integer not null,
+        "matched_upload_id" integer,
+        "distance" integer not null,
+        "status" varchar(20) not null default 'pending' check ("status" in ('pending', 'dismissed', 'banned')),
+        "note" text,
+        "reviewed_by" integer,
+        "reviewed_at" timestamp,
+        "created_at" timestamp not null default current_timestamp,
+        "updated_at" timestamp not null default current_timestamp,
+        foreign key ("upload_id") references "upload" ("id") on delete cascade,
+        foreign key ("matched_upload_id") references "upload" ("id") on delete set null,
+        foreign key ("reviewed_by") references "user" ("id") on delete set null
+    );
+
+create index "idx_upload_flag_status_created_at" on "upload_flag" ("status", "created_at");
+
+create table
+    "banned_image_hash" (
+        "id" serial primary key,
+        "hash" bigint not null unique,
+        "reason" text,
+        "created_by" integer,
+        "created_at" timestamp not null default current_timestamp,
+        foreign key ("created_by") references "user" ("id") on delete set null
+    );
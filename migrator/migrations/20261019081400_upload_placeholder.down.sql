@@ -0,0 +1,4 @@
+drop index if exists "idx_upload_path";
+
+alter table "upload"
+drop column if exists "placeholder";
@@ -0,0 +1,4 @@
+alter table "upload"
+add column "placeholder" jsonb;
+
+create index "idx_upload_path" on "upload" ("path");
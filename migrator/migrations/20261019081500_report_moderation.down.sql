@@ -0,0 +1,17 @@
+drop index if exists "idx_circle_warning_circle_id";
+
+drop table if exists "circle_warning";
+
+alter table "circle"
+drop column if exists "hidden";
+
+drop index if exists "idx_report_status_circle_id";
+
+alter table "report"
+drop column if exists "updated_at",
+drop column if exists "reviewed_at",
+drop column if exists "reviewed_by",
+drop column if exists "action",
+drop column if exists "note",
+drop column if exists "assigned_to",
+drop column if exists "status";
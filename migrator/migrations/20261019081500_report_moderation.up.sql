@@ -0,0 +1,30 @@
+alter table "report"
+add column "status" varchar(20) not null default 'open' check ("status" in ('open', 'assigned', 'resolved', 'dismissed')),
+add column "assigned_to" integer,
+add column "note" text,
+add column "action" varchar(20) check ("action" in ('unpublish', 'hide', 'unhide', 'warn')),
+add column "reviewed_by" integer,
+add column "reviewed_at" timestamp,
+add column "updated_at" timestamp not null default current_timestamp,
+add foreign key ("assigned_to") references "user" ("id") on delete set null,
+add foreign key ("reviewed_by") references "user" ("id") on delete set null;
+
+create index "idx_report_status_circle_id" on "report" ("status", "circle_id");
+
+alter table "circle"
+add column "hidden" boolean not null default false;
+
+create table
+    "circle_warning" (
+        "id" serial primary key,
+        "circle_id" integer not null,
+        "report_id" integer,
+        "message" text not null,
+        "created_by" integer,
+        "created_at" timestamp not null default current_timestamp,
+        foreign key ("circle_id") references "circle" ("id") on delete cascade,
+        foreign key ("report_id") references "report" ("id") on delete set null,
+        foreign key ("created_by") references "user" ("id") on delete set null
+    );
+
+create index "idx_circle_warning_circle_id" on "circle_warning" ("circle_id");
@@ -0,0 +1,6 @@
+drop index if exists "idx_report_user_id_circle_id_pending";
+
+alter table "report"
+drop column if exists "category";
+
+drop table if exists "report_category";
@@ -0,0 +1,55 @@
+create table
+    "report_category" (
+        "slug" varchar(50) primary key,
+        "name" varchar(100) not null,
+        "weight" integer not null default 1 check ("weight" >= 0),
+        "created_at" timestamp not null default current_timestamp,
+        "updated_at" timestamp not null default current_timestamp
+    );
+
+insert into
+    "report_category" ("slug", "name", "weight")
+values
+    ('stolen_art', 'Stolen art', 5),
+    ('wrong_rating', 'Wrong rating', 2),
+    ('spam', 'Spam', 3),
+    ('offensive', 'Offensive', 4);
+
+alter table "report"
+add column "category" varchar(50),
+add foreign key ("category") references "report_category" ("slug") on update cascade;
+
+-- keep only the latest pending report of every user on a circle
+update "report"
+set
+    "status" = 'dismissed',
+    "note" = 'Duplicate report',
+    "updated_at" = current_timestamp
+where
+    "id" in (
+        select
+            "id"
+        from
+            (
+                select
+                    "id",
+                    row_number() over (
+                        partition by
+                            "user_id",
+                            "circle_id"
+                        order by
+                            "created_at" desc,
+                            "id" desc
+                    ) as "rank"
+                from
+                    "report"
+                where
+                    "status" in ('open', 'assigned')
+            ) "pending"
+        where
+            "rank" > 1
+    );
+
+create unique index "idx_report_user_id_circle_id_pending" on "report" ("user_id", "circle_id")
+where
+    "status" in ('open', 'assigned');
@@ -0,0 +1,21 @@
+alter table "product"
+drop column if exists "hidden";
+
+drop index if exists "idx_report_target_type_target_id";
+
+drop index if exists "idx_report_user_id_target_pending";
+
+-- only circle reports fit the old model
+delete from "report"
+where
+    "target_type" <> 'circle';
+
+create unique index "idx_report_user_id_circle_id_pending" on "report" ("user_id", "circle_id")
+where
+    "status" in ('open', 'assigned');
+
+alter table "report"
+alter column "circle_id"
+set not null,
+drop column if exists "target_id",
+drop column if exists "target_type";
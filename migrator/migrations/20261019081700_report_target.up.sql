@@ -0,0 +1,27 @@
+alter table "report"
+add column "target_type" varchar(20) not null default 'circle' check ("target_type" in ('circle', 'product', 'user')),
+add column "target_id" integer;
+
+-- every report so far was made on a circle
+update "report"
+set
+    "target_id" = "circle_id";
+
+alter table "report"
+alter column "target_type"
+drop default,
+alter column "target_id"
+set not null,
+alter column "circle_id"
+drop not null;
+
+drop index if exists "idx_report_user_id_circle_id_pending";
+
+create unique index "idx_report_user_id_target_pending" on "report" ("user_id", "target_type", "target_id")
+where
+    "status" in ('open', 'assigned');
+
+create index "idx_report_target_type_target_id" on "report" ("target_type", "target_id");
+
+alter table "product"
+add column "hidden" boolean not null default false;
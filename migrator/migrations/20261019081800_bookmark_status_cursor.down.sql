@@ -0,0 +1,6 @@
+drop index if exists "idx_user_bookmark_status_user_id_sync_xid";
+
+create index "idx_user_bookmark_status_user_id_synced_at" on "user_bookmark_status" ("user_id", "synced_at");
+
+alter table "user_bookmark_status"
+drop column if exists "sync_xid";
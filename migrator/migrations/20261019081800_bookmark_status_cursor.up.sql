@@ -0,0 +1,8 @@
+-- the transaction writing a status, a sync can not miss a transaction that
+-- commits after it read like it can with a timestamp
+alter table "user_bookmark_status"
+add column "sync_xid" xid8 not null default pg_current_xact_id();
+
+drop index if exists "idx_user_bookmark_status_user_id_synced_at";
+
+create index "idx_user_bookmark_status_user_id_sync_xid" on "user_bookmark_status" ("user_id", "sync_xid");
@@ -0,0 +1,9 @@
+delete from "upload_reference"
+where
+    "entity_type" = 'circle_revision';
+
+drop index if exists "idx_upload_reference_entity_type_entity_id";
+
+alter table "upload_reference"
+drop constraint "upload_reference_entity_type_check",
+add constraint "upload_reference_entity_type_check" check ("entity_type" in ('circle', 'circle_draft', 'product', 'user'));
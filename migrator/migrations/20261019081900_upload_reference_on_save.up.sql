@@ -0,0 +1,33 @@
+alter table "upload_reference"
+drop constraint "upload_reference_entity_type_check",
+add constraint "upload_reference_entity_type_check" check ("entity_type" in ('circle', 'circle_draft', 'product', 'user', 'circle_revision'));
+
+create index "idx_upload_reference_entity_type_entity_id" on "upload_reference" ("entity_type", "entity_id");
+
+-- references are saved with their entity from now on, link what was saved
+-- before once, revisions included so they can still be restored
+with
+    "u" as (
+        select "id", '%' || replace("path", '.webp', '') || '%' as "pattern"
+        from "upload"
+        where "path" is not null
+    )
+insert into "upload_reference" ("upload_id", "entity_type", "entity_id")
+select "u"."id", 'circle', "c"."id" from "u"
+join "circle" "c" on concat_ws(' ', "c"."picture_url", "c"."cover_picture_url", "c"."description") like "u"."pattern"
+union
+select "u"."id", 'circle_draft', "d"."circle_id" from "u"
+join "circle_draft" "d" on "d"."payload"::text like "u"."pattern"
+union
+select "u"."id", 'product', "p"."id" from "u"
+join "product" "p" on concat_ws(' ', "p"."image_url", "p"."description") like "u"."pattern"
+union
+select "u"."id", 'product', "pi"."product_id" from "u"
+join "product_image" "pi" on "pi"."url" like "u"."pattern"
+union
+select "u"."id", 'user', "us"."id" from "u"
+join "user" "us" on "us"."profile_picture_url" like "u"."pattern"
+union
+select "u"."id", 'circle_revision', "r"."id" from "u"
+join "circle_revision" "r" on concat_ws(' ', "r"."before"::text, "r"."after"::text) like "u"."pattern"
+on conflict do nothing;
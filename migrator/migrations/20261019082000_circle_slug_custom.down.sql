@@ -0,0 +1,2 @@
+alter table "circle"
+drop column if exists "slug_custom";
@@ -0,0 +1,9 @@
+alter table "circle"
+add column "slug_custom" boolean not null default false;
+
+-- slugs without the suffix of a generated slug were picked by their owner
+update "circle"
+set
+    "slug_custom" = true
+where
+    "slug" !~ '-[a-z0-9]{2}$';
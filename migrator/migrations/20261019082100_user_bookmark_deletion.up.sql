@@ -0,0 +1,13 @@
+-- removing a bookmark cascades to its statuses, the row left here tells the
+-- other devices of the user to drop them on their next sync
+create table
+    "user_bookmark_deletion" (
+        "user_id" integer not null,
+        "circle_id" integer not null,
+        "deleted_at" timestamp not null default current_timestamp,
+        "sync_xid" xid8 not null default pg_current_xact_id(),
+        primary key ("user_id", "circle_id"),
+        foreign key ("user_id") references "user" ("id") on delete cascade
+    );
+
+create index "idx_user_bookmark_deletion_user_id_sync_xid" on "user_bookmark_deletion" ("user_id", "sync_xid");
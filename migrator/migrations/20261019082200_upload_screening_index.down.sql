@@ -0,0 +1,3 @@
+drop index if exists "idx_upload_flag_upload_id";
+
+drop index if exists "idx_upload_folder_hash";
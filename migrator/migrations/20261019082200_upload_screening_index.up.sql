@@ -0,0 +1,6 @@
+create index "idx_upload_folder_hash" on "upload" ("folder")
+where
+    "hash" is not null
+    and "status" = 'confirmed';
+
+create index "idx_upload_flag_upload_id" on "upload_flag" ("upload_id");
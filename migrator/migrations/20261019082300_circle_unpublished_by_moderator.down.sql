@@ -0,0 +1,13 @@
+update "report"
+set
+    "action" = null
+where
+    "action" = 'republish';
+
+alter table "report"
+drop constraint if exists "report_action_check";
+alter table "report"
+add constraint "report_action_check" check ("action" in ('unpublish', 'hide', 'unhide', 'warn'));
+
+alter table "circle"
+drop column if exists "unpublished_by_moderator";
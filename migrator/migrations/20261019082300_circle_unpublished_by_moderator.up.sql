@@ -0,0 +1,7 @@
+alter table "circle"
+add column "unpublished_by_moderator" boolean not null default false;
+
+alter table "report"
+drop constraint if exists "report_action_check";
+alter table "report"
+add constraint "report_action_check" check ("action" in ('unpublish', 'republish', 'hide', 'unhide', 'warn'));
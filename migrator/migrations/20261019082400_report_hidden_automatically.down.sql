@@ -0,0 +1,5 @@
+alter table "product"
+drop column if exists "hidden_automatically";
+
+alter table "circle"
+drop column if exists "hidden_automatically";
@@ -0,0 +1,5 @@
+alter table "circle"
+add column "hidden_automatically" boolean not null default false;
+
+alter table "product"
+add column "hidden_automatically" boolean not null default false;
@@ -0,0 +1,275 @@
+package circle_test
+
+import (
+	"catalog-be/internal/entity"
+	circle_dto "catalog-be/internal/modules/circle/dto"
+	test_helper "catalog-be/tests/test_helper"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// flatCircleRow is a row of the catalog query from before relations were
+// aggregated in SQL, one per circle × fandom × work type × product.
+type flatCircleRow struct {
+	ID             int
+	Name           string
+	Slug           string
+	PictureURL     *string
+	Verified       bool
+	Published      bool
+	Day            *entity.Day
+	EventID        *int
+	FandomID       int
+	FandomName     string
+	WorkTypeID     int
+	WorkTypeName   string
+	EventName      string
+	EventSlug      string
+	EventStartedAt *time.Time
+	EventEndedAt   *time.Time
+	BlockEventID   int
+	BlockEventName string
+	Bookmarked     bool
+}
+
+// getPaginatedCirclesFlat is the catalog query before the aggregation, kept
+// as the baseline of the benchmarks.
+func getPaginatedCirclesFlat(db *gorm.DB, filter *circle_dto.GetPaginatedCirclesFilter, userID int) ([]flatCircleRow, error) {
+	ids := db.
+		Table("circle c").
+		Joins("LEFT JOIN circle_fandom cf ON c.id = cf.circle_id").
+		Joins("LEFT JOIN fandom f ON f.id = cf.fandom_id").
+		Joins("LEFT JOIN circle_work_type cwt ON c.id = cwt.circle_id").
+		Joins("LEFT JOIN work_type wt ON wt.id = cwt.work_type_id").
+		Joins("LEFT JOIN block_event be ON c.id = be.circle_id AND be.event_id = c.event_id").
+		Where("c.deleted_at IS NULL").
+		Where("c.verified IS TRUE")
+
+	if len(filter.FandomIDs) > 0 {
+		ids = ids.Where("f.id in (?)", filter.FandomIDs)
+	}
+
+	if len(filter.WorkTypeIDs) > 0 {
+		ids = ids.Where("wt.id in (?)", filter.WorkTypeIDs)
+	}
+
+	if filter.Search != "" {
+		searchQuery := fmt.Sprintf("%%%s%%", filter.Search)
+		ids = ids.Where("c.name ILIKE ? OR f.name ILIKE ? OR wt.name ILIKE ? OR be.name ILIKE ?",
+			searchQuery,
+			searchQuery,
+			searchQuery,
+			searchQuery)
+	}
+
+	ids = ids.
+		Distinct("c.id").
+		Order("c.id desc").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit)
+
+	var rows []flatCircleRow
+	err := db.
+		Table("(?) AS cte", ids).
+		Joins("INNER JOIN circle c ON cte.id = c.id").
+		Joins("LEFT JOIN circle_fandom cf ON c.id = cf.circle_id").
+		Joins("LEFT JOIN fandom f ON f.id = cf.fandom_id").
+		Joins("LEFT JOIN circle_work_type cwt ON c.id = cwt.circle_id").
+		Joins("LEFT JOIN work_type wt ON wt.id = cwt.work_type_id").
+		Joins("LEFT JOIN product p ON c.id = p.circle_id").
+		Joins("LEFT JOIN user_bookmark ub ON c.id = ub.circle_id AND ub.user_id = COALESCE(?, ub.user_id)", userID).
+		Joins("LEFT JOIN event e ON c.event_id = e.id").
+		Joins("LEFT JOIN block_event be ON c.id = be.circle_id AND be.event_id = c.event_id").
+		Select(`
+			c.id, c.name, c.slug, c.picture_url, c.verified, c.published, c.day, c.event_id,
+			f.id as fandom_id, f.name as fandom_name,
+			wt.id as work_type_id, wt.name as work_type_name,
+			e.name as event_name, e.slug as event_slug, e.started_at as event_started_at, e.ended_at as event_ended_at,
+			be.id as block_event_id, be.name as block_event_name,
+			CASE WHEN ub.user_id IS NOT NULL THEN TRUE ELSE FALSE END AS bookmarked
+		`).
+		Order("c.id desc").
+		Scan(&rows).Error
+
+	return rows, err
+}
+
+// regroupFlatCircles is the regrouping CircleService did on the flat rows
+// before the aggregation.
+func regroupFlatCircles(rows []flatCircleRow) []circle_dto.CirclePaginatedResponse {
+	response := []circle_dto.CirclePaginatedResponse{}
+
+	for _, row := range rows {
+		var found bool
+		for i := range response {
+			if response[i].ID != row.ID {
+				continue
+			}
+			found = true
+
+			fandomExist := false
+			for _, fandom := range response[i].Fandom {
+				if fandom.ID == row.FandomID {
+					fandomExist = true
+					break
+				}
+			}
+			if !fandomExist && row.FandomID != 0 {
+				response[i].Fandom = append(response[i].Fandom, entity.Fandom{ID: row.FandomID, Name: row.FandomName})
+			}
+
+			workTypeExist := false
+			for _, workType := range response[i].WorkType {
+				if workType.ID == row.WorkTypeID {
+					workTypeExist = true
+					break
+				}
+			}
+			if !workTypeExist && row.WorkTypeID != 0 {
+				response[i].WorkType = append(response[i].WorkType, entity.WorkType{ID: row.WorkTypeID, Name: row.WorkTypeName})
+			}
+		}
+
+		if found {
+			continue
+		}
+
+		latestRow := circle_dto.CirclePaginatedResponse{
+			Circle: entity.Circle{
+				ID:         row.ID,
+				Name:       row.Name,
+				Slug:       row.Slug,
+				PictureURL: row.PictureURL,
+				Verified:   row.Verified,
+				Published:  row.Published,
+				Day:        row.Day,
+				EventID:    row.EventID,
+			},
+			Fandom:     []entity.Fandom{},
+			WorkType:   []entity.WorkType{},
+			Bookmarked: row.Bookmarked,
+		}
+
+		if row.FandomID != 0 {
+			latestRow.Fandom = append(latestRow.Fandom, entity.Fandom{ID: row.FandomID, Name: row.FandomName})
+		}
+
+		if row.WorkTypeID != 0 {
+			latestRow.WorkType = append(latestRow.WorkType, entity.WorkType{ID: row.WorkTypeID, Name: row.WorkTypeName})
+		}
+
+		if row.BlockEventID != 0 {
+			latestRow.BlockEvent = &circle_dto.BlockResponse{ID: row.BlockEventID, Name: row.BlockEventName}
+		}
+
+		if row.EventID != nil && row.EventStartedAt != nil && row.EventEndedAt != nil {
+			latestRow.Event = &entity.Event{
+				ID:        *row.EventID,
+				Name:      row.EventName,
+				Slug:      row.EventSlug,
+				StartedAt: *row.EventStartedAt,
+				EndedAt:   *row.EventEndedAt,
+			}
+		}
+
+		response = append(response, latestRow)
+	}
+
+	return response
+}
+
+// BenchmarkCircle measures the catalog queries against the seeded catalog.
+// Run with `make bench` to get query time and allocations per operation, the
+// "flat rows" benchmarks are the baseline from before the aggregation.
+func BenchmarkCircle(b *testing.B) {
+	ctx := context.Background()
+	connURL, _ := test_helper.GetConnURL(b, ctx)
+	db := test_helper.SetupDb(b, connURL)
+
+	seedDataForPagination(b, db)
+	instance := newCreateCircleInstance(db)
+
+	b.Run("GetPaginatedCircles", func(b *testing.B) {
+		filter := &circle_dto.GetPaginatedCirclesFilter{
+			Page:  1,
+			Limit: 20,
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := instance.circleService.GetPaginatedCircles(filter, 0); err != nil {
+				b.Fatal(err.Err)
+			}
+		}
+	})
+
+	b.Run("GetPaginatedCircles flat rows", func(b *testing.B) {
+		filter := &circle_dto.GetPaginatedCirclesFilter{
+			Page:  1,
+			Limit: 20,
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			rows, err := getPaginatedCirclesFlat(db, filter, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			regroupFlatCircles(rows)
+		}
+	})
+
+	b.Run("GetPaginatedCircles with filters", func(b *testing.B) {
+		filter := &circle_dto.GetPaginatedCirclesFilter{
+			Page:        1,
+			Limit:       20,
+			Search:      "a",
+			FandomIDs:   []int{1, 2, 3, 4, 5, 51, 52, 64},
+			WorkTypeIDs: []int{1, 3, 5},
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := instance.circleService.GetPaginatedCircles(filter, 0); err != nil {
+				b.Fatal(err.Err)
+			}
+		}
+	})
+
+	b.Run("GetPaginatedCircles with filters flat rows", func(b *testing.B) {
+		filter := &circle_dto.GetPaginatedCirclesFilter{
+			Page:        1,
+			Limit:       20,
+			Search:      "a",
+			FandomIDs:   []int{1, 2, 3, 4, 5, 51, 52, 64},
+			WorkTypeIDs: []int{1, 3, 5},
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			rows, err := getPaginatedCirclesFlat(db, filter, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			regroupFlatCircles(rows)
+		}
+	})
+
+	b.Run("GetOneCircleByCircleSlug", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := instance.circleService.GetOneCircleByCircleSlug("maghiarfer-ar", 0); err != nil {
+				b.Fatal(err.Err)
+			}
+		}
+	})
+}
@@ -1,20 +1,33 @@
 package circle_test
 
 import (
+	"bytes"
 	"catalog-be/internal/entity"
 	"catalog-be/internal/modules/circle"
 	"catalog-be/internal/modules/circle/bookmark"
+	bookmark_dto "catalog-be/internal/modules/circle/bookmark/dto"
 	"catalog-be/internal/modules/circle/circle_fandom"
 	"catalog-be/internal/modules/circle/circle_work_type"
 	circle_dto "catalog-be/internal/modules/circle/dto"
+	"catalog-be/internal/modules/circle/export"
 	"catalog-be/internal/modules/circle/referral"
+	"catalog-be/internal/modules/circle/revision"
+	revision_dto "catalog-be/internal/modules/circle/revision/dto"
+	"catalog-be/internal/modules/plan"
+	plan_dto "catalog-be/internal/modules/plan/dto"
+	"catalog-be/internal/modules/product"
+	product_dto "catalog-be/internal/modules/product/dto"
+	"catalog-be/internal/modules/product/reservation"
+	reservation_dto "catalog-be/internal/modules/product/reservation/dto"
 	refreshtoken "catalog-be/internal/modules/refresh_token"
 	"catalog-be/internal/modules/user"
 	"catalog-be/internal/utils"
 	"catalog-be/internal/validation"
 	test_helper "catalog-be/tests/test_helper"
 	"context"
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"math/rand"
 	"os"
 	"strings"
@@ -25,7 +38,7 @@ import (
 	"gorm.io/gorm"
 )
 
-func seedCircle(t *testing.T, db *gorm.DB) {
+func seedCircle(t testing.TB, db *gorm.DB) {
 	file, err := os.Open("./data/circle_initial.json")
 	if err != nil {
 		t.Fatal(err)
@@ -72,7 +85,7 @@ func seedCircle(t *testing.T, db *gorm.DB) {
 	}
 }
 
-func seedCircleWorkType(t *testing.T, db *gorm.DB) {
+func seedCircleWorkType(t testing.TB, db *gorm.DB) {
 	circleWorkTypeFile, err := os.Open("./data/circle_worktype_initial.json")
 	if err != nil {
 		t.Fatal(err)
@@ -101,7 +114,7 @@ func seedCircleWorkType(t *testing.T, db *gorm.DB) {
 	}
 }
 
-func seedCircleFandom(t *testing.T, db *gorm.DB) {
+func seedCircleFandom(t testing.TB, db *gorm.DB) {
 	circleFandomFile, err := os.Open("./data/circle_fandom_initial.json")
 	if err != nil {
 		t.Fatal(err)
@@ -131,7 +144,7 @@ func seedCircleFandom(t *testing.T, db *gorm.DB) {
 	}
 }
 
-func seedDataForPagination(t *testing.T, db *gorm.DB) {
+func seedDataForPagination(t testing.TB, db *gorm.DB) {
 	test_helper.SeedEvent(t, db)
 	test_helper.SeedFandom(t, db)
 	test_helper.SeedWorkType(t, db)
@@ -146,7 +159,8 @@ func TestMain(m *testing.M) {
 }
 
 type createCircleInstance struct {
-	circleService *circle.CircleService
+	circleService   *circle.CircleService
+	bookmarkService *bookmark.CircleBookmarkService
 }
 
 func newCreateCircleInstance(db *gorm.DB) *createCircleInstance {
@@ -166,10 +180,15 @@ func newCreateCircleInstance(db *gorm.DB) *createCircleInstance {
 	validation := validation.NewSanitizer()
 	referralRepo := referral.NewReferralRepo(db)
 	referralService := referral.NewReferralService(referralRepo)
+	revisionRepo := revision.NewRevisionRepo(db)
+	revisionService := revision.NewRevisionService(revisionRepo)
+	planRepo := plan.NewPlanRepo(db)
+	planService := plan.NewPlanService(planRepo)
 
-	circleService := circle.NewCircleService(circleRepo, userService, utils, refreshTokenService, circleWorkTypeService, circleFandomService, bookmarkService, validation, referralService)
+	circleService := circle.NewCircleService(circleRepo, userService, utils, refreshTokenService, circleWorkTypeService, circleFandomService, bookmarkService, validation, referralService, revisionService, planService)
 	return &createCircleInstance{
-		circleService: circleService,
+		circleService:   circleService,
+		bookmarkService: bookmarkService,
 	}
 }
 
@@ -467,6 +486,28 @@ func TestCircle(t *testing.T) {
 
 		})
 
+		t.Run("Test relations are aggregated per circle", func(t *testing.T) {
+			data, err := instance.circleService.GetPaginatedCircles(&circle_dto.GetPaginatedCirclesFilter{
+				Page:  1,
+				Limit: 20,
+			}, 0)
+			assert.Nil(t, err)
+
+			for _, circle := range data.Data {
+				fandomIDs := make(map[int]bool)
+				for _, fandom := range circle.Fandom {
+					assert.False(t, fandomIDs[fandom.ID])
+					fandomIDs[fandom.ID] = true
+				}
+
+				workTypeIDs := make(map[int]bool)
+				for _, workType := range circle.WorkType {
+					assert.False(t, workTypeIDs[workType.ID])
+					workTypeIDs[workType.ID] = true
+				}
+			}
+		})
+
 		t.Run("Test filter by event", func(t *testing.T) {
 			t.Run("Test single event", func(t *testing.T) {
 				event := "event-1"
@@ -484,4 +525,950 @@ func TestCircle(t *testing.T) {
 			})
 		})
 	})
+
+	t.Run("Test get one circle by slug", func(t *testing.T) {
+		t.Run("should return every fandom of the circle", func(t *testing.T) {
+			circle, err := instance.circleService.GetOneCircleByCircleSlug("maghiarfer-ar", 0)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, circle.ID)
+			assert.Len(t, circle.Fandom, 3)
+			assert.Len(t, circle.WorkType, 2)
+		})
+
+		t.Run("should return not found", func(t *testing.T) {
+			_, err := instance.circleService.GetOneCircleByCircleSlug("not-exist-circle", 0)
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+		})
+	})
+
+	t.Run("Test custom slug", func(t *testing.T) {
+		before, err := instance.circleService.GetOneCircleByCircleID(3)
+		assert.Nil(t, err)
+
+		t.Run("should reject reserved slug", func(t *testing.T) {
+			slug := "bookmarked"
+			_, err := instance.circleService.UpdateCircleByID(0, 3, &circle_dto.UpdateCirclePayload{Slug: &slug})
+			assert.NotNil(t, err)
+			assert.Equal(t, "SLUG_RESERVED", err.Err.Error())
+		})
+
+		t.Run("should reject slug of another circle", func(t *testing.T) {
+			slug := "maghiarfer-ar"
+			_, err := instance.circleService.UpdateCircleByID(0, 3, &circle_dto.UpdateCirclePayload{Slug: &slug})
+			assert.NotNil(t, err)
+			assert.Equal(t, 409, err.Code)
+		})
+
+		t.Run("should redirect old slug to the new one", func(t *testing.T) {
+			slug := "my-custom-slug"
+			updated, err := instance.circleService.UpdateCircleByID(0, 3, &circle_dto.UpdateCirclePayload{Slug: &slug})
+			assert.Nil(t, err)
+			assert.Equal(t, slug, updated.Slug)
+
+			canonical, err := instance.circleService.GetCanonicalCircleSlug(before.Slug)
+			assert.Nil(t, err)
+			assert.Equal(t, slug, canonical)
+		})
+
+		t.Run("should allow going back to an old slug", func(t *testing.T) {
+			updated, err := instance.circleService.UpdateCircleByID(0, 3, &circle_dto.UpdateCirclePayload{Slug: &before.Slug})
+			assert.Nil(t, err)
+			assert.Equal(t, before.Slug, updated.Slug)
+
+			canonical, err := instance.circleService.GetCanonicalCircleSlug("my-custom-slug")
+			assert.Nil(t, err)
+			assert.Equal(t, before.Slug, canonical)
+		})
+
+		t.Run("should keep a custom slug on rename", func(t *testing.T) {
+			slug := "picked-by-the-owner"
+			_, err := instance.circleService.UpdateCircleByID(0, 37, &circle_dto.UpdateCirclePayload{Slug: &slug})
+			assert.Nil(t, err)
+
+			name := "Renamed Circle"
+			updated, err := instance.circleService.UpdateCircleByID(0, 37, &circle_dto.UpdateCirclePayload{Name: &name})
+			assert.Nil(t, err)
+			assert.Equal(t, name, updated.Name)
+			assert.Equal(t, slug, updated.Slug)
+		})
+
+		t.Run("should regenerate a generated slug on rename", func(t *testing.T) {
+			name := "Renamed Generated"
+			updated, err := instance.circleService.UpdateCircleByID(0, 38, &circle_dto.UpdateCirclePayload{Name: &name})
+			assert.Nil(t, err)
+			assert.True(t, strings.HasPrefix(updated.Slug, "renamed-generated-"))
+		})
+
+		t.Run("should keep a custom slug shaped like a generated one", func(t *testing.T) {
+			name := "Keep Me"
+			slug := "keep-me-ok"
+			_, err := instance.circleService.UpdateCircleByID(0, 40, &circle_dto.UpdateCirclePayload{Name: &name, Slug: &slug})
+			assert.Nil(t, err)
+
+			renamed := "Kept Anyway"
+			updated, err := instance.circleService.UpdateCircleByID(0, 40, &circle_dto.UpdateCirclePayload{Name: &renamed})
+			assert.Nil(t, err)
+			assert.Equal(t, slug, updated.Slug)
+			assert.True(t, updated.SlugCustom)
+		})
+	})
+
+	t.Run("Test revision", func(t *testing.T) {
+		name := "Revisioned Circle"
+		fandomIDs := []int{1}
+		_, err := instance.circleService.UpdateCircleByID(0, 4, &circle_dto.UpdateCirclePayload{Name: &name, FandomIDs: &fandomIDs})
+		assert.Nil(t, err)
+
+		filter := &revision_dto.GetPaginatedRevisionsFilter{Page: 1, Limit: 20}
+
+		t.Run("should record the diff of an update", func(t *testing.T) {
+			revisions, err := instance.circleService.GetPaginatedRevisionsByCircleID(4, filter)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, revisions.Metadata.TotalDocs)
+
+			fields := []string{}
+			for _, change := range revisions.Data[0].Diff {
+				fields = append(fields, change.Field)
+			}
+			assert.Contains(t, fields, "name")
+			assert.Contains(t, fields, "slug")
+			assert.Contains(t, fields, "fandom_ids")
+		})
+
+		t.Run("should restore the circle to a previous revision", func(t *testing.T) {
+			secondName := "Revisioned Circle Again"
+			emptyFandomIDs := []int{}
+			_, err := instance.circleService.UpdateCircleByID(0, 4, &circle_dto.UpdateCirclePayload{Name: &secondName, FandomIDs: &emptyFandomIDs})
+			assert.Nil(t, err)
+
+			revisions, err := instance.circleService.GetPaginatedRevisionsByCircleID(4, filter)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, revisions.Metadata.TotalDocs)
+
+			first := revisions.Data[1]
+			err = instance.circleService.RestoreRevisionByID(0, 4, first.ID)
+			assert.Nil(t, err)
+
+			restored, err := instance.circleService.GetOneCircleByCircleSlug(first.After["slug"].(string), 0)
+			assert.Nil(t, err)
+			assert.Equal(t, name, restored.Name)
+			assert.Len(t, restored.Fandom, 1)
+
+			revisions, err = instance.circleService.GetPaginatedRevisionsByCircleID(4, filter)
+			assert.Nil(t, err)
+			assert.Equal(t, 3, revisions.Metadata.TotalDocs)
+			assert.Equal(t, entity.RevisionRestore, revisions.Data[0].Action)
+			assert.Equal(t, first.ID, *revisions.Data[0].RestoredFromID)
+		})
+
+		t.Run("should not restore revision of another circle", func(t *testing.T) {
+			revisions, err := instance.circleService.GetPaginatedRevisionsByCircleID(4, filter)
+			assert.Nil(t, err)
+
+			err = instance.circleService.RestoreRevisionByID(0, 5, revisions.Data[0].ID)
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+		})
+	})
+
+	t.Run("Test draft", func(t *testing.T) {
+		live, err := instance.circleService.GetOneCircleByCircleID(6)
+		assert.Nil(t, err)
+
+		name := "Drafted Circle"
+		fandomIDs := []int{1, 2}
+		draft, err := instance.circleService.SaveCircleDraft(0, 6, &circle_dto.UpdateCirclePayload{Name: &name, FandomIDs: &fandomIDs})
+		assert.Nil(t, err)
+		assert.NotEmpty(t, draft.PreviewToken)
+
+		t.Run("should preview without changing the live circle", func(t *testing.T) {
+			preview, err := instance.circleService.PreviewCircleDraft(draft.PreviewToken)
+			assert.Nil(t, err)
+			assert.Equal(t, name, preview.Name)
+			assert.Len(t, preview.Fandom, 2)
+
+			current, err := instance.circleService.GetOneCircleByCircleID(6)
+			assert.Nil(t, err)
+			assert.Equal(t, live.Name, current.Name)
+		})
+
+		t.Run("should keep drafted fields when saving again", func(t *testing.T) {
+			rating := "PG"
+			draft, err := instance.circleService.SaveCircleDraft(0, 6, &circle_dto.UpdateCirclePayload{Rating: &rating})
+			assert.Nil(t, err)
+			assert.Equal(t, name, draft.Payload["name"])
+			assert.Equal(t, rating, draft.Payload["rating"])
+		})
+
+		t.Run("should publish a scheduled draft once it is due", func(t *testing.T) {
+			publishAt := time.Now().Add(time.Hour)
+			_, err := instance.circleService.ScheduleCircleDraft(0, 6, publishAt)
+			assert.Nil(t, err)
+
+			published, err := instance.circleService.PublishDueCircleDrafts(time.Now())
+			assert.Nil(t, err)
+			assert.Equal(t, 0, published)
+
+			published, err = instance.circleService.PublishDueCircleDrafts(publishAt.Add(time.Minute))
+			assert.Nil(t, err)
+			assert.Equal(t, 1, published)
+
+			current, err := instance.circleService.GetOneCircleByCircleID(6)
+			assert.Nil(t, err)
+			assert.Equal(t, name, current.Name)
+			assert.True(t, current.Published)
+
+			_, err = instance.circleService.GetCircleDraft(6)
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+		})
+	})
+
+	t.Run("Test delete and restore", func(t *testing.T) {
+		circleID := 7
+		owner := entity.User{Name: "Owner 7", Email: "owner7@example.com", Hash: "hash", CircleID: &circleID}
+		if err := db.Create(&owner).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		t.Run("should unlink the owner when deleted", func(t *testing.T) {
+			err := instance.circleService.DeleteCircleByID(owner.ID, circleID)
+			assert.Nil(t, err)
+
+			_, err = instance.circleService.GetOneCircleByCircleID(circleID)
+			assert.NotNil(t, err)
+
+			var found entity.User
+			db.First(&found, owner.ID)
+			assert.Nil(t, found.CircleID)
+
+			var blocks int64
+			db.Model(&entity.BlockEvent{}).Where("circle_id = ?", circleID).Count(&blocks)
+			assert.Equal(t, int64(0), blocks)
+		})
+
+		t.Run("should list deleted circle", func(t *testing.T) {
+			deleted, err := instance.circleService.GetPaginatedDeletedCircles(&circle_dto.GetPaginatedDeletedCirclesFilter{Page: 1, Limit: 20})
+			assert.Nil(t, err)
+			assert.Equal(t, 1, deleted.Metadata.TotalDocs)
+			assert.Equal(t, circleID, deleted.Data[0].ID)
+		})
+
+		t.Run("should restore and link the owner back", func(t *testing.T) {
+			restored, err := instance.circleService.RestoreOwnDeletedCircle(owner.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, circleID, restored.ID)
+
+			var found entity.User
+			db.First(&found, owner.ID)
+			assert.Equal(t, circleID, *found.CircleID)
+		})
+
+		t.Run("should purge only deleted circle", func(t *testing.T) {
+			err := instance.circleService.PurgeDeletedCircleByID(circleID)
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+
+			err = instance.circleService.DeleteCircleByID(owner.ID, circleID)
+			assert.Nil(t, err)
+
+			err = instance.circleService.PurgeDeletedCircleByID(circleID)
+			assert.Nil(t, err)
+
+			var count int64
+			db.Unscoped().Model(&entity.Circle{}).Where("id = ?", circleID).Count(&count)
+			assert.Equal(t, int64(0), count)
+		})
+	})
+
+	t.Run("Test bookmark collection", func(t *testing.T) {
+		visitor := entity.User{Name: "Visitor", Email: "visitor@example.com", Hash: "hash"}
+		if err := db.Create(&visitor).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		for _, circleID := range []int{8, 9, 10} {
+			if err := instance.bookmarkService.CreateOneBookmark(circleID, visitor.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		collection, err := instance.bookmarkService.CreateOneCollection(visitor.ID, &bookmark_dto.CreateUpdateCollectionPayload{Name: "Day 1 must-visit"})
+		assert.Nil(t, err)
+
+		t.Run("should reject duplicated collection name", func(t *testing.T) {
+			_, err := instance.bookmarkService.CreateOneCollection(visitor.ID, &bookmark_dto.CreateUpdateCollectionPayload{Name: "Day 1 must-visit"})
+			assert.NotNil(t, err)
+			assert.Equal(t, 409, err.Code)
+		})
+
+		t.Run("should move bookmark with note and priority", func(t *testing.T) {
+			note := "Get the new artbook"
+			priority := 3
+			for _, circleID := range []int{8, 9} {
+				_, err := instance.bookmarkService.UpdateBookmark(circleID, visitor.ID, &bookmark_dto.SaveBookmarkPayload{
+					CollectionID: &collection.ID,
+					Note:         &note,
+					Priority:     &priority,
+				})
+				assert.Nil(t, err)
+			}
+
+			collections, err := instance.bookmarkService.GetAllCollections(visitor.ID)
+			assert.Nil(t, err)
+			assert.Len(t, collections, 1)
+			assert.Equal(t, 2, collections[0].BookmarkCount)
+
+			circles, err := instance.circleService.GetPaginatedBookmarkedCircle(visitor.ID, &circle_dto.GetPaginatedCirclesFilter{Page: 1, Limit: 20, CollectionID: &collection.ID})
+			assert.Nil(t, err)
+			assert.Equal(t, 2, circles.Metadata.TotalDocs)
+			assert.Equal(t, 8, circles.Data[0].ID)
+			assert.Equal(t, note, *circles.Data[0].Bookmark.Note)
+			assert.Equal(t, priority, circles.Data[0].Bookmark.Priority)
+		})
+
+		t.Run("should reorder bookmarks inside a collection", func(t *testing.T) {
+			err := instance.bookmarkService.ReorderBookmarks(visitor.ID, &bookmark_dto.ReorderBookmarksPayload{CollectionID: &collection.ID, CircleIDs: []int{9, 8}})
+			assert.Nil(t, err)
+
+			circles, err := instance.circleService.GetPaginatedBookmarkedCircle(visitor.ID, &circle_dto.GetPaginatedCirclesFilter{Page: 1, Limit: 20, CollectionID: &collection.ID})
+			assert.Nil(t, err)
+			assert.Equal(t, 9, circles.Data[0].ID)
+			assert.Equal(t, 8, circles.Data[1].ID)
+
+			err = instance.bookmarkService.ReorderBookmarks(visitor.ID, &bookmark_dto.ReorderBookmarksPayload{CollectionID: &collection.ID, CircleIDs: []int{9, 10}})
+			assert.NotNil(t, err)
+			assert.Equal(t, 400, err.Code)
+		})
+
+		t.Run("should not touch collection of another user", func(t *testing.T) {
+			_, err := instance.bookmarkService.UpdateOneCollection(visitor.ID+1, collection.ID, &bookmark_dto.CreateUpdateCollectionPayload{Name: "Mine"})
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+		})
+
+		t.Run("should keep bookmarks when collection is deleted", func(t *testing.T) {
+			err := instance.bookmarkService.DeleteOneCollection(visitor.ID, collection.ID)
+			assert.Nil(t, err)
+
+			uncategorized := 0
+			circles, err := instance.circleService.GetPaginatedBookmarkedCircle(visitor.ID, &circle_dto.GetPaginatedCirclesFilter{Page: 1, Limit: 20, CollectionID: &uncategorized})
+			assert.Nil(t, err)
+			assert.Equal(t, 3, circles.Metadata.TotalDocs)
+			assert.Equal(t, 10, circles.Data[0].ID)
+		})
+	})
+
+	t.Run("Test shared bookmark list", func(t *testing.T) {
+		owner := entity.User{Name: "List Owner", Email: "listowner@example.com", Hash: "hash"}
+		viewer := entity.User{Name: "List Viewer", Email: "listviewer@example.com", Hash: "hash"}
+		if err := db.Create(&[]*entity.User{&owner, &viewer}).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		collection, err := instance.bookmarkService.CreateOneCollection(owner.ID, &bookmark_dto.CreateUpdateCollectionPayload{Name: "Route"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, circleID := range []int{11, 12} {
+			if err := instance.bookmarkService.CreateOneBookmark(circleID, owner.ID); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := instance.bookmarkService.UpdateBookmark(circleID, owner.ID, &bookmark_dto.SaveBookmarkPayload{CollectionID: &collection.ID}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		note, priority := "ask for the secret freebie", 3
+		if _, err := instance.bookmarkService.UpdateBookmark(12, owner.ID, &bookmark_dto.SaveBookmarkPayload{Note: &note, Priority: &priority}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := instance.bookmarkService.CreateOneBookmark(11, viewer.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		shared, err := instance.bookmarkService.ShareCollection(owner.ID, collection.ID)
+		assert.Nil(t, err)
+		assert.NotNil(t, shared.ShareToken)
+		token := *shared.ShareToken
+
+		t.Run("should show the list with bookmarks of the viewer", func(t *testing.T) {
+			list, circles, err := instance.circleService.GetSharedBookmarkList(token, viewer.ID, &circle_dto.GetPaginatedCirclesFilter{Page: 1, Limit: 20})
+			assert.Nil(t, err)
+			assert.Equal(t, "Route", list.Name)
+			assert.Equal(t, "List Owner", list.OwnerName)
+			assert.Equal(t, 2, circles.Metadata.TotalDocs)
+			assert.Equal(t, 11, circles.Data[0].ID)
+			assert.True(t, circles.Data[0].Bookmarked)
+			assert.False(t, circles.Data[1].Bookmarked)
+		})
+
+		t.Run("should not expose the notes of the owner", func(t *testing.T) {
+			_, circles, err := instance.circleService.GetSharedBookmarkList(token, viewer.ID, &circle_dto.GetPaginatedCirclesFilter{Page: 1, Limit: 20})
+			assert.Nil(t, err)
+			for _, circle := range circles.Data {
+				assert.Nil(t, circle.Bookmark)
+			}
+
+			body, marshalErr := json.Marshal(circles.Data)
+			assert.Nil(t, marshalErr)
+			assert.NotContains(t, string(body), note)
+		})
+
+		t.Run("should clone only missing bookmarks", func(t *testing.T) {
+			_, err := instance.bookmarkService.CloneSharedCollection(owner.ID, token, &bookmark_dto.CloneCollectionPayload{})
+			assert.NotNil(t, err)
+			assert.Equal(t, 400, err.Code)
+
+			cloned, err := instance.bookmarkService.CloneSharedCollection(viewer.ID, token, &bookmark_dto.CloneCollectionPayload{})
+			assert.Nil(t, err)
+			assert.Equal(t, "Route", cloned.Name)
+			assert.Equal(t, 1, cloned.BookmarkCount)
+
+			var copied entity.UserBookmark
+			db.Where("user_id = ? AND circle_id = ?", viewer.ID, 12).First(&copied)
+			assert.Nil(t, copied.Note)
+			assert.Equal(t, 0, copied.Priority)
+		})
+
+		t.Run("should not open a revoked list", func(t *testing.T) {
+			err := instance.bookmarkService.RevokeCollectionShare(owner.ID, collection.ID)
+			assert.Nil(t, err)
+
+			_, _, err = instance.circleService.GetSharedBookmarkList(token, 0, &circle_dto.GetPaginatedCirclesFilter{Page: 1, Limit: 20})
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+		})
+	})
+
+	t.Run("Test bookmark route plan", func(t *testing.T) {
+		visitor := entity.User{Name: "Route Visitor", Email: "routevisitor@example.com", Hash: "hash"}
+		if err := db.Create(&visitor).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		db.Model(&entity.Circle{}).Where("id IN ?", []int{13, 14, 15, 16}).Updates(map[string]interface{}{"event_id": 1, "day": entity.First})
+		db.Model(&entity.Circle{}).Where("id = ?", 17).Updates(map[string]interface{}{"event_id": 1, "day": entity.Second})
+
+		blocks := []entity.BlockEvent{
+			{EventID: 1, CircleID: 13, Prefix: "B", Postfix: "2", Name: "B-2"},
+			{EventID: 1, CircleID: 14, Prefix: "A", Postfix: "10", Name: "A-10"},
+			{EventID: 1, CircleID: 15, Prefix: "A", Postfix: "2", Name: "A-2"},
+		}
+		if err := db.Create(&blocks).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		for _, circleID := range []int{13, 14, 15, 16, 17} {
+			if err := instance.bookmarkService.CreateOneBookmark(circleID, visitor.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		t.Run("should group by prefix in walking order", func(t *testing.T) {
+			plan, err := instance.circleService.GetBookmarkRoutePlan(visitor.ID, &circle_dto.GetRoutePlanFilter{Event: "event-1", Day: entity.First})
+			assert.Nil(t, err)
+			assert.Equal(t, 4, plan.Total)
+			assert.Len(t, plan.Groups, 2)
+
+			assert.Equal(t, "A", plan.Groups[0].Prefix)
+			assert.Equal(t, 15, plan.Groups[0].Stops[0].CircleID)
+			assert.Equal(t, 14, plan.Groups[0].Stops[1].CircleID)
+			assert.Equal(t, "B", plan.Groups[1].Prefix)
+			assert.Equal(t, 3, plan.Groups[1].Stops[0].Step)
+
+			assert.Len(t, plan.Unplaced, 1)
+			assert.Equal(t, 16, plan.Unplaced[0].CircleID)
+			assert.True(t, plan.Unplaced[0].NoBlock)
+
+			checklist := instance.circleService.RenderRoutePlanChecklist(plan)
+			assert.Contains(t, checklist, "[ ] A-2")
+			assert.Contains(t, checklist, "NO BLOCK YET")
+		})
+
+		t.Run("should return not found for unknown event", func(t *testing.T) {
+			_, err := instance.circleService.GetBookmarkRoutePlan(visitor.ID, &circle_dto.GetRoutePlanFilter{Event: "unknown", Day: entity.First})
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+		})
+	})
+
+	t.Run("Test bookmark status", func(t *testing.T) {
+		visitor := entity.User{Name: "Status Visitor", Email: "statusvisitor@example.com", Hash: "hash"}
+		if err := db.Create(&visitor).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if err := instance.bookmarkService.CreateOneBookmark(18, visitor.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		visitedAt := time.Now().Add(-time.Minute)
+		_, err := instance.bookmarkService.SaveBookmarkStatuses(visitor.ID, &bookmark_dto.SaveBookmarkStatusesPayload{
+			Statuses: []bookmark_dto.SaveBookmarkStatusPayload{{CircleID: 18, EventID: 1, Status: "visited", UpdatedAt: &visitedAt}},
+		})
+		assert.Nil(t, err)
+
+		t.Run("should keep the change made later on the device", func(t *testing.T) {
+			earlier := visitedAt.Add(-time.Hour)
+			statuses, err := instance.bookmarkService.SaveBookmarkStatuses(visitor.ID, &bookmark_dto.SaveBookmarkStatusesPayload{
+				Statuses: []bookmark_dto.SaveBookmarkStatusPayload{{CircleID: 18, EventID: 1, Status: "skipped", UpdatedAt: &earlier}},
+			})
+			assert.Nil(t, err)
+			assert.Len(t, statuses, 1)
+			assert.Equal(t, entity.BookmarkVisited, statuses[0].Status)
+		})
+
+		t.Run("should only sync changes after since", func(t *testing.T) {
+			statuses, _, cursor, err := instance.bookmarkService.SyncBookmarkStatuses(visitor.ID, nil, 0)
+			assert.Nil(t, err)
+			assert.Len(t, statuses, 1)
+
+			statuses, _, _, err = instance.bookmarkService.SyncBookmarkStatuses(visitor.ID, &cursor, 0)
+			assert.Nil(t, err)
+			assert.Len(t, statuses, 0)
+
+			_, err = instance.bookmarkService.SaveBookmarkStatuses(visitor.ID, &bookmark_dto.SaveBookmarkStatusesPayload{
+				Statuses: []bookmark_dto.SaveBookmarkStatusPayload{{CircleID: 18, EventID: 1, Status: "sold_out"}},
+			})
+			assert.Nil(t, err)
+
+			statuses, _, _, err = instance.bookmarkService.SyncBookmarkStatuses(visitor.ID, &cursor, 0)
+			assert.Nil(t, err)
+			assert.Len(t, statuses, 1)
+			assert.Equal(t, entity.BookmarkSoldOut, statuses[0].Status)
+		})
+
+		t.Run("should sync a change committed after a sync read", func(t *testing.T) {
+			if err := instance.bookmarkService.CreateOneBookmark(20, visitor.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			_, _, cursor, err := instance.bookmarkService.SyncBookmarkStatuses(visitor.ID, nil, 0)
+			assert.Nil(t, err)
+
+			// a save still running while the device syncs
+			tx := db.Begin()
+			tx.Exec("INSERT INTO user_bookmark_status (user_id, circle_id, event_id, status) VALUES (?, 20, 1, 'visited')", visitor.ID)
+
+			statuses, _, next, err := instance.bookmarkService.SyncBookmarkStatuses(visitor.ID, &cursor, 0)
+			assert.Nil(t, err)
+			assert.Len(t, statuses, 0)
+
+			assert.Nil(t, tx.Commit().Error)
+
+			statuses, _, _, err = instance.bookmarkService.SyncBookmarkStatuses(visitor.ID, &next, 0)
+			assert.Nil(t, err)
+			assert.Len(t, statuses, 1)
+			assert.Equal(t, 20, statuses[0].CircleID)
+		})
+
+		t.Run("should sync a removed bookmark to the other devices", func(t *testing.T) {
+			_, _, cursor, err := instance.bookmarkService.SyncBookmarkStatuses(visitor.ID, nil, 0)
+			assert.Nil(t, err)
+
+			assert.Nil(t, instance.bookmarkService.DeleteBookmarkByUserCircleID(20, visitor.ID))
+
+			statuses, deleted, _, err := instance.bookmarkService.SyncBookmarkStatuses(visitor.ID, &cursor, 0)
+			assert.Nil(t, err)
+			assert.Len(t, statuses, 0)
+			assert.Len(t, deleted, 1)
+			assert.Equal(t, 20, deleted[0].CircleID)
+
+			_, deleted, _, err = instance.bookmarkService.SyncBookmarkStatuses(visitor.ID, nil, 0)
+			assert.Nil(t, err)
+			assert.Len(t, deleted, 0)
+		})
+
+		t.Run("should reject circle that is not bookmarked", func(t *testing.T) {
+			_, err := instance.bookmarkService.SaveBookmarkStatuses(visitor.ID, &bookmark_dto.SaveBookmarkStatusesPayload{
+				Statuses: []bookmark_dto.SaveBookmarkStatusPayload{{CircleID: 19, EventID: 1, Status: "visited"}},
+			})
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+		})
+	})
+
+	t.Run("Test bookmark export", func(t *testing.T) {
+		visitor := entity.User{Name: "Export Visitor", Email: "exportvisitor@example.com", Hash: "hash"}
+		if err := db.Create(&visitor).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		db.Model(&entity.Circle{}).Where("id = ?", 20).Updates(map[string]interface{}{"event_id": 2, "day": entity.Both})
+		if err := db.Create(&entity.Product{Name: "Artbook, vol 2", ImageURL: "https://cdn.innercatalog.com/artbook.png", CircleID: 20}).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		for _, circleID := range []int{20, 21} {
+			if err := instance.bookmarkService.CreateOneBookmark(circleID, visitor.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var circle entity.Circle
+		db.First(&circle, 20)
+
+		t.Run("should export csv with product names", func(t *testing.T) {
+			content, err := instance.circleService.ExportBookmarkedCircles(visitor.ID, &circle_dto.GetPaginatedCirclesFilter{}, export.CSV)
+			assert.Nil(t, err)
+
+			lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+			assert.Len(t, lines, 3)
+			assert.True(t, strings.HasPrefix(lines[0], "name,slug,block,day,event"))
+			assert.Contains(t, string(content), `"Artbook, vol 2"`)
+		})
+
+		t.Run("should escape formula cells in csv", func(t *testing.T) {
+			var named entity.Circle
+			db.First(&named, 21)
+			db.Model(&entity.Circle{}).Where("id = ?", 21).Update("name", `=HYPERLINK("https://evil.example","open")`)
+			defer db.Model(&entity.Circle{}).Where("id = ?", 21).Update("name", named.Name)
+
+			content, err := instance.circleService.ExportBookmarkedCircles(visitor.ID, &circle_dto.GetPaginatedCirclesFilter{}, export.CSV)
+			assert.Nil(t, err)
+
+			records, csvErr := csv.NewReader(bytes.NewReader(content)).ReadAll()
+			assert.Nil(t, csvErr)
+
+			names := make([]string, 0, len(records))
+			for _, record := range records[1:] {
+				names = append(names, record[0])
+			}
+			assert.Contains(t, names, `'=HYPERLINK("https://evil.example","open")`)
+		})
+
+		t.Run("should apply the search filter", func(t *testing.T) {
+			content, err := instance.circleService.ExportBookmarkedCircles(visitor.ID, &circle_dto.GetPaginatedCirclesFilter{Search: circle.Name}, export.JSON)
+			assert.Nil(t, err)
+
+			var rows []circle_dto.BookmarkExportRow
+			assert.Nil(t, json.Unmarshal(content, &rows))
+			assert.Len(t, rows, 1)
+			assert.Equal(t, []string{"Artbook, vol 2"}, rows[0].Products)
+		})
+
+		t.Run("should map both days to two calendar entries", func(t *testing.T) {
+			content, err := instance.circleService.ExportBookmarkedCircles(visitor.ID, &circle_dto.GetPaginatedCirclesFilter{Event: "event-2"}, export.ICS)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, strings.Count(string(content), "BEGIN:VEVENT"))
+			assert.Contains(t, string(content), "UID:bookmark-event-2-second@innercatalog.com")
+		})
+	})
+
+	t.Run("Test product price range filter", func(t *testing.T) {
+		productService := product.NewProductService(product.NewProductRepo(db), plan.NewPlanService(plan.NewPlanRepo(db)))
+
+		price := func(amount int64) *int64 { return &amount }
+		noStock := 0
+
+		_, err := productService.CreateOneProductByCircleID(0, 22, entity.Product{Name: "Keychain", ImageURL: "https://cdn.innercatalog.com/keychain.png", Price: price(50000)})
+		assert.Nil(t, err)
+		_, err = productService.CreateOneProductByCircleID(0, 23, entity.Product{Name: "Figure", ImageURL: "https://cdn.innercatalog.com/figure.png", Price: price(500000)})
+		assert.Nil(t, err)
+		soldOut, err := productService.CreateOneProductByCircleID(0, 24, entity.Product{Name: "Sticker", ImageURL: "https://cdn.innercatalog.com/sticker.png", Price: price(30000), Stock: &noStock})
+		assert.Nil(t, err)
+
+		t.Run("should mark product without stock as sold out", func(t *testing.T) {
+			assert.Equal(t, entity.ProductSoldOut, soldOut.Status)
+			assert.Equal(t, "IDR", soldOut.Currency)
+		})
+
+		t.Run("should only return circle selling in the range", func(t *testing.T) {
+			circles, err := instance.circleService.GetPaginatedCircles(&circle_dto.GetPaginatedCirclesFilter{Page: 1, Limit: 20, PriceMin: price(10000), PriceMax: price(100000)}, 0)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, circles.Metadata.TotalDocs)
+			assert.Equal(t, 22, circles.Data[0].ID)
+		})
+
+		t.Run("should reject inverted range", func(t *testing.T) {
+			_, err := instance.circleService.GetPaginatedCircles(&circle_dto.GetPaginatedCirclesFilter{Page: 1, Limit: 20, PriceMin: price(100000), PriceMax: price(10000)}, 0)
+			assert.NotNil(t, err)
+			assert.Equal(t, 400, err.Code)
+		})
+	})
+
+	t.Run("Test product tree upsert", func(t *testing.T) {
+		productService := product.NewProductService(product.NewProductRepo(db), plan.NewPlanService(plan.NewPlanRepo(db)))
+		price := int64(75000)
+		stock := 10
+
+		products, err := productService.BatchUpsertProductsByCircleID(0, 25, []entity.Product{
+			{
+				Name:   "Acrylic stand",
+				Images: []entity.ProductImage{{URL: "https://cdn.innercatalog.com/stand-front.png"}, {URL: "https://cdn.innercatalog.com/stand-back.png"}},
+				Variants: []entity.ProductVariant{
+					{Name: "Design A", Price: &price, Stock: &stock},
+					{Name: "Design B"},
+				},
+			},
+			{Name: "Postcard", ImageURL: "https://cdn.innercatalog.com/postcard.png"},
+		})
+		assert.Nil(t, err)
+		assert.Len(t, products, 2)
+
+		stand := products[0]
+		assert.Equal(t, "https://cdn.innercatalog.com/stand-front.png", stand.ImageURL)
+		assert.Len(t, stand.Images, 2)
+		assert.Len(t, stand.Variants, 2)
+		assert.Len(t, products[1].Images, 1)
+
+		t.Run("should keep the gallery when only image_url is sent", func(t *testing.T) {
+			updated, err := productService.UpdateOneProductByCircleAndProductID(0, 25, entity.Product{
+				ID:       stand.ID,
+				Name:     "Acrylic stand",
+				ImageURL: "https://cdn.innercatalog.com/stand-side.png",
+			})
+			assert.Nil(t, err)
+			assert.Len(t, updated.Images, 2)
+			assert.Equal(t, "https://cdn.innercatalog.com/stand-front.png", updated.ImageURL)
+			assert.Len(t, updated.Variants, 2)
+		})
+
+		t.Run("should update kept products and delete the others", func(t *testing.T) {
+			newStock := 3
+			products, err := productService.BatchUpsertProductsByCircleID(0, 25, []entity.Product{
+				{
+					ID:     stand.ID,
+					Name:   "Acrylic stand",
+					Images: []entity.ProductImage{{URL: "https://cdn.innercatalog.com/stand-back.png"}},
+					Variants: []entity.ProductVariant{
+						{ID: stand.Variants[0].ID, Name: "Design A", Price: &price, Stock: &newStock},
+						{Name: "Design C"},
+					},
+				},
+			})
+			assert.Nil(t, err)
+			assert.Len(t, products, 1)
+			assert.Equal(t, "https://cdn.innercatalog.com/stand-back.png", products[0].ImageURL)
+			assert.Equal(t, newStock, *products[0].Variants[0].Stock)
+			assert.Equal(t, "Design C", products[0].Variants[1].Name)
+
+			var deleted int64
+			db.Unscoped().Model(&entity.ProductVariant{}).Where("id = ? AND deleted_at IS NOT NULL", stand.Variants[1].ID).Count(&deleted)
+			assert.Equal(t, int64(1), deleted)
+		})
+
+		t.Run("should not touch product of another circle", func(t *testing.T) {
+			_, err := productService.BatchUpsertProductsByCircleID(0, 26, []entity.Product{
+				{ID: stand.ID, Name: "Stolen", ImageURL: "https://cdn.innercatalog.com/stolen.png"},
+			})
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+		})
+	})
+
+	t.Run("Test product search", func(t *testing.T) {
+		productService := product.NewProductService(product.NewProductRepo(db), plan.NewPlanService(plan.NewPlanRepo(db)))
+		cheap := int64(15000)
+		pricey := int64(250000)
+
+		_, err := productService.CreateOneProductByCircleID(0, 27, entity.Product{Name: "Zephyrine keychain", ImageURL: "https://cdn.innercatalog.com/zephyrine-keychain.png", Price: &cheap})
+		assert.Nil(t, err)
+		_, err = productService.CreateOneProductByCircleID(0, 28, entity.Product{Name: "Zephyrine artbook", ImageURL: "https://cdn.innercatalog.com/zephyrine-artbook.png", Price: &pricey})
+		assert.Nil(t, err)
+
+		t.Run("should find products across circles with their circle", func(t *testing.T) {
+			products, err := productService.GetPaginatedProducts(&product_dto.GetPaginatedProductsFilter{Page: 1, Limit: 20, Search: "zephyrine"})
+			assert.Nil(t, err)
+			assert.Equal(t, 2, products.Metadata.TotalDocs)
+			assert.Equal(t, 28, products.Data[0].Circle.ID)
+			assert.NotEmpty(t, products.Data[0].Circle.Name)
+			assert.Len(t, products.Data[0].Images, 1)
+		})
+
+		t.Run("should filter products by price", func(t *testing.T) {
+			products, err := productService.GetPaginatedProducts(&product_dto.GetPaginatedProductsFilter{Page: 1, Limit: 20, Search: "zephyrine", PriceMax: &cheap})
+			assert.Nil(t, err)
+			assert.Equal(t, 1, products.Metadata.TotalDocs)
+			assert.Equal(t, "Zephyrine keychain", products.Data[0].Name)
+		})
+
+		t.Run("should reject inverted range", func(t *testing.T) {
+			_, err := productService.GetPaginatedProducts(&product_dto.GetPaginatedProductsFilter{Page: 1, Limit: 20, PriceMin: &pricey, PriceMax: &cheap})
+			assert.NotNil(t, err)
+			assert.Equal(t, 400, err.Code)
+		})
+	})
+
+	t.Run("Test product reservation", func(t *testing.T) {
+		productService := product.NewProductService(product.NewProductRepo(db), plan.NewPlanService(plan.NewPlanRepo(db)))
+		reservationService := reservation.NewProductReservationService(reservation.NewProductReservationRepo(db))
+
+		buyer := entity.User{Name: "Buyer", Email: "buyer@example.com", Hash: "hash"}
+		if err := db.Create(&buyer).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		stock := 3
+		zine, err := productService.CreateOneProductByCircleID(0, 29, entity.Product{Name: "Preorder zine", ImageURL: "https://cdn.innercatalog.com/preorder-zine.png", Stock: &stock, Status: entity.ProductPreorder})
+		assert.Nil(t, err)
+
+		first, err := reservationService.CreateReservation(buyer.ID, 29, zine.ID, &reservation_dto.CreateReservationPayload{Quantity: 2})
+		assert.Nil(t, err)
+		assert.Equal(t, entity.ReservationPending, first.Status)
+		assert.Equal(t, "Preorder zine", first.Product.Name)
+
+		t.Run("should not reserve product of another circle", func(t *testing.T) {
+			_, err := reservationService.CreateReservation(buyer.ID, 30, zine.ID, &reservation_dto.CreateReservationPayload{Quantity: 1})
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+		})
+
+		t.Run("should take stock when accepted", func(t *testing.T) {
+			accepted, err := reservationService.UpdateReservationStatus(29, first.ID, &reservation_dto.UpdateReservationStatusPayload{Status: "accepted"})
+			assert.Nil(t, err)
+			assert.Equal(t, entity.ReservationAccepted, accepted.Status)
+			assert.NotNil(t, accepted.RespondedAt)
+
+			var after entity.Product
+			db.First(&after, zine.ID)
+			assert.Equal(t, 1, *after.Stock)
+		})
+
+		t.Run("should reject quantity above stock", func(t *testing.T) {
+			_, err := reservationService.CreateReservation(buyer.ID, 29, zine.ID, &reservation_dto.CreateReservationPayload{Quantity: 2})
+			assert.NotNil(t, err)
+			assert.Equal(t, 400, err.Code)
+		})
+
+		t.Run("should not accept twice the last item", func(t *testing.T) {
+			second, err := reservationService.CreateReservation(buyer.ID, 29, zine.ID, &reservation_dto.CreateReservationPayload{Quantity: 1})
+			assert.Nil(t, err)
+			third, err := reservationService.CreateReservation(buyer.ID, 29, zine.ID, &reservation_dto.CreateReservationPayload{Quantity: 1})
+			assert.Nil(t, err)
+
+			_, err = reservationService.UpdateReservationStatus(29, second.ID, &reservation_dto.UpdateReservationStatusPayload{Status: "accepted"})
+			assert.Nil(t, err)
+
+			_, err = reservationService.UpdateReservationStatus(29, third.ID, &reservation_dto.UpdateReservationStatusPayload{Status: "accepted"})
+			assert.NotNil(t, err)
+			assert.Equal(t, 409, err.Code)
+
+			var unchanged entity.ProductReservation
+			db.First(&unchanged, third.ID)
+			assert.Equal(t, entity.ReservationPending, unchanged.Status)
+
+			cancelled, err := reservationService.CancelReservation(buyer.ID, third.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, entity.ReservationCancelled, cancelled.Status)
+		})
+
+		t.Run("should only fulfil accepted reservation", func(t *testing.T) {
+			fulfilled, err := reservationService.UpdateReservationStatus(29, first.ID, &reservation_dto.UpdateReservationStatusPayload{Status: "fulfilled"})
+			assert.Nil(t, err)
+			assert.Equal(t, entity.ReservationFulfilled, fulfilled.Status)
+
+			_, err = reservationService.UpdateReservationStatus(29, first.ID, &reservation_dto.UpdateReservationStatusPayload{Status: "declined"})
+			assert.NotNil(t, err)
+			assert.Equal(t, 409, err.Code)
+		})
+
+		t.Run("should list the history of both sides", func(t *testing.T) {
+			circleHistory, err := reservationService.GetPaginatedCircleReservations(29, &reservation_dto.GetPaginatedReservationsFilter{Page: 1, Limit: 20})
+			assert.Nil(t, err)
+			assert.Equal(t, 3, circleHistory.Metadata.TotalDocs)
+			assert.Equal(t, "Buyer", circleHistory.Data[0].User.Name)
+
+			buyerHistory, err := reservationService.GetPaginatedUserReservations(buyer.ID, &reservation_dto.GetPaginatedReservationsFilter{Page: 1, Limit: 20, Status: "cancelled"})
+			assert.Nil(t, err)
+			assert.Equal(t, 1, buyerHistory.Metadata.TotalDocs)
+			assert.NotEmpty(t, buyerHistory.Data[0].Circle.Name)
+		})
+	})
+
+	t.Run("Test circle plan limits", func(t *testing.T) {
+		planService := plan.NewPlanService(plan.NewPlanRepo(db))
+		productService := product.NewProductService(product.NewProductRepo(db), planService)
+
+		for i := 0; i < 5; i++ {
+			_, err := productService.CreateOneProductByCircleID(0, 31, entity.Product{Name: fmt.Sprintf("Plan product %d", i), ImageURL: "https://cdn.innercatalog.com/plan-product.png"})
+			assert.Nil(t, err)
+		}
+
+		t.Run("should stop at the default plan limit", func(t *testing.T) {
+			_, err := productService.CreateOneProductByCircleID(0, 31, entity.Product{Name: "One too many", ImageURL: "https://cdn.innercatalog.com/plan-product.png"})
+			assert.NotNil(t, err)
+			assert.Equal(t, 400, err.Code)
+			assert.Equal(t, "MAX_PRODUCT_EXCEEDED", err.Err.Error())
+
+			usage, err := planService.GetUsageByCircleID(31)
+			assert.Nil(t, err)
+			assert.Equal(t, "free", usage.Plan.Slug)
+			assert.Equal(t, plan_dto.ResourceUsage{Resource: "product", Used: 5, Limit: 5}, usage.Usage[0])
+		})
+
+		t.Run("should follow the plan assigned by admin", func(t *testing.T) {
+			pro, err := planService.CreatePlan(&plan_dto.CreateUpdatePlanPayload{Slug: "pro", Name: "Pro", MaxProducts: 10, MaxFandoms: 10, MaxWorkTypes: 10})
+			assert.Nil(t, err)
+
+			usage, err := planService.AssignPlanToCircle(31, &pro.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, "pro", usage.Plan.Slug)
+			assert.Equal(t, 10, usage.Usage[0].Limit)
+
+			_, err = productService.CreateOneProductByCircleID(0, 31, entity.Product{Name: "Pro product", ImageURL: "https://cdn.innercatalog.com/plan-product.png"})
+			assert.Nil(t, err)
+
+			assert.NotNil(t, planService.Enforce(31, plan.ResourceFandom, 11))
+			assert.Nil(t, planService.Enforce(31, plan.ResourceFandom, 10))
+		})
+
+		t.Run("should go back to the plan of the circle rule", func(t *testing.T) {
+			usage, err := planService.AssignPlanToCircle(31, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, "free", usage.Plan.Slug)
+			assert.Equal(t, 6, usage.Usage[0].Used)
+
+			missing := 9999
+			_, err = planService.AssignPlanToCircle(31, &missing)
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+		})
+	})
+	t.Run("Test hidden circles", func(t *testing.T) {
+		circleID := 39
+		owner := entity.User{Name: "Hidden owner", Email: "hidden-owner@example.com", Hash: "hash", CircleID: &circleID}
+		visitor := entity.User{Name: "Hidden visitor", Email: "hidden-visitor@example.com", Hash: "hash"}
+		for _, user := range []*entity.User{&owner, &visitor} {
+			if err := db.Create(user).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var circle entity.Circle
+		db.First(&circle, circleID)
+		assert.Nil(t, instance.bookmarkService.CreateOneBookmark(circleID, visitor.ID))
+		db.Model(&circle).Update("hidden", true)
+
+		t.Run("should not find a hidden circle by slug", func(t *testing.T) {
+			for _, userID := range []int{0, visitor.ID} {
+				_, err := instance.circleService.GetOneCircleByCircleSlug(circle.Slug, userID)
+				assert.NotNil(t, err)
+				assert.Equal(t, 404, err.Code)
+			}
+		})
+
+		t.Run("should find a hidden circle for its owner and the admin", func(t *testing.T) {
+			for _, userID := range []int{owner.ID, 1} {
+				detailed, err := instance.circleService.GetOneCircleByCircleSlug(circle.Slug, userID)
+				assert.Nil(t, err)
+				assert.Equal(t, circleID, detailed.ID)
+			}
+		})
+
+		t.Run("should leave a hidden circle out of bookmarks and exports", func(t *testing.T) {
+			circles, err := instance.circleService.GetPaginatedBookmarkedCircle(visitor.ID, &circle_dto.GetPaginatedCirclesFilter{Page: 1, Limit: 20})
+			assert.Nil(t, err)
+			assert.Empty(t, circles.Data)
+			assert.Equal(t, 0, circles.Metadata.TotalDocs)
+
+			content, err := instance.circleService.ExportBookmarkedCircles(visitor.ID, &circle_dto.GetPaginatedCirclesFilter{}, export.JSON)
+			assert.Nil(t, err)
+			assert.NotContains(t, string(content), circle.Slug)
+		})
+	})
 }
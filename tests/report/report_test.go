@@ -3,7 +3,13 @@ package report_test
 import (
 	"catalog-be/internal/entity"
 	"catalog-be/internal/modules/circle"
+	circle_dto "catalog-be/internal/modules/circle/dto"
+	product_module "catalog-be/internal/modules/product"
+	"catalog-be/internal/modules/product/reservation"
+	reservation_dto "catalog-be/internal/modules/product/reservation/dto"
 	"catalog-be/internal/modules/report"
+	report_dto "catalog-be/internal/modules/report/dto"
+	"catalog-be/internal/utils"
 	test_helper "catalog-be/tests/test_helper"
 	"context"
 	"encoding/json"
@@ -75,30 +81,404 @@ func TestReport(t *testing.T) {
 
 	circleRepo := circle.NewCircleRepo(db)
 	repo := report.NewReportRepo(db)
-	service := report.NewReportService(repo, circleRepo)
+	service := report.NewReportService(repo, circleRepo, utils.NewUtils())
 
 	t.Run("Create new report", func(t *testing.T) {
 		reportEntity := entity.Report{
-			UserID:   1,
-			CircleID: 1,
-			Reason:   "this is a test report",
+			UserID:     1,
+			TargetType: entity.ReportTargetCircle,
+			TargetID:   1,
+			Reason:     "this is a test report",
 		}
 
-		err := service.CreateReportCircle(&reportEntity)
+		err := service.CreateReport(&reportEntity)
 		assert.Nil(t, err)
 	})
 
 	t.Run("Circle not found", func(t *testing.T) {
 		reportEntity := entity.Report{
-			UserID:   1,
-			CircleID: 1000,
-			Reason:   "this is a test report",
+			UserID:     1,
+			TargetType: entity.ReportTargetCircle,
+			TargetID:   1000,
+			Reason:     "this is a test report",
 		}
 
-		err := service.CreateReportCircle(&reportEntity)
+		err := service.CreateReport(&reportEntity)
 		assert.NotNil(t, err)
 		assert.Equal(t, 404, err.Code)
 		assert.Equal(t, errors.New("CIRCLE_NOT_FOUND"), err.Err)
 	})
 
+	t.Run("Moderation queue", func(t *testing.T) {
+		reports := map[int][]entity.Report{}
+		for _, circleID := range []int{2, 2, 2, 3} {
+			report := entity.Report{UserID: len(reports[circleID]) + 1, TargetType: entity.ReportTargetCircle, TargetID: circleID, Reason: "stolen artwork"}
+			err := service.CreateReport(&report)
+			assert.Nil(t, err)
+			reports[circleID] = append(reports[circleID], report)
+		}
+
+		t.Run("should group reports by circle", func(t *testing.T) {
+			queue, err := service.GetPaginatedReportedTargets(&report_dto.GetPaginatedReportedTargetsFilter{Page: 1, Limit: 20})
+			assert.Nil(t, err)
+			assert.Equal(t, 3, queue.Metadata.TotalDocs)
+			assert.Equal(t, 2, queue.Data[0].TargetID)
+			assert.Equal(t, 3, queue.Data[0].ReportCount)
+			assert.Equal(t, 3, queue.Data[0].PendingCount)
+		})
+
+		t.Run("should assign a report", func(t *testing.T) {
+			assigned, err := service.AssignReport(1, reports[2][0].ID, &report_dto.AssignReportPayload{})
+			assert.Nil(t, err)
+			assert.Equal(t, entity.ReportAssigned, assigned.Status)
+			assert.Equal(t, 1, *assigned.AssignedTo)
+
+			queue, err := service.GetPaginatedReportedTargets(&report_dto.GetPaginatedReportedTargetsFilter{AssignedTo: 1, Page: 1, Limit: 20})
+			assert.Nil(t, err)
+			assert.Len(t, queue.Data, 1)
+			assert.Equal(t, 1, queue.Data[0].ReportCount)
+
+			missing := 9999
+			_, err = service.AssignReport(1, reports[2][0].ID, &report_dto.AssignReportPayload{AssigneeID: &missing})
+			assert.NotNil(t, err)
+			assert.Equal(t, "ASSIGNEE_NOT_FOUND", err.Err.Error())
+		})
+
+		t.Run("should dismiss a report once", func(t *testing.T) {
+			note := "not stolen"
+			dismissed, err := service.ReviewReport(1, reports[2][1].ID, &report_dto.ReviewReportPayload{Status: "dismissed", Note: &note})
+			assert.Nil(t, err)
+			assert.Equal(t, entity.ReportDismissed, dismissed.Status)
+			assert.Equal(t, note, *dismissed.Note)
+
+			_, err = service.ReviewReport(1, reports[2][1].ID, &report_dto.ReviewReportPayload{Status: "resolved"})
+			assert.NotNil(t, err)
+			assert.Equal(t, 409, err.Code)
+
+			_, err = service.ReviewReport(1, 9999, &report_dto.ReviewReportPayload{Status: "resolved"})
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+		})
+
+		t.Run("should hide the circle and resolve its pending reports", func(t *testing.T) {
+			resolved, err := service.TakeAction(1, reports[2][2].ID, &report_dto.ReportActionPayload{Action: "hide"})
+			assert.Nil(t, err)
+			assert.Equal(t, entity.ReportResolved, resolved.Status)
+			assert.Equal(t, entity.ReportActionHide, *resolved.Action)
+
+			var circle entity.Circle
+			db.First(&circle, 2)
+			assert.True(t, circle.Hidden)
+
+			stale := circle
+			stale.Hidden = false
+			_, err = circleRepo.UpdateOneCircleAndAllRelation(1, &stale, &circle_dto.UpdateCirclePayload{})
+			assert.Nil(t, err)
+			db.First(&circle, 2)
+			assert.True(t, circle.Hidden)
+
+			_, err = service.TakeAction(1, reports[2][1].ID, &report_dto.ReportActionPayload{Action: "unhide"})
+			assert.NotNil(t, err)
+			assert.Equal(t, 409, err.Code)
+			assert.Equal(t, "REPORT_ALREADY_CLOSED", err.Err.Error())
+
+			all, err := service.FindAllReportByCircleID(2)
+			assert.Nil(t, err)
+			statuses := []entity.ReportStatus{}
+			for _, report := range all {
+				statuses = append(statuses, report.Status)
+			}
+			assert.ElementsMatch(t, []entity.ReportStatus{entity.ReportResolved, entity.ReportDismissed, entity.ReportResolved}, statuses)
+
+			queue, err := service.GetPaginatedReportedTargets(&report_dto.GetPaginatedReportedTargetsFilter{Status: "open", Page: 1, Limit: 20})
+			assert.Nil(t, err)
+			for _, reported := range queue.Data {
+				assert.NotEqual(t, 2, reported.TargetID)
+			}
+		})
+
+		t.Run("should warn the circle", func(t *testing.T) {
+			_, err := service.TakeAction(1, reports[3][0].ID, &report_dto.ReportActionPayload{Action: "warn", Message: "Credit the original artist"})
+			assert.Nil(t, err)
+
+			warnings, err := service.GetCircleWarnings(3)
+			assert.Nil(t, err)
+			assert.Len(t, warnings, 1)
+			assert.Equal(t, "Credit the original artist", warnings[0].Message)
+			assert.Equal(t, reports[3][0].ID, *warnings[0].ReportID)
+		})
+
+		t.Run("should unpublish the circle", func(t *testing.T) {
+			first, err := service.FindAllReportByCircleID(1)
+			assert.Nil(t, err)
+
+			_, err = service.TakeAction(1, first[0].ID, &report_dto.ReportActionPayload{Action: "unpublish"})
+			assert.Nil(t, err)
+
+			var circle entity.Circle
+			db.First(&circle, 1)
+			assert.False(t, circle.Published)
+			assert.True(t, circle.UnpublishedByModerator)
+
+			err = circleRepo.UpdatePublishedByCircleID(1, true)
+			assert.NotNil(t, err)
+			assert.Equal(t, 403, err.Code)
+			db.First(&circle, 1)
+			assert.False(t, circle.Published)
+		})
+
+		t.Run("should republish the circle", func(t *testing.T) {
+			report := entity.Report{UserID: 2, TargetType: entity.ReportTargetCircle, TargetID: 1, Reason: "unpublished by mistake"}
+			err := service.CreateReport(&report)
+			assert.Nil(t, err)
+
+			_, err = service.TakeAction(1, report.ID, &report_dto.ReportActionPayload{Action: "republish"})
+			assert.Nil(t, err)
+
+			var circle entity.Circle
+			db.First(&circle, 1)
+			assert.True(t, circle.Published)
+			assert.False(t, circle.UnpublishedByModerator)
+
+			err = circleRepo.UpdatePublishedByCircleID(1, false)
+			assert.Nil(t, err)
+			err = circleRepo.UpdatePublishedByCircleID(1, true)
+			assert.Nil(t, err)
+		})
+	})
+
+	t.Run("Report categories", func(t *testing.T) {
+		category := func(slug string) *string { return &slug }
+		hidden := func() bool {
+			var circle entity.Circle
+			db.First(&circle, 4)
+			return circle.Hidden
+		}
+
+		t.Run("should list categories heaviest first", func(t *testing.T) {
+			categories, err := service.GetAllCategories()
+			assert.Nil(t, err)
+			assert.Len(t, categories, 4)
+			assert.Equal(t, "stolen_art", categories[0].Slug)
+			assert.Equal(t, 5, categories[0].Weight)
+		})
+
+		t.Run("should keep one open report per user", func(t *testing.T) {
+			err := service.CreateReport(&entity.Report{UserID: 1, TargetType: entity.ReportTargetCircle, TargetID: 4, Category: category("stolen_art")})
+			assert.Nil(t, err)
+
+			err = service.CreateReport(&entity.Report{UserID: 1, TargetType: entity.ReportTargetCircle, TargetID: 4, Category: category("spam")})
+			assert.NotNil(t, err)
+			assert.Equal(t, 409, err.Code)
+			assert.Equal(t, "REPORT_ALREADY_OPEN", err.Err.Error())
+		})
+
+		t.Run("should reject unknown categories", func(t *testing.T) {
+			err := service.CreateReport(&entity.Report{UserID: 3, TargetType: entity.ReportTargetCircle, TargetID: 4, Category: category("boring")})
+			assert.NotNil(t, err)
+			assert.Equal(t, "REPORT_CATEGORY_NOT_FOUND", err.Err.Error())
+		})
+
+		t.Run("should hide the circle over the threshold", func(t *testing.T) {
+			err := service.CreateReport(&entity.Report{UserID: 2, TargetType: entity.ReportTargetCircle, TargetID: 4, Category: category("offensive")})
+			assert.Nil(t, err)
+			assert.False(t, hidden())
+
+			err = service.CreateReport(&entity.Report{UserID: 3, TargetType: entity.ReportTargetCircle, TargetID: 4, Category: category("spam")})
+			assert.Nil(t, err)
+			assert.True(t, hidden())
+
+			queue, err := service.GetPaginatedReportedTargets(&report_dto.GetPaginatedReportedTargetsFilter{Page: 1, Limit: 20})
+			assert.Nil(t, err)
+			assert.Equal(t, 4, queue.Data[0].TargetID)
+			assert.Equal(t, 12, queue.Data[0].PendingWeight)
+			assert.True(t, queue.Data[0].Hidden)
+		})
+
+		t.Run("should allow reporting again once reviewed", func(t *testing.T) {
+			reports, err := service.FindAllReportByCircleID(4)
+			assert.Nil(t, err)
+
+			for _, report := range reports {
+				if report.UserID == 1 {
+					_, err = service.ReviewReport(1, report.ID, &report_dto.ReviewReportPayload{Status: "dismissed"})
+					assert.Nil(t, err)
+				}
+			}
+
+			assert.False(t, hidden())
+
+			err = service.CreateReport(&entity.Report{UserID: 1, TargetType: entity.ReportTargetCircle, TargetID: 4, Category: category("wrong_rating")})
+			assert.Nil(t, err)
+			assert.False(t, hidden())
+		})
+
+		t.Run("should keep the circle hidden by a moderator", func(t *testing.T) {
+			report := entity.Report{UserID: 3, TargetType: entity.ReportTargetCircle, TargetID: 2, Category: category("spam")}
+			err := service.CreateReport(&report)
+			assert.Nil(t, err)
+
+			_, err = service.ReviewReport(1, report.ID, &report_dto.ReviewReportPayload{Status: "dismissed"})
+			assert.Nil(t, err)
+
+			var circle entity.Circle
+			db.First(&circle, 2)
+			assert.True(t, circle.Hidden)
+			assert.False(t, circle.HiddenAutomatically)
+		})
+
+		t.Run("should update a category", func(t *testing.T) {
+			updated, err := service.UpdateCategory("spam", &report_dto.UpdateReportCategoryPayload{Name: "Spam", Weight: 1})
+			assert.Nil(t, err)
+			assert.Equal(t, 1, updated.Weight)
+
+			_, err = service.UpdateCategory("boring", &report_dto.UpdateReportCategoryPayload{Name: "Boring", Weight: 1})
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+		})
+	})
+
+	t.Run("Product and user reports", func(t *testing.T) {
+		category := func(slug string) *string { return &slug }
+		product := entity.Product{Name: "Acrylic stand", ImageURL: "https://example.com/stand.png", CircleID: 5}
+		if err := db.Create(&product).Error; err != nil {
+			t.Fatal(err)
+		}
+		productHidden := func() bool {
+			var found entity.Product
+			db.First(&found, product.ID)
+			return found.Hidden
+		}
+
+		t.Run("should report a product with its circle", func(t *testing.T) {
+			report := entity.Report{UserID: 1, TargetType: entity.ReportTargetProduct, TargetID: product.ID, Category: category("stolen_art")}
+			err := service.CreateReport(&report)
+			assert.Nil(t, err)
+			assert.Equal(t, 5, *report.CircleID)
+		})
+
+		t.Run("should not report missing targets", func(t *testing.T) {
+			err := service.CreateReport(&entity.Report{UserID: 1, TargetType: entity.ReportTargetProduct, TargetID: 9999, Category: category("spam")})
+			assert.NotNil(t, err)
+			assert.Equal(t, "PRODUCT_NOT_FOUND", err.Err.Error())
+
+			err = service.CreateReport(&entity.Report{UserID: 1, TargetType: entity.ReportTargetUser, TargetID: 9999, Category: category("spam")})
+			assert.NotNil(t, err)
+			assert.Equal(t, "USER_NOT_FOUND", err.Err.Error())
+		})
+
+		t.Run("should hide the product over the threshold", func(t *testing.T) {
+			err := service.CreateReport(&entity.Report{UserID: 2, TargetType: entity.ReportTargetProduct, TargetID: product.ID, Category: category("offensive")})
+			assert.Nil(t, err)
+			assert.False(t, productHidden())
+
+			err = service.CreateReport(&entity.Report{UserID: 3, TargetType: entity.ReportTargetProduct, TargetID: product.ID, Category: category("stolen_art")})
+			assert.Nil(t, err)
+			assert.True(t, productHidden())
+
+			var circle entity.Circle
+			db.First(&circle, 5)
+			assert.False(t, circle.Hidden)
+
+			queue, err := service.GetPaginatedReportedTargets(&report_dto.GetPaginatedReportedTargetsFilter{TargetType: "product", Page: 1, Limit: 20})
+			assert.Nil(t, err)
+			assert.Equal(t, 1, queue.Metadata.TotalDocs)
+			assert.Equal(t, product.ID, queue.Data[0].TargetID)
+			assert.Equal(t, "Acrylic stand", queue.Data[0].Name)
+			assert.Equal(t, 5, *queue.Data[0].CircleID)
+			assert.Equal(t, 14, queue.Data[0].PendingWeight)
+			assert.True(t, queue.Data[0].Hidden)
+		})
+
+		t.Run("should list the hidden product only for its circle and the admin", func(t *testing.T) {
+			circleID := 5
+			owner := entity.User{Name: "Stand maker", Email: "stand-maker@example.com", Hash: "hash", CircleID: &circleID}
+			if err := db.Create(&owner).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			productIDs := func(viewerID int) []int {
+				products, err := product_module.NewProductRepo(db).GetAllProductByCircleID(circleID, viewerID)
+				assert.Nil(t, err)
+
+				ids := []int{}
+				for _, found := range products {
+					ids = append(ids, found.ID)
+				}
+				return ids
+			}
+
+			assert.NotContains(t, productIDs(0), product.ID)
+			assert.NotContains(t, productIDs(2), product.ID)
+			assert.Contains(t, productIDs(owner.ID), product.ID)
+			assert.Contains(t, productIDs(1), product.ID)
+
+			keychain := entity.Product{Name: "Keychain", ImageURL: "https://example.com/keychain.png", CircleID: circleID}
+			if err := db.Create(&keychain).Error; err != nil {
+				t.Fatal(err)
+			}
+			assert.Contains(t, productIDs(0), keychain.ID)
+
+			db.Model(&entity.Circle{}).Where("id = ?", circleID).Update("hidden", true)
+			defer db.Model(&entity.Circle{}).Where("id = ?", circleID).Update("hidden", false)
+			assert.NotContains(t, productIDs(0), keychain.ID)
+			assert.Contains(t, productIDs(owner.ID), keychain.ID)
+			assert.Contains(t, productIDs(1), keychain.ID)
+		})
+
+		t.Run("should not reserve nor sell the hidden product", func(t *testing.T) {
+			reservationService := reservation.NewProductReservationService(reservation.NewProductReservationRepo(db))
+
+			_, err := reservationService.CreateReservation(2, 5, product.ID, &reservation_dto.CreateReservationPayload{Quantity: 1})
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+
+			reserved := entity.ProductReservation{ProductID: product.ID, CircleID: 5, UserID: 2, Quantity: 1}
+			if err := db.Create(&reserved).Error; err != nil {
+				t.Fatal(err)
+			}
+			_, err = reservationService.UpdateReservationStatus(5, reserved.ID, &reservation_dto.UpdateReservationStatusPayload{Status: "accepted"})
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+			assert.Equal(t, "PRODUCT_NOT_FOUND", err.Err.Error())
+		})
+
+		t.Run("should unhide the product and resolve its reports", func(t *testing.T) {
+			reports, err := service.FindAllReportByTarget(entity.ReportTargetProduct, product.ID)
+			assert.Nil(t, err)
+			assert.Len(t, reports, 3)
+
+			_, err = service.TakeAction(1, reports[0].ID, &report_dto.ReportActionPayload{Action: "unpublish"})
+			assert.NotNil(t, err)
+			assert.Equal(t, "ACTION_NOT_SUPPORTED", err.Err.Error())
+
+			_, err = service.TakeAction(1, reports[0].ID, &report_dto.ReportActionPayload{Action: "unhide"})
+			assert.Nil(t, err)
+			assert.False(t, productHidden())
+
+			reports, err = service.FindAllReportByTarget(entity.ReportTargetProduct, product.ID)
+			assert.Nil(t, err)
+			for _, report := range reports {
+				assert.Equal(t, entity.ReportResolved, report.Status)
+			}
+		})
+
+		t.Run("should warn a reported user through their circle", func(t *testing.T) {
+			report := entity.Report{UserID: 1, TargetType: entity.ReportTargetUser, TargetID: 3, Category: category("offensive")}
+			err := service.CreateReport(&report)
+			assert.Nil(t, err)
+			assert.Equal(t, 3, *report.CircleID)
+
+			_, err = service.TakeAction(1, report.ID, &report_dto.ReportActionPayload{Action: "hide"})
+			assert.NotNil(t, err)
+			assert.Equal(t, 400, err.Code)
+
+			_, err = service.TakeAction(1, report.ID, &report_dto.ReportActionPayload{Action: "warn", Message: "Be kind to your buyers"})
+			assert.Nil(t, err)
+
+			warnings, err := service.GetCircleWarnings(3)
+			assert.Nil(t, err)
+			assert.Equal(t, "Be kind to your buyers", warnings[0].Message)
+		})
+	})
 }
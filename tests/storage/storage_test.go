@@ -0,0 +1,222 @@
+package storage_test
+
+import (
+	"bytes"
+	"catalog-be/internal/database"
+	"catalog-be/internal/storage"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/localstack"
+)
+
+const baseURL = "http://localhost:8080/storage"
+
+// signedQuery returns the expires and signature of a presigned URL.
+func signedQuery(t *testing.T, presigned string) (string, string) {
+	parsed, err := url.Parse(presigned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Query().Get("expires"), parsed.Query().Get("signature")
+}
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+	local := storage.NewLocalStorage(t.TempDir(), baseURL, "secret")
+	key := "local/products/object.webp"
+
+	t.Run("Put, get and delete", func(t *testing.T) {
+		err := local.Put(ctx, key, strings.NewReader("content"), storage.PutOptions{ContentType: "image/webp"})
+		assert.Nil(t, err)
+
+		exists, err := local.Exists(ctx, key)
+		assert.Nil(t, err)
+		assert.True(t, exists)
+
+		object, err := local.Get(ctx, key)
+		assert.Nil(t, err)
+		data, readErr := io.ReadAll(object)
+		object.Close()
+		assert.Nil(t, readErr)
+		assert.Equal(t, "content", string(data))
+
+		err = local.Delete(ctx, key)
+		assert.Nil(t, err)
+
+		exists, err = local.Exists(ctx, key)
+		assert.Nil(t, err)
+		assert.False(t, exists)
+
+		_, err = local.Get(ctx, key)
+		assert.NotNil(t, err)
+		assert.Equal(t, 404, err.Code)
+		assert.Equal(t, "OBJECT_NOT_FOUND", err.Err.Error())
+	})
+
+	t.Run("Key outside of the directory", func(t *testing.T) {
+		err := local.Put(ctx, "../escaped.webp", strings.NewReader("content"), storage.PutOptions{})
+		assert.NotNil(t, err)
+		assert.Equal(t, "OBJECT_KEY_INVALID", err.Err.Error())
+	})
+
+	t.Run("Presigned upload", func(t *testing.T) {
+		presigned, err := local.Presign(ctx, key, storage.PutOptions{ContentType: "image/png"}, time.Minute)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(presigned, baseURL+"/"+key+"?"))
+
+		expires, signature := signedQuery(t, presigned)
+		err = local.PutSigned(ctx, key, expires, signature, "image/png", bytes.NewReader([]byte("content")))
+		assert.Nil(t, err)
+
+		exists, _ := local.Exists(ctx, key)
+		assert.True(t, exists)
+	})
+
+	t.Run("Presigned upload with another content type", func(t *testing.T) {
+		presigned, _ := local.Presign(ctx, key, storage.PutOptions{ContentType: "image/png"}, time.Minute)
+		expires, signature := signedQuery(t, presigned)
+
+		err := local.PutSigned(ctx, key, expires, signature, "text/html", bytes.NewReader([]byte("content")))
+		assert.NotNil(t, err)
+		assert.Equal(t, 403, err.Code)
+		assert.Equal(t, "PRESIGNED_URL_INVALID", err.Err.Error())
+	})
+
+	t.Run("Presigned upload to another key", func(t *testing.T) {
+		presigned, _ := local.Presign(ctx, key, storage.PutOptions{ContentType: "image/png"}, time.Minute)
+		expires, signature := signedQuery(t, presigned)
+
+		err := local.PutSigned(ctx, "local/products/other.webp", expires, signature, "image/png", bytes.NewReader([]byte("content")))
+		assert.NotNil(t, err)
+		assert.Equal(t, "PRESIGNED_URL_INVALID", err.Err.Error())
+	})
+
+	t.Run("Presigned upload with a later expiry", func(t *testing.T) {
+		presigned, _ := local.Presign(ctx, key, storage.PutOptions{ContentType: "image/png"}, time.Minute)
+		_, signature := signedQuery(t, presigned)
+		expires := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+
+		err := local.PutSigned(ctx, key, expires, signature, "image/png", bytes.NewReader([]byte("content")))
+		assert.NotNil(t, err)
+		assert.Equal(t, "PRESIGNED_URL_INVALID", err.Err.Error())
+	})
+
+	t.Run("Expired presigned upload", func(t *testing.T) {
+		presigned, _ := local.Presign(ctx, key, storage.PutOptions{ContentType: "image/png"}, -time.Minute)
+		expires, signature := signedQuery(t, presigned)
+
+		err := local.PutSigned(ctx, key, expires, signature, "image/png", bytes.NewReader([]byte("content")))
+		assert.NotNil(t, err)
+		assert.Equal(t, "PRESIGNED_URL_EXPIRED", err.Err.Error())
+	})
+}
+
+// createS3Storage starts localstack with an empty bucket, the test is skipped
+// when there is no Docker to run it on.
+func createS3Storage(ctx context.Context, t *testing.T) *storage.S3Storage {
+	provider, err := testcontainers.NewDockerProvider()
+	if err != nil {
+		t.Skipf("Docker is not available: %s", err)
+	}
+	defer provider.Close()
+
+	if err := provider.Health(ctx); err != nil {
+		t.Skipf("Docker is not available: %s", err)
+	}
+
+	localstackContainer, err := localstack.Run(ctx, "localstack/localstack:1.4.0")
+	if err != nil {
+		t.Fatalf("Could not start localstack container: %s", err)
+	}
+	t.Cleanup(func() {
+		localstackContainer.Terminate(context.Background())
+	})
+
+	mappedPort, err := localstackContainer.MappedPort(ctx, "4566")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, err := provider.DaemonHost(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("ACCOUNT_KEY_ID", "test")
+	os.Setenv("ACCOUNT_KEY_SECRET", "test")
+	client := database.NewS3(aws.Endpoint{
+		URL:               fmt.Sprintf("http://%s:%d", host, mappedPort.Int()),
+		HostnameImmutable: true,
+	}, "us-east-1")
+
+	bucket := "localstack"
+	if _, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)}); err != nil {
+		t.Fatal(err)
+	}
+
+	return storage.NewS3Storage(client, bucket)
+}
+
+func TestS3Storage(t *testing.T) {
+	ctx := context.Background()
+	bucket := createS3Storage(ctx, t)
+	key := "local/products/object.webp"
+
+	t.Run("Put, get and delete", func(t *testing.T) {
+		err := bucket.Put(ctx, key, strings.NewReader("content"), storage.PutOptions{ContentType: "image/webp"})
+		assert.Nil(t, err)
+
+		exists, err := bucket.Exists(ctx, key)
+		assert.Nil(t, err)
+		assert.True(t, exists)
+
+		object, err := bucket.Get(ctx, key)
+		assert.Nil(t, err)
+		data, readErr := io.ReadAll(object)
+		object.Close()
+		assert.Nil(t, readErr)
+		assert.Equal(t, "content", string(data))
+
+		err = bucket.Delete(ctx, key)
+		assert.Nil(t, err)
+
+		exists, err = bucket.Exists(ctx, key)
+		assert.Nil(t, err)
+		assert.False(t, exists)
+
+		_, err = bucket.Get(ctx, key)
+		assert.NotNil(t, err)
+		assert.Equal(t, 404, err.Code)
+		assert.Equal(t, "OBJECT_NOT_FOUND", err.Err.Error())
+	})
+
+	t.Run("Presigned upload", func(t *testing.T) {
+		presigned, err := bucket.Presign(ctx, key, storage.PutOptions{ContentType: "image/png"}, time.Minute)
+		assert.Nil(t, err)
+
+		request, requestErr := http.NewRequest(http.MethodPut, presigned, bytes.NewReader([]byte("content")))
+		assert.Nil(t, requestErr)
+		request.Header.Set("Content-Type", "image/png")
+
+		response, requestErr := http.DefaultClient.Do(request)
+		assert.Nil(t, requestErr)
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+
+		exists, _ := bucket.Exists(ctx, key)
+		assert.True(t, exists)
+	})
+}
@@ -36,7 +36,7 @@ type CircleJson struct {
 	WorkTypeIDs      []int      `json:"work_type_ids"`
 }
 
-func Migrate(t *testing.T, db *gorm.DB) {
+func Migrate(t testing.TB, db *gorm.DB) {
 	sqlDir := "../../migrator/migrations"
 
 	files, err := os.ReadDir(sqlDir)
@@ -59,13 +59,13 @@ func Migrate(t *testing.T, db *gorm.DB) {
 	}
 }
 
-func SetupDb(t *testing.T, dsn string) *gorm.DB {
+func SetupDb(t testing.TB, dsn string) *gorm.DB {
 	db := database.New(dsn, false)
 	Migrate(t, db)
 	return db
 }
 
-func SeedEvent(t *testing.T, db *gorm.DB) {
+func SeedEvent(t testing.TB, db *gorm.DB) {
 	err := db.Create([]entity.Event{
 		{
 			Name: "Event 1",
@@ -85,7 +85,7 @@ func SeedEvent(t *testing.T, db *gorm.DB) {
 	}
 }
 
-func SeedFandom(t *testing.T, db *gorm.DB) {
+func SeedFandom(t testing.TB, db *gorm.DB) {
 	fandomFile, err := os.Open("../circle/data/fandom.json")
 	if err != nil {
 		t.Fatal(err)
@@ -111,7 +111,7 @@ func SeedFandom(t *testing.T, db *gorm.DB) {
 	}
 }
 
-func SeedWorkType(t *testing.T, db *gorm.DB) {
+func SeedWorkType(t testing.TB, db *gorm.DB) {
 	workType, err := os.Open("../circle/data/work_type.json")
 	if err != nil {
 		t.Fatal(err)
@@ -138,7 +138,7 @@ func SeedWorkType(t *testing.T, db *gorm.DB) {
 	}
 }
 
-func SeedUser(t *testing.T, db *gorm.DB) {
+func SeedUser(t testing.TB, db *gorm.DB) {
 	makeCircleID := func(s int) *int {
 		return &s
 	}
@@ -173,7 +173,7 @@ func SeedUser(t *testing.T, db *gorm.DB) {
 	}
 }
 
-func GetConnURL(t *testing.T, ctx context.Context) (string, testcontainers.Container) {
+func GetConnURL(t testing.TB, ctx context.Context) (string, testcontainers.Container) {
 	container, err := postgres.Run(ctx,
 		"postgres:16-alpine",
 		postgres.WithDatabase("testdb"),
@@ -2,74 +2,122 @@ package upload_test
 
 import (
 	"bytes"
-	"catalog-be/internal/database"
+	"catalog-be/internal/domain"
+	"catalog-be/internal/entity"
+	"catalog-be/internal/modules/circle"
+	"catalog-be/internal/modules/circle/bookmark"
+	"catalog-be/internal/modules/circle/circle_fandom"
+	"catalog-be/internal/modules/circle/circle_work_type"
+	circle_dto "catalog-be/internal/modules/circle/dto"
+	"catalog-be/internal/modules/circle/referral"
+	"catalog-be/internal/modules/circle/revision"
+	"catalog-be/internal/modules/plan"
+	plan_dto "catalog-be/internal/modules/plan/dto"
+	"catalog-be/internal/modules/product"
+	refreshtoken "catalog-be/internal/modules/refresh_token"
 	"catalog-be/internal/modules/upload"
+	upload_dto "catalog-be/internal/modules/upload/dto"
+	"catalog-be/internal/modules/upload/imaging"
+	"catalog-be/internal/modules/upload/screening"
+	screening_dto "catalog-be/internal/modules/upload/screening/dto"
+	"catalog-be/internal/modules/user"
+	"catalog-be/internal/storage"
+	"catalog-be/internal/utils"
+	"catalog-be/internal/validation"
+	test_helper "catalog-be/tests/test_helper"
 	"context"
-	"fmt"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
 	"io"
+	"io/fs"
 	"log"
+	"math/rand"
 	"mime/multipart"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
-	"github.com/testcontainers/testcontainers-go"
-	"github.com/testcontainers/testcontainers-go/modules/localstack"
+	"gorm.io/gorm"
 )
 
-func createS3Client(ctx context.Context, t *testing.T) *s3.Client {
-	localstackContainer, err := localstack.Run(ctx, "localstack/localstack:1.4.0")
-	if err != nil {
-		t.Logf("Error starting localstack container: %s", err)
-	}
-
-	mappedPort, err := localstackContainer.MappedPort(ctx, "4566")
-	if err != nil {
-		t.Logf("Error getting mapped port: %s", err)
-	}
-
-	provider, err := testcontainers.NewDockerProvider()
-	if err != nil {
-		t.Logf("Error creating docker provider: %s", err)
-	}
-	defer provider.Close()
-	host, err := provider.DaemonHost(ctx)
-	if err != nil {
-		t.Logf("Error getting daemon host: %s", err)
-	}
-
-	endpoint := aws.Endpoint{
-		URL:               fmt.Sprintf("http://%s:%d", host, mappedPort.Int()),
-		HostnameImmutable: true,
-	}
-
-	client := database.NewS3(endpoint, "us-east-1")
-
-	return client
-}
-
 func TestMain(m *testing.M) {
-	os.Setenv("BUCKET_NAME", "localstack")
 	os.Setenv("APP_STAGE", "local")
-	os.Setenv("ACCOUNT_KEY_ID", "test")
-	os.Setenv("ACCOUNT_KEY_SECRET", "test")
 	res := m.Run()
 	os.Exit(res)
 }
 
 type uploadInstance struct {
 	uploadService *upload.UploadService
+	circleService *circle.CircleService
 }
 
-func newUploadInstance(client *s3.Client) *uploadInstance {
-	uploadService := upload.NewUploadService(client)
+func newUploadInstance(db *gorm.DB, storage storage.Storage) *uploadInstance {
+	uploadRepo := upload.NewUploadRepo(db)
+	planService := plan.NewPlanService(plan.NewPlanRepo(db))
+	screeningService := screening.NewScreeningService(screening.NewScreeningRepo(db))
+	uploadService := upload.NewUploadService(storage, uploadRepo, planService, screeningService)
+
+	utils := utils.NewUtils()
+	circleService := circle.NewCircleService(
+		circle.NewCircleRepo(db),
+		user.NewUserService(user.NewUserRepo(db)),
+		utils,
+		refreshtoken.NewRefreshTokenService(refreshtoken.NewRefreshTokenRepo(db), utils),
+		circle_work_type.NewCircleWorkTypeService(circle_work_type.NewCircleWorkTypeRepo(db)),
+		circle_fandom.NewCircleFandomService(circle_fandom.NewCircleFandomRepo(db)),
+		bookmark.NewCircleBookmarkService(bookmark.NewCircleBookmarkRepo(db)),
+		validation.NewSanitizer(),
+		referral.NewReferralService(referral.NewReferralRepo(db)),
+		revision.NewRevisionService(revision.NewRevisionRepo(db)),
+		planService,
+	)
 	return &uploadInstance{
 		uploadService,
+		circleService,
+	}
+}
+
+// createUploader creates a circle of its own for a test, with its owner.
+func createUploader(t *testing.T, db *gorm.DB, slug string) (int, *entity.User) {
+	circle := entity.Circle{Name: slug, Slug: slug, Published: true, Verified: true}
+	if err := db.Create(&circle).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	user := entity.User{Name: slug, Email: slug + "@example.com", Hash: "hash", CircleID: &circle.ID}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
 	}
+
+	return circle.ID, &user
+}
+
+// presignAndPut asks for a presigned upload of a product picture as userID of
+// circleID, then plays the client putting data to the presigned URL.
+func presignAndPut(t *testing.T, uploadService *upload.UploadService, local *storage.LocalStorage, userID int, circleID int, data []byte) *upload_dto.PresignUploadResponse {
+	presigned, err := uploadService.PresignUpload(userID, circleID, &upload_dto.PresignUploadPayload{Type: "products", ContentType: "image/png"})
+	if err != nil {
+		t.Fatal(err.Err)
+	}
+
+	parsed, parseErr := url.Parse(presigned.URL)
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+
+	key := strings.TrimPrefix(parsed.Path, "/storage/")
+	putErr := local.PutSigned(context.Background(), key, parsed.Query().Get("expires"), parsed.Query().Get("signature"), presigned.Headers["Content-Type"], bytes.NewReader(data))
+	assert.Nil(t, putErr)
+
+	return presigned
 }
 
 func createFileHeader(path string, contentType string) *multipart.FileHeader {
@@ -122,6 +170,47 @@ func createFileHeader(path string, contentType string) *multipart.FileHeader {
 	return fileHeader
 }
 
+// writeFile copies data into a file called name inside a temporary directory
+// so a test can craft uploads the data folder does not have.
+func writeFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// hugePNG is a valid 1x1 PNG whose header claims width x height pixels.
+func hugePNG(t *testing.T, width uint32, height uint32) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+
+	// IHDR data starts after the 8 bytes signature, its length and its type
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:20], width)
+	binary.BigEndian.PutUint32(data[20:24], height)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+// noisePNG is a 1200x1000 PNG of random pixels, noise does not compress so it
+// weighs about 3.4 MB, over the limit of covers and descriptions.
+func noisePNG(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 1200, 1000))
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
 func isUUID(s string) bool {
 	_, err := uuid.Parse(s)
 	return err == nil
@@ -129,53 +218,118 @@ func isUUID(s string) bool {
 
 func TestUpload(t *testing.T) {
 	t.Parallel()
-	bucketName := os.Getenv("BUCKET_NAME")
 	appStage := os.Getenv("APP_STAGE")
 	ctx := context.Background()
-	client := createS3Client(ctx, t)
-	// create bucket
-	_, err := client.CreateBucket(ctx, &s3.CreateBucketInput{
-		Bucket: aws.String(bucketName),
-	})
-	if err != nil {
-		t.Logf("Error creating bucket: %s", err)
-	}
+	connURL, _ := test_helper.GetConnURL(t, ctx)
+	db := test_helper.SetupDb(t, connURL)
 
-	instance := newUploadInstance(client)
-	if client == nil {
-		t.Fatal("Error creating S3 client")
+	circle := entity.Circle{Name: "Uploader", Slug: "uploader"}
+	if err := db.Create(&circle).Error; err != nil {
+		t.Fatal(err)
 	}
+	user := entity.User{Name: "Uploader", Email: "uploader@example.com", Hash: "hash", CircleID: &circle.ID}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	local := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080/storage", "secret")
+	instance := newUploadInstance(db, local)
 
 	t.Run("Test upload covers", func(t *testing.T) {
 		t.Run("Test upload covers success", func(t *testing.T) {
 			fileHeader := createFileHeader("./data/accepted_jpeg.jpeg", "image/jpeg")
-			_, uploadErr := instance.uploadService.UploadImage("covers", fileHeader)
+			_, uploadErr := instance.uploadService.UploadImage(user.ID, circle.ID, "covers", fileHeader)
 			if uploadErr != nil {
 				t.Logf("Error uploading image: %s", uploadErr.Err.Error())
 			}
 			assert.Nil(t, uploadErr)
 		})
 		t.Run("Test upload covers file too large", func(t *testing.T) {
-			fileHeader := createFileHeader("./data/4mb.jpg", "image/jpg")
-			_, uploadErr := instance.uploadService.UploadImage("covers", fileHeader)
+			fileHeader := createFileHeader(writeFile(t, "large.png", noisePNG(t)), "image/png")
+			_, uploadErr := instance.uploadService.UploadImage(user.ID, circle.ID, "covers", fileHeader)
 			assert.NotNil(t, uploadErr)
 			assert.Equal(t, "FILE_SIZE_TOO_LARGE", uploadErr.Err.Error())
 		})
 
+		t.Run("Test upload covers strips metadata", func(t *testing.T) {
+			fileHeader := createFileHeader("./data/accepted_jpg.jpg", "image/jpeg")
+			uploaded, uploadErr := instance.uploadService.UploadImage(user.ID, circle.ID, "covers", fileHeader)
+			assert.Nil(t, uploadErr)
+
+			object, getErr := local.Get(ctx, strings.TrimPrefix(uploaded.Variants["thumb"], "/"))
+			assert.Nil(t, getErr)
+			defer object.Close()
+
+			data, err := io.ReadAll(object)
+			assert.Nil(t, err)
+			assert.Equal(t, imaging.FormatWebP, imaging.Sniff(data))
+			assert.NotContains(t, string(data), "Exif")
+
+			var registered entity.Upload
+			db.Where("path = ?", uploaded.Path).First(&registered)
+			assert.Equal(t, entity.UploadConfirmed, registered.Status)
+			assert.Equal(t, circle.ID, *registered.CircleID)
+			assert.Equal(t, uploaded.Size, registered.Size)
+			assert.Equal(t, uploaded.Variants, registered.Variants)
+			assert.Equal(t, uploaded.Hash, *registered.Hash)
+			assert.Equal(t, uploaded.Placeholder, *registered.Placeholder)
+		})
+
 		t.Run("Incorrect file type", func(t *testing.T) {
 			fileHeader := createFileHeader("./data/dummies.pdf", "application/pdf")
-			_, uploadErr := instance.uploadService.UploadImage("covers", fileHeader)
+			_, uploadErr := instance.uploadService.UploadImage(user.ID, circle.ID, "covers", fileHeader)
 			assert.NotNil(t, uploadErr)
 			assert.Equal(t, "FILE_TYPE_INVALID", uploadErr.Err.Error())
 		})
+
+		t.Run("Content type is not trusted", func(t *testing.T) {
+			data, err := os.ReadFile("./data/dummies.pdf")
+			assert.Nil(t, err)
+
+			fileHeader := createFileHeader(writeFile(t, "dummies.png", data), "image/png")
+			_, uploadErr := instance.uploadService.UploadImage(user.ID, circle.ID, "covers", fileHeader)
+			assert.NotNil(t, uploadErr)
+			assert.Equal(t, "FILE_TYPE_INVALID", uploadErr.Err.Error())
+		})
+
+		t.Run("Extension does not match content", func(t *testing.T) {
+			data, err := os.ReadFile("./data/accepted_png.png")
+			assert.Nil(t, err)
+
+			fileHeader := createFileHeader(writeFile(t, "accepted_png.jpg", data), "image/jpeg")
+			_, uploadErr := instance.uploadService.UploadImage(user.ID, circle.ID, "covers", fileHeader)
+			assert.NotNil(t, uploadErr)
+			assert.Equal(t, "FILE_EXTENSION_MISMATCH", uploadErr.Err.Error())
+		})
+
+		t.Run("Truncated image", func(t *testing.T) {
+			data, err := os.ReadFile("./data/accepted_jpg.jpg")
+			assert.Nil(t, err)
+
+			fileHeader := createFileHeader(writeFile(t, "truncated.jpg", data[:len(data)/2]), "image/jpeg")
+			_, uploadErr := instance.uploadService.UploadImage(user.ID, circle.ID, "covers", fileHeader)
+			assert.NotNil(t, uploadErr)
+			assert.Equal(t, "IMAGE_CORRUPTED", uploadErr.Err.Error())
+		})
+
+		t.Run("Decompression bomb", func(t *testing.T) {
+			fileHeader := createFileHeader(writeFile(t, "bomb.png", hugePNG(t, 50000, 50000)), "image/png")
+			_, uploadErr := instance.uploadService.UploadImage(user.ID, circle.ID, "covers", fileHeader)
+			assert.NotNil(t, uploadErr)
+			assert.Equal(t, "IMAGE_DIMENSIONS_TOO_LARGE", uploadErr.Err.Error())
+		})
 	})
 
 	t.Run("Test upload products", func(t *testing.T) {
 		t.Run("Test upload product successs", func(t *testing.T) {
-			fileHeader := createFileHeader("./data/4mb.jpg", "image/jpg")
-			path, uploadErr := instance.uploadService.UploadImage("products", fileHeader)
+			fileHeader := createFileHeader(writeFile(t, "large.png", noisePNG(t)), "image/png")
+			uploaded, uploadErr := instance.uploadService.UploadImage(user.ID, circle.ID, "products", fileHeader)
 			assert.Nil(t, uploadErr)
+			assert.Len(t, uploaded.Variants, 3)
+
+			path := uploaded.Path
 			assert.NotEmpty(t, path)
+			assert.Equal(t, ".webp", filepath.Ext(path))
 			assert.Contains(t, path, "products")
 			assert.Contains(t, path, appStage)
 
@@ -189,9 +343,12 @@ func TestUpload(t *testing.T) {
 	t.Run("Test upload descriptions", func(t *testing.T) {
 		t.Run("Test upload description successs", func(t *testing.T) {
 			fileHeader := createFileHeader("./data/accepted_webp.webp", "image/webp")
-			path, uploadErr := instance.uploadService.UploadImage("descriptions", fileHeader)
+			uploaded, uploadErr := instance.uploadService.UploadImage(user.ID, circle.ID, "descriptions", fileHeader)
 			assert.Nil(t, uploadErr)
+
+			path := uploaded.Path
 			assert.NotEmpty(t, path)
+			assert.Equal(t, path, uploaded.Variants["full"])
 			assert.Contains(t, path, "descriptions")
 			assert.Contains(t, path, appStage)
 
@@ -202,11 +359,474 @@ func TestUpload(t *testing.T) {
 		})
 
 		t.Run("Test upload description failed file too large", func(t *testing.T) {
-			fileHeader := createFileHeader("./data/4mb.jpg", "image/jpg")
-			_, uploadErr := instance.uploadService.UploadImage("descriptions", fileHeader)
+			fileHeader := createFileHeader(writeFile(t, "large.png", noisePNG(t)), "image/png")
+			_, uploadErr := instance.uploadService.UploadImage(user.ID, circle.ID, "descriptions", fileHeader)
 			assert.NotNil(t, uploadErr)
 			assert.Equal(t, "FILE_SIZE_TOO_LARGE", uploadErr.Err.Error())
 
 		})
 	})
+
+	t.Run("Test presigned upload", func(t *testing.T) {
+		circleID, uploader := createUploader(t, db, "presigned")
+
+		local := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080/storage", "secret")
+		uploadService := upload.NewUploadService(local, upload.NewUploadRepo(db), plan.NewPlanService(plan.NewPlanRepo(db)), screening.NewScreeningService(screening.NewScreeningRepo(db)))
+
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, image.NewGray(image.Rect(0, 0, 64, 64))); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Run("should store the variants once confirmed", func(t *testing.T) {
+			presigned := presignAndPut(t, uploadService, local, uploader.ID, circleID, encoded.Bytes())
+
+			uploaded, err := uploadService.ConfirmUpload(circleID, presigned.Ticket)
+			assert.Nil(t, err)
+			assert.Len(t, uploaded.Variants, 3)
+
+			exists, _ := local.Exists(ctx, strings.TrimPrefix(uploaded.Path, "/"))
+			assert.True(t, exists)
+
+			var registered entity.Upload
+			db.Where("ticket = ?", presigned.Ticket).First(&registered)
+			assert.Equal(t, entity.UploadConfirmed, registered.Status)
+			assert.Equal(t, uploaded.Path, *registered.Path)
+
+			exists, _ = local.Exists(ctx, *registered.ObjectKey)
+			assert.False(t, exists)
+
+			_, err = uploadService.ConfirmUpload(circleID, presigned.Ticket)
+			assert.NotNil(t, err)
+			assert.Equal(t, "UPLOAD_ALREADY_CONFIRMED", err.Err.Error())
+		})
+
+		t.Run("should not be confirmed by another circle", func(t *testing.T) {
+			presigned := presignAndPut(t, uploadService, local, uploader.ID, circleID, encoded.Bytes())
+
+			_, err := uploadService.ConfirmUpload(circleID+1, presigned.Ticket)
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+			assert.Equal(t, "UPLOAD_NOT_FOUND", err.Err.Error())
+		})
+
+		t.Run("should wait for the object", func(t *testing.T) {
+			presigned, err := uploadService.PresignUpload(uploader.ID, circleID, &upload_dto.PresignUploadPayload{Type: "products", ContentType: "image/png"})
+			assert.Nil(t, err)
+			assert.Equal(t, "PUT", presigned.Method)
+
+			_, err = uploadService.ConfirmUpload(circleID, presigned.Ticket)
+			assert.NotNil(t, err)
+			assert.Equal(t, "UPLOAD_OBJECT_NOT_FOUND", err.Err.Error())
+		})
+
+		t.Run("should reject an object that is not an image", func(t *testing.T) {
+			presigned := presignAndPut(t, uploadService, local, uploader.ID, circleID, []byte("%PDF-1.4 not an image"))
+
+			_, err := uploadService.ConfirmUpload(circleID, presigned.Ticket)
+			assert.NotNil(t, err)
+			assert.Equal(t, "FILE_TYPE_INVALID", err.Err.Error())
+
+			var registered entity.Upload
+			db.Where("ticket = ?", presigned.Ticket).First(&registered)
+			assert.Equal(t, entity.UploadPending, registered.Status)
+
+			exists, _ := local.Exists(ctx, *registered.ObjectKey)
+			assert.False(t, exists)
+		})
+
+		t.Run("should not leave variants behind when two confirms race", func(t *testing.T) {
+			root := t.TempDir()
+			racing := storage.NewLocalStorage(root, "http://localhost:8080/storage", "secret")
+			racingService := upload.NewUploadService(racing, upload.NewUploadRepo(db), plan.NewPlanService(plan.NewPlanRepo(db)), screening.NewScreeningService(screening.NewScreeningRepo(db)))
+
+			presigned := presignAndPut(t, racingService, racing, uploader.ID, circleID, encoded.Bytes())
+
+			var wg sync.WaitGroup
+			results := make([]*upload.UploadedImage, 2)
+			for i := range results {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					results[i], _ = racingService.ConfirmUpload(circleID, presigned.Ticket)
+				}(i)
+			}
+			wg.Wait()
+
+			confirmed := 0
+			for _, result := range results {
+				if result != nil {
+					confirmed++
+				}
+			}
+			assert.Equal(t, 1, confirmed)
+
+			files := 0
+			filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+				if err == nil && !entry.IsDir() {
+					files++
+				}
+				return nil
+			})
+			assert.Equal(t, 3, files)
+		})
+	})
+
+	t.Run("Test upload sweeper and storage quota", func(t *testing.T) {
+		circleID, uploader := createUploader(t, db, "sweeper")
+
+		local := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080/storage", "secret")
+		planService := plan.NewPlanService(plan.NewPlanRepo(db))
+		uploadService := upload.NewUploadService(local, upload.NewUploadRepo(db), planService, screening.NewScreeningService(screening.NewScreeningRepo(db)))
+
+		now := time.Now()
+		old := now.Add(-48 * time.Hour)
+		expired := now.Add(-time.Hour)
+
+		// track stores the variants of name and registers them as uploaded at createdAt
+		track := func(t *testing.T, name string, createdAt time.Time) entity.Upload {
+			path := "/test/products/" + name + ".webp"
+			variants := map[string]string{"full": path, "thumb": "/test/products/" + name + "_thumb.webp"}
+			for _, variant := range variants {
+				assert.Nil(t, local.Put(ctx, strings.TrimPrefix(variant, "/"), strings.NewReader(name), storage.PutOptions{}))
+			}
+
+			tracked := entity.Upload{
+				CircleID:  &circleID,
+				UserID:    &uploader.ID,
+				Folder:    "products",
+				Path:      &path,
+				Variants:  variants,
+				Size:      100,
+				Status:    entity.UploadConfirmed,
+				CreatedAt: &createdAt,
+			}
+			if err := db.Create(&tracked).Error; err != nil {
+				t.Fatal(err)
+			}
+			return tracked
+		}
+
+		picture := track(t, "0192a4c1-6f2e-7c1b-9d3a-5b8e2f4c7a01", old)
+		description := track(t, "0192a4c1-6f2e-7c1b-9d3a-5b8e2f4c7a02", old)
+		orphan := track(t, "0192a4c1-6f2e-7c1b-9d3a-5b8e2f4c7a03", old)
+		recent := track(t, "0192a4c1-6f2e-7c1b-9d3a-5b8e2f4c7a04", now)
+		replaced := track(t, "0192a4c1-6f2e-7c1b-9d3a-5b8e2f4c7a05", old)
+
+		pendingKey := "test/pending/products/sweep-pending.png"
+		assert.Nil(t, local.Put(ctx, pendingKey, strings.NewReader("original"), storage.PutOptions{}))
+		ticket := "0192a4c1-6f2e-7c1b-9d3a-5b8e2f4c7a11"
+		pending := entity.Upload{Ticket: &ticket, CircleID: &circleID, Folder: "products", ObjectKey: &pendingKey, Status: entity.UploadPending, ExpiresAt: &expired}
+		if err := db.Create(&pending).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		// the circle showed replaced before picture, only its revisions still use it
+		cdnReplaced := "https://cdn.innercatalog.com" + *replaced.Path
+		_, updateErr := instance.circleService.UpdateCircleByID(uploader.ID, circleID, &circle_dto.UpdateCirclePayload{PictureURL: &cdnReplaced})
+		assert.Nil(t, updateErr)
+		cdnPicture := "https://cdn.innercatalog.com" + *picture.Path
+		_, updateErr = instance.circleService.UpdateCircleByID(uploader.ID, circleID, &circle_dto.UpdateCirclePayload{PictureURL: &cdnPicture})
+		assert.Nil(t, updateErr)
+
+		productService := product.NewProductService(product.NewProductRepo(db), planService)
+		productDescription := `<p><img src="https://cdn.innercatalog.com` + description.Variants["thumb"] + `"></p>`
+		sweptProduct, productErr := productService.CreateOneProductByCircleID(uploader.ID, circleID, entity.Product{Name: "Swept product", ImageURL: cdnPicture, Description: &productDescription})
+		assert.Nil(t, productErr)
+
+		t.Run("should delete uploads nothing references after the grace period", func(t *testing.T) {
+			swept, err := uploadService.SweepOrphanedUploads(now)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, swept)
+
+			var remaining []int
+			db.Model(&entity.Upload{}).Where("circle_id = ?", circleID).Order("id").Pluck("id", &remaining)
+			assert.Equal(t, []int{picture.ID, description.ID, recent.ID, replaced.ID}, remaining)
+
+			for _, variant := range orphan.Variants {
+				exists, _ := local.Exists(ctx, strings.TrimPrefix(variant, "/"))
+				assert.False(t, exists)
+			}
+			exists, _ := local.Exists(ctx, pendingKey)
+			assert.False(t, exists)
+			exists, _ = local.Exists(ctx, strings.TrimPrefix(*recent.Path, "/"))
+			assert.True(t, exists)
+
+			var references []entity.UploadReference
+			db.
+				Where("upload_id IN ? AND entity_type IN ?", []int{picture.ID, description.ID}, []entity.UploadReferenceType{entity.UploadReferenceCircle, entity.UploadReferenceProduct}).
+				Order("upload_id, entity_type").
+				Find(&references)
+			assert.Equal(t, []entity.UploadReference{
+				{UploadID: picture.ID, EntityType: entity.UploadReferenceCircle, EntityID: circleID},
+				{UploadID: picture.ID, EntityType: entity.UploadReferenceProduct, EntityID: sweptProduct.ID},
+				{UploadID: description.ID, EntityType: entity.UploadReferenceProduct, EntityID: sweptProduct.ID},
+			}, references)
+		})
+
+		t.Run("should keep uploads a revision still references", func(t *testing.T) {
+			var types []entity.UploadReferenceType
+			db.Model(&entity.UploadReference{}).Where("upload_id = ?", replaced.ID).Distinct().Pluck("entity_type", &types)
+			assert.Equal(t, []entity.UploadReferenceType{entity.UploadReferenceCircleRevision}, types)
+
+			for _, variant := range replaced.Variants {
+				exists, _ := local.Exists(ctx, strings.TrimPrefix(variant, "/"))
+				assert.True(t, exists)
+			}
+		})
+
+		t.Run("should forget the references of a purged circle", func(t *testing.T) {
+			tx := db.Begin()
+			defer tx.Rollback()
+
+			assert.Nil(t, upload.DeleteCircleReferences(tx, circleID))
+
+			var count int64
+			tx.Model(&entity.UploadReference{}).Where("upload_id IN ?", []int{picture.ID, description.ID, replaced.ID}).Count(&count)
+			assert.Equal(t, int64(0), count)
+		})
+
+		storageUsed := func(t *testing.T) int {
+			usage, err := planService.GetUsageByCircleID(circleID)
+			assert.Nil(t, err)
+			return usage.Usage[3].Used
+		}
+
+		t.Run("should free the quota of a replaced picture", func(t *testing.T) {
+			assert.Equal(t, 300, storageUsed(t))
+
+			cdnRecent := "https://cdn.innercatalog.com" + *recent.Path
+			_, err := instance.circleService.UpdateCircleByID(uploader.ID, circleID, &circle_dto.UpdateCirclePayload{CoverPictureURL: &cdnRecent})
+			assert.Nil(t, err)
+			assert.Equal(t, 300, storageUsed(t))
+
+			_, err = instance.circleService.UpdateCircleByID(uploader.ID, circleID, &circle_dto.UpdateCirclePayload{CoverPictureURL: &cdnPicture})
+			assert.Nil(t, err)
+			assert.Equal(t, 200, storageUsed(t))
+
+			var types []entity.UploadReferenceType
+			db.Model(&entity.UploadReference{}).Where("upload_id = ?", recent.ID).Distinct().Pluck("entity_type", &types)
+			assert.Equal(t, []entity.UploadReferenceType{entity.UploadReferenceCircleRevision}, types)
+		})
+
+		t.Run("should count uploads in the storage quota", func(t *testing.T) {
+			usage, err := planService.GetUsageByCircleID(circleID)
+			assert.Nil(t, err)
+			assert.Equal(t, plan_dto.ResourceUsage{Resource: "storage", Used: 200, Limit: plan.DEFAULT_PLAN.MaxStorageBytes}, usage.Usage[3])
+
+			tiny, err := planService.CreatePlan(&plan_dto.CreateUpdatePlanPayload{Slug: "tiny", Name: "Tiny", MaxProducts: 5, MaxFandoms: 5, MaxWorkTypes: 5, MaxStorageBytes: 200})
+			assert.Nil(t, err)
+			_, err = planService.AssignPlanToCircle(circleID, &tiny.ID)
+			assert.Nil(t, err)
+
+			_, err = uploadService.PresignUpload(uploader.ID, circleID, &upload_dto.PresignUploadPayload{Type: "products", ContentType: "image/png"})
+			assert.NotNil(t, err)
+			assert.Equal(t, 400, err.Code)
+			assert.Equal(t, "STORAGE_QUOTA_EXCEEDED", err.Err.Error())
+		})
+	})
+
+	t.Run("Test near-duplicate and banned uploads", func(t *testing.T) {
+		owner, ownerUser := createUploader(t, db, "owner")
+		thief, thiefUser := createUploader(t, db, "thief")
+		uploaders := map[int]*entity.User{owner: ownerUser, thief: thiefUser}
+		moderator := entity.User{Name: "Moderator", Email: "moderator@example.com", Hash: "hash"}
+		if err := db.Create(&moderator).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		local := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080/storage", "secret")
+		screeningService := screening.NewScreeningService(screening.NewScreeningRepo(db))
+		uploadService := upload.NewUploadService(local, upload.NewUploadRepo(db), plan.NewPlanService(plan.NewPlanRepo(db)), screeningService)
+
+		// encode draws a size x size picture with pixel(x, y) as PNG
+		encode := func(t *testing.T, size int, pixel func(x int, y int) uint8) []byte {
+			img := image.NewGray(image.Rect(0, 0, size, size))
+			for y := 0; y < size; y++ {
+				for x := 0; x < size; x++ {
+					img.Pix[y*img.Stride+x] = pixel(x, y)
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, img); err != nil {
+				t.Fatal(err)
+			}
+			return buf.Bytes()
+		}
+		artwork := func(size int) []byte {
+			return encode(t, size, func(x int, y int) uint8 { return uint8(x * 255 / (size - 1)) })
+		}
+		checker := encode(t, 64, func(x int, y int) uint8 { return uint8((x/8 + y/8) % 2 * 255) })
+
+		// send plays a circle uploading data through a presigned URL
+		send := func(t *testing.T, circleID int, data []byte) (*entity.Upload, *domain.Error) {
+			presigned := presignAndPut(t, uploadService, local, uploaders[circleID].ID, circleID, data)
+
+			_, err := uploadService.ConfirmUpload(circleID, presigned.Ticket)
+			if err != nil {
+				return nil, err
+			}
+
+			var registered entity.Upload
+			db.Where("ticket = ?", presigned.Ticket).First(&registered)
+			return &registered, nil
+		}
+
+		flagsOf := func(uploadID int) []entity.UploadFlag {
+			var flags []entity.UploadFlag
+			db.Where("upload_id = ?", uploadID).Find(&flags)
+			return flags
+		}
+
+		original, err := send(t, owner, artwork(64))
+		assert.Nil(t, err)
+		assert.NotNil(t, original.Hash)
+		assert.Empty(t, flagsOf(original.ID))
+
+		var flag entity.UploadFlag
+
+		t.Run("should flag a copy owned by another circle", func(t *testing.T) {
+			copied, err := send(t, thief, artwork(80))
+			assert.Nil(t, err)
+
+			flags := flagsOf(copied.ID)
+			assert.Len(t, flags, 1)
+			assert.Equal(t, original.ID, *flags[0].MatchedUploadID)
+			assert.Equal(t, entity.UploadFlagPending, flags[0].Status)
+			assert.LessOrEqual(t, flags[0].Distance, screening.NEAR_DUPLICATE_DISTANCE)
+			flag = flags[0]
+
+			unrelated, err := send(t, thief, checker)
+			assert.Nil(t, err)
+			assert.Empty(t, flagsOf(unrelated.ID))
+
+			again, err := send(t, owner, artwork(96))
+			assert.Nil(t, err)
+			assert.Empty(t, flagsOf(again.ID))
+		})
+
+		t.Run("should only compare uploads of the same folder", func(t *testing.T) {
+			confirmedAt := time.Now()
+			cover := entity.Upload{CircleID: &thief, UserID: &uploaders[thief].ID, Folder: "covers", Hash: original.Hash, Status: entity.UploadConfirmed, ConfirmedAt: &confirmedAt}
+			if err := db.Create(&cover).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Nil(t, screeningService.FlagNearDuplicate(&cover))
+			assert.Empty(t, flagsOf(cover.ID))
+		})
+
+		t.Run("should ban the hash of a flag reviewed as banned", func(t *testing.T) {
+			note := "Stolen artwork"
+			reviewed, err := screeningService.ReviewFlag(moderator.ID, flag.ID, &screening_dto.ReviewFlagPayload{Status: "banned", Note: &note})
+			assert.Nil(t, err)
+			assert.Equal(t, entity.UploadFlagBanned, reviewed.Status)
+			assert.Equal(t, moderator.ID, *reviewed.ReviewedBy)
+
+			_, err = screeningService.ReviewFlag(moderator.ID, flag.ID, &screening_dto.ReviewFlagPayload{Status: "dismissed"})
+			assert.NotNil(t, err)
+			assert.Equal(t, 409, err.Code)
+
+			_, err = send(t, thief, artwork(72))
+			assert.NotNil(t, err)
+			assert.Equal(t, 400, err.Code)
+			assert.Equal(t, "IMAGE_BANNED", err.Err.Error())
+
+			_, err = send(t, thief, checker)
+			assert.Nil(t, err)
+		})
+
+		t.Run("should manage the banned hashes", func(t *testing.T) {
+			_, err := screeningService.CreateBannedHash(moderator.ID, &screening_dto.CreateBannedHashPayload{UploadID: &flag.UploadID})
+			assert.NotNil(t, err)
+			assert.Equal(t, "HASH_ALREADY_BANNED", err.Err.Error())
+
+			hashes, err := screeningService.GetPaginatedBannedHashes(&screening_dto.GetPaginatedBannedHashesFilter{Page: 1, Limit: 20})
+			assert.Nil(t, err)
+			assert.Len(t, hashes.Data, 1)
+			assert.Equal(t, *original.Hash, hashes.Data[0].Hash)
+
+			err = screeningService.DeleteBannedHash(hashes.Data[0].ID)
+			assert.Nil(t, err)
+			err = screeningService.DeleteBannedHash(hashes.Data[0].ID)
+			assert.NotNil(t, err)
+			assert.Equal(t, 404, err.Code)
+
+			_, err = send(t, owner, artwork(72))
+			assert.Nil(t, err)
+
+			hexHash := "5aa55aa55aa55aa5"
+			banned, err := screeningService.CreateBannedHash(moderator.ID, &screening_dto.CreateBannedHashPayload{Hash: &hexHash})
+			assert.Nil(t, err)
+
+			_, err = send(t, owner, checker)
+			assert.NotNil(t, err)
+			assert.Equal(t, "IMAGE_BANNED", err.Err.Error())
+
+			assert.Nil(t, screeningService.DeleteBannedHash(banned.ID))
+		})
+	})
+
+	t.Run("Test image placeholders", func(t *testing.T) {
+		circleID, uploader := createUploader(t, db, "placeholder")
+
+		local := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080/storage", "secret")
+		planService := plan.NewPlanService(plan.NewPlanRepo(db))
+		uploadService := upload.NewUploadService(local, upload.NewUploadRepo(db), planService, screening.NewScreeningService(screening.NewScreeningRepo(db)))
+		productService := product.NewProductService(product.NewProductRepo(db), planService)
+
+		img := image.NewRGBA(image.Rect(0, 0, 300, 200))
+		for y := 0; y < 200; y++ {
+			for x := 0; x < 300; x++ {
+				img.Pix[img.PixOffset(x, y)] = 200
+				img.Pix[img.PixOffset(x, y)+1] = uint8(y)
+				img.Pix[img.PixOffset(x, y)+3] = 255
+			}
+		}
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, img); err != nil {
+			t.Fatal(err)
+		}
+
+		presigned := presignAndPut(t, uploadService, local, uploader.ID, circleID, encoded.Bytes())
+
+		uploaded, err := uploadService.ConfirmUpload(circleID, presigned.Ticket)
+		assert.Nil(t, err)
+
+		cdnURL := "https://cdn.innercatalog.com" + uploaded.Path
+
+		t.Run("should compute the placeholder at upload", func(t *testing.T) {
+			assert.Equal(t, 300, uploaded.Placeholder.Width)
+			assert.Equal(t, 200, uploaded.Placeholder.Height)
+			assert.Len(t, uploaded.Placeholder.BlurHash, 28)
+			assert.Regexp(t, "^#[0-9a-f]{6}$", uploaded.Placeholder.DominantColor)
+
+			var registered entity.Upload
+			db.Where("ticket = ?", presigned.Ticket).First(&registered)
+			assert.Equal(t, uploaded.Placeholder, *registered.Placeholder)
+		})
+
+		t.Run("should return the placeholder of circle pictures", func(t *testing.T) {
+			var circle entity.Circle
+			db.First(&circle, circleID)
+			db.Model(&circle).Update("picture_url", cdnURL)
+
+			detailed, err := instance.circleService.GetOneCircleByCircleSlug(circle.Slug, 0)
+			assert.Nil(t, err)
+			assert.Equal(t, uploaded.Placeholder, *detailed.PicturePlaceholder)
+			assert.Nil(t, detailed.CoverPicturePlaceholder)
+		})
+
+		t.Run("should return the placeholder of product pictures", func(t *testing.T) {
+			created, err := productService.CreateOneProductByCircleID(uploader.ID, circleID, entity.Product{Name: "Poster", ImageURL: cdnURL})
+			assert.Nil(t, err)
+			assert.Equal(t, uploaded.Placeholder, *created.ImagePlaceholder)
+			assert.Equal(t, uploaded.Placeholder, *created.Images[0].Placeholder)
+
+			products, err := productService.GetAllProductsByCircleID(circleID, 0)
+			assert.Nil(t, err)
+			assert.Equal(t, uploaded.Placeholder, *products[0].ImagePlaceholder)
+		})
+	})
 }